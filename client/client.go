@@ -0,0 +1,261 @@
+// Package client is a Go client of the shareasecret JSON API, allowing other services to create, retrieve and delete
+// secrets without constructing the HTTP requests themselves.
+//
+// Secrets must be encrypted before they are created (and decrypted once retrieved) in the same format as the web
+// interface produces, as the server never sees the plain text of a secret.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// passphraseHeader is the header the passphrase of a passphrase protected secret is supplied in when retrieving it
+const passphraseHeader = "X-Shareasecret-Passphrase"
+
+// ErrNotFound is returned (wrapped in an [*Error]) when a secret does not exist, has been deleted, or has expired
+var ErrNotFound = errors.New("secret not found")
+
+// ErrGone is returned (wrapped in an [*Error]) when a secret existed but has since been deleted or has expired. It is
+// only ever returned by instances configured to distinguish such secrets from those that never existed, and is also
+// identified as [ErrNotFound].
+var ErrGone = errors.New("secret gone")
+
+// Client is a client of the API of a shareasecret instance. Its zero value is not usable; create one with [New].
+type Client struct {
+	// HTTPClient is the client requests are sent with, which defaults to [http.DefaultClient]
+	HTTPClient *http.Client
+
+	baseURL *url.URL
+}
+
+// New creates a client of the shareasecret instance at the given base URL (i.e. https://secret.mycompany.example or
+// https://mycompany.example/secrets for instances served under a base path)
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing base url: %w", err)
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url (%v): must be an absolute http or https url", baseURL)
+	}
+
+	return &Client{HTTPClient: http.DefaultClient, baseURL: u}, nil
+}
+
+// Error is returned when the API responds with an unsuccessful status code
+type Error struct {
+	StatusCode int
+	Message    string
+	// Code identifies why a secret failed validation (i.e. ttl_too_long), and is empty for any other failure
+	Code string
+}
+
+// Error describes the failed response
+func (e *Error) Error() string {
+	return fmt.Sprintf("shareasecret api responded with %v: %v", e.StatusCode, e.Message)
+}
+
+// Is identifies a response with a 404 status code as [ErrNotFound], and a response with a 410 status code as both
+// [ErrGone] and [ErrNotFound]
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case ErrGone:
+		return e.StatusCode == http.StatusGone
+	default:
+		return false
+	}
+}
+
+// CreatedSecret contains the identifiers and links of a newly created secret. The view URL is shared with whoever the
+// secret is for, whereas the management URL and ID are kept by its creator.
+type CreatedSecret struct {
+	ViewingID    string `json:"viewingID"`
+	ManagementID string `json:"managementID"`
+	ViewURL      string `json:"viewURL"`
+	ManageURL    string `json:"manageURL"`
+}
+
+// Secret is a secret retrieved via its viewing ID
+type Secret struct {
+	CipherText string
+	// RevealDelay is how long the creator of the secret asked for viewers to wait after decrypting it before showing it
+	RevealDelay time.Duration
+}
+
+// CreateOption configures optional behaviour of a secret being created with [Client.CreateSecret]
+type CreateOption func(r *createSecretRequest)
+
+// WithMaxViews sets the number of times the secret can be viewed, where 0 permits infinite views. Secrets can be viewed
+// once unless configured otherwise.
+func WithMaxViews(n int) CreateOption {
+	return func(r *createSecretRequest) { r.MaxViews = n }
+}
+
+// WithBurnAfterReading deletes the secret as soon as it has been viewed
+func WithBurnAfterReading() CreateOption {
+	return func(r *createSecretRequest) { r.BurnAfterReading = true }
+}
+
+// WithPassphrase requires the given passphrase to be supplied (see [Client.GetSecretWithPassphrase]) to view the secret
+func WithPassphrase(passphrase string) CreateOption {
+	return func(r *createSecretRequest) { r.Passphrase = passphrase }
+}
+
+// WithLabel attaches a label to the secret that is only ever shown to its creator
+func WithLabel(label string) CreateOption {
+	return func(r *createSecretRequest) { r.Label = label }
+}
+
+// WithNotifyWebhook sends a request to the given URL every time the secret is viewed
+func WithNotifyWebhook(webhookURL string) CreateOption {
+	return func(r *createSecretRequest) { r.NotifyWebhook = webhookURL }
+}
+
+// WithRevealDelay asks viewers to wait the given duration (rounded down to whole seconds) after decrypting the secret
+// before showing it
+func WithRevealDelay(d time.Duration) CreateOption {
+	return func(r *createSecretRequest) { r.RevealDelaySeconds = int(d / time.Second) }
+}
+
+// WithTrackAccess records the time, user agent and hashed IP address of every view of the secret, which are listed on
+// its management page
+func WithTrackAccess() CreateOption {
+	return func(r *createSecretRequest) { r.TrackAccess = true }
+}
+
+// WithNotBefore prevents the secret from being viewed until the given time, which must be before it expires
+func WithNotBefore(t time.Time) CreateOption {
+	return func(r *createSecretRequest) { r.NotBefore = &t }
+}
+
+// createSecretRequest is the JSON request body of the create secret endpoint
+type createSecretRequest struct {
+	EncryptedSecret    string     `json:"encryptedSecret"`
+	TTL                int        `json:"ttl"`
+	MaxViews           int        `json:"maxViews"`
+	BurnAfterReading   bool       `json:"burnAfterReading"`
+	RevealDelaySeconds int        `json:"revealDelaySeconds,omitempty"`
+	Passphrase         string     `json:"passphrase,omitempty"`
+	NotifyWebhook      string     `json:"notifyWebhook,omitempty"`
+	Label              string     `json:"label,omitempty"`
+	TrackAccess        bool       `json:"trackAccess,omitempty"`
+	NotBefore          *time.Time `json:"notBefore,omitempty"`
+}
+
+// getSecretResponse is the JSON response body of the retrieve secret endpoint
+type getSecretResponse struct {
+	CipherText         string `json:"cipherText"`
+	RevealDelaySeconds int    `json:"revealDelaySeconds"`
+}
+
+// errorResponse is the JSON response body of any endpoint that fails
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// CreateSecret creates a secret from already encrypted cipher text, expiring after the given TTL (rounded up to whole
+// minutes). A TTL of 0 creates a secret that never expires, should the instance permit it.
+func (c *Client) CreateSecret(ctx context.Context, cipherText string, ttl time.Duration, opts ...CreateOption) (*CreatedSecret, error) {
+	if ttl < 0 {
+		return nil, errors.New("ttl must not be negative")
+	}
+
+	req := createSecretRequest{
+		EncryptedSecret: cipherText,
+		TTL:             int((ttl + time.Minute - 1) / time.Minute),
+		MaxViews:        1,
+	}
+	for _, opt := range opts {
+		opt(&req)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	var created CreatedSecret
+	if err := c.do(ctx, http.MethodPost, "/api/secrets", bytes.NewReader(body), nil, &created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// GetSecret retrieves the cipher text of a secret via its viewing ID, which counts as a view of the secret
+func (c *Client) GetSecret(ctx context.Context, viewingID string) (*Secret, error) {
+	return c.GetSecretWithPassphrase(ctx, viewingID, "")
+}
+
+// GetSecretWithPassphrase retrieves the cipher text of a passphrase protected secret via its viewing ID, which counts
+// as a view of the secret
+func (c *Client) GetSecretWithPassphrase(ctx context.Context, viewingID string, passphrase string) (*Secret, error) {
+	header := http.Header{}
+	if passphrase != "" {
+		header.Set(passphraseHeader, passphrase)
+	}
+
+	var res getSecretResponse
+	if err := c.do(ctx, http.MethodGet, "/api/secrets/"+url.PathEscape(viewingID), nil, header, &res); err != nil {
+		return nil, err
+	}
+
+	return &Secret{CipherText: res.CipherText, RevealDelay: time.Duration(res.RevealDelaySeconds) * time.Second}, nil
+}
+
+// DeleteSecret deletes a secret via its management ID so that it can no longer be viewed. [ErrNotFound] is returned if
+// the secret does not exist or has already been deleted.
+func (c *Client) DeleteSecret(ctx context.Context, managementID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/secrets/"+url.PathEscape(managementID), nil, nil, nil)
+}
+
+// do sends a request to the given path of the API, decoding a successful JSON response into the given value (if it
+// isn't nil) and converting unsuccessful responses into an [*Error]
+func (c *Client) do(ctx context.Context, method string, path string, body io.Reader, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var e errorResponse
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+			e.Error = http.StatusText(res.StatusCode)
+		}
+
+		return &Error{StatusCode: res.StatusCode, Message: e.Error, Code: e.Code}
+	}
+
+	if v == nil {
+		return nil
+	} else if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lsymds/shareasecret/internal/shareasecret"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t)
+
+	t.Run("creates, retrieves and deletes secrets", func(t *testing.T) {
+		created, err := c.CreateSecret(ctx, "YWJj.ZGVm.Z2hp", 30*time.Minute, WithMaxViews(2), WithRevealDelay(5*time.Second))
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		} else if created.ViewingID == "" || created.ManagementID == "" || created.ViewURL == "" || created.ManageURL == "" {
+			t.Fatalf("wanted identifiers and urls of the secret, got %+v", created)
+		}
+
+		secret, err := c.GetSecret(ctx, created.ViewingID)
+		if err != nil {
+			t.Fatalf("retrieving secret: %v", err)
+		} else if secret.CipherText != "YWJj.ZGVm.Z2hp" || secret.RevealDelay != 5*time.Second {
+			t.Errorf("wanted cipher text and reveal delay of the secret, got %+v", secret)
+		}
+
+		if err := c.DeleteSecret(ctx, created.ManagementID); err != nil {
+			t.Fatalf("deleting secret: %v", err)
+		}
+
+		if _, err := c.GetSecret(ctx, created.ViewingID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("wanted not found error once deleted, got %v", err)
+		}
+		if err := c.DeleteSecret(ctx, created.ManagementID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("wanted not found error when deleting again, got %v", err)
+		}
+	})
+
+	t.Run("supplies the passphrase of passphrase protected secrets", func(t *testing.T) {
+		created, err := c.CreateSecret(ctx, "YWJj.ZGVm.Z2hp", time.Hour, WithPassphrase("open sesame"))
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		var apiErr *Error
+		if _, err := c.GetSecretWithPassphrase(ctx, created.ViewingID, "wrong"); !errors.As(err, &apiErr) || apiErr.StatusCode != 403 {
+			t.Errorf("wanted 403 error for incorrect passphrase, got %v", err)
+		}
+
+		if secret, err := c.GetSecretWithPassphrase(ctx, created.ViewingID, "open sesame"); err != nil || secret.CipherText != "YWJj.ZGVm.Z2hp" {
+			t.Errorf("wanted cipher text, got %+v (%v)", secret, err)
+		}
+	})
+
+	t.Run("describes why secrets could not be created", func(t *testing.T) {
+		var apiErr *Error
+		if _, err := c.CreateSecret(ctx, "not encrypted", time.Hour); !errors.As(err, &apiErr) || apiErr.StatusCode != 400 || apiErr.Message == "" || apiErr.Code != "invalid_cipher_format" {
+			t.Errorf("wanted 400 error with a message and code, got %v", err)
+		}
+
+		if _, err := c.CreateSecret(ctx, "YWJj.ZGVm.Z2hp", -time.Minute); err == nil {
+			t.Errorf("wanted error for negative ttl")
+		}
+	})
+}
+
+func TestError(t *testing.T) {
+	gone := &Error{StatusCode: 410, Message: "This secret was deleted by its creator."}
+	if !errors.Is(gone, ErrGone) || !errors.Is(gone, ErrNotFound) {
+		t.Errorf("wanted 410 error to be identified as gone and not found")
+	}
+
+	notFound := &Error{StatusCode: 404, Message: "not found"}
+	if errors.Is(notFound, ErrGone) || !errors.Is(notFound, ErrNotFound) {
+		t.Errorf("wanted 404 error to be identified as not found only")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"", "secret.mycompany.example", "ftp://secret.mycompany.example", "://"} {
+		if _, err := New(baseURL); err == nil {
+			t.Errorf("wanted error for base url %q", baseURL)
+		}
+	}
+
+	c, err := New("https://mycompany.example/secrets/")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	} else if c.baseURL.String() != "https://mycompany.example/secrets" {
+		t.Errorf("wanted trailing slash to be removed, got %v", c.baseURL)
+	}
+}
+
+// testClient creates a client of an in memory shareasecret instance that is stopped once the test completes
+func testClient(t *testing.T) *Client {
+	t.Helper()
+
+	config := &shareasecret.Configuration{}
+	config.Database.Driver = "memory"
+	config.Server.BaseUrl = "http://127.0.0.1:8999"
+	config.Secrets.MaximumSize = 1024
+	config.Secrets.ViewingIDSize = 24
+	config.Secrets.ViewingIDEncoding = "hex"
+	config.Secrets.AllowNeverExpire = true
+
+	app, err := shareasecret.NewApplication(config, os.DirFS("../web/"))
+	if err != nil {
+		t.Fatalf("new application: %v", err)
+	}
+
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+
+	c, err := New(server.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	return c
+}
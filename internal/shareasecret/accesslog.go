@@ -0,0 +1,66 @@
+package shareasecret
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// accessLog wraps the given handler, logging the outcome of every request once it has been handled. Successful health
+// checks are not logged.
+func (a *Application) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusRecordingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		if isHealthCheck(r) && rw.statusCode == http.StatusOK {
+			return
+		}
+
+		zerolog.Ctx(r.Context()).
+			Info().
+			Int("status", rw.statusCode).
+			Int("bytes", rw.bytesWritten).
+			Dur("latency", time.Since(start)).
+			Str("ip", rateLimitKey(r)).
+			Str("user_agent", r.UserAgent()).
+			Msg("handled request")
+	})
+}
+
+// statusRecordingResponseWriter is a [http.ResponseWriter] that records the status code and number of bytes written
+// to the response
+type statusRecordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	bytesWritten int
+}
+
+// WriteHeader records the status code before writing it to the underlying response
+func (w *statusRecordingResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records the number of bytes written before writing them to the underlying response
+func (w *statusRecordingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytesWritten += n
+
+	return n, err
+}
+
+// Flush flushes the underlying response if it supports flushing, so streamed responses continue to work
+func (w *statusRecordingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying response so that it can be used with [http.ResponseController]
+func (w *statusRecordingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
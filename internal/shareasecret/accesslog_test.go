@@ -0,0 +1,33 @@
+package shareasecret
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	t.Run("records the status code and bytes written", func(t *testing.T) {
+		rw := &statusRecordingResponseWriter{ResponseWriter: httptest.NewRecorder(), statusCode: http.StatusOK}
+
+		rw.WriteHeader(http.StatusTeapot)
+		rw.Write([]byte("short and stout"))
+
+		if rw.statusCode != http.StatusTeapot {
+			t.Errorf("wanted status code 418, got %v", rw.statusCode)
+		} else if rw.bytesWritten != 15 {
+			t.Errorf("wanted 15 bytes written, got %v", rw.bytesWritten)
+		}
+	})
+
+	t.Run("supports flushing the underlying response", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		rw := &statusRecordingResponseWriter{ResponseWriter: recorder, statusCode: http.StatusOK}
+
+		if err := http.NewResponseController(rw).Flush(); err != nil {
+			t.Errorf("flushing response: %v", err)
+		} else if !recorder.Flushed {
+			t.Errorf("expected underlying response to be flushed")
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package shareasecret
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// adminOnly wraps the given API handler, only permitting requests that supply the configured admin token as a bearer
+// token. Admin endpoints are disabled entirely, appearing not to exist, when no admin token is configured.
+func (a *Application) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.config.Admin.Token == "" {
+			apiError("not found", http.StatusNotFound, w)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !a.isAdminToken(token) {
+			zerolog.Ctx(r.Context()).Warn().Str("ip", rateLimitKey(r)).Msg("unauthorized admin request")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			apiError("Unauthorized.", http.StatusUnauthorized, w)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// adminPageOnly wraps the given page handler, only permitting requests that supply the configured admin token as the
+// password of HTTP basic authentication so that browsers prompt for it. Any username is accepted. As with
+// [Application.adminOnly], admin pages appear not to exist when no admin token is configured.
+func (a *Application) adminPageOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.config.Admin.Token == "" {
+			notFound(w, r)
+			return
+		}
+
+		_, password, ok := r.BasicAuth()
+		if !ok || !a.isAdminToken(password) {
+			zerolog.Ctx(r.Context()).Warn().Str("ip", rateLimitKey(r)).Msg("unauthorized admin request")
+			w.Header().Set("WWW-Authenticate", `Basic realm="shareasecret admin", charset="UTF-8"`)
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// isAdminToken identifies whether the given token is the configured admin token, taking the same time regardless of
+// how much of it matches
+func (a *Application) isAdminToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.config.Admin.Token)) == 1
+}
+
+// handleAdminDashboard renders aggregate statistics of all secrets. Only counts and timestamps are queried; no
+// identifiers or cipher text of any secret are ever shown.
+func (a *Application) handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.store.Stats(r.Context(), time.Now())
+	if err != nil {
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("aggregating secret statistics")
+		internalServerError(w, r)
+		return
+	}
+
+	d := adminDashboardDetails{
+		active:         stats.active,
+		viewed:         stats.viewed,
+		expired:        stats.expired,
+		deleted:        stats.deleted,
+		createdLastDay: stats.createdLastDay,
+	}
+	if stats.oldestActiveCreatedAt != 0 {
+		d.oldestActiveCreatedAt = time.UnixMilli(stats.oldestActiveCreatedAt).UTC()
+	}
+
+	noStore(w)
+	pageAdminDashboard(d).Render(r.Context(), w)
+}
@@ -0,0 +1,68 @@
+package shareasecret
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminDashboard(t *testing.T) {
+	t.Run("does not exist when no admin token is configured", func(t *testing.T) {
+		r := get(t, app.adminPageOnly(app.handleAdminDashboard), func(r *http.Request) {
+			r.SetBasicAuth("admin", "")
+		})
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		}
+	})
+
+	app.config.Admin.Token = "admin-token"
+	defer func() { app.config.Admin.Token = "" }()
+
+	t.Run("prompts for credentials when they are missing or incorrect", func(t *testing.T) {
+		for _, password := range []string{"", "incorrect"} {
+			r := get(t, app.adminPageOnly(app.handleAdminDashboard), func(r *http.Request) {
+				if password != "" {
+					r.SetBasicAuth("admin", password)
+				}
+			})
+			if r.statusCode != 401 {
+				t.Errorf("wanted 401 status code, got %v", r.statusCode)
+			}
+			if !strings.HasPrefix(r.headers.Get("WWW-Authenticate"), "Basic ") {
+				t.Errorf("wanted basic authentication challenge, got %q", r.headers.Get("WWW-Authenticate"))
+			}
+		}
+	})
+
+	t.Run("does not accept the admin token as a bearer token", func(t *testing.T) {
+		r := get(t, app.adminPageOnly(app.handleAdminDashboard), func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer admin-token")
+		})
+		if r.statusCode != 401 {
+			t.Errorf("wanted 401 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("renders aggregate statistics without secrets", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.adminPageOnly(app.handleAdminDashboard), func(r *http.Request) {
+			r.SetBasicAuth("anyone", "admin-token")
+		})
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		}
+
+		if !strings.Contains(r.body, "Created in the last 24 hours") {
+			t.Errorf("wanted statistics to be rendered, got %v", r.body)
+		}
+		if strings.Contains(r.body, accessID) || strings.Contains(r.body, managementID) || strings.Contains(r.body, "YWJj") {
+			t.Errorf("wanted no secret details to be rendered, got %v", r.body)
+		}
+		if r.headers.Get("Cache-Control") != "no-store, max-age=0" {
+			t.Errorf("wanted no-store cache control, got %q", r.headers.Get("Cache-Control"))
+		}
+	})
+}
@@ -0,0 +1,423 @@
+package shareasecret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// passphraseHeader is the request header API clients supply the passphrase of a passphrase protected secret in
+const passphraseHeader = "X-Shareasecret-Passphrase"
+
+// apiCreateSecretRequest is the JSON request body accepted by the [handleAPICreateSecret] handler. The TTL is a
+// number of minutes, as it is when secrets are created through the web form.
+type apiCreateSecretRequest struct {
+	EncryptedSecret  string       `json:"encryptedSecret"`
+	TTL              int          `json:"ttl"`
+	MaxViews         int          `json:"maxViews"`
+	BurnAfterReading bool         `json:"burnAfterReading"`
+	RevealDelay      int          `json:"revealDelaySeconds"`
+	Passphrase       string       `json:"passphrase"`
+	NotifyWebhook    string       `json:"notifyWebhook"`
+	NotifyEmail      string       `json:"notifyEmail"`
+	Label            string       `json:"label"`
+	RecoveryToken    string       `json:"recoveryToken"`
+	TrackAccess      bool         `json:"trackAccess"`
+	ExpiresAt        apiTimestamp `json:"expiresAt"`
+	NotBefore        apiTimestamp `json:"notBefore"`
+}
+
+// apiTimestamp is a timestamp supplied to the API either as an RFC3339 string or as a number of unix milliseconds, kept
+// in its textual form so it can be parsed by [parseTimestamp]
+type apiTimestamp string
+
+// UnmarshalJSON accepts both JSON strings and numbers
+func (t *apiTimestamp) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	} else if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+
+		*t = apiTimestamp(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+
+	*t = apiTimestamp(n)
+	return nil
+}
+
+// apiCreateSecretResponse is the JSON response body returned by the [handleAPICreateSecret] handler
+type apiCreateSecretResponse struct {
+	ViewingID    string `json:"viewingID"`
+	ManagementID string `json:"managementID"`
+	ViewURL      string `json:"viewURL"`
+	ManageURL    string `json:"manageURL"`
+}
+
+// apiValidateSecretResponse is the JSON response body returned by the [handleAPIValidateSecret] handler. Size is the
+// size (in bytes) of the encrypted secret, and Error describes why the secret is invalid if it is.
+type apiValidateSecretResponse struct {
+	Valid bool `json:"valid"`
+	Size  int  `json:"size"`
+	// Format is the version of the format of the cipher text, if it is valid
+	Format string `json:"format,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Code identifies why the secret is invalid, if it is
+	Code errorCode `json:"code,omitempty"`
+}
+
+// apiAccessSecretResponse is the JSON response body returned by the [handleAPIAccessSecret] handler
+type apiAccessSecretResponse struct {
+	CipherText         string `json:"cipherText"`
+	RevealDelaySeconds int    `json:"revealDelaySeconds,omitempty"`
+}
+
+// apiRotateViewingLinkResponse is the JSON response body returned by the [handleAPIRotateViewingLink] handler
+type apiRotateViewingLinkResponse struct {
+	ViewURL string `json:"viewURL"`
+}
+
+// apiSecretMetadataResponse is the JSON response body returned by the [handleAPISecretMetadata] handler. ExpiresAt is
+// null for secrets that never expire, NotBefore is omitted for secrets that could be viewed as soon as they were
+// created, and DeletionReason is omitted for secrets that haven't been deleted.
+type apiSecretMetadataResponse struct {
+	ViewURL        string     `json:"viewURL"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	NotBefore      *time.Time `json:"notBefore,omitempty"`
+	Views          int        `json:"views"`
+	Expired        bool       `json:"expired"`
+	Deleted        bool       `json:"deleted"`
+	DeletionReason string     `json:"deletionReason,omitempty"`
+}
+
+// apiErrorResponse is the JSON response body returned by any API handler that fails
+type apiErrorResponse struct {
+	Error string `json:"error"`
+	// Code identifies why the request failed, for the failures clients may want to handle specifically
+	Code errorCode `json:"code,omitempty"`
+}
+
+// handleAPICreateSecret validates and persists a secret (consisting of encrypted ciphertext) submitted as JSON, and is
+// the API equivalent of the [handleCreateSecret] handler
+func (a *Application) handleAPICreateSecret(w http.ResponseWriter, r *http.Request) {
+	l := zerolog.Ctx(r.Context())
+
+	if !requestingIPCanCreateSecret(a.config, r) {
+		apiError("Not permitted to create secrets.", http.StatusForbidden, w)
+		return
+	}
+
+	secret, invalidErr := a.decodeAPISecret(w, r)
+	if invalidErr != nil {
+		apiValidationError(invalidErr, w)
+		return
+	} else if invalidErr := a.validateSecret(&secret); invalidErr != nil {
+		apiValidationError(invalidErr, w)
+		return
+	}
+
+	accessID, managementID, err := a.createSecret(r.Context(), secret)
+	if errors.Is(err, errTooManyCreations) {
+		w.Header().Set("Retry-After", "1")
+		apiError("Too many secrets are being created. Please wait a moment and try again.", http.StatusServiceUnavailable, w)
+		return
+	} else if err != nil {
+		l.Err(err).Msg("creating secret")
+		apiError("Unable to create secret.", http.StatusInternalServerError, w)
+		return
+	}
+
+	a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventCreated})
+
+	writeJSON(
+		apiCreateSecretResponse{
+			ViewingID:    accessID,
+			ManagementID: managementID,
+			ViewURL:      fmt.Sprintf("%s/secret/%s", a.baseURL, accessID),
+			ManageURL:    fmt.Sprintf("%s/manage-secret/%s", a.baseURL, managementID),
+		},
+		http.StatusCreated,
+		w,
+	)
+}
+
+// decodeAPISecret decodes a secret submitted as JSON to either the [handleAPICreateSecret] or [handleAPIValidateSecret]
+// handlers, refusing to buffer bodies that couldn't possibly contain a valid secret. A message describing why the
+// request could not be decoded is returned if it couldn't be.
+func (a *Application) decodeAPISecret(w http.ResponseWriter, r *http.Request) (newSecret, *validationError) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(a.config.Secrets.MaximumSize+maximumRequestOverhead))
+
+	var req apiCreateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return newSecret{}, a.secretTooLarge()
+		}
+
+		return newSecret{}, invalid(errorCodeInvalidRequest, "invalid.requestBody")
+	}
+
+	// an absolute expiry time takes precedence over the TTL
+	if req.ExpiresAt != "" {
+		ttl, err := ttlUntil(string(req.ExpiresAt), time.Now())
+		if errors.Is(err, errExpiryInPast) {
+			return newSecret{}, invalid(errorCodeExpiryInPast, "invalid.expiryInPast")
+		} else if err != nil {
+			return newSecret{}, invalid(errorCodeInvalidExpiry, "invalid.expiry")
+		}
+
+		req.TTL = ttl
+	}
+
+	var notBefore time.Time
+	if req.NotBefore != "" {
+		var err error
+		if notBefore, err = parseTimestamp(string(req.NotBefore)); err != nil {
+			return newSecret{}, invalid(errorCodeInvalidNotBefore, "invalid.notBefore")
+		}
+	}
+
+	return newSecret{
+		cipherText:       req.EncryptedSecret,
+		ttl:              req.TTL,
+		maxViews:         req.MaxViews,
+		burnAfterReading: req.BurnAfterReading,
+		revealDelay:      req.RevealDelay,
+		notBefore:        notBefore,
+		passphrase:       req.Passphrase,
+		notifyWebhook:    req.NotifyWebhook,
+		notifyEmail:      req.NotifyEmail,
+		label:            req.Label,
+		recoveryToken:    req.RecoveryToken,
+		trackAccess:      req.TrackAccess,
+	}, nil
+}
+
+// handleAPIValidateSecret validates a secret submitted in the same manner as to the [handleAPICreateSecret] handler
+// without storing it, so that clients can confirm their encryption output is well formed before submitting it
+func (a *Application) handleAPIValidateSecret(w http.ResponseWriter, r *http.Request) {
+	if !requestingIPCanCreateSecret(a.config, r) {
+		apiError("Not permitted to create secrets.", http.StatusForbidden, w)
+		return
+	}
+
+	secret, err := a.decodeAPISecret(w, r)
+	if err != nil {
+		apiValidationError(err, w)
+		return
+	}
+
+	res := apiValidateSecretResponse{Valid: true, Size: len(secret.cipherText)}
+	if err := a.validateSecret(&secret); err != nil {
+		res.Valid = false
+		res.Error = err.message(defaultLanguage)
+		res.Code = err.code
+	} else {
+		res.Format, _ = cipherTextFormat(secret.cipherText)
+	}
+
+	writeJSON(res, http.StatusOK, w)
+}
+
+// handleAPIAccessSecret returns the cipher text of a secret as JSON, recording a view of the secret in the process. It
+// honours the same expiry, burn after reading and maximum view semantics as the [handleAccessSecret] handler.
+//
+// A secret that never existed is indistinguishable from one that has been deleted or has expired, unless the instance
+// is configured to distinguish them, in which case a 410 status code is returned for secrets that existed.
+func (a *Application) handleAPIAccessSecret(w http.ResponseWriter, r *http.Request) {
+	noStore(w)
+
+	fail := apiError
+	if a.config.API.DistinguishGone {
+		fail = func(msg string, statusCode int, w http.ResponseWriter) {
+			if statusCode == http.StatusNotFound {
+				if reason, ok := a.secretGone(r, r.PathValue("accessID")); ok {
+					apiError(describeDeletionReason(reason), http.StatusGone, w)
+					return
+				}
+			}
+
+			apiError(msg, statusCode, w)
+		}
+	}
+
+	secret, ok := a.viewSecretDirectly(w, r, fail)
+	if !ok {
+		return
+	}
+
+	writeJSON(apiAccessSecretResponse{CipherText: secret.cipherText, RevealDelaySeconds: secret.revealDelaySeconds}, http.StatusOK, w)
+}
+
+// secretGone retrieves why a secret that existed can no longer be viewed, returning false if it never existed (or the
+// reason couldn't be retrieved, in which case it is treated as if it never existed)
+func (a *Application) secretGone(r *http.Request, accessID string) (string, bool) {
+	reason, err := a.store.UnavailableReason(r.Context(), accessID)
+	if errors.Is(err, errSecretNotFound) {
+		return "", false
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("access_id", accessID).Msg("retrieving why secret is unavailable")
+		return "", false
+	}
+
+	return reason, true
+}
+
+// handleAPIDeleteSecret deletes a secret via its management ID, allowing automated clients to revoke a secret once it
+// is no longer required. A secret that never existed is indistinguishable from one that has already been deleted.
+func (a *Application) handleAPIDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	found, err := a.deleteSecret(r, managementID)
+	if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("deleting secret")
+		apiError("Unable to delete secret.", http.StatusInternalServerError, w)
+		return
+	} else if !found {
+		apiError("not found", http.StatusNotFound, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIRotateViewingLink replaces the viewing link of a secret via its management ID, returning the new viewing
+// link as JSON. It is the API equivalent of the [handleRotateViewingLink] handler.
+func (a *Application) handleAPIRotateViewingLink(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	noStore(w)
+
+	accessID, err := a.rotateAccessID(r.Context(), managementID)
+	if errors.Is(err, errSecretNotFound) {
+		apiError("not found", http.StatusNotFound, w)
+		return
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("rotating viewing link")
+		apiError("Unable to regenerate the viewing link.", http.StatusInternalServerError, w)
+		return
+	}
+
+	a.recordEvent(r, secretEvent{managementID: managementID, eventType: eventRotated})
+
+	writeJSON(apiRotateViewingLinkResponse{ViewURL: fmt.Sprintf("%s/secret/%s", a.baseURL, accessID)}, http.StatusOK, w)
+}
+
+// handleAPISecretMetadata returns the state of a secret via its management ID as JSON, allowing automated clients to
+// poll the secrets they created. It is the API equivalent of the [handleManageSecret] handler, and as such never returns
+// the cipher text of the secret.
+func (a *Application) handleAPISecretMetadata(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	noStore(w)
+
+	secret, err := a.store.GetByManagementID(r.Context(), managementID)
+	if errors.Is(err, errSecretNotFound) {
+		apiError("not found", http.StatusNotFound, w)
+		return
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("retrieving secret")
+		apiError("Unable to retrieve secret.", http.StatusInternalServerError, w)
+		return
+	}
+
+	res := apiSecretMetadataResponse{
+		ViewURL:        fmt.Sprintf("%s/secret/%s", a.baseURL, secret.accessID),
+		CreatedAt:      time.UnixMilli(secret.createdAt).UTC(),
+		Views:          secret.views,
+		Expired:        !secret.deleted() && secret.expired(),
+		Deleted:        secret.deleted(),
+		DeletionReason: secret.deletionReason,
+	}
+	if secret.ttl > 0 {
+		expiresAt := time.UnixMilli(secret.createdAt + (secret.ttl * 60 * 1000)).UTC()
+		res.ExpiresAt = &expiresAt
+	}
+	if secret.notBefore > 0 {
+		notBefore := time.UnixMilli(secret.notBefore).UTC()
+		res.NotBefore = &notBefore
+	}
+
+	writeJSON(res, http.StatusOK, w)
+}
+
+// apiError writes a JSON error response with the given message and status code
+func apiError(msg string, statusCode int, w http.ResponseWriter) {
+	writeJSON(apiErrorResponse{Error: msg}, statusCode, w)
+}
+
+// apiValidationError writes a JSON error response describing the validation failure with a 400 status code
+func apiValidationError(err *validationError, w http.ResponseWriter) {
+	writeJSON(apiErrorResponse{Error: err.message(defaultLanguage), Code: err.code}, http.StatusBadRequest, w)
+}
+
+// apiTooManyRequests writes a JSON error response with a 429 status code
+func apiTooManyRequests(w http.ResponseWriter) {
+	apiError("Too many requests. Please wait a moment and try again.", http.StatusTooManyRequests, w)
+}
+
+// prefersJSON identifies whether the client prefers a JSON response to an HTML one, based on the quality values of the
+// media ranges in its Accept header. Wildcards count towards neither, so browsers continue to receive HTML.
+func prefersJSON(r *http.Request) bool {
+	jsonQuality, htmlQuality := acceptedQualities(r)
+
+	return jsonQuality > htmlQuality
+}
+
+// prefersHTML identifies whether the client explicitly accepts HTML and prefers it to JSON, as browsers navigating to
+// a page or submitting a form do (unlike scripts, which accept anything by default)
+func prefersHTML(r *http.Request) bool {
+	jsonQuality, htmlQuality := acceptedQualities(r)
+
+	return htmlQuality > 0 && htmlQuality >= jsonQuality
+}
+
+// acceptedQualities retrieves the highest quality values of the JSON and HTML media ranges in the Accept header of the
+// request, which are zero for those that aren't accepted
+func acceptedQualities(r *http.Request) (float64, float64) {
+	jsonQuality, htmlQuality := 0.0, 0.0
+
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(v, ";")
+
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && k == "q" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "application/json":
+			jsonQuality = max(jsonQuality, q)
+		case "text/html", "application/xhtml+xml":
+			htmlQuality = max(htmlQuality, q)
+		}
+	}
+
+	return jsonQuality, htmlQuality
+}
+
+// writeJSON serializes the given value as JSON and writes it to the response with the given status code
+func writeJSON(v any, statusCode int, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,423 @@
+package shareasecret
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPISecretCreation(t *testing.T) {
+	t.Run("forbidden if not valid requesting ip", func(t *testing.T) {
+		r := post(
+			t,
+			app.handleAPICreateSecret,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30}`,
+			func(r *http.Request) { r.Header.Set("X-Forwarded-For", "192.0.2.1") },
+		)
+
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("bad request for malformed json", func(t *testing.T) {
+		if r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": "30"}`, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, `"error":`) {
+			t.Errorf("wanted json error in body, got %v", r.body)
+		}
+	})
+
+	t.Run("bad request for request body exceeding the maximum size", func(t *testing.T) {
+		body := `{"encryptedSecret": "a.b.` + strings.Repeat("c", app.config.Secrets.MaximumSize+maximumRequestOverhead) + `", "ttl": 30}`
+
+		if r := post(t, app.handleAPICreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "Secret is too large") || !strings.Contains(r.body, `"code":"secret_too_large"`) {
+			t.Errorf("wanted 'Secret is too large' and its code in body, got %v", r.body)
+		}
+	})
+
+	t.Run("accepts expiry times as strings or unix milliseconds", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+
+		for _, v := range []string{`"` + expiresAt.Format(time.RFC3339) + `"`, fmt.Sprint(expiresAt.UnixMilli())} {
+			r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "expiresAt": `+v+`}`, emptyRequestConfigurer)
+			if r.statusCode != 201 {
+				t.Fatalf("wanted 201 status code for %v, got %v", v, r.statusCode)
+			}
+
+			var res apiCreateSecretResponse
+			if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+				t.Fatalf("unmarshalling response: %v", err)
+			}
+
+			if secret, err := app.store.GetByManagementID(context.Background(), res.ManagementID); err != nil || secret.ttl != 60 {
+				t.Errorf("wanted ttl of 60 minutes for %v, got %v (%v)", v, secret.ttl, err)
+			}
+		}
+
+		if r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "expiresAt": 1000}`, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code for expiry time in the past, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("bad request for invalid ciphertext", func(t *testing.T) {
+		if r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "a", "ttl": 30}`, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "format is invalid") || !strings.Contains(r.body, `"code":"invalid_cipher_format"`) {
+			t.Errorf("wanted 'format is invalid' and its code in body, got %v", r.body)
+		}
+	})
+
+	t.Run("identifies each validation failure with a code", func(t *testing.T) {
+		cases := map[string]errorCode{
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": "30"}`:                           errorCodeInvalidRequest,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": -1}`:                             errorCodeInvalidTTL,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "expiresAt": "2000-01-01T00:00:00Z"}`:   errorCodeExpiryInPast,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "expiresAt": "tomorrow"}`:               errorCodeInvalidExpiry,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30, "maxViews": -1}`:             errorCodeInvalidMaxViews,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30, "revealDelaySeconds": 61}`:   errorCodeInvalidRevealDelay,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30, "notBefore": "soon"}`:        errorCodeInvalidNotBefore,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 1, "notBefore": 9000000000000}`:  errorCodeNotBeforeAfterExpiry,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30, "notifyWebhook": "ftp://a"}`: errorCodeInvalidWebhook,
+			`{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30, "recoveryToken": "short"}`:   errorCodeRecoveryTokenTooShort,
+		}
+
+		for body, code := range cases {
+			r := post(t, app.handleAPICreateSecret, body, emptyRequestConfigurer)
+
+			var res apiErrorResponse
+			if err := json.Unmarshal([]byte(r.body), &res); err != nil || r.statusCode != 400 || res.Code != code || res.Error == "" {
+				t.Errorf("wanted 400 status code and %v for %v, got %v: %v", code, body, r.statusCode, r.body)
+			}
+		}
+	})
+
+	t.Run("creates the secret and returns its identifiers", func(t *testing.T) {
+		r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30}`, emptyRequestConfigurer)
+		if r.statusCode != 201 {
+			t.Errorf("wanted 201 status code, got %v", r.statusCode)
+		} else if ct := r.headers.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("wanted application/json content type, got %v", ct)
+		}
+
+		var res apiCreateSecretResponse
+		if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+			t.Errorf("unmarshalling response: %v", err)
+		} else if res.ViewURL != app.baseURL+"/secret/"+res.ViewingID {
+			t.Errorf("unexpected view url %v", res.ViewURL)
+		} else if res.ManageURL != app.baseURL+"/manage-secret/"+res.ManagementID {
+			t.Errorf("unexpected manage url %v", res.ManageURL)
+		}
+
+		var rc int
+
+		err := testDB().QueryRow("SELECT COUNT(1) FROM secrets WHERE management_id = ?", res.ManagementID).Scan(&rc)
+		if err != nil {
+			t.Errorf("querying for secret: %v", err)
+		} else if rc != 1 {
+			t.Errorf("expected 1 secret, got %v", rc)
+		}
+	})
+}
+
+func TestAPISecretAccess(t *testing.T) {
+	t.Run("not found for unknown secret", func(t *testing.T) {
+		r := get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", "unknown") })
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, `"error":"not found"`) {
+			t.Errorf("wanted not found error in body, got %v", r.body)
+		}
+	})
+
+	t.Run("not found for deleted secret", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		r := get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("gone for deleted secret when configured to distinguish them", func(t *testing.T) {
+		app.config.API.DistinguishGone = true
+		defer func() { app.config.API.DistinguishGone = false }()
+
+		accessID, _ := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		r := get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if r.statusCode != 410 {
+			t.Errorf("wanted 410 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, describeDeletionReason(deletionReasonUserDeleted)) {
+			t.Errorf("wanted deletion reason in body, got %v", r.body)
+		}
+
+		r = get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", "unknown") })
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code for unknown secret, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("returns cipher text and honours maximum views", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if r.statusCode != 200 {
+			t.Errorf("wanted 200 status code, got %v", r.statusCode)
+		}
+
+		var res apiAccessSecretResponse
+		if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+			t.Errorf("unmarshalling response: %v", err)
+		} else if res.CipherText != "a.b.c" {
+			t.Errorf("wanted cipher text a.b.c, got %v", res.CipherText)
+		}
+
+		r = get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code once maximum views reached, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("says when secrets that cannot be viewed yet become viewable", func(t *testing.T) {
+		notBefore := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+		r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 120, "notBefore": "`+notBefore.Format(time.RFC3339)+`"}`, emptyRequestConfigurer)
+
+		var created apiCreateSecretResponse
+		if err := json.Unmarshal([]byte(r.body), &created); err != nil {
+			t.Fatalf("unmarshalling response: %v", err)
+		}
+
+		r = get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", created.ViewingID) })
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "not available until "+notBefore.Format(time.RFC3339)) {
+			t.Errorf("wanted time the secret becomes viewable in body, got %v", r.body)
+		}
+
+		r = get(t, app.handleAPISecretMetadata, func(r *http.Request) { r.SetPathValue("managementID", created.ManagementID) })
+
+		var metadata apiSecretMetadataResponse
+		if err := json.Unmarshal([]byte(r.body), &metadata); err != nil || metadata.NotBefore == nil || !metadata.NotBefore.Equal(notBefore) {
+			t.Errorf("wanted not before time %v in metadata, got %v (%v)", notBefore, r.body, err)
+		}
+	})
+
+	t.Run("requires the passphrase of a passphrase protected secret", func(t *testing.T) {
+		r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30, "passphrase": "open sesame"}`, emptyRequestConfigurer)
+
+		var created apiCreateSecretResponse
+		if err := json.Unmarshal([]byte(r.body), &created); err != nil {
+			t.Errorf("unmarshalling response: %v", err)
+		}
+
+		r = get(t, app.handleAPIAccessSecret, func(r *http.Request) {
+			r.SetPathValue("accessID", created.ViewingID)
+			r.Header.Set(passphraseHeader, "wrong")
+		})
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		}
+
+		r = get(t, app.handleAPIAccessSecret, func(r *http.Request) {
+			r.SetPathValue("accessID", created.ViewingID)
+			r.Header.Set(passphraseHeader, "open sesame")
+		})
+		if r.statusCode != 200 {
+			t.Errorf("wanted 200 status code, got %v", r.statusCode)
+		}
+	})
+}
+
+func TestAPISecretDeletion(t *testing.T) {
+	t.Run("not found for unknown secret", func(t *testing.T) {
+		r := get(t, app.handleAPIDeleteSecret, func(r *http.Request) { r.SetPathValue("managementID", "unknown") })
+
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("deletes the secret without setting flash cookies", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleAPIDeleteSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if r.statusCode != 204 {
+			t.Errorf("wanted 204 status code, got %v", r.statusCode)
+		} else if len(r.cookies) != 0 {
+			t.Errorf("wanted no cookies, got %v", r.cookies)
+		}
+
+		var deletionReason sql.NullString
+		var cipherText sql.NullString
+
+		err := testDB().
+			QueryRow("SELECT deletion_reason, cipher_text FROM secrets WHERE access_id = ?", accessID).
+			Scan(&deletionReason, &cipherText)
+		if err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if deletionReason.String != deletionReasonUserDeleted || cipherText.Valid {
+			t.Errorf("wanted secret to have been deleted by the user, got %v", deletionReason.String)
+		}
+
+		r = get(t, app.handleAPIDeleteSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code for already deleted secret, got %v", r.statusCode)
+		}
+	})
+}
+
+func TestPrefersJSON(t *testing.T) {
+	for accept, wanted := range map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"application/json":                  true,
+		"text/html, application/json;q=0.9": false,
+		"text/html;q=0.5, application/json": true,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": false,
+	} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", accept)
+
+		if got := prefersJSON(r); got != wanted {
+			t.Errorf("%q: wanted %v, got %v", accept, wanted, got)
+		}
+	}
+}
+
+func TestAPISecretMetadata(t *testing.T) {
+	t.Run("not found for unknown secret", func(t *testing.T) {
+		r := get(t, app.handleAPISecretMetadata, func(r *http.Request) { r.SetPathValue("managementID", "unknown") })
+
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, `"error":"not found"`) {
+			t.Errorf("wanted not found error in body, got %v", r.body)
+		}
+	})
+
+	t.Run("describes an active secret without its cipher text", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleAPISecretMetadata, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		} else if strings.Contains(r.body, "a.b.c") || strings.Contains(r.body, "cipherText") {
+			t.Errorf("did not expect cipher text in body, got %v", r.body)
+		}
+
+		var res apiSecretMetadataResponse
+		if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+
+		if !strings.HasSuffix(res.ViewURL, "/secret/"+accessID) {
+			t.Errorf("wanted view url of secret, got %v", res.ViewURL)
+		} else if res.ExpiresAt == nil || res.ExpiresAt.Sub(res.CreatedAt) != 30*time.Minute {
+			t.Errorf("wanted secret to expire 30 minutes after it was created, got %v", res.ExpiresAt)
+		} else if res.Deleted || res.Expired || res.DeletionReason != "" {
+			t.Errorf("wanted secret to be active, got %+v", res)
+		}
+	})
+
+	t.Run("describes a deleted secret", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		r := get(t, app.handleAPISecretMetadata, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, `"deleted":true`) || !strings.Contains(r.body, `"deletionReason":"`+deletionReasonUserDeleted+`"`) {
+			t.Errorf("wanted deleted secret with its deletion reason, got %v", r.body)
+		}
+	})
+}
+
+func TestAPIRotateViewingLink(t *testing.T) {
+	t.Run("not found for unknown secret", func(t *testing.T) {
+		r := post(t, app.handleAPIRotateViewingLink, "", func(r *http.Request) { r.SetPathValue("managementID", "unknown") })
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("returns the new viewing url", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		r := post(t, app.handleAPIRotateViewingLink, "", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		}
+
+		var res apiRotateViewingLinkResponse
+		if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+			t.Fatalf("unmarshalling response: %v", err)
+		} else if !strings.HasPrefix(res.ViewURL, app.baseURL+"/secret/") || strings.HasSuffix(res.ViewURL, accessID) {
+			t.Errorf("wanted new viewing url, got %v", res.ViewURL)
+		}
+	})
+}
+
+func TestAPISecretValidation(t *testing.T) {
+	activeSecrets := func() int64 {
+		n, err := app.store.CountActive(context.Background())
+		if err != nil {
+			t.Fatalf("counting active secrets: %v", err)
+		}
+
+		return n
+	}
+
+	t.Run("accepts a well formed secret without storing it", func(t *testing.T) {
+		before := activeSecrets()
+
+		r := post(t, app.handleAPIValidateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30}`, emptyRequestConfigurer)
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		} else if r.body != `{"valid":true,"size":14,"format":"v1"}`+"\n" {
+			t.Errorf("wanted valid secret of 14 bytes, got %v", r.body)
+		}
+
+		r = post(t, app.handleAPIValidateSecret, `{"encryptedSecret": "v2.AES-GCM.YWJj.ZGVm.Z2hp", "ttl": 30}`, emptyRequestConfigurer)
+		if !strings.Contains(r.body, `"format":"v2"`) {
+			t.Errorf("wanted version of the cipher text format, got %v", r.body)
+		}
+
+		if after := activeSecrets(); after != before {
+			t.Errorf("did not expect a secret to be stored")
+		}
+	})
+
+	t.Run("describes why a secret is invalid", func(t *testing.T) {
+		r := post(t, app.handleAPIValidateSecret, `{"encryptedSecret": "YWJj.ZGVm", "ttl": 30}`, emptyRequestConfigurer)
+
+		var res apiValidateSecretResponse
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		} else if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		} else if res.Valid || res.Size != 9 || !strings.Contains(res.Error, "format is invalid") || res.Code != errorCodeInvalidCipherFormat {
+			t.Errorf("wanted invalid format of 9 bytes, got %+v", res)
+		}
+	})
+
+	t.Run("bad request for malformed json", func(t *testing.T) {
+		if r := post(t, app.handleAPIValidateSecret, `{"encryptedSecret": `, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("forbidden if not valid requesting ip", func(t *testing.T) {
+		r := post(t, app.handleAPIValidateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp"}`, func(r *http.Request) { r.Header.Set("X-Forwarded-For", "192.0.2.1") })
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		}
+	})
+}
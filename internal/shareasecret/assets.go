@@ -0,0 +1,74 @@
+package shareasecret
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+)
+
+// immutableAssetMaxAge is the max-age (in seconds) fingerprinted assets are cached for, equal to one year
+const immutableAssetMaxAge = "31536000"
+
+// assetVersionContextKey is the context key the fingerprint of the web assets is stored under
+type assetVersionContextKey struct{}
+
+// fingerprintAssets creates a fingerprint of the contents of every file in the given filesystem, which changes whenever
+// any of them do
+func fingerprintAssets(assets fs.FS) (string, error) {
+	h := sha256.New()
+
+	err := fs.WalkDir(assets, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := fs.ReadFile(assets, path)
+		if err != nil {
+			return err
+		}
+
+		h.Write([]byte(path))
+		h.Write(b)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// assetVersions wraps the given handler, storing the fingerprint of the web assets in the context of the request for
+// links to assets to be generated with via [assetPath]
+func (a *Application) assetVersions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), assetVersionContextKey{}, a.assetVersion)))
+	})
+}
+
+// assetPath returns the [pathFor] the given asset, fingerprinted with the current version of the web assets so that it
+// can be cached by browsers until the assets change
+func assetPath(ctx context.Context, path string) string {
+	if v, ok := ctx.Value(assetVersionContextKey{}).(string); ok && v != "" {
+		return pathFor(ctx, path) + "?v=" + v
+	}
+
+	return pathFor(ctx, path)
+}
+
+// cacheAssets wraps the given asset handler, allowing assets requested with the current fingerprint (see [assetPath])
+// to be cached indefinitely. Any other request for an asset must be revalidated before a cached copy is used.
+func (a *Application) cacheAssets(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.URL.Query().Get("v"); v != "" && v == a.assetVersion {
+			w.Header().Set("Cache-Control", "public, max-age="+immutableAssetMaxAge+", immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
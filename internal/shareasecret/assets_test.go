@@ -0,0 +1,70 @@
+package shareasecret
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFingerprintAssets(t *testing.T) {
+	a, err := fingerprintAssets(fstest.MapFS{"css/style.css": {Data: []byte("a")}})
+	if err != nil {
+		t.Fatalf("fingerprinting assets: %v", err)
+	}
+
+	b, err := fingerprintAssets(fstest.MapFS{"css/style.css": {Data: []byte("b")}})
+	if err != nil {
+		t.Fatalf("fingerprinting assets: %v", err)
+	}
+
+	if a == b {
+		t.Errorf("wanted fingerprint to change with the contents of the assets")
+	}
+}
+
+func TestAssetCaching(t *testing.T) {
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+
+		app.ServeHTTP(recorder, r)
+
+		return recorder
+	}
+
+	t.Run("fingerprints links to assets", func(t *testing.T) {
+		r := serve("/")
+		if !strings.Contains(r.Body.String(), `href="/static/css/style.css?v=`+app.assetVersion+`"`) {
+			t.Errorf("wanted fingerprinted link to stylesheet in body")
+		}
+	})
+
+	cases := []struct {
+		path         string
+		cacheControl string
+	}{
+		{"/static/css/style.css?v=" + app.assetVersion, "public, max-age=31536000, immutable"},
+		{"/static/css/style.css?v=outdated", "no-cache"},
+		{"/static/css/style.css", "no-cache"},
+		{"/favicon.ico?v=" + app.assetVersion, "public, max-age=31536000, immutable"},
+		{"/favicon.ico", "no-cache"},
+	}
+
+	for _, c := range cases {
+		r := serve(c.path)
+		if r.Code != http.StatusOK {
+			t.Errorf("%v: wanted 200 status code, got %v", c.path, r.Code)
+		} else if h := r.Header().Get("Cache-Control"); h != c.cacheControl {
+			t.Errorf("%v: wanted Cache-Control of %v, got %v", c.path, c.cacheControl, h)
+		}
+	}
+
+	t.Run("does not cache pages", func(t *testing.T) {
+		if h := serve("/").Header().Get("Cache-Control"); h != "" {
+			t.Errorf("wanted no Cache-Control header, got %v", h)
+		}
+	})
+}
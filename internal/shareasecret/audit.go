@@ -0,0 +1,147 @@
+package shareasecret
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Secret event types recorded in the audit log of each secret
+const (
+	eventCreated = "created"
+	eventViewed  = "viewed"
+	eventDeleted = "deleted"
+	eventExpired = "expired"
+	eventRotated = "rotated"
+	// eventTTLUpdated details the new TTL (in minutes) of the secret
+	eventTTLUpdated = "ttl_updated"
+	// eventRecipientEmailed details the domain of the recipient the viewing URL of the secret was emailed to
+	eventRecipientEmailed = "recipient_emailed"
+)
+
+// defaultEventsLimit and maximumEventsLimit are the default and largest number of events returned by the
+// [handleAPIAdminSecretEvents] handler
+const (
+	defaultEventsLimit = 50
+	maximumEventsLimit = 500
+)
+
+// secretEvent is an event in the lifecycle of a secret. Events never contain the cipher text of the secret, and the IP
+// address of the requester is only ever stored hashed.
+type secretEvent struct {
+	accessID     string
+	managementID string
+	eventType    string
+	// detail holds additional context for the event, such as the reason a secret was deleted
+	detail     string
+	ipHash     string
+	occurredAt int64
+}
+
+// ipHasher hashes the IP addresses recorded in the audit log. Hashes are keyed with a secret generated when the
+// application starts, so the same address can be correlated across events until the application restarts but the
+// small space of IP addresses cannot be brute forced to reverse them.
+type ipHasher struct {
+	key []byte
+}
+
+// newIPHasher creates an [ipHasher] with a randomly generated key
+func newIPHasher() (*ipHasher, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return &ipHasher{key: key}, nil
+}
+
+// hash returns the hex encoded HMAC-SHA256 of the given IP address
+func (h *ipHasher) hash(ip string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(ip))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordEvent records an event caused by the given request in the audit log of a secret. Failing to record an event
+// is logged rather than failing the action that caused it.
+func (a *Application) recordEvent(r *http.Request, e secretEvent) {
+	e.occurredAt = time.Now().UnixMilli()
+	e.ipHash = a.ipHasher.hash(rateLimitKey(r))
+
+	// the action that caused the event has already happened, so the event is recorded even if the request is cancelled
+	if err := a.store.RecordEvent(context.WithoutCancel(r.Context()), e); err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("event_type", e.eventType).Msg("recording secret event")
+	}
+}
+
+// recordViewEvents records the view of a secret in its audit log, along with its deletion if the view exhausted it
+func (a *Application) recordViewEvents(r *http.Request, accessID string, deletionReason string) {
+	a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventViewed})
+
+	if deletionReason != "" {
+		a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventDeleted, detail: deletionReason})
+	}
+}
+
+// apiSecretEvent is an individual event returned by the [handleAPIAdminSecretEvents] handler
+type apiSecretEvent struct {
+	Type       string    `json:"type"`
+	Detail     string    `json:"detail,omitempty"`
+	IPHash     string    `json:"ipHash,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// handleAPIAdminSecretEvents returns the most recent events in the audit log of a secret via its management ID. The
+// number of events returned can be set with the limit query parameter.
+func (a *Application) handleAPIAdminSecretEvents(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	limit := defaultEventsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maximumEventsLimit {
+			apiError(fmt.Sprintf("Limit must be between 1 and %v.", maximumEventsLimit), http.StatusBadRequest, w)
+			return
+		}
+
+		limit = n
+	}
+
+	if _, err := a.store.GetByManagementID(r.Context(), managementID); errors.Is(err, errSecretNotFound) {
+		apiError("not found", http.StatusNotFound, w)
+		return
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("retrieving secret")
+		apiError("Unable to retrieve events.", http.StatusInternalServerError, w)
+		return
+	}
+
+	events, err := a.store.Events(r.Context(), managementID, limit)
+	if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("retrieving secret events")
+		apiError("Unable to retrieve events.", http.StatusInternalServerError, w)
+		return
+	}
+
+	res := make([]apiSecretEvent, 0, len(events))
+	for _, e := range events {
+		res = append(res, apiSecretEvent{
+			Type:       e.eventType,
+			Detail:     e.detail,
+			IPHash:     e.ipHash,
+			OccurredAt: time.UnixMilli(e.occurredAt).UTC(),
+		})
+	}
+
+	writeJSON(res, http.StatusOK, w)
+}
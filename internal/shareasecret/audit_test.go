@@ -0,0 +1,126 @@
+package shareasecret
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	app.config.Admin.Token = "admin-token"
+	defer func() { app.config.Admin.Token = "" }()
+
+	events := func(t *testing.T, managementID string) []apiSecretEvent {
+		t.Helper()
+
+		r := get(t, app.adminOnly(app.handleAPIAdminSecretEvents), func(r *http.Request) {
+			r.SetPathValue("managementID", managementID)
+			r.Header.Set("Authorization", "Bearer admin-token")
+		})
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		}
+
+		var res []apiSecretEvent
+		if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+			t.Fatalf("unmarshalling response: %v", err)
+		}
+
+		return res
+	}
+
+	t.Run("records the lifecycle of a secret without its cipher text", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+		secret, err := app.store.GetByManagementID(context.Background(), managementID)
+		if err != nil {
+			t.Fatalf("retrieving secret: %v", err)
+		}
+
+		r = post(t, app.handleCreateSecretView, "", func(r *http.Request) { r.SetPathValue("accessID", secret.accessID) })
+		viewingKey := strings.TrimPrefix(r.headers.Get("Location"), "/secret/"+secret.accessID+"/")
+
+		get(t, app.handleAccessSecret, func(r *http.Request) {
+			r.SetPathValue("accessID", secret.accessID)
+			r.SetPathValue("viewingKey", viewingKey)
+		})
+
+		res := events(t, managementID)
+
+		var types []string
+		for _, e := range res {
+			types = append(types, e.Type+":"+e.Detail)
+			if e.IPHash == "" || strings.Contains(e.IPHash, "127.0.0.1") {
+				t.Errorf("wanted hashed ip address, got %q", e.IPHash)
+			}
+		}
+
+		if got := strings.Join(types, ","); got != "deleted:maximum_view_count_hit,viewed:,created:" {
+			t.Errorf("wanted newest events first, got %v", got)
+		} else if res[0].IPHash != res[2].IPHash {
+			t.Errorf("wanted ip hashes of the same requester to match")
+		}
+
+		var cipherTexts int
+		if err := testDB().QueryRow("SELECT COUNT(1) FROM secret_events WHERE detail LIKE '%YWJj%'").Scan(&cipherTexts); err != nil {
+			t.Errorf("querying events: %v", err)
+		} else if cipherTexts != 0 {
+			t.Errorf("did not expect cipher text in events")
+		}
+	})
+
+	t.Run("records secrets deleted by their creator", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		post(t, app.handleDeleteSecret, "", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+
+		if res := events(t, managementID); len(res) != 1 || res[0].Type != eventDeleted || res[0].Detail != deletionReasonUserDeleted {
+			t.Errorf("wanted a single user deleted event, got %+v", res)
+		}
+	})
+
+	t.Run("not found for unknown secret", func(t *testing.T) {
+		r := get(t, app.adminOnly(app.handleAPIAdminSecretEvents), func(r *http.Request) {
+			r.SetPathValue("managementID", "unknown")
+			r.Header.Set("Authorization", "Bearer admin-token")
+		})
+
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("unauthorized without the admin token", func(t *testing.T) {
+		for _, h := range []string{"", "Bearer wrong-token", "admin-token"} {
+			r := get(t, app.adminOnly(app.handleAPIAdminSecretEvents), func(r *http.Request) {
+				r.SetPathValue("managementID", "unknown")
+				r.Header.Set("Authorization", h)
+			})
+
+			if r.statusCode != 401 {
+				t.Errorf("wanted 401 status code for %q, got %v", h, r.statusCode)
+			}
+		}
+	})
+
+	t.Run("disabled when no admin token is configured", func(t *testing.T) {
+		app.config.Admin.Token = ""
+		defer func() { app.config.Admin.Token = "admin-token" }()
+
+		r := get(t, app.adminOnly(app.handleAPIAdminSecretEvents), func(r *http.Request) {
+			r.SetPathValue("managementID", "unknown")
+			r.Header.Set("Authorization", "Bearer ")
+		})
+
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// basePathContextKey is the context key the path prefix the application is served under is stored under
+type basePathContextKey struct{}
+
+// stripBasePath wraps the given handler, removing the configured path prefix from incoming requests so that routes can
+// be registered without it. The prefix is stored in the context of the request for generated links to be prefixed with
+// via [pathFor]. Requests outside of the prefix do not belong to the application and are not found.
+func (a *Application) stripBasePath(next http.Handler) http.Handler {
+	if a.basePath == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), basePathContextKey{}, a.basePath))
+
+		if r.URL.Path == a.basePath {
+			http.Redirect(w, r, a.basePath+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		p, ok := strings.CutPrefix(r.URL.Path, a.basePath+"/")
+		if !ok {
+			notFound(w, r)
+			return
+		}
+
+		u := *r.URL
+		u.Path = "/" + p
+		u.RawPath = ""
+		r.URL = &u
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pathFor prefixes the given path (which must begin with a forward slash) with the path the application is served under
+func pathFor(ctx context.Context, path string) string {
+	if basePath, ok := ctx.Value(basePathContextKey{}).(string); ok {
+		return basePath + path
+	}
+
+	return path
+}
@@ -0,0 +1,74 @@
+package shareasecret
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	app.basePath = "/secrets"
+	defer func() { app.basePath = "" }()
+
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+
+		return recorder
+	}
+
+	t.Run("serves routes under the base path", func(t *testing.T) {
+		for _, path := range []string{"/secrets/healthz", "/secrets/static/css/style.css"} {
+			if r := serve("GET", path); r.Code != 200 {
+				t.Errorf("%v: wanted 200 status code, got %v", path, r.Code)
+			}
+		}
+	})
+
+	t.Run("does not serve routes outside of the base path", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/secretshealthz"} {
+			if r := serve("GET", path); r.Code != 404 {
+				t.Errorf("%v: wanted 404 status code, got %v", path, r.Code)
+			}
+		}
+	})
+
+	t.Run("redirects the base path to the index page", func(t *testing.T) {
+		r := serve("GET", "/secrets")
+		if r.Code != 301 || r.Header().Get("Location") != "/secrets/" {
+			t.Errorf("wanted redirect to /secrets/, got %v to %v", r.Code, r.Header().Get("Location"))
+		}
+	})
+
+	t.Run("prefixes generated links with the base path", func(t *testing.T) {
+		r := serve("GET", "/secrets/")
+		if r.Code != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.Code)
+		}
+
+		for _, link := range []string{`href="/secrets/static/css/style.css?v=`, `href="/secrets/"`, `src="/secrets/static/js/index_page.mjs?v=`} {
+			if !strings.Contains(r.Body.String(), link) {
+				t.Errorf("wanted %v in body", link)
+			}
+		}
+
+		r = serve("GET", "/secrets/secret/unknown")
+		if r.Header().Get("Location") != "/secrets/" {
+			t.Errorf("wanted redirect to /secrets/, got %v", r.Header().Get("Location"))
+		}
+	})
+
+	t.Run("scopes cookies to the base path", func(t *testing.T) {
+		r := serve("GET", "/secrets/secret/unknown")
+
+		cookies := r.Result().Cookies()
+		if len(cookies) == 0 {
+			t.Fatalf("wanted cookies to be set")
+		}
+		for _, c := range cookies {
+			if c.Path != "/secrets/" {
+				t.Errorf("wanted %v cookie to have a path of /secrets/, got %v", c.Name, c.Path)
+			}
+		}
+	})
+}
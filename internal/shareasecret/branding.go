@@ -0,0 +1,61 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+)
+
+// defaultBrandName is the name pages are branded with when no other name is configured
+const defaultBrandName = "shareasecret"
+
+// brandingContextKey is the context key the branding of the application is stored under
+type brandingContextKey struct{}
+
+// branding is how the pages of the application are branded, allowing organisations to present it as their own
+type branding struct {
+	// name replaces shareasecret in the header and title of every page
+	name string
+	// logo is the URL of an image shown alongside the name in the header, if set
+	logo string
+	// footerText is shown in the footer of every page, if set
+	footerText string
+	// supportURL is linked to from the footer of every page, if set
+	supportURL string
+}
+
+// newBranding creates the [branding] described by the given configuration, falling back to the default name
+func newBranding(c *Configuration) branding {
+	b := branding{
+		name:       c.Branding.Name,
+		logo:       c.Branding.Logo,
+		footerText: c.Branding.FooterText,
+		supportURL: c.Branding.SupportURL,
+	}
+	if b.name == "" {
+		b.name = defaultBrandName
+	}
+
+	return b
+}
+
+// hasFooter identifies whether there is anything to show in the footer of a page
+func (b branding) hasFooter() bool {
+	return b.footerText != "" || b.supportURL != ""
+}
+
+// brand wraps the given handler, storing the branding of the application in the context of the request so that it can
+// be rendered by the layout via [brandingFrom]
+func (a *Application) brand(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), brandingContextKey{}, a.branding)))
+	})
+}
+
+// brandingFrom retrieves the branding of the application from the context, falling back to the default branding
+func brandingFrom(ctx context.Context) branding {
+	if b, ok := ctx.Value(brandingContextKey{}).(branding); ok {
+		return b
+	}
+
+	return branding{name: defaultBrandName}
+}
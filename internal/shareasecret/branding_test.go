@@ -0,0 +1,66 @@
+package shareasecret
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBranding(t *testing.T) {
+	serve := func() string {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+
+		app.ServeHTTP(recorder, r)
+
+		return recorder.Body.String()
+	}
+
+	t.Run("preserves the default appearance", func(t *testing.T) {
+		body := serve()
+
+		if !strings.Contains(body, "<title>shareasecret - ") || !strings.Contains(body, "<header>shareasecret</header>") {
+			t.Errorf("wanted default name in title and header")
+		} else if strings.Contains(body, "<footer") || strings.Contains(body, "header__logo") {
+			t.Errorf("did not expect a footer or logo")
+		}
+	})
+
+	t.Run("brands pages with the configured branding", func(t *testing.T) {
+		original := app.branding
+		app.branding = branding{
+			name:       "Example Corp Secrets",
+			logo:       "/logo.png",
+			footerText: "Operated by the Example Corp security team",
+			supportURL: "https://support.example.com",
+		}
+		defer func() { app.branding = original }()
+
+		body := serve()
+
+		for _, want := range []string{
+			"<title>Example Corp Secrets - ",
+			`src="/logo.png"`,
+			"Operated by the Example Corp security team",
+			`href="https://support.example.com"`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("wanted %v in body", want)
+			}
+		}
+	})
+}
+
+func TestNewBranding(t *testing.T) {
+	var c Configuration
+
+	if b := newBranding(&c); b.name != defaultBrandName {
+		t.Errorf("wanted default name, got %v", b.name)
+	}
+
+	c.Branding.Name = "Example Corp Secrets"
+	if b := newBranding(&c); b.name != "Example Corp Secrets" {
+		t.Errorf("wanted configured name, got %v", b.name)
+	}
+}
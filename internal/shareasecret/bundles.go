@@ -0,0 +1,207 @@
+package shareasecret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// maximumBundleSize is the maximum number of secrets that can be shared together in a bundle
+const maximumBundleSize = 10
+
+// createBundleFromForm creates a bundle from the secrets submitted to [handleCreateSecret] as repeated encryptedSecret
+// fields, titled by the secretTitle field in the same position. Every secret of the bundle is created with the options
+// of the given (already validated) secret.
+func (a *Application) createBundleFromForm(w http.ResponseWriter, r *http.Request, options newSecret) {
+	cipherTexts := r.Form["encryptedSecret"]
+	titles := r.Form["secretTitle"]
+
+	if len(cipherTexts) > maximumBundleSize {
+		a.createSecretFailed(invalid(errorCodeBundleTooLarge, "invalid.bundleTooLarge", maximumBundleSize), w, r)
+		return
+	}
+
+	secrets := make([]newSecret, len(cipherTexts))
+	secretTitles := make([]string, len(cipherTexts))
+	for i, cipherText := range cipherTexts {
+		secrets[i] = options
+		secrets[i].cipherText = cipherText
+
+		if err := a.validateSecret(&secrets[i]); err != nil {
+			a.createSecretFailed(invalid(err.code, "invalid.bundleSecret", i+1, err), w, r)
+			return
+		}
+
+		if i < len(titles) {
+			secretTitles[i] = titles[i]
+		}
+		if utf8.RuneCountInString(secretTitles[i]) > maximumLabelLength {
+			a.createSecretFailed(invalid(errorCodeTitleTooLong, "invalid.titleTooLong", i+1, maximumLabelLength), w, r)
+			return
+		}
+	}
+
+	accessIDs, managementID, err := a.createBundle(r.Context(), secrets, secretTitles)
+	if errors.Is(err, errTooManyCreations) {
+		serviceUnavailable(w)
+		return
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Msg("creating bundle")
+		internalServerError(w, r)
+		return
+	}
+
+	for _, accessID := range accessIDs {
+		a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventCreated})
+	}
+	noteDefaultTTL(secrets[0], w, r)
+
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/manage-bundle/%s", managementID)))
+}
+
+// createBundle persists already validated secrets as a bundle with the given titles, returning the access identifiers
+// of the secrets and the management identifier generated for the bundle
+func (a *Application) createBundle(ctx context.Context, secrets []newSecret, titles []string) ([]string, string, error) {
+	bundle := storedBundle{createdAt: time.Now().UnixMilli()}
+	for i, s := range secrets {
+		stored, err := a.storedSecretFrom(s)
+		if err != nil {
+			return nil, "", err
+		}
+
+		bundle.secrets = append(bundle.secrets, bundledSecret{title: titles[i], storedSecret: stored})
+	}
+
+	release, err := a.acquireCreation(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
+	// identifiers are generated for the bundle and each of its secrets, all of which are regenerated should any collide
+	// with those of an existing bundle or secret
+	for attempt := 1; ; attempt++ {
+		if bundle.accessID, bundle.managementID, err = a.secretIDs(); err != nil {
+			return nil, "", err
+		}
+
+		for i := range bundle.secrets {
+			if bundle.secrets[i].accessID, bundle.secrets[i].managementID, err = a.secretIDs(); err != nil {
+				return nil, "", err
+			}
+		}
+
+		err = a.store.CreateBundle(ctx, bundle)
+		if errors.Is(err, errSecretIDCollision) && attempt < maximumSecretIDAttempts {
+			continue
+		} else if err != nil {
+			return nil, "", fmt.Errorf("inserting bundle: %w", err)
+		}
+
+		break
+	}
+
+	accessIDs := make([]string, len(bundle.secrets))
+	for i, s := range bundle.secrets {
+		accessIDs[i] = s.accessID
+		a.metrics.secretCreated()
+	}
+
+	return accessIDs, bundle.managementID, nil
+}
+
+// handleViewBundle lists the secrets of a bundle to the visitor, each of which links to its own viewing page so that
+// viewing the bundle does not use a view of any of its secrets
+func (a *Application) handleViewBundle(w http.ResponseWriter, r *http.Request) {
+	accessID := r.PathValue("accessID")
+
+	noIndex(w)
+
+	bundle, err := a.store.GetBundleByViewingID(r.Context(), accessID)
+	if errors.Is(err, errSecretNotFound) {
+		redirectSecretNotFound(w, r)
+		return
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("bundle_access_id", accessID).Msg("retrieving bundle")
+		redirectToOopsPage(w, r)
+		return
+	}
+
+	var secrets []bundleSecretDetails
+	for _, s := range bundle.secrets {
+		secrets = append(secrets, bundleSecretDetails{
+			title:     s.title,
+			viewURL:   fmt.Sprintf("%s/secret/%s", a.baseURL, s.accessID),
+			available: s.unavailableReason() == "" && !s.notYetAvailable(),
+		})
+	}
+
+	pageViewBundle(secrets).Render(r.Context(), w)
+}
+
+// handleManageBundle renders the management page of a bundle, describing the state of each of its secrets, and is
+// intended for the original creator of the bundle to view
+func (a *Application) handleManageBundle(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	noStore(w)
+	noIndex(w)
+
+	bundle, err := a.store.GetBundleByManagementID(r.Context(), managementID)
+	if errors.Is(err, errSecretNotFound) {
+		setFlashErr(translate("flash.bundleNotFound", language(r.Context())), w, r)
+		safeRedirect(w, r, pathFor(r.Context(), "/"))
+		return
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("bundle_management_id", managementID).Msg("retrieving bundle")
+		redirectToOopsPage(w, r)
+		return
+	}
+
+	details := bundleManagementDetails{
+		viewBundleURL:   fmt.Sprintf("%s/bundle/%s", a.baseURL, bundle.accessID),
+		deleteBundleURL: fmt.Sprintf("%s/manage-bundle/%s/delete", a.baseURL, managementID),
+	}
+	for _, s := range bundle.secrets {
+		details.secrets = append(details.secrets, bundleSecretDetails{
+			title:             s.title,
+			manageURL:         fmt.Sprintf("%s/manage-secret/%s", a.baseURL, s.managementID),
+			views:             s.views,
+			unavailableReason: s.unavailableReason(),
+		})
+	}
+
+	pageManageBundle(details, notificationsFromRequest(r, w)).Render(r.Context(), w)
+}
+
+// handleDeleteBundle deletes every secret of a bundle that hasn't already been deleted, returning the creator to the
+// management page of the bundle
+func (a *Application) handleDeleteBundle(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	accessIDs, err := a.store.DeleteBundle(r.Context(), managementID)
+	if errors.Is(err, errSecretNotFound) {
+		setFlashErr(translate("flash.bundleNotFound", language(r.Context())), w, r)
+		safeRedirect(w, r, pathFor(r.Context(), "/"))
+		return
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("bundle_management_id", managementID).Msg("deleting bundle")
+		redirectToOopsPage(w, r)
+		return
+	}
+
+	if len(accessIDs) > 0 {
+		a.metrics.secretsDeleted(deletionReasonUserDeleted, int64(len(accessIDs)))
+	}
+	for _, accessID := range accessIDs {
+		a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventDeleted, detail: deletionReasonUserDeleted})
+	}
+
+	setFlashSuccess(translate("flash.bundleDeleted", language(r.Context())), w, r)
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/manage-bundle/%s", managementID)))
+}
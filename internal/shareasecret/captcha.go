@@ -0,0 +1,203 @@
+package shareasecret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/rs/zerolog"
+)
+
+// captchaTimeout is the maximum amount of time the CAPTCHA provider is given to verify a single challenge response
+const captchaTimeout = 10 * time.Second
+
+// captchaHeader is the request header API clients supply a challenge response token in when a CAPTCHA is required
+const captchaHeader = "X-Shareasecret-Captcha-Token"
+
+const (
+	captchaProviderTurnstile = "turnstile"
+	captchaProviderHCaptcha  = "hcaptcha"
+)
+
+// captchaProvider describes how a CAPTCHA provider's widget is embedded in a page and how the challenge responses it
+// produces are verified
+type captchaProvider struct {
+	// verifyURL is the endpoint challenge responses are verified against
+	verifyURL string
+	// scriptURL is the script that renders the widget
+	scriptURL string
+	// widgetClass is the class of the element the script renders the widget into
+	widgetClass string
+	// responseField is the form field the widget submits its challenge response in
+	responseField string
+	// origins are the origins the widget loads scripts, frames and styles from, which must be permitted by the
+	// Content-Security-Policy of the page it is rendered in
+	origins string
+}
+
+// captchaProviders are the supported CAPTCHA providers, keyed by the value of SHAREASECRET_CAPTCHA_PROVIDER
+var captchaProviders = map[string]captchaProvider{
+	captchaProviderTurnstile: {
+		verifyURL:     "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		scriptURL:     "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		widgetClass:   "cf-turnstile",
+		responseField: "cf-turnstile-response",
+		origins:       "https://challenges.cloudflare.com",
+	},
+	captchaProviderHCaptcha: {
+		verifyURL:     "https://api.hcaptcha.com/siteverify",
+		scriptURL:     "https://js.hcaptcha.com/1/api.js",
+		widgetClass:   "h-captcha",
+		responseField: "h-captcha-response",
+		origins:       "https://hcaptcha.com https://*.hcaptcha.com",
+	},
+}
+
+// captchaVerifier verifies that viewers of secrets have solved a CAPTCHA challenge. It is disabled (and every request
+// is treated as verified) when no keys are configured.
+type captchaVerifier struct {
+	provider  captchaProvider
+	siteKey   string
+	secretKey string
+	client    *http.Client
+}
+
+// newCaptchaVerifier creates a [captchaVerifier] from the CAPTCHA configuration
+func newCaptchaVerifier(config *Configuration) *captchaVerifier {
+	return &captchaVerifier{
+		provider:  captchaProviders[config.Captcha.Provider],
+		siteKey:   config.Captcha.SiteKey,
+		secretKey: config.Captcha.SecretKey,
+		client:    &http.Client{Timeout: captchaTimeout},
+	}
+}
+
+// enabled identifies whether viewers must solve a challenge before secrets are revealed to them
+func (v *captchaVerifier) enabled() bool {
+	return v.secretKey != ""
+}
+
+// verify verifies the challenge response token with the provider, returning whether it was valid. Requests are always
+// valid when the verifier is disabled.
+func (v *captchaVerifier) verify(ctx context.Context, token string, remoteIP string) (bool, error) {
+	if !v.enabled() {
+		return true, nil
+	} else if token == "" {
+		return false, nil
+	}
+
+	form := url.Values{"secret": {v.secretKey}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.provider.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, fmt.Errorf("creating verification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("sending verification request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("verification request responded with %v", res.StatusCode)
+	}
+
+	var body struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return false, fmt.Errorf("decoding verification response: %w", err)
+	}
+
+	return body.Success, nil
+}
+
+// captchaDirectives are the Content-Security-Policy directives the origins of a provider must be permitted by for its
+// widget to load, along with the sources each is given when the configured policy doesn't set it
+var captchaDirectives = []struct {
+	name    string
+	sources []string
+}{
+	{"script-src", []string{"'self'"}},
+	{"frame-src", nil},
+	{"style-src", []string{"'self'"}},
+	{"connect-src", []string{"'self'"}},
+}
+
+// contentSecurityPolicy extends the given Content-Security-Policy to permit the widget of the provider to load, for
+// the pages it is rendered in. The origins are merged into any of the directives the policy already sets, as browsers
+// ignore all but the first occurrence of a directive, and are added in new directives otherwise.
+func (v *captchaVerifier) contentSecurityPolicy(policy string) string {
+	origins := strings.Fields(v.provider.origins)
+
+	directives := [][]string{}
+	for _, d := range strings.Split(policy, ";") {
+		if fields := strings.Fields(d); len(fields) > 0 {
+			directives = append(directives, fields)
+		}
+	}
+
+	for _, cd := range captchaDirectives {
+		i := slices.IndexFunc(directives, func(d []string) bool { return strings.EqualFold(d[0], cd.name) })
+		if i == -1 {
+			directives = append(directives, append(append([]string{cd.name}, cd.sources...), origins...))
+			continue
+		}
+
+		// 'none' cannot be combined with other sources, so it is replaced by the origins rather than extended
+		if len(directives[i]) == 2 && directives[i][1] == "'none'" {
+			directives[i] = directives[i][:1]
+		}
+		directives[i] = append(directives[i], origins...)
+	}
+
+	merged := make([]string, len(directives))
+	for i, d := range directives {
+		merged[i] = strings.Join(d, " ")
+	}
+
+	return strings.Join(merged, "; ")
+}
+
+// widget describes the widget rendered in the pages viewers solve challenges in, which is empty when the verifier is
+// disabled
+func (v *captchaVerifier) widget() captchaWidgetDetails {
+	if !v.enabled() {
+		return captchaWidgetDetails{}
+	}
+
+	return captchaWidgetDetails{scriptURL: v.provider.scriptURL, widgetClass: v.provider.widgetClass, siteKey: v.siteKey}
+}
+
+// captchaScripts returns the scripts that render the given widget, if there is one
+func captchaScripts(captcha captchaWidgetDetails) []templ.Component {
+	if captcha.siteKey == "" {
+		return nil
+	}
+
+	return []templ.Component{script("text/javascript", captcha.scriptURL)}
+}
+
+// captchaSolved identifies whether the requester has solved a challenge, given the response token produced by the
+// widget. A provider that cannot be reached is logged and treated as an unsolved challenge so that secrets are never
+// revealed without one.
+func (a *Application) captchaSolved(r *http.Request, token string) bool {
+	ok, err := a.captcha.verify(r.Context(), token, rateLimitKey(r))
+	if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Msg("verifying captcha")
+		return false
+	}
+
+	return ok
+}
@@ -0,0 +1,129 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCaptcha(t *testing.T) {
+	// the provider accepts a single token, rejecting any others
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("secret") == "secret-key" && r.PostFormValue("response") == "solved" {
+			w.Write([]byte(`{"success":true}`))
+		} else {
+			w.Write([]byte(`{"success":false}`))
+		}
+	}))
+	defer provider.Close()
+
+	disabled := app.captcha
+	app.captcha = &captchaVerifier{
+		provider:  captchaProviders[captchaProviderTurnstile],
+		siteKey:   "site-key",
+		secretKey: "secret-key",
+		client:    provider.Client(),
+	}
+	app.captcha.provider.verifyURL = provider.URL
+	defer func() { app.captcha = disabled }()
+
+	t.Run("renders the widget in the interstitial page", func(t *testing.T) {
+		accessID, _, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if !strings.Contains(r.body, `class="cf-turnstile"`) || !strings.Contains(r.body, `data-sitekey="site-key"`) {
+			t.Errorf("expected widget to be in body")
+		} else if !strings.Contains(r.headers.Get("Content-Security-Policy"), "frame-src https://challenges.cloudflare.com") {
+			t.Errorf("expected content security policy to permit the widget, got %v", r.headers.Get("Content-Security-Policy"))
+		}
+	})
+
+	t.Run("requires the challenge to be solved before viewing a secret", func(t *testing.T) {
+		accessID, _, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		for _, body := range []string{"", "cf-turnstile-response=unsolved"} {
+			r := post(t, app.handleCreateSecretView, body, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+			if !responseIsRedirectTo(r, "/secret/"+accessID) {
+				t.Errorf("expected redirect back to interstitial for body %q, got %v %v", body, r.statusCode, r.headers.Get("Location"))
+			}
+		}
+
+		r := post(t, app.handleCreateSecretView, "cf-turnstile-response=solved", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if r.statusCode != http.StatusSeeOther || !strings.HasPrefix(r.headers.Get("Location"), "/secret/"+accessID+"/") {
+			t.Errorf("expected redirect to viewing page, got %v %v", r.statusCode, r.headers.Get("Location"))
+		}
+	})
+
+	t.Run("requires the challenge to be solved before viewing a secret via the api", func(t *testing.T) {
+		accessID, _, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		r := get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if r.statusCode != http.StatusForbidden {
+			t.Errorf("expected 403 without a challenge response, got %v", r.statusCode)
+		}
+
+		r = get(t, app.handleAPIAccessSecret, func(r *http.Request) {
+			r.SetPathValue("accessID", accessID)
+			r.Header.Set(captchaHeader, "solved")
+		})
+		if r.statusCode != http.StatusOK || !strings.Contains(r.body, "YWJj.ZGVm.Z2hp") {
+			t.Errorf("expected cipher text with a solved challenge, got %v %v", r.statusCode, r.body)
+		}
+	})
+
+	t.Run("treats an unreachable provider as an unsolved challenge", func(t *testing.T) {
+		unreachable := *app.captcha
+		unreachable.provider.verifyURL = "http://127.0.0.1:0"
+
+		ok, err := unreachable.verify(context.Background(), "solved", "")
+		if ok || err == nil {
+			t.Errorf("expected error and an unsolved challenge, got %v %v", ok, err)
+		}
+	})
+}
+
+func TestCaptchaDisabled(t *testing.T) {
+	v := newCaptchaVerifier(&Configuration{})
+	if v.enabled() {
+		t.Fatalf("expected verifier without keys to be disabled")
+	}
+
+	if ok, err := v.verify(context.Background(), "", ""); !ok || err != nil {
+		t.Errorf("expected every request to be verified, got %v %v", ok, err)
+	}
+}
+
+func TestCaptchaContentSecurityPolicy(t *testing.T) {
+	v := &captchaVerifier{provider: captchaProviders[captchaProviderTurnstile]}
+
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{
+			defaultContentSecurityPolicy,
+			"default-src 'self'; frame-ancestors 'none'; script-src 'self' https://challenges.cloudflare.com; frame-src https://challenges.cloudflare.com; style-src 'self' https://challenges.cloudflare.com; connect-src 'self' https://challenges.cloudflare.com",
+		},
+		{
+			"default-src 'self'; script-src 'self' https://cdn.example; frame-src 'none';",
+			"default-src 'self'; script-src 'self' https://cdn.example https://challenges.cloudflare.com; frame-src https://challenges.cloudflare.com; style-src 'self' https://challenges.cloudflare.com; connect-src 'self' https://challenges.cloudflare.com",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := v.contentSecurityPolicy(tt.policy); got != tt.want {
+			t.Errorf("%q: wanted %q, got %q", tt.policy, tt.want, got)
+		}
+	}
+}
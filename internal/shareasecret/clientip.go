@@ -0,0 +1,104 @@
+package shareasecret
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// defaultTrustedProxies are the networks whose X-Forwarded-* headers are honored when SHAREASECRET_TRUSTED_PROXIES
+// isn't configured: loopback addresses only, which reverse proxies on the same host connect from. Private addresses
+// aren't trusted by default as any other machine (or container) on the same network could then spoof its address.
+var defaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// trustedProxiesNone is the value of SHAREASECRET_TRUSTED_PROXIES that trusts no proxies, so that X-Forwarded-*
+// headers are always ignored
+const trustedProxiesNone = "none"
+
+// clientContextKey is the context key the resolved client of the current request is stored under
+type clientContextKey struct{}
+
+// client describes who made a request, as resolved from the immediate peer and any forwarded headers it is trusted to
+// set
+type client struct {
+	ip net.IP
+	// proxied identifies whether the immediate peer is a trusted proxy, and so whether its X-Forwarded-* headers are
+	// honored
+	proxied bool
+}
+
+// clientIPs wraps the given handler, resolving the client of every request so that logging, rate limiting and IP
+// restrictions all agree on who made it
+func (a *Application) clientIPs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := resolveClient(r, a.config.Server.TrustedProxies)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientContextKey{}, c)))
+	})
+}
+
+// resolveClient resolves the client of the request. The X-Forwarded-For header is only honored when the immediate
+// peer is one of the trusted proxies, in which case it is walked from the right (the entry added by the peer) past any
+// further trusted proxies to the first address that isn't one. Anything left of that address could have been supplied
+// by the client itself and is ignored.
+func resolveClient(r *http.Request, trusted []net.IPNet) client {
+	peer := peerIP(r)
+	if peer == nil || !inNetworks(peer, trusted) {
+		return client{ip: peer}
+	}
+
+	c := client{ip: peer, proxied: true}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+
+		c.ip = ip
+		if !inNetworks(ip, trusted) {
+			break
+		}
+	}
+
+	return c
+}
+
+// clientFrom retrieves the resolved client of the request, resolving it without trusting any proxies when the request
+// didn't pass through [Application.clientIPs]
+func clientFrom(r *http.Request) client {
+	if c, ok := r.Context().Value(clientContextKey{}).(client); ok {
+		return c
+	}
+
+	return resolveClient(r, nil)
+}
+
+// clientIP returns the IP address of whoever made the request, only honoring the X-Forwarded-For header when the
+// immediate peer is a trusted proxy, or nil if it cannot be determined
+func clientIP(r *http.Request) net.IP {
+	return clientFrom(r).ip
+}
+
+// peerIP returns the IP address of the immediate peer of the request, or nil if it cannot be determined
+func peerIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+// inNetworks identifies whether the IP address is contained in any of the networks
+func inNetworks(ip net.IP, networks []net.IPNet) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package shareasecret
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClient(t *testing.T) {
+	var trusted []net.IPNet
+	for _, v := range []string{"127.0.0.0/8", "10.0.0.0/8"} {
+		_, nw, _ := net.ParseCIDR(v)
+		trusted = append(trusted, *nw)
+	}
+
+	cases := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		ip           string
+		proxied      bool
+	}{
+		{"uses the peer without forwarded headers", "203.0.113.7:5000", nil, "203.0.113.7", false},
+		{"ignores forwarded headers from untrusted peers", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7", false},
+		{"honors forwarded headers from trusted peers", "127.0.0.1:5000", []string{"198.51.100.1"}, "198.51.100.1", true},
+		{"skips chained trusted proxies", "127.0.0.1:5000", []string{"198.51.100.1, 10.0.0.2"}, "198.51.100.1", true},
+		{"ignores addresses spoofed by the client", "127.0.0.1:5000", []string{"192.0.2.1, 198.51.100.1"}, "198.51.100.1", true},
+		{"combines repeated headers", "127.0.0.1:5000", []string{"192.0.2.1", "198.51.100.1"}, "198.51.100.1", true},
+		{"stops at malformed addresses", "127.0.0.1:5000", []string{"198.51.100.1, unknown, 10.0.0.2"}, "10.0.0.2", true},
+		{"uses the peer when only trusted proxies forwarded", "127.0.0.1:5000", []string{"10.0.0.2"}, "10.0.0.2", true},
+		{"uses peers without a port", "203.0.113.7", nil, "203.0.113.7", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = c.remoteAddr
+			for _, v := range c.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := resolveClient(r, trusted); got.ip.String() != c.ip || got.proxied != c.proxied {
+				t.Errorf("wanted %v (proxied %v), got %v (proxied %v)", c.ip, c.proxied, got.ip, got.proxied)
+			}
+		})
+	}
+
+	t.Run("cannot resolve unparseable peers", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+		if got := resolveClient(r, trusted); got.ip != nil || got.proxied {
+			t.Errorf("wanted no client ip, got %v", got)
+		}
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	if ip := clientIP(r); ip.String() != "127.0.0.1" || requestIsSecure(r) {
+		t.Errorf("wanted forwarded headers to be ignored outside of the middleware, got %v", ip)
+	}
+
+	app.clientIPs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := clientIP(r); ip.String() != "198.51.100.1" || !requestIsSecure(r) {
+			t.Errorf("wanted forwarded headers of a trusted proxy to be honored, got %v", ip)
+		}
+	})).ServeHTTP(httptest.NewRecorder(), r)
+}
@@ -0,0 +1,177 @@
+package shareasecret
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minimumCompressedSize is the size (in bytes) a response must reach before it is compressed, as compressing smaller
+// responses saves little or even increases their size
+const minimumCompressedSize = 1024
+
+// compressibleContentTypes are the media types of responses that are compressed. Any other response, such as the
+// already compressed QR code PNGs, is written as is.
+var compressibleContentTypes = map[string]bool{
+	"text/html":        true,
+	"application/json": true,
+}
+
+// compress wraps the given handler, compressing compressible responses of at least [minimumCompressedSize] bytes with
+// the most preferred of gzip or deflate supported by the client (as identified by its Accept-Encoding header)
+func (a *Application) compress(next http.Handler) http.Handler {
+	if a.config.Server.DisableCompression {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressingResponseWriter{ResponseWriter: w, encoding: acceptedEncoding(r)}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding identifies the preferred encoding of the client out of gzip and deflate (favouring gzip when equally
+// preferred), returning an empty string if it accepts neither
+func acceptedEncoding(r *http.Request) string {
+	gzipQuality, deflateQuality := 0.0, 0.0
+
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(v, ";")
+
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && k == "q" {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip":
+			gzipQuality = max(gzipQuality, q)
+		case "deflate":
+			deflateQuality = max(deflateQuality, q)
+		}
+	}
+
+	if gzipQuality > 0 && gzipQuality >= deflateQuality {
+		return "gzip"
+	} else if deflateQuality > 0 {
+		return "deflate"
+	}
+
+	return ""
+}
+
+// compressingResponseWriter is a [http.ResponseWriter] that buffers the start of a response until it can decide
+// whether to compress it, then either compresses or passes through the remainder. It must be closed once the response
+// has been written.
+type compressingResponseWriter struct {
+	http.ResponseWriter
+	encoding   string
+	statusCode int
+	buf        []byte
+	decided    bool
+	compressor io.WriteCloser
+}
+
+// WriteHeader records the status code, which is written to the underlying response once whether to compress the
+// response has been decided
+func (w *compressingResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// Write buffers the start of the response until enough has been written to decide whether to compress it
+func (w *compressingResponseWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Write(b)
+		}
+
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= minimumCompressedSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Close writes any buffered response and flushes the compressor, if the response was compressed
+func (w *compressingResponseWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+
+	return nil
+}
+
+// Unwrap returns the underlying response for use by [http.ResponseController]
+func (w *compressingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide writes the headers of the response, choosing to compress it if it is compressible, is large enough, and the
+// client accepts a supported encoding, then writes the buffered start of the response
+func (w *compressingResponseWriter) decide() error {
+	w.decided = true
+
+	h := w.Header()
+
+	// the content type must be sniffed before compression, as it cannot be once the body has been compressed
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	compressible := compressibleContentTypes[mediaType] && h.Get("Content-Encoding") == ""
+	if compressible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	if compressible && w.encoding != "" && len(w.buf) >= minimumCompressedSize {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+
+		if w.encoding == "gzip" {
+			w.compressor = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.compressor = zlib.NewWriter(w.ResponseWriter)
+		}
+	}
+
+	if w.statusCode != 0 {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+	}
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.compressor != nil {
+		_, err = w.compressor.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+
+	return err
+}
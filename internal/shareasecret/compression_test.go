@@ -0,0 +1,118 @@
+package shareasecret
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("a", minimumCompressedSize)
+
+	serve := func(contentType string, body string, acceptEncoding string) *httptest.ResponseRecorder {
+		handler := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusTeapot)
+			io.WriteString(w, body)
+		}))
+
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+
+		handler.ServeHTTP(recorder, r)
+
+		return recorder
+	}
+
+	t.Run("gzips large html and json responses", func(t *testing.T) {
+		for _, ct := range []string{"text/html; charset=utf-8", "application/json"} {
+			r := serve(ct, large, "gzip, deflate")
+			if r.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("%v: wanted gzip content encoding, got %v", ct, r.Header().Get("Content-Encoding"))
+			} else if r.Code != http.StatusTeapot {
+				t.Errorf("%v: wanted 418 status code, got %v", ct, r.Code)
+			}
+
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatalf("%v: reading gzip body: %v", ct, err)
+			}
+			if b, _ := io.ReadAll(gr); string(b) != large {
+				t.Errorf("%v: wanted decompressed body to match", ct)
+			}
+		}
+	})
+
+	t.Run("deflates when gzip is not accepted", func(t *testing.T) {
+		r := serve("text/html", large, "gzip;q=0, deflate")
+		if r.Header().Get("Content-Encoding") != "deflate" {
+			t.Fatalf("wanted deflate content encoding, got %v", r.Header().Get("Content-Encoding"))
+		}
+
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("reading deflate body: %v", err)
+		}
+		if b, _ := io.ReadAll(zr); string(b) != large {
+			t.Errorf("wanted decompressed body to match")
+		}
+	})
+
+	cases := []struct {
+		name           string
+		contentType    string
+		body           string
+		acceptEncoding string
+	}{
+		{"does not compress small responses", "text/html", "small", "gzip"},
+		{"does not compress already compressed responses", "image/png", large, "gzip"},
+		{"does not compress when no encoding is accepted", "text/html", large, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := serve(c.contentType, c.body, c.acceptEncoding)
+			if h := r.Header().Get("Content-Encoding"); h != "" {
+				t.Errorf("wanted no content encoding, got %v", h)
+			} else if r.Body.String() != c.body {
+				t.Errorf("wanted body to be written as is")
+			} else if r.Code != http.StatusTeapot {
+				t.Errorf("wanted 418 status code, got %v", r.Code)
+			}
+		})
+	}
+
+	t.Run("can be disabled", func(t *testing.T) {
+		app.config.Server.DisableCompression = true
+		defer func() { app.config.Server.DisableCompression = false }()
+
+		if h := serve("text/html", large, "gzip").Header().Get("Content-Encoding"); h != "" {
+			t.Errorf("wanted no content encoding, got %v", h)
+		}
+	})
+}
+
+func TestAcceptedEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                      "",
+		"br":                    "",
+		"gzip":                  "gzip",
+		"deflate, gzip":         "gzip",
+		"gzip;q=0.5, deflate":   "deflate",
+		"gzip;q=0, deflate;q=0": "",
+	}
+
+	for header, want := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", header)
+
+		if got := acceptedEncoding(r); got != want {
+			t.Errorf("%q: wanted %q, got %q", header, want, got)
+		}
+	}
+}
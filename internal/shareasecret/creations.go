@@ -0,0 +1,49 @@
+package shareasecret
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultMaximumConcurrentCreations is the number of secrets (or bundles) that can be inserted at once when it isn't
+// configured. SQLite serializes writes, so a burst of many more than this only contends for the database.
+const defaultMaximumConcurrentCreations = 16
+
+// creationWaitTimeout is how long a creation waits for another to finish once the maximum are already in progress
+const creationWaitTimeout = 2 * time.Second
+
+// errTooManyCreations is returned when creating a secret (or bundle) whilst the maximum concurrent creations are in
+// progress, none of which finished in time
+var errTooManyCreations = errors.New("too many concurrent creations")
+
+// newCreationSemaphore creates the semaphore limiting the number of concurrent creations to the given maximum, or nil
+// if creations are unlimited
+func newCreationSemaphore(maximum int) chan struct{} {
+	if maximum < 1 {
+		return nil
+	}
+
+	return make(chan struct{}, maximum)
+}
+
+// acquireCreation waits for one of the concurrent creations to be available, returning a function that releases it
+// once the creation completes. Should none become available within [creationWaitTimeout] (or before the context is
+// done) [errTooManyCreations] is returned instead.
+func (a *Application) acquireCreation(ctx context.Context) (func(), error) {
+	if a.creations == nil {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(creationWaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case a.creations <- struct{}{}:
+		return func() { <-a.creations }, nil
+	case <-timer.C:
+		return nil, errTooManyCreations
+	case <-ctx.Done():
+		return nil, errTooManyCreations
+	}
+}
@@ -0,0 +1,68 @@
+package shareasecret
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConcurrentCreations(t *testing.T) {
+	t.Run("waits for a creation to finish once the maximum are in progress", func(t *testing.T) {
+		a := &Application{creations: newCreationSemaphore(1)}
+
+		release, err := a.acquireCreation(context.Background())
+		if err != nil {
+			t.Fatalf("acquiring creation: %v", err)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			release()
+		}()
+
+		if _, err := a.acquireCreation(context.Background()); err != nil {
+			t.Errorf("wanted creation once the other finished, got %v", err)
+		}
+	})
+
+	t.Run("gives up once the context is done", func(t *testing.T) {
+		a := &Application{creations: newCreationSemaphore(1)}
+		a.creations <- struct{}{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := a.acquireCreation(ctx); !errors.Is(err, errTooManyCreations) {
+			t.Errorf("wanted errTooManyCreations, got %v", err)
+		}
+	})
+
+	t.Run("is unlimited when the maximum is 0", func(t *testing.T) {
+		a := &Application{creations: newCreationSemaphore(0)}
+
+		for i := 0; i < 100; i++ {
+			if _, err := a.acquireCreation(context.Background()); err != nil {
+				t.Fatalf("wanted unlimited creations, got %v", err)
+			}
+		}
+	})
+
+	t.Run("responds with 503 when creations cannot proceed", func(t *testing.T) {
+		creations := app.creations
+		app.creations = newCreationSemaphore(1)
+		app.creations <- struct{}{}
+		defer func() { app.creations = creations }()
+
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != http.StatusServiceUnavailable || r.headers.Get("Retry-After") == "" {
+			t.Errorf("wanted 503 status code with a retry after header, got %v %v", r.statusCode, r.headers)
+		}
+
+		r = post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30}`, emptyRequestConfigurer)
+		if r.statusCode != http.StatusServiceUnavailable {
+			t.Errorf("wanted 503 status code from api, got %v", r.statusCode)
+		}
+	})
+}
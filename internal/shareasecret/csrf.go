@@ -0,0 +1,88 @@
+package shareasecret
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// csrfCookieName is the name of the cookie containing the visitor's CSRF token
+const csrfCookieName = "csrf_token"
+
+// csrfFormField is the name of the hidden form field that must contain the visitor's CSRF token on POST requests
+const csrfFormField = "csrfToken"
+
+// csrfTokenContextKey is the context key the visitor's current CSRF token is stored under
+type csrfTokenContextKey struct{}
+
+// csrfProtection wraps the given handler, ensuring every visitor has a CSRF token and that all state changing (POST)
+// requests made outside of the API contain a form field matching it. Tokens are rotated after every successful POST
+// request to prevent fixation.
+func (a *Application) csrfProtection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if c, err := r.Cookie(csrfCookieName); err == nil {
+			token = c.Value
+		}
+
+		if r.Method == http.MethodPost && !strings.HasPrefix(r.URL.Path, "/api/") {
+			// the body is capped before the token is read from it so that unauthenticated requests cannot make the
+			// instance buffer more than the largest form needs. Only url encoded forms are parsed: no form is submitted
+			// as multipart, so such bodies are never read and fail the check.
+			submitted := ""
+			var tooLarge *http.MaxBytesError
+			if err := a.parseForm(w, r); errors.As(err, &tooLarge) {
+				a.formTooLarge(w, r)
+				return
+			} else if err == nil {
+				submitted = r.PostForm.Get(csrfFormField)
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(submitted)) != 1 {
+				zerolog.Ctx(r.Context()).Warn().Str("path", r.URL.Path).Msg("csrf token mismatch")
+				forbidden("Invalid or missing CSRF token. Please refresh the page and try again.", w)
+				return
+			}
+
+			token = ""
+		}
+
+		// issue a new token if the visitor doesn't have one or has just used theirs
+		if token == "" {
+			t, err := secureID(32)
+			if err != nil {
+				zerolog.Ctx(r.Context()).Err(err).Msg("generating csrf token")
+				internalServerError(w, r)
+				return
+			}
+
+			token = t
+			http.SetCookie(
+				w,
+				&http.Cookie{
+					Name:     csrfCookieName,
+					Value:    token,
+					Path:     pathFor(r.Context(), "/"),
+					HttpOnly: true,
+					Secure:   requestIsSecure(r),
+					SameSite: http.SameSiteStrictMode,
+				},
+			)
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfTokenContextKey{}, token)))
+	})
+}
+
+// csrfToken retrieves the visitor's current CSRF token from the context, for inclusion in rendered forms
+func csrfToken(ctx context.Context) string {
+	if t, ok := ctx.Value(csrfTokenContextKey{}).(string); ok {
+		return t
+	}
+
+	return ""
+}
@@ -0,0 +1,92 @@
+package shareasecret
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	handler := app.csrfProtection(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(csrfToken(r.Context())))
+	})).ServeHTTP
+
+	t.Run("issues a token to visitors without one", func(t *testing.T) {
+		r := get(t, handler, func(r *http.Request) { r.Method = http.MethodGet })
+
+		if len(r.cookies) != 1 || r.cookies[0].Name != csrfCookieName {
+			t.Errorf("expected csrf cookie to be set")
+		} else if r.body != r.cookies[0].Value {
+			t.Errorf("expected token to be available in the request context")
+		}
+	})
+
+	t.Run("forbids posts without a token", func(t *testing.T) {
+		r := post(t, handler, "", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+		})
+
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("forbids posts with a mismatched token", func(t *testing.T) {
+		r := post(t, handler, "csrfToken=other", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+		})
+
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("permits posts with a matching token and rotates it", func(t *testing.T) {
+		r := post(t, handler, "csrfToken=token", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+		})
+
+		if r.statusCode != 200 {
+			t.Errorf("wanted 200 status code, got %v", r.statusCode)
+		} else if len(r.cookies) != 1 || r.cookies[0].Value == "token" {
+			t.Errorf("expected csrf token to be rotated")
+		}
+	})
+
+	t.Run("does not parse multipart bodies", func(t *testing.T) {
+		body := "--boundary\r\nContent-Disposition: form-data; name=\"csrfToken\"\r\n\r\ntoken\r\n--boundary--\r\n"
+		r := post(t, handler, body, func(r *http.Request) {
+			r.Header.Set("Content-Type", "multipart/form-data; boundary=boundary")
+			r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+		})
+
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("only sends the token over https when the request was", func(t *testing.T) {
+		r := get(t, handler, func(r *http.Request) { r.Method = http.MethodGet })
+		if len(r.cookies) != 1 || r.cookies[0].Secure {
+			t.Errorf("wanted insecure csrf cookie over http, got %v", r.cookies)
+		}
+
+		r = get(t, handler, func(r *http.Request) {
+			r.Method = http.MethodGet
+			r.TLS = &tls.ConnectionState{}
+		})
+		if len(r.cookies) != 1 || !r.cookies[0].Secure {
+			t.Errorf("wanted secure csrf cookie over https, got %v", r.cookies)
+		}
+	})
+
+	t.Run("does not protect api routes", func(t *testing.T) {
+		r := post(t, handler, "", func(r *http.Request) {
+			r.URL.Path = "/api/secrets"
+		})
+
+		if r.statusCode != 200 {
+			t.Errorf("wanted 200 status code, got %v", r.statusCode)
+		}
+	})
+}
@@ -0,0 +1,450 @@
+package shareasecret
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFS embed.FS
+
+// driverSQLite is the database driver used for SQLite databases
+const driverSQLite = "sqlite"
+
+// driverPostgres is the database driver used for PostgreSQL databases
+const driverPostgres = "postgres"
+
+// defaultBusyTimeout is how long SQLite waits for a lock held by another connection to be released before failing with
+// a busy error, unless configured otherwise
+const defaultBusyTimeout = 5 * time.Second
+
+// defaultQueryTimeout is the maximum amount of time an operation on the database may take before it is abandoned,
+// unless configured otherwise. It allows for operations waiting on locks for up to the default busy timeout.
+const defaultQueryTimeout = 10 * time.Second
+
+// sqlitePragmaNames are the pragmas set on every connection to a SQLite database by the connection string returned from
+// [sqliteConnectionString]
+var sqlitePragmaNames = []string{"busy_timeout", "journal_mode", "synchronous", "foreign_keys"}
+
+// busyRetries is the number of times a query that failed because the database was busy or locked is retried
+const busyRetries = 4
+
+// busyRetryBackoff is how long to wait before the first retry of a query that failed because the database was busy or
+// locked. The wait doubles with each subsequent retry.
+const busyRetryBackoff = 10 * time.Millisecond
+
+// postgresUniqueViolation is the SQLSTATE code PostgreSQL returns when a query violates a unique constraint
+const postgresUniqueViolation = "23505"
+
+// database is a wrapper around either a SQLite or a PostgreSQL database. Queries executed via the wrapper are written
+// with SQLite style (? or ?N) placeholders which are translated to the selected driver's style before being executed.
+//
+// Timestamps are stored as unix milliseconds in integer columns regardless of the driver.
+type database struct {
+	db     *sql.DB
+	driver string
+}
+
+// transaction is a wrapper around a database transaction that translates placeholders in the same manner as [database]
+type transaction struct {
+	tx     *sql.Tx
+	driver string
+	ctx    context.Context
+}
+
+// newDatabase creates a connection to the database with the given driver and then runs any applicable migrations or
+// seeders
+func newDatabase(driver string, connectionString string) (*database, error) {
+	var driverName string
+	switch driver {
+	case driverSQLite:
+		driverName = "sqlite"
+	case driverPostgres:
+		driverName = "pgx"
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %v", driver)
+	}
+
+	con, err := sql.Open(driverName, connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &database{
+		db:     con,
+		driver: driver,
+	}
+
+	if err := db.migrate(); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	return db, nil
+}
+
+// sqliteConnectionString returns the connection string of the SQLite database at the given path. The connection string
+// sets pragmas that reduce lock contention under concurrent reads and writes, and enforces foreign keys. Pragmas are set
+// via the connection string as (with the exception of the journal mode) they only apply to the connection they are
+// executed on, and [sql.DB] opens further connections as required.
+func sqliteConnectionString(path string, busyTimeout time.Duration) string {
+	return fmt.Sprintf(
+		"file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(wal)&_pragma=synchronous(normal)&_pragma=foreign_keys(1)",
+		path,
+		busyTimeout.Milliseconds(),
+	)
+}
+
+// sqlitePragmas reads the effective values of the pragmas set by [sqliteConnectionString], so that operators can
+// verify them
+func (d *database) sqlitePragmas() (map[string]any, error) {
+	pragmas := make(map[string]any, len(sqlitePragmaNames))
+	for _, name := range sqlitePragmaNames {
+		var v string
+		if err := d.db.QueryRow("PRAGMA " + name).Scan(&v); err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+
+		pragmas[name] = v
+	}
+
+	return pragmas, nil
+}
+
+// exec executes a query that doesn't return rows
+func (d *database) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, d.driver, query)
+
+	rs, err := d.db.ExecContext(ctx, rebind(d.driver, query), args...)
+	endQuerySpan(span, err)
+
+	return rs, err
+}
+
+// query executes a query that returns rows
+func (d *database) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, d.driver, query)
+
+	rows, err := d.db.QueryContext(ctx, rebind(d.driver, query), args...)
+	endQuerySpan(span, err)
+
+	return rows, err
+}
+
+// queryRow executes a query that is expected to return at most one row
+func (d *database) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuerySpan(ctx, d.driver, query)
+
+	row := d.db.QueryRowContext(ctx, rebind(d.driver, query), args...)
+	endQuerySpan(span, row.Err())
+
+	return row
+}
+
+// begin starts a transaction, which is rolled back should the given context be done before it is committed. Queries
+// within the transaction are executed with the same context.
+func (d *database) begin(ctx context.Context) (*transaction, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction{tx: tx, driver: d.driver, ctx: ctx}, nil
+}
+
+// exec executes a query that doesn't return rows within the transaction
+func (t *transaction) exec(query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuerySpan(t.ctx, t.driver, query)
+
+	rs, err := t.tx.ExecContext(ctx, rebind(t.driver, query), args...)
+	endQuerySpan(span, err)
+
+	return rs, err
+}
+
+// queryRow executes a query that is expected to return at most one row within the transaction
+func (t *transaction) queryRow(query string, args ...any) *sql.Row {
+	ctx, span := startQuerySpan(t.ctx, t.driver, query)
+
+	row := t.tx.QueryRowContext(ctx, rebind(t.driver, query), args...)
+	endQuerySpan(span, row.Err())
+
+	return row
+}
+
+// commit commits the transaction
+func (t *transaction) commit() error {
+	return t.tx.Commit()
+}
+
+// rollback aborts the transaction. It is safe to call after the transaction has been committed.
+func (t *transaction) rollback() error {
+	return t.tx.Rollback()
+}
+
+// withTx runs the given function within a transaction, committing the transaction if the function succeeds and rolling
+// it back otherwise. The error of the function is returned as is, so that callers can identify it.
+func (d *database) withTx(ctx context.Context, fn func(tx *transaction) error) error {
+	tx, err := d.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.commit(); err != nil {
+		return fmt.Errorf("committing tx: %w", err)
+	}
+
+	return nil
+}
+
+// retryBusy runs the given function, retrying it with an exponential backoff whilst it fails because the database is
+// busy or locked by another connection (which is common when SQLite is written to concurrently). The error of the
+// final attempt is returned once the retries are exhausted.
+func (d *database) retryBusy(fn func() error) error {
+	backoff := busyRetryBackoff
+
+	err := fn()
+	for i := 0; i < busyRetries && isBusy(err); i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+
+		err = fn()
+	}
+
+	return err
+}
+
+// isBusy identifies whether the given error occurred because a SQLite database was busy or locked
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	// extended result codes (i.e. SQLITE_BUSY_SNAPSHOT) share the primary code in their lowest 8 bits
+	code := sqliteErr.Code() & 0xff
+
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// isUniqueViolation identifies whether the error returned by a query was caused by it violating a unique constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == postgresUniqueViolation
+	}
+
+	return false
+}
+
+// migrationsAdvisoryLock is the key of the PostgreSQL advisory lock held whilst migrating, so that instances starting
+// at the same time do not apply the same migrations concurrently
+const migrationsAdvisoryLock = 7336057085662403136
+
+// migration is an embedded SQL file that changes the schema of the database. Migrations are applied in order of their
+// version, which is the number that prefixes their file name (i.e. 0010 of 0010-Unique-Identifiers.sql).
+type migration struct {
+	version  int64
+	fileName string
+}
+
+// migrate applies every migration that has not yet been applied to the database within a single transaction, rolling
+// it back and returning the error should any occur. Applied migrations are recorded by version in the
+// schema_migrations table, so migrating is idempotent and safe to do every time the application starts.
+func (d *database) migrate() error {
+	if _, err := d.db.Exec(
+		"CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at BIGINT NOT NULL);",
+	); err != nil {
+		return fmt.Errorf("create schema migrations table: %w", err)
+	}
+
+	migrations, err := d.migrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %w", err)
+	}
+	defer tx.rollback()
+
+	if d.driver == driverPostgres {
+		if _, err := tx.exec("SELECT pg_advisory_xact_lock(?)", migrationsAdvisoryLock); err != nil {
+			return fmt.Errorf("acquiring migrations lock: %w", err)
+		}
+	}
+
+	// databases created before migrations were versioned recorded them by file name in the migrations table, which is
+	// carried over before being dropped
+	legacy, err := d.hasLegacyMigrationsTable(tx)
+	if err != nil {
+		return fmt.Errorf("checking for legacy migrations table: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err = d.migrateFile(m, legacy, tx); err != nil {
+			return fmt.Errorf("applying migration %v: %w", m.fileName, err)
+		}
+	}
+
+	if legacy {
+		if _, err := tx.exec("DROP TABLE migrations"); err != nil {
+			return fmt.Errorf("dropping legacy migrations table: %w", err)
+		}
+	}
+
+	return tx.commit()
+}
+
+// migrations lists the embedded migrations applicable to the driver of the database, ordered by version
+func (d *database) migrations() ([]migration, error) {
+	pattern := "migrations/*.sql"
+	if d.driver == driverPostgres {
+		pattern = "migrations/postgres/*.sql"
+	}
+
+	fileNames, err := fs.Glob(migrationFS, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("globbing migration files: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(fileNames))
+	versions := map[int64]string{}
+
+	for _, fileName := range fileNames {
+		prefix, _, _ := strings.Cut(path.Base(fileName), "-")
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %v is not prefixed with a version", fileName)
+		} else if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migrations %v and %v share version %v", other, fileName, version)
+		}
+
+		versions[version] = fileName
+		migrations = append(migrations, migration{version: version, fileName: fileName})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
+
+// hasLegacyMigrationsTable identifies whether the database still has the unversioned migrations table
+func (d *database) hasLegacyMigrationsTable(tx *transaction) (bool, error) {
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'migrations'"
+	if d.driver == driverPostgres {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'migrations'"
+	}
+
+	var c int
+	err := tx.queryRow(query).Scan(&c)
+
+	return c > 0, err
+}
+
+// migrateFile applies the given migration unless it has been applied before, recording that it has been applied. When
+// the legacy migrations table exists, migrations recorded in it are recorded as applied without being applied again.
+func (d *database) migrateFile(m migration, legacy bool, tx *transaction) error {
+	var c int
+	if err := tx.queryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", m.version).Scan(&c); err != nil {
+		return err
+	} else if c != 0 {
+		return nil
+	}
+
+	applied := false
+	if legacy {
+		if err := tx.queryRow("SELECT COUNT(*) FROM migrations WHERE name = ?", m.fileName).Scan(&c); err != nil {
+			return err
+		}
+
+		applied = c != 0
+	}
+
+	if !applied {
+		if buf, err := fs.ReadFile(migrationFS, m.fileName); err != nil {
+			return err
+		} else if _, err := tx.exec(string(buf)); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version,
+		path.Base(m.fileName),
+		time.Now().UnixMilli(),
+	)
+
+	return err
+}
+
+// rebind translates the SQLite style placeholders (? or ?N) in a query to the style used by the given driver. Queries
+// for SQLite are returned untouched, whilst PostgreSQL queries have their placeholders replaced with $N.
+func rebind(driver string, query string) string {
+	if driver != driverPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inQuotes := false
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		if c == '\'' {
+			inQuotes = !inQuotes
+		}
+
+		if c != '?' || inQuotes {
+			b.WriteByte(c)
+			continue
+		}
+
+		// numbered placeholders (?N) keep their number, whilst anonymous ones are numbered sequentially
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+
+		b.WriteByte('$')
+		if j > i+1 {
+			b.WriteString(query[i+1 : j])
+		} else {
+			n++
+			b.WriteString(strconv.Itoa(n))
+		}
+
+		i = j - 1
+	}
+
+	return b.String()
+}
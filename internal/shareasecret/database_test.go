@@ -0,0 +1,298 @@
+package shareasecret
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebind(t *testing.T) {
+	cases := []struct {
+		driver string
+		query  string
+		want   string
+	}{
+		{driverSQLite, "SELECT * FROM secrets WHERE access_id = ? AND ttl = ?", "SELECT * FROM secrets WHERE access_id = ? AND ttl = ?"},
+		{driverPostgres, "SELECT * FROM secrets WHERE access_id = ? AND ttl = ?", "SELECT * FROM secrets WHERE access_id = $1 AND ttl = $2"},
+		{driverPostgres, "UPDATE secrets SET deleted_at = ?1 WHERE created_at <= ?1 AND deletion_reason = ?2", "UPDATE secrets SET deleted_at = $1 WHERE created_at <= $1 AND deletion_reason = $2"},
+		{driverPostgres, "SELECT '?' FROM secrets WHERE id = ?", "SELECT '?' FROM secrets WHERE id = $1"},
+	}
+
+	for _, c := range cases {
+		if got := rebind(c.driver, c.query); got != c.want {
+			t.Errorf("rebind(%v, %q): wanted %q, got %q", c.driver, c.query, c.want, got)
+		}
+	}
+}
+
+func TestRetryBusy(t *testing.T) {
+	// busy errors are returned immediately without a busy timeout
+	path := sqliteConnectionString(filepath.Join(t.TempDir(), "busy.db"), 0)
+
+	db, err := newDatabase(driverSQLite, path)
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+	defer db.db.Close()
+
+	// a transaction on a second connection holds the write lock, making writes from the first fail as busy until it ends
+	lock := func(t *testing.T) func() {
+		t.Helper()
+
+		c, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatalf("opening second connection: %v", err)
+		}
+		tx, err := c.Begin()
+		if err != nil {
+			t.Fatalf("beginning transaction: %v", err)
+		} else if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (-1, 'lock', 0)"); err != nil {
+			t.Fatalf("acquiring write lock: %v", err)
+		}
+
+		return func() {
+			tx.Rollback()
+			c.Close()
+		}
+	}
+
+	write := func() error {
+		_, err := db.exec(context.Background(), "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'write', 0)", -time.Now().UnixNano())
+		return err
+	}
+
+	t.Run("retries writes until the database is no longer busy", func(t *testing.T) {
+		unlock := lock(t)
+		time.AfterFunc(3*busyRetryBackoff/2, unlock)
+
+		if err := db.retryBusy(write); err != nil {
+			t.Errorf("wanted write to succeed once unlocked, got %v", err)
+		}
+	})
+
+	t.Run("gives up once the retries are exhausted", func(t *testing.T) {
+		unlock := lock(t)
+		defer unlock()
+
+		if err := db.retryBusy(write); !isBusy(err) {
+			t.Errorf("wanted busy error, got %v", err)
+		}
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		attempts := 0
+		err := db.retryBusy(func() error {
+			attempts++
+			return errors.New("failed")
+		})
+
+		if err == nil || attempts != 1 {
+			t.Errorf("wanted a single failed attempt, got %v (%v)", attempts, err)
+		}
+	})
+}
+
+func TestWithTx(t *testing.T) {
+	db, err := newDatabase(driverSQLite, sqliteConnectionString(filepath.Join(t.TempDir(), "tx.db"), 0))
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+	defer db.db.Close()
+
+	ctx := context.Background()
+
+	insert := func(tx *transaction, version int) error {
+		_, err := tx.exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'tx', 0)", version)
+		return err
+	}
+	exists := func(version int) bool {
+		var n int
+		db.queryRow(ctx, "SELECT COUNT(1) FROM schema_migrations WHERE version = ?", version).Scan(&n)
+		return n == 1
+	}
+
+	t.Run("commits when the function succeeds", func(t *testing.T) {
+		if err := db.withTx(ctx, func(tx *transaction) error { return insert(tx, -1) }); err != nil {
+			t.Fatalf("with tx: %v", err)
+		}
+
+		if !exists(-1) {
+			t.Errorf("wanted write to be committed")
+		}
+	})
+
+	t.Run("rolls back and returns the error when the function fails", func(t *testing.T) {
+		failed := errors.New("failed")
+
+		err := db.withTx(ctx, func(tx *transaction) error {
+			if err := insert(tx, -2); err != nil {
+				return err
+			}
+
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("wanted error of the function, got %v", err)
+		}
+
+		if exists(-2) {
+			t.Errorf("wanted write to be rolled back")
+		}
+	})
+}
+
+func TestSQLitePragmas(t *testing.T) {
+	db, err := newDatabase(driverSQLite, sqliteConnectionString(filepath.Join(t.TempDir(), "pragmas.db"), 2*time.Second))
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+	defer db.db.Close()
+
+	pragmas, err := db.sqlitePragmas()
+	if err != nil {
+		t.Fatalf("reading pragmas: %v", err)
+	}
+
+	// synchronous is reported numerically, where 1 is NORMAL
+	wanted := map[string]any{"busy_timeout": "2000", "journal_mode": "wal", "synchronous": "1", "foreign_keys": "1"}
+	for name, v := range wanted {
+		if pragmas[name] != v {
+			t.Errorf("wanted %v of %v, got %v", name, v, pragmas[name])
+		}
+	}
+}
+
+func TestSQLiteIndexes(t *testing.T) {
+	db, err := newDatabase(driverSQLite, sqliteConnectionString(filepath.Join(t.TempDir(), "indexes.db"), defaultBusyTimeout))
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+	defer db.db.Close()
+
+	cases := map[string]string{
+		"SELECT id FROM secrets WHERE access_id = 'a' AND deleted_at IS NULL":                        "idx_secrets_access_id",
+		"SELECT id FROM secrets WHERE management_id = 'a'":                                           "idx_secrets_management_id",
+		"UPDATE secrets SET deleted_at = 1 WHERE deleted_at IS NULL AND created_at <= 1 AND ttl > 0": "idx_secrets_deleted_at_created_at_ttl",
+	}
+
+	for query, index := range cases {
+		rows, err := db.db.Query("EXPLAIN QUERY PLAN " + query)
+		if err != nil {
+			t.Fatalf("explaining %v: %v", query, err)
+		}
+
+		var plan strings.Builder
+		for rows.Next() {
+			var id, parent, notUsed int
+			var detail string
+			if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+				t.Fatalf("scanning plan: %v", err)
+			}
+
+			plan.WriteString(detail + "\n")
+		}
+		rows.Close()
+
+		if !strings.Contains(plan.String(), "INDEX "+index) {
+			t.Errorf("wanted %v to use %v, got %v", query, index, plan.String())
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	appliedVersions := func(t *testing.T, db *database) []int64 {
+		t.Helper()
+
+		rows, err := db.db.Query("SELECT version FROM schema_migrations ORDER BY version")
+		if err != nil {
+			t.Fatalf("querying schema migrations: %v", err)
+		}
+		defer rows.Close()
+
+		var versions []int64
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("scanning schema migration: %v", err)
+			}
+			versions = append(versions, v)
+		}
+
+		return versions
+	}
+
+	t.Run("records every migration by version and is idempotent", func(t *testing.T) {
+		db, err := newDatabase(driverSQLite, sqliteConnectionString(filepath.Join(t.TempDir(), "migrate.db"), defaultBusyTimeout))
+		if err != nil {
+			t.Fatalf("new database: %v", err)
+		}
+		defer db.db.Close()
+
+		migrations, err := db.migrations()
+		if err != nil {
+			t.Fatalf("listing migrations: %v", err)
+		}
+
+		applied := appliedVersions(t, db)
+		if len(applied) != len(migrations) {
+			t.Fatalf("wanted %v applied migrations, got %v", len(migrations), applied)
+		}
+		for i, m := range migrations {
+			if applied[i] != m.version || m.version != int64(i) {
+				t.Errorf("wanted migration %v to have version %v, got %v", m.fileName, i, applied[i])
+			}
+		}
+
+		if err := db.migrate(); err != nil {
+			t.Errorf("wanted migrating again to succeed, got %v", err)
+		} else if again := appliedVersions(t, db); len(again) != len(applied) {
+			t.Errorf("wanted migrating again to apply nothing, got %v", again)
+		}
+	})
+
+	t.Run("carries over migrations recorded in the legacy migrations table", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "legacy.db")
+
+		// arrange a database as it was left by the unversioned migration runner, having applied the first migration
+		legacy, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatalf("opening database: %v", err)
+		}
+		initial, err := migrationFS.ReadFile("migrations/0000-Initial-Migration.sql")
+		if err != nil {
+			t.Fatalf("reading initial migration: %v", err)
+		}
+		for _, q := range []string{
+			"CREATE TABLE migrations (name TEXT PRIMARY KEY)",
+			string(initial),
+			"INSERT INTO migrations (name) VALUES ('migrations/0000-Initial-Migration.sql')",
+		} {
+			if _, err := legacy.Exec(q); err != nil {
+				t.Fatalf("arranging legacy database: %v", err)
+			}
+		}
+		legacy.Close()
+
+		db, err := newDatabase(driverSQLite, sqliteConnectionString(path, defaultBusyTimeout))
+		if err != nil {
+			t.Fatalf("wanted legacy database to migrate, got %v", err)
+		}
+		defer db.db.Close()
+
+		var c int
+		if err := db.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'migrations'").Scan(&c); err != nil {
+			t.Fatalf("querying tables: %v", err)
+		} else if c != 0 {
+			t.Errorf("wanted legacy migrations table to be dropped")
+		}
+
+		migrations, _ := db.migrations()
+		if applied := appliedVersions(t, db); len(applied) != len(migrations) || applied[0] != 0 {
+			t.Errorf("wanted every migration to be recorded, got %v", applied)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package shareasecret
+
+import (
+	"net/http"
+)
+
+// hstsMaxAge is the max-age (in seconds) sent in the Strict-Transport-Security header, equal to two years
+const hstsMaxAge = "63072000"
+
+// securityHeaders wraps the given handler, adding hardening headers to every response. The Strict-Transport-Security
+// header is only sent when the request was served over HTTPS.
+func (a *Application) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", a.config.Server.ContentSecurityPolicy)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("X-Frame-Options", "DENY")
+
+		if requestIsSecure(r) {
+			h.Set("Strict-Transport-Security", "max-age="+hstsMaxAge+"; includeSubDomains")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// noStore prevents the response from being stored by browsers (including their back/forward cache) and any shared
+// caches between them and the application, for pages that reveal secrets or the means to manage them
+func noStore(w http.ResponseWriter) {
+	h := w.Header()
+	h.Set("Cache-Control", "no-store, max-age=0")
+	h.Set("Pragma", "no-cache")
+	h.Set("Expires", "0")
+}
+
+// noIndex asks search engines not to index the response or follow the links within it, should a page only reachable
+// via a shared link be crawled despite robots.txt
+func noIndex(w http.ResponseWriter) {
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+}
+
+// requestIsSecure identifies whether the request was served over HTTPS, either directly or via a trusted reverse proxy
+// that sets the X-Forwarded-Proto header
+func requestIsSecure(r *http.Request) bool {
+	return r.TLS != nil || (clientFrom(r).proxied && r.Header.Get("X-Forwarded-Proto") == "https")
+}
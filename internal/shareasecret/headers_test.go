@@ -0,0 +1,93 @@
+package shareasecret
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := app.securityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP
+
+	t.Run("sets hardening headers", func(t *testing.T) {
+		r := get(t, handler, emptyRequestConfigurer)
+
+		want := map[string]string{
+			"Content-Security-Policy": defaultContentSecurityPolicy,
+			"X-Content-Type-Options":  "nosniff",
+			"Referrer-Policy":         "no-referrer",
+			"X-Frame-Options":         "DENY",
+		}
+		for k, v := range want {
+			if h := r.headers.Get(k); h != v {
+				t.Errorf("wanted %v header to be %v, got %v", k, v, h)
+			}
+		}
+
+		if h := r.headers.Get("Strict-Transport-Security"); h != "" {
+			t.Errorf("did not expect Strict-Transport-Security header when not served over https")
+		}
+	})
+
+	t.Run("sets strict transport security header when served over https", func(t *testing.T) {
+		r := get(t, handler, func(r *http.Request) { r.Header.Set("X-Forwarded-Proto", "https") })
+
+		if h := r.headers.Get("Strict-Transport-Security"); h == "" {
+			t.Errorf("expected Strict-Transport-Security header to be present")
+		}
+	})
+}
+
+func TestNoStore(t *testing.T) {
+	_, managementID := createSecret(t, time.Time{}, "")
+
+	handlers := map[string]http.HandlerFunc{
+		"manage": func(w http.ResponseWriter, r *http.Request) {
+			r.SetPathValue("managementID", managementID)
+			app.handleManageSecret(w, r)
+		},
+		"view": func(w http.ResponseWriter, r *http.Request) {
+			r.SetPathValue("accessID", "unknown")
+			r.SetPathValue("viewingKey", "unknown")
+			app.handleAccessSecret(w, r)
+		},
+	}
+
+	want := map[string]string{"Cache-Control": "no-store, max-age=0", "Pragma": "no-cache", "Expires": "0"}
+
+	for name, handler := range handlers {
+		r := get(t, handler, emptyRequestConfigurer)
+
+		for k, v := range want {
+			if h := r.headers.Get(k); h != v {
+				t.Errorf("%v: wanted %v header to be %v, got %v", name, k, v, h)
+			}
+		}
+	}
+}
+
+func TestNoIndex(t *testing.T) {
+	accessID, managementID := createSecret(t, time.Time{}, "")
+
+	handlers := map[string]http.HandlerFunc{
+		"interstitial": func(w http.ResponseWriter, r *http.Request) {
+			r.SetPathValue("accessID", accessID)
+			app.handleAccessSecretInterstitial(w, r)
+		},
+		"manage": func(w http.ResponseWriter, r *http.Request) {
+			r.SetPathValue("managementID", managementID)
+			app.handleManageSecret(w, r)
+		},
+		"view": func(w http.ResponseWriter, r *http.Request) {
+			r.SetPathValue("accessID", accessID)
+			r.SetPathValue("viewingKey", "unknown")
+			app.handleAccessSecret(w, r)
+		},
+	}
+
+	for name, handler := range handlers {
+		if h := get(t, handler, emptyRequestConfigurer).headers.Get("X-Robots-Tag"); h != "noindex, nofollow" {
+			t.Errorf("%v: wanted X-Robots-Tag header to be noindex, nofollow, got %v", name, h)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// healthCheckTimeout is the maximum amount of time a health check waits for the database to respond
+const healthCheckTimeout = 2 * time.Second
+
+// healthResponse is the JSON body returned from the health check endpoints
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// handleHealthz reports whether the application is alive, which it considers itself to be if it can reach the database
+func (a *Application) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !a.databaseIsReachable(r) {
+		writeJSON(healthResponse{Status: "database unreachable"}, http.StatusServiceUnavailable, w)
+		return
+	}
+
+	writeJSON(healthResponse{Status: "ok"}, http.StatusOK, w)
+}
+
+// handleReadyz reports whether the application is ready to serve traffic, which it considers itself to be if it can
+// reach the database and the background job that deletes expired secrets is running
+func (a *Application) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !a.databaseIsReachable(r) {
+		writeJSON(healthResponse{Status: "database unreachable"}, http.StatusServiceUnavailable, w)
+		return
+	} else if !a.deleteExpiredSecretsJobRunning.Load() {
+		writeJSON(healthResponse{Status: "delete expired secrets job not running"}, http.StatusServiceUnavailable, w)
+		return
+	}
+
+	writeJSON(healthResponse{Status: "ok"}, http.StatusOK, w)
+}
+
+// databaseIsReachable pings the secret store's database, logging any failure to do so
+func (a *Application) databaseIsReachable(r *http.Request) bool {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	if err := a.store.Ping(ctx); err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Msg("pinging database")
+		return false
+	}
+
+	return true
+}
+
+// isHealthCheck identifies whether the request is to one of the health check endpoints, which are polled frequently
+// and would otherwise drown out other requests in the access log
+func isHealthCheck(r *http.Request) bool {
+	return r.URL.Path == "/healthz" || r.URL.Path == "/readyz"
+}
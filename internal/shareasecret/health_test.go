@@ -0,0 +1,33 @@
+package shareasecret
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthChecks(t *testing.T) {
+	t.Run("healthz is ok when the database is reachable", func(t *testing.T) {
+		if r := get(t, app.handleHealthz, emptyRequestConfigurer); r.statusCode != 200 {
+			t.Errorf("wanted 200 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("readyz is unavailable until the delete expired secrets job is running", func(t *testing.T) {
+		until(t, func() bool { return !app.deleteExpiredSecretsJobRunning.Load() }, 10, 10*time.Millisecond)
+
+		if r := get(t, app.handleReadyz, emptyRequestConfigurer); r.statusCode != 503 {
+			t.Errorf("wanted 503 status code, got %v", r.statusCode)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		app.RunDeleteExpiredSecretsJob(ctx)
+
+		if r := get(t, app.handleReadyz, emptyRequestConfigurer); r.statusCode != 200 {
+			t.Errorf("wanted 200 status code, got %v", r.statusCode)
+		}
+
+		cancel()
+		until(t, func() bool { return !app.deleteExpiredSecretsJobRunning.Load() }, 10, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,306 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultLanguage is the language used when a visitor has no preference for a supported language, and the language
+// messages missing from other languages fall back to
+const defaultLanguage = "en"
+
+// languageCookieName is the name of the cookie visitors can set to override the language chosen from their browser
+const languageCookieName = "lang"
+
+// languageContextKey is the context key the language chosen for the current request is stored under
+type languageContextKey struct{}
+
+// messages is the catalog of user facing messages, keyed by language and then by message key. Messages containing
+// formatting verbs are formatted with [fmt.Sprintf] by the caller.
+var messages = map[string]map[string]string{
+	"en": {
+		"layout.title":                 "share encrypted secrets with others",
+		"layout.description":           "Client-side encrypted, time limited, opening count restricted shareable links.",
+		"layout.home":                  "navigate to landing page",
+		"layout.support":               "support",
+		"flash.passphraseRateLimited":  "Too many passphrase attempts. Please wait a moment and try again.",
+		"flash.passphraseIncorrect":    "Incorrect passphrase. Please try again.",
+		"flash.captchaFailed":          "Please complete the challenge to open the secret.",
+		"flash.viewNotFound":           "Secret does not exist, has been deleted, or the unique viewing key you attempted to use has been used before.",
+		"flash.secretNotFound":         "Secret does not exist.",
+		"flash.secretDeleted":          "Secret successfully deleted.",
+		"flash.secretAlreadyDeleted":   "Secret was already deleted or not found.",
+		"flash.viewingLinkRotated":     "Viewing URL regenerated. The previous viewing URL no longer works.",
+		"flash.ttlUpdated":             "Expiry updated.",
+		"flash.ttlInvalid":             "Enter the number of minutes from now the secret should expire in.",
+		"flash.ttlTooShort":            "TTL (time to live) is too short. Secrets must live for at least %v minutes.",
+		"flash.ttlTooLong":             "TTL (time to live) is too long. Secrets must expire within %v minutes of being created.",
+		"flash.ttlBeforeViewable":      "Secrets must expire after the time they become viewable.",
+		"flash.recipientEmailed":       "Viewing URL emailed.",
+		"flash.recipientsDisabled":     "Emailing viewing URLs is not enabled on this instance.",
+		"flash.recipientInvalid":       "Enter the email address to send the viewing URL to.",
+		"flash.recipientNotPermitted":  "Viewing URLs cannot be emailed to that address.",
+		"flash.recipientRateLimited":   "Too many viewing URLs have been emailed. Please wait a moment and try again.",
+		"flash.bundleDeleted":          "Bundle successfully deleted.",
+		"flash.bundleNotFound":         "Bundle not found.",
+		"flash.secretUnavailable":      "Secret does not exist or has been deleted.",
+		"flash.recoveryTokenRequired":  "Enter the recovery token your secrets were created with.",
+		"flash.secretsRevoked":         "%v secret(s) revoked.",
+		"flash.defaultTTLApplied":      "No expiry was given, so the secret expires after the default of %v minutes.",
+		"invalid.requestBody":          "Unable to parse request body. Please try again.",
+		"invalid.requestForm":          "Unable to parse request form. Please try again.",
+		"invalid.secretTooLarge":       "Secret is too large. Secrets must be %v bytes or fewer once encrypted.",
+		"invalid.cipherFormat":         "Secret format is invalid. It should consist of a version segment (v1 or v2) followed by the base64 encoded segments of that version, separated by full stops.",
+		"invalid.expiryInPast":         "Expiry time must be in the future.",
+		"invalid.expiry":               "Unable to parse the expiry time of the secret.",
+		"invalid.ttlPreset":            "Unable to parse the TTL (time to live) preset for the secret.",
+		"invalid.ttl":                  "Unable to parse the TTL (time to live) for the secret.",
+		"invalid.ttlNegative":          "TTL (time to live) must not be negative.",
+		"invalid.ttlTooLong":           "TTL (time to live) is too long. Secrets must expire within %v minutes.",
+		"invalid.ttlTooShort":          "TTL (time to live) is too short. Secrets must live for at least %v minutes.",
+		"invalid.neverExpire":          "Secrets that never expire are not permitted. Choose a TTL (time to live) of at least 1 minute.",
+		"invalid.maxViews":             "Unable to parse the maximum views permitted for the secret.",
+		"invalid.burnAfterReading":     "Unable to parse the burn after reading option for the secret.",
+		"invalid.revealDelay":          "Unable to parse the reveal delay of the secret.",
+		"invalid.revealDelayRange":     "Reveal delay must be between 0 and %v seconds.",
+		"invalid.notBefore":            "Unable to parse the time the secret becomes viewable.",
+		"invalid.viewableAfterExpiry":  "The time the secret becomes viewable must be before it expires.",
+		"invalid.passphraseTooLong":    "Passphrase must be 72 bytes or fewer.",
+		"invalid.webhook":              "Webhook must be a public http or https URL.",
+		"invalid.emailsDisabled":       "Email notifications are not enabled on this instance.",
+		"invalid.notifyEmail":          "Notification email address is invalid.",
+		"invalid.labelTooLong":         "Label is too long. Labels must be %v characters or fewer.",
+		"invalid.recoveryTokenShort":   "Recovery token is too short. Recovery tokens must be %v characters or more.",
+		"invalid.bundleTooLarge":       "Too many secrets. Bundles can contain %v secrets or fewer.",
+		"invalid.bundleSecret":         "Secret %v: %v",
+		"invalid.titleTooLong":         "Secret %v: Title is too long. Titles must be %v characters or fewer.",
+		"warning.burnedAfterReading":   "This secret was burned after reading. It will not be accessible again.",
+		"warning.maximumViewsReached":  "Maximum views reached. This secret will not be accessible again.",
+		"bundle.title":                 "shared secrets",
+		"bundle.body":                  "the following secrets have been shared with you. each is opened separately, using a view of that secret alone.",
+		"bundle.open":                  "Open",
+		"bundle.untitled":              "Untitled secret",
+		"bundle.unavailable":           "no longer available",
+		"interstitial.title":           "open secret",
+		"interstitial.body":            "by clicking the button below and progressing you will add a view of the secret. if your view is then equal to the maximum amount of views this secret permits, it will be deleted and will not be viewable for anyone but you in your current session",
+		"interstitial.passphrase":      "the creator of this secret has protected it with a passphrase. enter it below to open the secret. this is not the same as the encryption key.",
+		"interstitial.tracked":         "the creator of this secret logs each view of it, recording the time, your browser (user agent) and a hash of your IP address.",
+		"interstitial.passphraseLabel": "Passphrase:",
+		"interstitial.submit":          "Open Secret",
+		"view.title":                   "view secret",
+		"view.body":                    "enter the encryption key originally used to encrypt this secret to reverse the encrypted cipher text back to its plaintext form.",
+		"view.unknownKey":              "if you don't know what the encryption key is/was, get the sender of this link to tell you again. if they don't know it, then they'll need to create a new secret with a new password.",
+		"view.secretLabel":             "Secret:",
+		"view.keyLabel":                "Encryption Key:",
+		"view.submit":                  "Decrypt",
+		"view.receipt":                 "You have viewed this secret; it is now destroyed. Reloading this page will not show it again, so decrypt it and keep it somewhere safe before leaving.",
+		"revoke.title":                 "revoke secrets",
+		"revoke.body":                  "suspect your secrets have been compromised? enter the recovery token you created them with to delete every one of them that hasn't already been deleted. they will no longer be able to be viewed by anyone.",
+		"revoke.tokenLabel":            "Recovery token:",
+		"revoke.submit":                "Revoke all secrets",
+		"mySecrets.title":              "my secrets",
+		"mySecrets.body":               "the secrets created in this browser with the option to list them here. only the links to manage them are remembered, so they can only be opened by whoever they were shared with.",
+		"mySecrets.empty":              "no secrets have been created in this browser with the option to list them here.",
+		"mySecrets.secret":             "Secret",
+		"mySecrets.created":            "Created",
+		"mySecrets.views":              "Views",
+		"mySecrets.state":              "State",
+		"mySecrets.unlabelled":         "Unlabelled secret",
+		"mySecrets.available":          "available",
+		"nojs.title":                   "javascript is required",
+		"indexNojs.title":              "create a secret",
+		"indexNojs.body":               "secrets are encrypted in your browser using javascript before they are sent to the server, and javascript appears to be unavailable or disabled. enable it and reload this page to create a secret.",
+		"indexNojs.link":               "why is javascript required?",
+		"nojs.body":                    "the core component of this application (secrets) relies completely on client side encryption enabled by javascript. thus, if your browser does not support JavaScript or if you have it disabled, you will not be able to continue.",
+		"oops.title":                   "oops - something broke",
+		"oops.body":                    "something went wrong. if you were performing an action when this error occurred; try again. if you keep experiencing the same error contact the administrator of this instance.",
+		"oops.reference":               "please include the following reference when doing so:",
+		"oops.retry":                   "try again",
+		"notFound.title":               "not found",
+		"notFound.body":                "the page you were looking for doesn't exist. if you were given a link to a secret, check it was copied in its entirety and try again.",
+		"alreadyViewed.title":          "already viewed",
+		"alreadyViewed.body":           "This secret was one-time and has already been viewed. if you were expecting to view it, ask whoever shared it with you to share it again.",
+	},
+	"es": {
+		"layout.title":                 "comparte secretos cifrados con otras personas",
+		"layout.description":           "Enlaces compartibles cifrados en el navegador, con caducidad y un número limitado de aperturas.",
+		"layout.home":                  "ir a la página de inicio",
+		"layout.support":               "soporte",
+		"flash.passphraseRateLimited":  "Demasiados intentos de frase de contraseña. Espera un momento y vuelve a intentarlo.",
+		"flash.passphraseIncorrect":    "Frase de contraseña incorrecta. Vuelve a intentarlo.",
+		"flash.captchaFailed":          "Completa el desafío para abrir el secreto.",
+		"flash.viewNotFound":           "El secreto no existe, ha sido eliminado, o la clave de visualización única que intentaste usar ya se ha utilizado.",
+		"flash.secretNotFound":         "El secreto no existe.",
+		"flash.secretDeleted":          "Secreto eliminado correctamente.",
+		"flash.secretAlreadyDeleted":   "El secreto ya se había eliminado o no se encontró.",
+		"flash.viewingLinkRotated":     "URL de visualización regenerada. La URL anterior ya no funciona.",
+		"flash.ttlUpdated":             "Caducidad actualizada.",
+		"flash.ttlInvalid":             "Introduce dentro de cuántos minutos debe caducar el secreto.",
+		"flash.ttlTooShort":            "El TTL (tiempo de vida) es demasiado corto. Los secretos deben durar al menos %v minutos.",
+		"flash.ttlTooLong":             "El TTL (tiempo de vida) es demasiado largo. Los secretos deben caducar en un máximo de %v minutos desde su creación.",
+		"flash.ttlBeforeViewable":      "Los secretos deben caducar después del momento en que se pueden ver.",
+		"flash.recipientEmailed":       "URL de visualización enviada por correo.",
+		"flash.recipientsDisabled":     "El envío de URLs de visualización por correo no está habilitado en esta instancia.",
+		"flash.recipientInvalid":       "Introduce la dirección de correo a la que enviar la URL de visualización.",
+		"flash.recipientNotPermitted":  "No se pueden enviar URLs de visualización a esa dirección.",
+		"flash.recipientRateLimited":   "Se han enviado demasiadas URLs de visualización. Espera un momento y vuelve a intentarlo.",
+		"flash.bundleDeleted":          "Paquete eliminado correctamente.",
+		"flash.bundleNotFound":         "Paquete no encontrado.",
+		"flash.secretUnavailable":      "El secreto no existe o ha sido eliminado.",
+		"flash.recoveryTokenRequired":  "Introduce el token de recuperación con el que se crearon tus secretos.",
+		"flash.secretsRevoked":         "%v secreto(s) revocado(s).",
+		"flash.defaultTTLApplied":      "No se indicó una caducidad, por lo que el secreto caduca tras el valor predeterminado de %v minutos.",
+		"invalid.requestBody":          "No se pudo procesar el cuerpo de la solicitud. Vuelve a intentarlo.",
+		"invalid.requestForm":          "No se pudo procesar el formulario. Vuelve a intentarlo.",
+		"invalid.secretTooLarge":       "El secreto es demasiado grande. Los secretos deben ocupar %v bytes o menos una vez cifrados.",
+		"invalid.cipherFormat":         "El formato del secreto no es válido. Debe constar de un segmento de versión (v1 o v2) seguido de los segmentos codificados en base64 de esa versión, separados por puntos.",
+		"invalid.expiryInPast":         "La hora de caducidad debe estar en el futuro.",
+		"invalid.expiry":               "No se pudo interpretar la hora de caducidad del secreto.",
+		"invalid.ttlPreset":            "No se pudo interpretar el TTL (tiempo de vida) predefinido del secreto.",
+		"invalid.ttl":                  "No se pudo interpretar el TTL (tiempo de vida) del secreto.",
+		"invalid.ttlNegative":          "El TTL (tiempo de vida) no puede ser negativo.",
+		"invalid.ttlTooLong":           "El TTL (tiempo de vida) es demasiado largo. Los secretos deben caducar en un máximo de %v minutos.",
+		"invalid.ttlTooShort":          "El TTL (tiempo de vida) es demasiado corto. Los secretos deben durar al menos %v minutos.",
+		"invalid.neverExpire":          "No se permiten secretos que nunca caducan. Elige un TTL (tiempo de vida) de al menos 1 minuto.",
+		"invalid.maxViews":             "No se pudo interpretar el máximo de visualizaciones permitidas del secreto.",
+		"invalid.burnAfterReading":     "No se pudo interpretar la opción de destruir tras la lectura del secreto.",
+		"invalid.revealDelay":          "No se pudo interpretar el retraso de revelado del secreto.",
+		"invalid.revealDelayRange":     "El retraso de revelado debe estar entre 0 y %v segundos.",
+		"invalid.notBefore":            "No se pudo interpretar el momento en que el secreto se puede ver.",
+		"invalid.viewableAfterExpiry":  "El momento en que el secreto se puede ver debe ser anterior a su caducidad.",
+		"invalid.passphraseTooLong":    "La frase de contraseña debe ocupar 72 bytes o menos.",
+		"invalid.webhook":              "El webhook debe ser una URL http o https pública.",
+		"invalid.emailsDisabled":       "Las notificaciones por correo no están habilitadas en esta instancia.",
+		"invalid.notifyEmail":          "La dirección de correo para notificaciones no es válida.",
+		"invalid.labelTooLong":         "La etiqueta es demasiado larga. Las etiquetas deben tener %v caracteres o menos.",
+		"invalid.recoveryTokenShort":   "El token de recuperación es demasiado corto. Los tokens de recuperación deben tener %v caracteres o más.",
+		"invalid.bundleTooLarge":       "Demasiados secretos. Los paquetes pueden contener %v secretos o menos.",
+		"invalid.bundleSecret":         "Secreto %v: %v",
+		"invalid.titleTooLong":         "Secreto %v: El título es demasiado largo. Los títulos deben tener %v caracteres o menos.",
+		"warning.burnedAfterReading":   "Este secreto se ha destruido tras su lectura. No se podrá acceder a él de nuevo.",
+		"warning.maximumViewsReached":  "Se ha alcanzado el máximo de visualizaciones. No se podrá acceder a este secreto de nuevo.",
+		"bundle.title":                 "secretos compartidos",
+		"bundle.body":                  "se han compartido contigo los siguientes secretos. cada uno se abre por separado, usando una visualización solo de ese secreto.",
+		"bundle.open":                  "Abrir",
+		"bundle.untitled":              "Secreto sin título",
+		"bundle.unavailable":           "ya no está disponible",
+		"interstitial.title":           "abrir secreto",
+		"interstitial.body":            "al pulsar el botón de abajo y continuar añadirás una visualización del secreto. si tu visualización alcanza el máximo que permite este secreto, se eliminará y nadie salvo tú podrá verlo en tu sesión actual",
+		"interstitial.passphrase":      "quien creó este secreto lo ha protegido con una frase de contraseña. introdúcela abajo para abrir el secreto. no es lo mismo que la clave de cifrado.",
+		"interstitial.tracked":         "quien creó este secreto registra cada visualización, guardando la hora, tu navegador (user agent) y un hash de tu dirección IP.",
+		"interstitial.passphraseLabel": "Frase de contraseña:",
+		"interstitial.submit":          "Abrir secreto",
+		"view.title":                   "ver secreto",
+		"view.body":                    "introduce la clave de cifrado con la que se cifró este secreto para devolver el texto cifrado a su forma original.",
+		"view.unknownKey":              "si no sabes cuál es la clave de cifrado, pide a quien te envió este enlace que te la diga de nuevo. si no la sabe, tendrá que crear un nuevo secreto con una nueva contraseña.",
+		"view.secretLabel":             "Secreto:",
+		"view.keyLabel":                "Clave de cifrado:",
+		"view.submit":                  "Descifrar",
+		"view.receipt":                 "Has visto este secreto; ahora está destruido. Volver a cargar esta página no lo mostrará de nuevo, así que descífralo y guárdalo en un lugar seguro antes de salir.",
+		"revoke.title":                 "revocar secretos",
+		"revoke.body":                  "¿sospechas que tus secretos se han visto comprometidos? introduce el token de recuperación con el que los creaste para eliminar todos los que aún no se hayan eliminado. nadie podrá volver a verlos.",
+		"revoke.tokenLabel":            "Token de recuperación:",
+		"revoke.submit":                "Revocar todos los secretos",
+		"mySecrets.title":              "mis secretos",
+		"mySecrets.body":               "los secretos creados en este navegador con la opción de mostrarlos aquí. solo se recuerdan los enlaces para gestionarlos, por lo que solo pueden abrirlos las personas con quienes se compartieron.",
+		"mySecrets.empty":              "no se ha creado ningún secreto en este navegador con la opción de mostrarlo aquí.",
+		"mySecrets.secret":             "Secreto",
+		"mySecrets.created":            "Creado",
+		"mySecrets.views":              "Visualizaciones",
+		"mySecrets.state":              "Estado",
+		"mySecrets.unlabelled":         "Secreto sin etiqueta",
+		"mySecrets.available":          "disponible",
+		"nojs.title":                   "se necesita javascript",
+		"indexNojs.title":              "crear un secreto",
+		"indexNojs.body":               "los secretos se cifran en tu navegador mediante javascript antes de enviarse al servidor, y javascript parece no estar disponible o estar desactivado. actívalo y recarga esta página para crear un secreto.",
+		"indexNojs.link":               "¿por qué se necesita javascript?",
+		"nojs.body":                    "el componente principal de esta aplicación (los secretos) depende por completo del cifrado en el navegador mediante javascript. por tanto, si tu navegador no admite JavaScript o lo tienes desactivado, no podrás continuar.",
+		"oops.title":                   "vaya - algo se ha roto",
+		"oops.body":                    "algo ha ido mal. si estabas realizando una acción cuando ocurrió este error, vuelve a intentarlo. si el error persiste, ponte en contacto con quien administra esta instancia.",
+		"oops.reference":               "incluye la siguiente referencia al hacerlo:",
+		"oops.retry":                   "volver a intentarlo",
+		"notFound.title":               "no encontrado",
+		"notFound.body":                "la página que buscabas no existe. si te han dado un enlace a un secreto, comprueba que se copió entero y vuelve a intentarlo.",
+		"alreadyViewed.title":          "ya visto",
+		"alreadyViewed.body":           "Este secreto era de un solo uso y ya ha sido visto. si esperabas verlo, pide a quien te lo compartió que lo vuelva a compartir.",
+	},
+}
+
+// translate returns the message with the given key in the given language, falling back to the default language if the
+// language is unsupported or is missing the message. The key itself is returned if no language has the message.
+func translate(key string, lang string) string {
+	if m, ok := messages[lang][key]; ok {
+		return m
+	} else if m, ok := messages[defaultLanguage][key]; ok {
+		return m
+	}
+
+	return key
+}
+
+// languages wraps the given handler, choosing the supported language each request should be served in and storing it
+// in the context of the request, where it can be retrieved with [language]
+func (a *Application) languages(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Language")
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), languageContextKey{}, languageFromRequest(r))))
+	})
+}
+
+// language retrieves the language chosen for the current request from the context
+func language(ctx context.Context) string {
+	if l, ok := ctx.Value(languageContextKey{}).(string); ok {
+		return l
+	}
+
+	return defaultLanguage
+}
+
+// languageFromRequest chooses the supported language a request should be served in. A supported language in the lang
+// cookie takes precedence, followed by the most preferred supported language in the Accept-Language header.
+func languageFromRequest(r *http.Request) string {
+	if c, err := r.Cookie(languageCookieName); err == nil {
+		if _, ok := messages[c.Value]; ok {
+			return c.Value
+		}
+	}
+
+	type preference struct {
+		lang    string
+		quality float64
+	}
+
+	var preferences []preference
+	for _, v := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(v), ";")
+
+		// only the primary subtag (i.e. es of es-MX) is used to choose between supported languages
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := messages[lang]; !ok {
+			continue
+		}
+
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && k == "q" {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		if q > 0 {
+			preferences = append(preferences, preference{lang, q})
+		}
+	}
+
+	if len(preferences) == 0 {
+		return defaultLanguage
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].quality > preferences[j].quality })
+
+	return preferences[0].lang
+}
@@ -0,0 +1,98 @@
+package shareasecret
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	t.Run("every language has the messages of the default language", func(t *testing.T) {
+		for lang, m := range messages {
+			for k := range messages[defaultLanguage] {
+				if _, ok := m[k]; !ok {
+					t.Errorf("wanted %v to have message %v", lang, k)
+				}
+			}
+		}
+	})
+
+	tests := []struct {
+		name string
+		key  string
+		lang string
+		want string
+	}{
+		{"translates into the language", "view.submit", "es", "Descifrar"},
+		{"falls back to the default language when the language is unsupported", "view.submit", "xx", "Decrypt"},
+		{"returns the key when no language has the message", "missing.key", "es", "missing.key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := translate(tt.key, tt.lang); got != tt.want {
+				t.Errorf("wanted %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("falls back to the default language when the language is missing the message", func(t *testing.T) {
+		messages[defaultLanguage]["test.onlyEnglish"] = "english"
+		t.Cleanup(func() { delete(messages[defaultLanguage], "test.onlyEnglish") })
+
+		if got := translate("test.onlyEnglish", "es"); got != "english" {
+			t.Errorf("wanted english, got %v", got)
+		}
+	})
+}
+
+func TestLanguageFromRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		cookie         string
+		want           string
+	}{
+		{"defaults when nothing is set", "", "", "en"},
+		{"uses the primary subtag of the accept language header", "es-MX", "", "es"},
+		{"uses the most preferred supported language", "fr;q=1, en;q=0.5, es;q=0.8", "", "es"},
+		{"ignores languages that are not acceptable", "es;q=0, en;q=0.1", "", "en"},
+		{"defaults when no language is supported", "fr, de", "", "en"},
+		{"prefers the cookie over the header", "en", "es", "es"},
+		{"ignores unsupported cookie values", "es", "xx", "es"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: languageCookieName, Value: tt.cookie})
+			}
+
+			if got := languageFromRequest(r); got != tt.want {
+				t.Errorf("wanted %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	r := get(t, app.ServeHTTP, func(r *http.Request) {
+		r.URL.Path = "/nojs"
+		r.Method = http.MethodGet
+		r.Header.Set("Accept-Language", "es")
+	})
+
+	if !strings.Contains(r.body, translate("nojs.title", "es")) {
+		t.Errorf("expected page to be translated, got %v", r.body)
+	}
+	if !strings.Contains(r.body, `lang="es"`) {
+		t.Errorf("expected html lang attribute to be es")
+	}
+	if h := r.headers.Values("Vary"); !strings.Contains(strings.Join(h, ","), "Accept-Language") {
+		t.Errorf("wanted Vary header to include Accept-Language, got %v", h)
+	}
+}
@@ -0,0 +1,108 @@
+package shareasecret
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+)
+
+// managementIDSize is the number of random bytes in the management ID of every secret and bundle
+const managementIDSize = 24
+
+// wellFormedIDs wraps the given handler, responding with the malformed function instead of calling it when the access
+// or management ID in the request path could never have been generated by this instance. Requests for made up or
+// mistyped IDs are therefore turned away without a database lookup, using the same response as any other ID that
+// doesn't exist.
+func (a *Application) wellFormedIDs(next http.HandlerFunc, malformed http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if id := r.PathValue("accessID"); id != "" && !a.wellFormedViewingID(id) {
+			malformed(w, r)
+			return
+		} else if id := r.PathValue("managementID"); id != "" && !wellFormedID(id, viewingIDEncodingHex, managementIDSize) {
+			malformed(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// wellFormedViewingID identifies whether the viewing ID is in either encoding and of any size from the minimum up to
+// the configured (or default, if larger) size, so that links created before either setting was changed keep working
+func (a *Application) wellFormedViewingID(id string) bool {
+	for size := minimumViewingIDSize; size <= max(a.config.Secrets.ViewingIDSize, defaultViewingIDSize); size++ {
+		if wellFormedID(id, viewingIDEncodingHex, size) || wellFormedID(id, viewingIDEncodingBase64URL, size) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wellFormedID identifies whether the ID is of the length and alphabet that [secureID] (for the hex encoding) or
+// [secureSlug] (for the base64url encoding) generates for the given size, without decoding it
+func wellFormedID(id string, encoding string, size int) bool {
+	if encoding == viewingIDEncodingBase64URL {
+		if len(id) != base64.RawURLEncoding.EncodedLen(size) {
+			return false
+		}
+
+		for _, c := range []byte(id) {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if len(id) != hex.EncodedLen(size) {
+		return false
+	}
+
+	for _, c := range []byte(id) {
+		if !(c >= 'a' && c <= 'f' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apiNotFound responds to API requests for secrets that don't exist
+func apiNotFound(w http.ResponseWriter, r *http.Request) {
+	apiError("not found", http.StatusNotFound, w)
+}
+
+// plainNotFound responds to requests for the raw content of secrets that don't exist
+func plainNotFound(w http.ResponseWriter, r *http.Request) {
+	plainError("not found", http.StatusNotFound, w)
+}
+
+// redirectManagedSecretNotFound redirects the creator to the index page, explaining that the secret they are
+// attempting to manage doesn't exist
+func redirectManagedSecretNotFound(w http.ResponseWriter, r *http.Request) {
+	setFlashErr(translate("flash.secretNotFound", language(r.Context())), w, r)
+	safeRedirect(w, r, pathFor(r.Context(), "/"))
+}
+
+// redirectManagedBundleNotFound redirects the creator to the index page, explaining that the bundle they are
+// attempting to manage doesn't exist
+func redirectManagedBundleNotFound(w http.ResponseWriter, r *http.Request) {
+	setFlashErr(translate("flash.bundleNotFound", language(r.Context())), w, r)
+	safeRedirect(w, r, pathFor(r.Context(), "/"))
+}
+
+// viewNotFound responds to requests for views of secrets that don't exist, as JSON to clients that prefer it
+func viewNotFound(w http.ResponseWriter, r *http.Request) {
+	noStore(w)
+	w.Header().Add("Vary", "Accept")
+
+	if prefersJSON(r) {
+		apiError("not found", http.StatusNotFound, w)
+		return
+	}
+
+	setFlashErr(translate("flash.viewNotFound", language(r.Context())), w, r)
+	safeRedirect(w, r, pathFor(r.Context(), "/"))
+}
@@ -0,0 +1,94 @@
+package shareasecret
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWellFormedID(t *testing.T) {
+	hexID, _ := secureID(24)
+	slug, _ := secureSlug(24)
+
+	tests := []struct {
+		id       string
+		encoding string
+		size     int
+		want     bool
+	}{
+		{hexID, viewingIDEncodingHex, 24, true},
+		{hexID, viewingIDEncodingHex, 16, false},
+		{strings.ToUpper(hexID), viewingIDEncodingHex, 24, false},
+		{hexID[:47] + "g", viewingIDEncodingHex, 24, false},
+		{slug, viewingIDEncodingBase64URL, 24, true},
+		{slug, viewingIDEncodingHex, 24, false},
+		{slug[:31] + "=", viewingIDEncodingBase64URL, 24, false},
+		{slug[:31] + "/", viewingIDEncodingBase64URL, 24, false},
+		{hexID, viewingIDEncodingBase64URL, 24, false},
+		{"", viewingIDEncodingHex, 24, false},
+	}
+
+	for _, tt := range tests {
+		if got := wellFormedID(tt.id, tt.encoding, tt.size); got != tt.want {
+			t.Errorf("%q (%v, %v): wanted %v, got %v", tt.id, tt.encoding, tt.size, tt.want, got)
+		}
+	}
+}
+
+func TestWellFormedIDs(t *testing.T) {
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+
+		return recorder
+	}
+
+	t.Run("responds to malformed ids as it does to ids that don't exist", func(t *testing.T) {
+		unknown, _ := secureID(24)
+
+		for _, id := range []string{"not-an-id", strings.Repeat("z", 48), unknown + "0"} {
+			if r := serve("GET", "/api/secrets/"+id); r.Code != 404 || !strings.Contains(r.Body.String(), "not found") {
+				t.Errorf("%v: wanted api not found response, got %v: %v", id, r.Code, r.Body.String())
+			}
+			if r := serve("GET", "/api/manage/"+id); r.Code != 404 || !strings.Contains(r.Body.String(), "not found") {
+				t.Errorf("%v: wanted api not found response, got %v: %v", id, r.Code, r.Body.String())
+			}
+			if r := serve("GET", "/secret/"+id); r.Code != 303 || r.Header().Get("Location") != "/" {
+				t.Errorf("%v: wanted redirect to index page, got %v to %v", id, r.Code, r.Header().Get("Location"))
+			}
+			if r := serve("GET", "/manage-secret/"+id); r.Code != 303 || r.Header().Get("Location") != "/" {
+				t.Errorf("%v: wanted redirect to index page, got %v to %v", id, r.Code, r.Header().Get("Location"))
+			}
+		}
+
+		for _, r := range []*httptest.ResponseRecorder{serve("GET", "/api/secrets/"+unknown), serve("GET", "/api/manage/"+unknown)} {
+			if r.Code != 404 {
+				t.Errorf("wanted well formed but unknown id to be looked up and not found, got %v", r.Code)
+			}
+		}
+	})
+
+	t.Run("accepts ids of either encoding up to the configured size", func(t *testing.T) {
+		app.config.Secrets.ViewingIDEncoding = viewingIDEncodingBase64URL
+		app.config.Secrets.ViewingIDSize = 32
+		defer func() {
+			app.config.Secrets.ViewingIDEncoding = viewingIDEncodingHex
+			app.config.Secrets.ViewingIDSize = defaultViewingIDSize
+		}()
+
+		for _, size := range []int{minimumViewingIDSize, defaultViewingIDSize, 32} {
+			slug, _ := secureSlug(size)
+			hexID, _ := secureID(size)
+
+			if !app.wellFormedViewingID(slug) || !app.wellFormedViewingID(hexID) {
+				t.Errorf("wanted ids of %v bytes in either encoding to be well formed", size)
+			}
+		}
+
+		tooShort, _ := secureSlug(minimumViewingIDSize - 1)
+		tooLong, _ := secureID(33)
+		if app.wellFormedViewingID(tooShort) || app.wellFormedViewingID(tooLong) {
+			t.Errorf("wanted ids outside of the permitted sizes to be malformed")
+		}
+	})
+}
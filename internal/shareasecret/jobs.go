@@ -1,59 +1,126 @@
 package shareasecret
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-// RunDeleteExpiredSecretsJob runs a background job that identifies expired secrets and removes them accordingly
-func (a *Application) RunDeleteExpiredSecretsJob() {
+// RunDeleteExpiredSecretsJob runs a background job that identifies expired secrets and removes them accordingly. The
+// job stops once the given context is cancelled.
+func (a *Application) RunDeleteExpiredSecretsJob(ctx context.Context) {
 	runJobInBackground(
+		ctx,
+		&a.jobs,
+		&a.deleteExpiredSecretsJobRunning,
 		"delete_expired_secrets",
 		func(l zerolog.Logger) error {
-			rows, err := a.db.db.Exec(
-				`
-					UPDATE
-						secrets
-					SET
-						deleted_at = ?1,
-						deletion_reason = ?2,
-						cipher_text = NULL
-					WHERE
-						(created_at + (ttl * 60 * 1000)) <= ?1 AND
-						deleted_at IS NULL
-				`,
-				time.Now().UnixMilli(),
-				deletionReasonExpired,
-			)
-			if err != nil {
-				return err
-			}
+			_, err := a.deleteExpiredSecrets(ctx, l)
+			return err
+		},
+		a.config.Jobs.DeleteExpiredSecretsInterval,
+	)
+}
 
-			c, err := rows.RowsAffected()
-			if err != nil {
-				return err
-			}
+// PruneResult describes the secrets affected by [Application.Prune]
+type PruneResult struct {
+	// Expired is the number of secrets deleted as they had expired
+	Expired int
+	// Purged is the number of deleted secrets removed entirely as their retention period had passed
+	Purged int64
+}
 
-			l.Info().Int64("deleted_secrets", c).Msg("deleted expired secrets")
+// Prune deletes expired secrets and purges deleted secrets past their retention period once, exactly as the
+// background job started by [Application.RunDeleteExpiredSecretsJob] does, for operators to run from cron or after
+// changing the retention period. Notifications of the expired secrets are sent before the resources of the application
+// are released, so the application cannot be used once it has been pruned.
+func (a *Application) Prune(ctx context.Context) (PruneResult, error) {
+	result, err := a.deleteExpiredSecrets(ctx, log.With().Str("job_name", "prune").Logger())
 
-			return nil
-		},
-		1*time.Minute,
-	)
+	a.webhooks.wait()
+	a.emails.wait()
+
+	if closeErr := a.store.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("closing database: %w", closeErr)
+	}
+	if closeErr := a.state.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("closing shared state: %w", closeErr)
+	}
+	if closeErr := a.shutdownTracing(ctx); closeErr != nil && err == nil {
+		err = fmt.Errorf("shutting down tracing: %w", closeErr)
+	}
+
+	return result, err
+}
+
+// deleteExpiredSecrets deletes the secrets that have expired, notifying their creators, before purging deleted secrets
+// that have passed the configured retention period
+func (a *Application) deleteExpiredSecrets(ctx context.Context, l zerolog.Logger) (PruneResult, error) {
+	var result PruneResult
+
+	swept, err := a.store.SweepExpired(ctx, time.Now())
+	if err != nil {
+		return result, err
+	}
+
+	for _, s := range swept {
+		a.notifySecretExpired(s)
+
+		e := secretEvent{accessID: s.accessID, eventType: eventExpired, occurredAt: time.Now().UnixMilli()}
+		if err := a.store.RecordEvent(ctx, e); err != nil {
+			l.Err(err).Str("event_type", e.eventType).Msg("recording secret event")
+		}
+	}
+
+	result.Expired = len(swept)
+
+	l.Info().Int("deleted_secrets", len(swept)).Msg("deleted expired secrets")
+	a.metrics.secretsDeleted(deletionReasonExpired, int64(len(swept)))
+
+	// deleted secrets are kept (without their cipher text) for their creators to see why they were deleted, until
+	// the retention period passes and even their metadata is removed
+	if retention := a.config.Jobs.DeletedSecretRetention; retention > 0 {
+		purged, err := a.store.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			return result, fmt.Errorf("purging deleted secrets: %w", err)
+		}
+
+		result.Purged = purged
+
+		l.Info().Int64("purged_secrets", purged).Dur("retention", retention).Msg("purged deleted secrets")
+	}
+
+	return result, nil
 }
 
 // runJobInBackground runs the given function in a coroutine, recovering from any panics and repeating continuously,
-// pausing for the specified duration after every run
-func runJobInBackground(name string, f func(l zerolog.Logger) error, every time.Duration) {
+// pausing for the specified duration after every run until the context is cancelled. The running flag is set whilst
+// the job is running and the wait group is marked as done once the job has stopped.
+func runJobInBackground(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	running *atomic.Bool,
+	name string,
+	f func(l zerolog.Logger) error,
+	every time.Duration,
+) {
+	wg.Add(1)
+	running.Store(true)
+
 	go func() {
+		defer wg.Done()
+		defer running.Store(false)
+
+		l := log.With().Str("job_name", name).Logger()
+
 		for {
 			// this is annoying, but the only way to recover and carry on
 			func() {
-				l := log.With().Str("job_name", name).Logger()
-
 				defer func() {
 					if err := recover(); err != nil {
 						l.Err(fmt.Errorf("recover: %v", err)).Msg("recover")
@@ -67,9 +134,14 @@ func runJobInBackground(name string, f func(l zerolog.Logger) error, every time.
 				}
 
 				l.Debug().Msg("executed job")
-
-				<-time.After(every)
 			}()
+
+			select {
+			case <-ctx.Done():
+				l.Debug().Msg("stopping job")
+				return
+			case <-time.After(every):
+			}
 		}
 	}()
 }
@@ -1,7 +1,9 @@
 package shareasecret
 
 import (
+	"context"
 	"database/sql"
+	"os"
 	"testing"
 	"time"
 )
@@ -10,23 +12,30 @@ func TestDeleteExpiredSecretsJob(t *testing.T) {
 	t.Run("deletes secret that should have expired", func(t *testing.T) {
 		accessID, _ := createSecret(t, time.Time{}, "")
 
-		_, err := app.db.db.Exec(
+		_, err := testDB().Exec(
 			"UPDATE secrets SET ttl = 1, created_at = ? WHERE access_id = ?",
-			time.Now().Add(-2*time.Minute),
+			time.Now().Add(-2*time.Minute).UnixMilli(),
 			accessID,
 		)
 		if err != nil {
 			t.Errorf("updating secret TTL: %v", err)
 		}
 
-		app.RunDeleteExpiredSecretsJob()
+		// the job is stopped before the next subtest starts, as they change the configuration it reads
+		ctx, cancel := context.WithCancel(context.Background())
+		defer func() {
+			cancel()
+			app.jobs.Wait()
+		}()
+
+		app.RunDeleteExpiredSecretsJob(ctx)
 
 		until(
 			t,
 			func() bool {
 				var deletedAt sql.NullInt64
 
-				err := app.db.db.QueryRow("SELECT deleted_at FROM secrets WHERE access_id = ?", accessID).Scan(&deletedAt)
+				err := testDB().QueryRow("SELECT deleted_at FROM secrets WHERE access_id = ?", accessID).Scan(&deletedAt)
 				if err != nil {
 					t.Errorf("querying secret: %v", err)
 				}
@@ -36,5 +45,113 @@ func TestDeleteExpiredSecretsJob(t *testing.T) {
 			10,
 			5*time.Millisecond,
 		)
+
+		until(
+			t,
+			func() bool {
+				var events int
+
+				err := testDB().QueryRow(
+					"SELECT COUNT(1) FROM secret_events e INNER JOIN secrets s ON s.id = e.secret_id WHERE s.access_id = ? AND e.event_type = ?",
+					accessID,
+					eventExpired,
+				).Scan(&events)
+				if err != nil {
+					t.Errorf("querying secret events: %v", err)
+				}
+
+				return events == 1
+			},
+			10,
+			5*time.Millisecond,
+		)
+	})
+
+	t.Run("purges secrets deleted before the retention period", func(t *testing.T) {
+		app.config.Jobs.DeletedSecretRetention = time.Hour
+		defer func() { app.config.Jobs.DeletedSecretRetention = 0 }()
+
+		purgedAccessID, _ := createSecret(t, time.Now().Add(-2*time.Hour), deletionReasonUserDeleted)
+		keptAccessID, _ := createSecret(t, time.Now().Add(-30*time.Minute), deletionReasonUserDeleted)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer func() {
+			cancel()
+			app.jobs.Wait()
+		}()
+
+		app.RunDeleteExpiredSecretsJob(ctx)
+
+		count := func(accessID string) int {
+			var c int
+
+			if err := testDB().QueryRow("SELECT COUNT(1) FROM secrets WHERE access_id = ?", accessID).Scan(&c); err != nil {
+				t.Errorf("querying secret: %v", err)
+			}
+
+			return c
+		}
+
+		until(t, func() bool { return count(purgedAccessID) == 0 }, 10, 5*time.Millisecond)
+
+		if count(keptAccessID) != 1 {
+			t.Errorf("wanted secret deleted within the retention period to be kept")
+		}
+	})
+
+	t.Run("does not delete secrets without a ttl", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec(
+			"UPDATE secrets SET ttl = 0, created_at = ? WHERE access_id = ?",
+			time.Now().Add(-48*time.Hour).UnixMilli(),
+			accessID,
+		)
+		if err != nil {
+			t.Errorf("updating secret TTL: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		app.RunDeleteExpiredSecretsJob(ctx)
+		<-time.After(20 * time.Millisecond)
+		cancel()
+		app.jobs.Wait()
+
+		var deletedAt sql.NullInt64
+
+		err = testDB().QueryRow("SELECT deleted_at FROM secrets WHERE access_id = ?", accessID).Scan(&deletedAt)
+		if err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if deletedAt.Valid {
+			t.Errorf("expected secret without a ttl not to be deleted")
+		}
 	})
 }
+
+func TestPrune(t *testing.T) {
+	config := *app.config
+	config.Database.Driver = driverMemory
+	config.Jobs.DeletedSecretRetention = time.Hour
+
+	a, err := NewApplication(&config, os.DirFS("../../web/"))
+	if err != nil {
+		t.Fatalf("creating application: %v", err)
+	}
+
+	// an expired secret, a deleted secret past the retention period and an active secret
+	newTestStoredSecret(t, a.store, func(s *storedSecret) {
+		s.createdAt = time.Now().Add(-time.Hour).UnixMilli()
+	})
+	newTestStoredSecret(t, a.store, func(s *storedSecret) {
+		s.deletedAt = time.Now().Add(-2 * time.Hour).UnixMilli()
+		s.deletionReason = deletionReasonUserDeleted
+	})
+	newTestStoredSecret(t, a.store, nil)
+
+	result, err := a.Prune(context.Background())
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	} else if result.Expired != 1 || result.Purged != 1 {
+		t.Errorf("wanted 1 expired and 1 purged secret, got %+v", result)
+	}
+}
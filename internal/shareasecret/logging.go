@@ -0,0 +1,66 @@
+package shareasecret
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// logFormatJSON and logFormatConsole are the formats logs can be written in. JSON suits log aggregators in production
+// whereas the console format is easier to read during development.
+const (
+	logFormatJSON    = "json"
+	logFormatConsole = "console"
+)
+
+// defaultLogFileMaxSize is the size (in megabytes) a log file grows to before it is rotated when it isn't configured
+const defaultLogFileMaxSize = 100
+
+// defaultLogFileMaxBackups is the number of rotated log files kept when it isn't configured
+const defaultLogFileMaxBackups = 3
+
+// ConfigureLogging replaces the global logger, which every request logger is derived from, with one that writes at the
+// configured level and in the configured format to either stdout or a rotated log file. The returned function closes
+// the log file, if there is one, and should be called once nothing else will be logged.
+func ConfigureLogging(config *Configuration) func() error {
+	logger, file := newLogger(config)
+
+	zerolog.SetGlobalLevel(config.Logging.Level)
+	log.Logger = logger
+
+	return func() error {
+		if file == nil {
+			return nil
+		}
+
+		return file.Close()
+	}
+}
+
+// newLogger creates a logger as described by the logging configuration, along with the log file it writes to if it
+// doesn't write to stdout
+func newLogger(config *Configuration) (zerolog.Logger, *lumberjack.Logger) {
+	var w io.Writer = os.Stdout
+
+	// files are opened (and rotated) lazily as they are written to
+	var file *lumberjack.Logger
+	if config.Logging.File != "" {
+		file = &lumberjack.Logger{
+			Filename:   config.Logging.File,
+			MaxSize:    config.Logging.FileMaxSize,
+			MaxBackups: config.Logging.FileMaxBackups,
+		}
+
+		w = file
+	}
+
+	if config.Logging.Format == logFormatConsole {
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339, NoColor: config.Logging.File != ""}
+	}
+
+	return zerolog.New(w).Level(config.Logging.Level).With().Timestamp().Logger(), file
+}
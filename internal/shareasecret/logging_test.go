@@ -0,0 +1,61 @@
+package shareasecret
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLogging(t *testing.T) {
+	// logs are written to a file so that they can be read back
+	write := func(t *testing.T, format string) string {
+		t.Helper()
+
+		config := &Configuration{}
+		config.Logging.Format = format
+		config.Logging.Level = zerolog.InfoLevel
+		config.Logging.File = filepath.Join(t.TempDir(), "shareasecret.log")
+		config.Logging.FileMaxSize = defaultLogFileMaxSize
+
+		logger, file := newLogger(config)
+		logger.Debug().Msg("debugging")
+		logger.Info().Str("key", "value").Msg("informing")
+
+		if err := file.Close(); err != nil {
+			t.Fatalf("closing log file: %v", err)
+		}
+
+		b, err := os.ReadFile(config.Logging.File)
+		if err != nil {
+			t.Fatalf("reading log file: %v", err)
+		}
+
+		return string(b)
+	}
+
+	t.Run("writes json logs at or above the configured level", func(t *testing.T) {
+		logs := write(t, logFormatJSON)
+
+		var line map[string]any
+		if err := json.Unmarshal([]byte(logs), &line); err != nil {
+			t.Fatalf("wanted a single json log line, got %v (%v)", logs, err)
+		}
+		if line["message"] != "informing" || line["key"] != "value" || line["time"] == nil {
+			t.Errorf("wanted message, fields and time in log line, got %v", line)
+		}
+	})
+
+	t.Run("writes readable console logs", func(t *testing.T) {
+		logs := write(t, logFormatConsole)
+
+		if strings.HasPrefix(logs, "{") || !strings.Contains(logs, "INF informing key=value") {
+			t.Errorf("wanted console formatted log line, got %v", logs)
+		} else if strings.Contains(logs, "debugging") {
+			t.Errorf("did not expect logs below the configured level, got %v", logs)
+		}
+	})
+}
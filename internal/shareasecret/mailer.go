@@ -0,0 +1,185 @@
+package shareasecret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// emailTimeout is the maximum amount of time a mailer is given to send a single email
+const emailTimeout = 30 * time.Second
+
+// maximumNotifyEmailLength is the longest email address a secret can be created with
+const maximumNotifyEmailLength = 254
+
+// Mailer sends plain text emails. It exists so that the mechanism used to deliver emails can be swapped out.
+type Mailer interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+// smtpMailer is a [Mailer] that delivers emails via an SMTP server, authenticating with it if credentials are provided
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// newSMTPMailer creates an [smtpMailer] from the email configuration
+func newSMTPMailer(config *Configuration) *smtpMailer {
+	m := &smtpMailer{
+		addr: net.JoinHostPort(config.Email.SMTPHost, strconv.Itoa(config.Email.SMTPPort)),
+		from: config.Email.From,
+	}
+
+	if config.Email.SMTPUsername != "" {
+		m.auth = smtp.PlainAuth("", config.Email.SMTPUsername, config.Email.SMTPPassword, config.Email.SMTPHost)
+	}
+
+	return m
+}
+
+// Send sends the email, upgrading the connection with STARTTLS if the server supports it. The context is not honoured
+// as the standard library's SMTP client does not support one.
+func (m *smtpMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	msg := strings.Join(
+		[]string{
+			"From: " + m.from,
+			"To: " + to,
+			"Subject: " + subject,
+			"Date: " + time.Now().UTC().Format(time.RFC1123Z),
+			"MIME-Version: 1.0",
+			"Content-Type: text/plain; charset=utf-8",
+			"",
+			body,
+		},
+		"\r\n",
+	)
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg))
+}
+
+// emailNotifier asynchronously emails the creators of secrets who asked to be notified about them
+type emailNotifier struct {
+	mailer     Mailer
+	key        []byte
+	deliveries sync.WaitGroup
+}
+
+// enabled identifies whether email notifications can be sent
+func (n *emailNotifier) enabled() bool {
+	return n.mailer != nil
+}
+
+// notify decrypts the stored email address and sends the email to it in the background, logging (but otherwise
+// ignoring) any failure to do so
+func (n *emailNotifier) notify(encryptedEmail string, accessID string, subject string, body string) {
+	if !n.enabled() {
+		return
+	}
+
+	to, err := decryptNotifyEmail(n.key, encryptedEmail)
+	if err != nil {
+		log.Err(err).Str("access_id", accessID).Msg("decrypting notification email")
+		return
+	}
+
+	n.send(to, accessID, subject, body)
+}
+
+// send sends the email to the given address in the background, logging (but otherwise ignoring) any failure to do so
+func (n *emailNotifier) send(to string, accessID string, subject string, body string) {
+	if !n.enabled() {
+		return
+	}
+
+	n.deliveries.Add(1)
+
+	go func() {
+		defer n.deliveries.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), emailTimeout)
+		defer cancel()
+
+		if err := n.mailer.Send(ctx, to, subject, body); err != nil {
+			log.Err(err).Str("access_id", accessID).Msg("sending email")
+		}
+	}()
+}
+
+// wait blocks until all in-flight emails have been sent
+func (n *emailNotifier) wait() {
+	n.deliveries.Wait()
+}
+
+// validNotifyEmail identifies whether the given value is a bare email address that notifications can be sent to
+func validNotifyEmail(email string) bool {
+	if len(email) > maximumNotifyEmailLength || strings.ContainsAny(email, "\r\n") {
+		return false
+	}
+
+	a, err := mail.ParseAddress(email)
+
+	return err == nil && a.Address == email
+}
+
+// encryptNotifyEmail encrypts an email address with AES-GCM so that it isn't stored in plain text, returning the
+// base64 encoded nonce and cipher text
+func encryptNotifyEmail(key []byte, email string) (string, error) {
+	gcm, err := newNotifyEmailCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(email), nil)), nil
+}
+
+// decryptNotifyEmail reverses [encryptNotifyEmail]
+func decryptNotifyEmail(key []byte, encrypted string) (string, error) {
+	gcm, err := newNotifyEmailCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("decoding: %w", err)
+	} else if len(b) < gcm.NonceSize() {
+		return "", errors.New("encrypted email is too short")
+	}
+
+	email, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting: %w", err)
+	}
+
+	return string(email), nil
+}
+
+// newNotifyEmailCipher creates the AES-GCM cipher used to encrypt and decrypt email addresses
+func newNotifyEmailCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
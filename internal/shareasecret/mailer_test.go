@@ -0,0 +1,152 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMailer is a [Mailer] that records the emails it is asked to send
+type fakeMailer struct {
+	mu     sync.Mutex
+	sent   []string
+	bodies []string
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, to+": "+subject)
+	m.bodies = append(m.bodies, body)
+
+	return nil
+}
+
+func (m *fakeMailer) sentTo(to string, subject string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sent {
+		if s == to+": "+subject {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestEmailNotifications(t *testing.T) {
+	t.Run("rejects notification emails when email is disabled", func(t *testing.T) {
+		body := "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&notifyEmail=creator%40example.com"
+
+		if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "not enabled") {
+			t.Errorf("wanted 'not enabled' in body, got %v", r.body)
+		}
+	})
+
+	t.Run("emails the creator when their secret is viewed", func(t *testing.T) {
+		m := withFakeMailer(t)
+
+		r := post(t, app.handleAPICreateSecret, `{"encryptedSecret": "YWJj.ZGVm.Z2hp", "ttl": 30, "notifyEmail": "creator@example.com"}`, emptyRequestConfigurer)
+		if r.statusCode != 201 {
+			t.Fatalf("wanted 201 status code, got %v: %v", r.statusCode, r.body)
+		}
+
+		var encrypted string
+
+		if err := testDB().QueryRow("SELECT notify_email FROM secrets ORDER BY id DESC LIMIT 1").Scan(&encrypted); err != nil {
+			t.Fatalf("querying secret: %v", err)
+		} else if strings.Contains(encrypted, "creator@example.com") {
+			t.Errorf("wanted notification email to be encrypted at rest")
+		}
+
+		var accessID string
+		testDB().QueryRow("SELECT access_id FROM secrets ORDER BY id DESC LIMIT 1").Scan(&accessID)
+
+		get(t, app.handleAPIAccessSecret, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		app.emails.wait()
+
+		if !m.sentTo("creator@example.com", "Your secret has been viewed") {
+			t.Errorf("wanted viewed email to have been sent, got %v", m.sent)
+		}
+	})
+
+	t.Run("emails the creator when their secret expires", func(t *testing.T) {
+		m := withFakeMailer(t)
+
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		encrypted, err := encryptNotifyEmail(app.emails.key, "expired@example.com")
+		if err != nil {
+			t.Fatalf("encrypting email: %v", err)
+		}
+
+		_, err = testDB().Exec(
+			"UPDATE secrets SET notify_email = ?, ttl = 1, created_at = ? WHERE access_id = ?",
+			encrypted,
+			time.Now().Add(-2*time.Minute).UnixMilli(),
+			accessID,
+		)
+		if err != nil {
+			t.Fatalf("updating secret: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		app.RunDeleteExpiredSecretsJob(ctx)
+
+		until(t, func() bool { return m.sentTo("expired@example.com", "Your secret has expired") }, 20, 5*time.Millisecond)
+	})
+
+	t.Run("validates notification emails", func(t *testing.T) {
+		for _, e := range []string{"", "not an email", "Creator <creator@example.com>", "creator@example.com\r\nBcc: x@example.com"} {
+			if validNotifyEmail(e) {
+				t.Errorf("wanted %q to be invalid", e)
+			}
+		}
+
+		if !validNotifyEmail("creator@example.com") {
+			t.Errorf("wanted bare email address to be valid")
+		}
+	})
+
+	t.Run("encrypts and decrypts notification emails", func(t *testing.T) {
+		key := make([]byte, 32)
+
+		encrypted, err := encryptNotifyEmail(key, "creator@example.com")
+		if err != nil {
+			t.Fatalf("encrypting: %v", err)
+		}
+
+		if email, err := decryptNotifyEmail(key, encrypted); err != nil || email != "creator@example.com" {
+			t.Errorf("wanted decrypted email, got %v (%v)", email, err)
+		} else if _, err := decryptNotifyEmail(append([]byte{1}, key[1:]...), encrypted); err == nil {
+			t.Errorf("wanted decryption with the wrong key to fail")
+		}
+	})
+}
+
+// withFakeMailer enables email notifications on the shared application for the duration of the test, returning the
+// fake mailer emails are sent with
+func withFakeMailer(t *testing.T) *fakeMailer {
+	m := &fakeMailer{}
+
+	app.emails.wait()
+	app.emails.mailer = m
+	app.emails.key = make([]byte, 32)
+
+	t.Cleanup(func() {
+		app.emails.wait()
+		app.emails.mailer = nil
+		app.emails.key = nil
+	})
+
+	return m
+}
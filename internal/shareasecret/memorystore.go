@@ -0,0 +1,646 @@
+package shareasecret
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// driverMemory is the database driver used to hold secrets in memory only, meaning they are lost on restart
+const driverMemory = "memory"
+
+// memorySecretStore is a [SecretStore] that holds secrets in memory. It is suitable for tests and for ephemeral
+// instances that should never write secrets to disk.
+type memorySecretStore struct {
+	mu      sync.Mutex
+	secrets map[string]*memorySecret
+	// accessIDs maps the management ID of each secret to its access ID
+	accessIDs map[string]string
+	bundles   map[string]*memoryBundle
+	// bundleAccessIDs maps the management ID of each bundle to its access ID
+	bundleAccessIDs map[string]string
+}
+
+// memoryBundle is a bundle held by a [memorySecretStore], whose secrets are held alongside all other secrets
+type memoryBundle struct {
+	accessID     string
+	managementID string
+	createdAt    int64
+	titles       []string
+	// secretAccessIDs are the access IDs of the secrets of the bundle, in the same order as their titles
+	secretAccessIDs []string
+}
+
+// memorySecret is a secret held by a [memorySecretStore] along with the views of it
+type memorySecret struct {
+	storedSecret
+	decryptFailures int
+	events          []secretEvent
+	accesses        []secretAccess
+	// viewingKeys maps the key of each view of the secret to whether it has been used
+	viewingKeys map[string]bool
+}
+
+// newMemorySecretStore creates an empty [memorySecretStore]
+func newMemorySecretStore() *memorySecretStore {
+	return &memorySecretStore{
+		secrets:         map[string]*memorySecret{},
+		accessIDs:       map[string]string{},
+		bundles:         map[string]*memoryBundle{},
+		bundleAccessIDs: map[string]string{},
+	}
+}
+
+// Create stores a new secret, refusing to overwrite an existing one with the same identifiers
+func (m *memorySecretStore) Create(ctx context.Context, s storedSecret) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.collides(s) {
+		return errSecretIDCollision
+	}
+
+	m.create(s)
+
+	return nil
+}
+
+// GetByViewingID retrieves a secret that can still be viewed via its access ID. The cipher text is not returned.
+func (m *memorySecretStore) GetByViewingID(ctx context.Context, accessID string) (storedSecret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.viewable(accessID)
+	if !ok {
+		return storedSecret{}, errSecretNotFound
+	} else if s.notYetAvailable() {
+		return storedSecret{notBefore: s.notBefore}, errSecretNotYetAvailable
+	}
+
+	return forViewer(withoutCipherText(s.storedSecret)), nil
+}
+
+// UnavailableReason retrieves why a secret that existed can no longer be viewed via its access ID
+func (m *memorySecretStore) UnavailableReason(ctx context.Context, accessID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.secrets[accessID]
+	switch {
+	case !ok:
+		return "", errSecretNotFound
+	case s.deleted():
+		return s.deletionReason, nil
+	case s.expired():
+		return deletionReasonExpired, nil
+	default:
+		return "", errSecretNotFound
+	}
+}
+
+// GetByManagementID retrieves a secret via its management ID, regardless of whether it has been deleted or expired.
+// Only the size of the cipher text is returned.
+func (m *memorySecretStore) GetByManagementID(ctx context.Context, managementID string) (storedSecret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID, ok := m.accessIDs[managementID]
+	if !ok {
+		return storedSecret{}, errSecretNotFound
+	}
+
+	s := m.secrets[accessID].storedSecret
+	s.cipherTextSize = len(s.cipherText)
+
+	return withoutCipherText(s), nil
+}
+
+// CreateView records an unused view of a secret that can be viewed
+func (m *memorySecretStore) CreateView(ctx context.Context, accessID string, viewingKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.viewable(accessID)
+	if !ok || s.notYetAvailable() {
+		return errSecretNotFound
+	}
+
+	s.viewingKeys[viewingKey] = false
+
+	return nil
+}
+
+// ConsumeView marks the view of a secret as used, deleting the secret if the view exhausts it
+func (m *memorySecretStore) ConsumeView(ctx context.Context, accessID string, viewingKey string) (storedSecret, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.viewable(accessID)
+	if !ok {
+		return storedSecret{}, "", errSecretNotFound
+	} else if used, ok := s.viewingKeys[viewingKey]; !ok || used {
+		return storedSecret{}, "", errSecretNotFound
+	}
+
+	s.viewingKeys[viewingKey] = true
+	s.views++
+
+	viewed := forViewer(s.storedSecret)
+
+	deletionReason := ""
+	if s.burnAfterReading {
+		deletionReason = deletionReasonViewed
+	} else if s.maximumViews > 0 && s.views >= s.maximumViews {
+		deletionReason = deletionReasonMaximumViewCountHit
+	}
+
+	if deletionReason != "" {
+		s.delete(deletionReason)
+	}
+
+	return viewed, deletionReason, nil
+}
+
+// Acknowledge records that the creator of a secret has stored its links
+func (m *memorySecretStore) Acknowledge(ctx context.Context, managementID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID, ok := m.accessIDs[managementID]
+	if !ok {
+		return false, nil
+	}
+
+	if s := m.secrets[accessID]; s.acknowledgedAt == 0 {
+		s.acknowledgedAt = time.Now().UnixMilli()
+	}
+
+	return true, nil
+}
+
+// Delete deletes a secret on behalf of its creator
+func (m *memorySecretStore) Delete(ctx context.Context, managementID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID, ok := m.accessIDs[managementID]
+	if !ok || m.secrets[accessID].deleted() {
+		return false, nil
+	}
+
+	m.secrets[accessID].delete(deletionReasonUserDeleted)
+
+	return true, nil
+}
+
+// RotateAccessID replaces the access ID of a secret that can still be viewed, discarding any unused views of it
+func (m *memorySecretStore) RotateAccessID(ctx context.Context, managementID string, accessID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, ok := m.accessIDs[managementID]
+	if !ok {
+		return errSecretNotFound
+	}
+
+	s, ok := m.viewable(previous)
+	if !ok {
+		return errSecretNotFound
+	} else if _, used := m.secrets[accessID]; used {
+		return errSecretIDCollision
+	}
+
+	for key, used := range s.viewingKeys {
+		if !used {
+			delete(s.viewingKeys, key)
+		}
+	}
+
+	s.accessID = accessID
+	delete(m.secrets, previous)
+	m.secrets[accessID] = s
+	m.accessIDs[managementID] = accessID
+
+	// bundles refer to their secrets by access ID, so any bundle the secret belongs to has to follow it
+	for _, b := range m.bundles {
+		for i, id := range b.secretAccessIDs {
+			if id == previous {
+				b.secretAccessIDs[i] = accessID
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpdateTTL replaces the TTL of a secret that can still be viewed
+func (m *memorySecretStore) UpdateTTL(ctx context.Context, managementID string, ttl int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.viewable(m.accessIDs[managementID])
+	if !ok {
+		return errSecretNotFound
+	}
+
+	s.ttl = ttl
+
+	return nil
+}
+
+// Revoke deletes all secrets associated with the given recovery token hash on behalf of their creator
+func (m *memorySecretStore) Revoke(ctx context.Context, recoveryTokenHash string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var accessIDs []string
+	for _, s := range m.secrets {
+		if s.deleted() || s.recoveryTokenHash == "" || s.recoveryTokenHash != recoveryTokenHash {
+			continue
+		}
+
+		s.delete(deletionReasonUserDeleted)
+		accessIDs = append(accessIDs, s.accessID)
+	}
+
+	return accessIDs, nil
+}
+
+// ListByCreatorSession retrieves the most recently created secrets associated with the given creator session hash
+func (m *memorySecretStore) ListByCreatorSession(ctx context.Context, creatorSessionHash string, limit int) ([]storedSecret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var secrets []storedSecret
+	for _, s := range m.secrets {
+		if s.creatorSessionHash == "" || s.creatorSessionHash != creatorSessionHash {
+			continue
+		}
+
+		secrets = append(secrets, withoutCipherText(s.storedSecret))
+	}
+
+	// secrets are held in a map, so they are ordered by creation time (and then access ID, for a stable order)
+	sort.Slice(secrets, func(i, j int) bool {
+		if secrets[i].createdAt != secrets[j].createdAt {
+			return secrets[i].createdAt > secrets[j].createdAt
+		}
+
+		return secrets[i].accessID < secrets[j].accessID
+	})
+
+	if len(secrets) > limit {
+		secrets = secrets[:limit]
+	}
+
+	return secrets, nil
+}
+
+// RecordDecryptionFailure increments the number of failed decryption attempts of a secret, deleting it once the
+// maximum has been reached
+func (m *memorySecretStore) RecordDecryptionFailure(ctx context.Context, accessID string, maximum int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.viewable(accessID)
+	if !ok {
+		return false, errSecretNotFound
+	}
+
+	s.decryptFailures++
+	if maximum <= 0 || s.decryptFailures < maximum {
+		return false, nil
+	}
+
+	s.delete(deletionReasonDecryptionFailures)
+
+	return true, nil
+}
+
+// SweepExpired deletes all secrets that have expired as of the given time
+func (m *memorySecretStore) SweepExpired(ctx context.Context, now time.Time) ([]storedSecret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var swept []storedSecret
+	for _, s := range m.secrets {
+		if s.deleted() || s.ttl <= 0 || s.createdAt+(s.ttl*60*1000) > now.UnixMilli() {
+			continue
+		}
+
+		s.delete(deletionReasonExpired)
+		swept = append(swept, storedSecret{accessID: s.accessID, notifyEmail: s.notifyEmail})
+	}
+
+	return swept, nil
+}
+
+// PurgeDeleted permanently removes all secrets deleted at or before the given time
+func (m *memorySecretStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for accessID, s := range m.secrets {
+		if !s.deleted() || s.deletedAt > deletedBefore.UnixMilli() {
+			continue
+		}
+
+		delete(m.secrets, accessID)
+		delete(m.accessIDs, s.managementID)
+		purged++
+	}
+
+	// bundles are removed along with the last of their secrets
+	for accessID, b := range m.bundles {
+		if len(m.bundle(b).secrets) == 0 {
+			delete(m.bundles, accessID)
+			delete(m.bundleAccessIDs, b.managementID)
+		}
+	}
+
+	return purged, nil
+}
+
+// RecordEvent records an event in the lifecycle of a secret, which is identified by whichever of its access ID or
+// management ID is set on the event
+func (m *memorySecretStore) RecordEvent(ctx context.Context, e secretEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID := e.accessID
+	if id, ok := m.accessIDs[e.managementID]; ok {
+		accessID = id
+	}
+
+	if s, ok := m.secrets[accessID]; ok {
+		s.events = append(s.events, e)
+	}
+
+	return nil
+}
+
+// Events retrieves the most recent events of a secret via its management ID, newest first
+func (m *memorySecretStore) Events(ctx context.Context, managementID string, limit int) ([]secretEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID, ok := m.accessIDs[managementID]
+	if !ok {
+		return nil, nil
+	}
+
+	var events []secretEvent
+	for i := len(m.secrets[accessID].events) - 1; i >= 0 && len(events) < limit; i-- {
+		e := m.secrets[accessID].events[i]
+		e.accessID = ""
+		e.managementID = managementID
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// RecordAccess records an access of the secret with the given access ID in the access log shown to its creator
+func (m *memorySecretStore) RecordAccess(ctx context.Context, accessID string, access secretAccess) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.secrets[accessID]; ok {
+		s.accesses = append(s.accesses, access)
+	}
+
+	return nil
+}
+
+// Accesses retrieves the most recent accesses of a secret via its management ID, newest first
+func (m *memorySecretStore) Accesses(ctx context.Context, managementID string, limit int) ([]secretAccess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID, ok := m.accessIDs[managementID]
+	if !ok {
+		return nil, nil
+	}
+
+	var accesses []secretAccess
+	for i := len(m.secrets[accessID].accesses) - 1; i >= 0 && len(accesses) < limit; i-- {
+		accesses = append(accesses, m.secrets[accessID].accesses[i])
+	}
+
+	return accesses, nil
+}
+
+// CreateBundle stores a new bundle along with its secrets, refusing to overwrite any existing bundle or secret with the
+// same identifiers
+func (m *memorySecretStore) CreateBundle(ctx context.Context, b storedBundle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.bundles[b.accessID]; ok {
+		return errSecretIDCollision
+	} else if _, ok := m.bundleAccessIDs[b.managementID]; ok {
+		return errSecretIDCollision
+	}
+
+	// the identifiers of the secrets must also be unique amongst themselves, not just amongst existing secrets
+	ids := map[string]bool{}
+	for _, s := range b.secrets {
+		if m.collides(s.storedSecret) || ids[s.accessID] || ids[s.managementID] {
+			return errSecretIDCollision
+		}
+
+		ids[s.accessID] = true
+		ids[s.managementID] = true
+	}
+
+	bundle := &memoryBundle{accessID: b.accessID, managementID: b.managementID, createdAt: b.createdAt}
+	for _, s := range b.secrets {
+		m.create(s.storedSecret)
+		bundle.titles = append(bundle.titles, s.title)
+		bundle.secretAccessIDs = append(bundle.secretAccessIDs, s.accessID)
+	}
+
+	m.bundles[b.accessID] = bundle
+	m.bundleAccessIDs[b.managementID] = b.accessID
+
+	return nil
+}
+
+// GetBundleByViewingID retrieves a bundle via its access ID along with all of its secrets
+func (m *memorySecretStore) GetBundleByViewingID(ctx context.Context, accessID string) (storedBundle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.bundles[accessID]
+	if !ok {
+		return storedBundle{}, errSecretNotFound
+	}
+
+	bundle := m.bundle(b)
+	for i, s := range bundle.secrets {
+		bundle.secrets[i].storedSecret = forViewer(s.storedSecret)
+	}
+
+	return bundle, nil
+}
+
+// GetBundleByManagementID retrieves a bundle via its management ID along with all of its secrets
+func (m *memorySecretStore) GetBundleByManagementID(ctx context.Context, managementID string) (storedBundle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID, ok := m.bundleAccessIDs[managementID]
+	if !ok {
+		return storedBundle{}, errSecretNotFound
+	}
+
+	return m.bundle(m.bundles[accessID]), nil
+}
+
+// DeleteBundle deletes all of the secrets of a bundle on behalf of its creator
+func (m *memorySecretStore) DeleteBundle(ctx context.Context, managementID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accessID, ok := m.bundleAccessIDs[managementID]
+	if !ok {
+		return nil, errSecretNotFound
+	}
+
+	var deleted []string
+	for _, id := range m.bundles[accessID].secretAccessIDs {
+		if s, ok := m.secrets[id]; ok && !s.deleted() {
+			s.delete(deletionReasonUserDeleted)
+			deleted = append(deleted, id)
+		}
+	}
+
+	return deleted, nil
+}
+
+// CountActive counts the secrets that have neither been deleted nor expired
+func (m *memorySecretStore) CountActive(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var active int64
+	for _, s := range m.secrets {
+		if !s.deleted() && !s.expired() {
+			active++
+		}
+	}
+
+	return active, nil
+}
+
+// Stats aggregates the state of all secrets as of the given time
+func (m *memorySecretStore) Stats(ctx context.Context, now time.Time) (secretStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stats secretStats
+	for _, s := range m.secrets {
+		expired := s.ttl > 0 && s.createdAt+(s.ttl*60*1000) <= now.UnixMilli()
+
+		switch {
+		case s.deletionReason == deletionReasonExpired || (!s.deleted() && expired):
+			stats.expired++
+		case s.deleted():
+			stats.deleted++
+		default:
+			stats.active++
+			if stats.oldestActiveCreatedAt == 0 || s.createdAt < stats.oldestActiveCreatedAt {
+				stats.oldestActiveCreatedAt = s.createdAt
+			}
+		}
+
+		if s.views > 0 {
+			stats.viewed++
+		}
+		if s.createdAt > now.Add(-24*time.Hour).UnixMilli() {
+			stats.createdLastDay++
+		}
+	}
+
+	return stats, nil
+}
+
+// Ping always succeeds as there is nothing to reach
+func (m *memorySecretStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close discards all secrets held by the store
+func (m *memorySecretStore) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.secrets = map[string]*memorySecret{}
+	m.accessIDs = map[string]string{}
+	m.bundles = map[string]*memoryBundle{}
+	m.bundleAccessIDs = map[string]string{}
+
+	return nil
+}
+
+// collides identifies whether either identifier of the given secret is already in use. The lock must be held by the
+// caller.
+func (m *memorySecretStore) collides(s storedSecret) bool {
+	_, accessIDUsed := m.secrets[s.accessID]
+	_, managementIDUsed := m.accessIDs[s.managementID]
+
+	return accessIDUsed || managementIDUsed
+}
+
+// create stores a new secret without checking its identifiers are unique. The lock must be held by the caller.
+func (m *memorySecretStore) create(s storedSecret) {
+	m.secrets[s.accessID] = &memorySecret{storedSecret: s, viewingKeys: map[string]bool{}}
+	m.accessIDs[s.managementID] = s.accessID
+}
+
+// bundle copies the given bundle along with those of its secrets that have not been purged, without their cipher text.
+// The lock must be held by the caller.
+func (m *memorySecretStore) bundle(b *memoryBundle) storedBundle {
+	bundle := storedBundle{accessID: b.accessID, managementID: b.managementID, createdAt: b.createdAt}
+	for i, id := range b.secretAccessIDs {
+		if s, ok := m.secrets[id]; ok {
+			bundle.secrets = append(bundle.secrets, bundledSecret{title: b.titles[i], storedSecret: withoutCipherText(s.storedSecret)})
+		}
+	}
+
+	return bundle
+}
+
+// viewable retrieves the secret with the given access ID if it has neither been deleted nor expired. The lock must be
+// held by the caller.
+func (m *memorySecretStore) viewable(accessID string) (*memorySecret, bool) {
+	s, ok := m.secrets[accessID]
+	if !ok || s.deleted() || s.expired() {
+		return nil, false
+	}
+
+	return s, true
+}
+
+// delete marks the secret as deleted for the given reason, discarding its cipher text
+func (s *memorySecret) delete(reason string) {
+	s.deletedAt = time.Now().UnixMilli()
+	s.deletionReason = reason
+	s.cipherText = ""
+}
+
+// forViewer returns a copy of the secret with the values only its creator may see removed
+func forViewer(s storedSecret) storedSecret {
+	s.label = ""
+
+	return s
+}
+
+// withoutCipherText returns a copy of the secret with its cipher text removed
+func withoutCipherText(s storedSecret) storedSecret {
+	s.cipherText = ""
+
+	return s
+}
@@ -0,0 +1,106 @@
+package shareasecret
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// metrics contains the counters exposed in the Prometheus text format by the [handleMetrics] handler
+type metrics struct {
+	mu      sync.Mutex
+	created int64
+	viewed  int64
+	deleted map[string]int64
+}
+
+// newMetrics creates a [metrics] instance with every deletion reason initialised so that all series are present from
+// the first scrape
+func newMetrics() *metrics {
+	m := &metrics{deleted: map[string]int64{}}
+	for reason := range deletionReasonDescriptions {
+		m.deleted[reason] = 0
+	}
+
+	return m
+}
+
+// secretCreated increments the number of secrets created
+func (m *metrics) secretCreated() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.created++
+}
+
+// secretViewed increments the number of secrets viewed
+func (m *metrics) secretViewed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.viewed++
+}
+
+// secretsDeleted increments the number of secrets deleted for the given reason by n
+func (m *metrics) secretsDeleted(reason string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleted[reason] += n
+}
+
+// handleMetrics exposes the application's metrics in the Prometheus text exposition format
+func (a *Application) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	l := zerolog.Ctx(r.Context())
+
+	// active secrets are counted at scrape time as secrets can expire without the delete expired secrets job having
+	// ran yet
+	active, err := a.store.CountActive(r.Context())
+	if err != nil {
+		l.Err(err).Msg("counting active secrets")
+		internalServerError(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	a.metrics.write(w, active)
+}
+
+// write writes all metrics in the Prometheus text exposition format
+func (m *metrics) write(w io.Writer, active int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeMetric(w, "shareasecret_secrets_created_total", "counter", "Total number of secrets created.")
+	fmt.Fprintf(w, "shareasecret_secrets_created_total %d\n", m.created)
+
+	writeMetric(w, "shareasecret_secrets_viewed_total", "counter", "Total number of secrets viewed.")
+	fmt.Fprintf(w, "shareasecret_secrets_viewed_total %d\n", m.viewed)
+
+	writeMetric(
+		w,
+		"shareasecret_secrets_deleted_total",
+		"counter",
+		"Total number of secrets deleted by reason. Expired secrets are deleted by the delete expired secrets job.",
+	)
+	reasons := make([]string, 0, len(m.deleted))
+	for reason := range m.deleted {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "shareasecret_secrets_deleted_total{reason=%q} %d\n", reason, m.deleted[reason])
+	}
+
+	writeMetric(w, "shareasecret_secrets_active", "gauge", "Number of secrets that have not been deleted or expired.")
+	fmt.Fprintf(w, "shareasecret_secrets_active %d\n", active)
+}
+
+// writeMetric writes the HELP and TYPE lines that precede a metric's samples
+func writeMetric(w io.Writer, name string, metricType string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
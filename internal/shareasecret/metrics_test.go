@@ -0,0 +1,63 @@
+package shareasecret
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("exposes counters in the prometheus text format", func(t *testing.T) {
+		before := metricValue(t, `shareasecret_secrets_created_total`)
+
+		post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+
+		if after := metricValue(t, `shareasecret_secrets_created_total`); after != before+1 {
+			t.Errorf("expected created counter to increase by 1, got %v -> %v", before, after)
+		}
+	})
+
+	t.Run("exposes deleted secrets by reason", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+		before := metricValue(t, `shareasecret_secrets_deleted_total{reason="user_deleted"}`)
+
+		post(t, app.handleDeleteSecret, "", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+
+		if after := metricValue(t, `shareasecret_secrets_deleted_total{reason="user_deleted"}`); after != before+1 {
+			t.Errorf("expected deleted counter to increase by 1, got %v -> %v", before, after)
+		}
+	})
+
+	t.Run("exposes the number of active secrets", func(t *testing.T) {
+		before := metricValue(t, "shareasecret_secrets_active")
+
+		createSecret(t, time.Time{}, "")
+		createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		if after := metricValue(t, "shareasecret_secrets_active"); after != before+1 {
+			t.Errorf("expected active gauge to increase by 1, got %v -> %v", before, after)
+		}
+	})
+}
+
+// metricValue scrapes the metrics endpoint and returns the value of the given series
+func metricValue(t *testing.T, series string) int64 {
+	r := get(t, app.handleMetrics, emptyRequestConfigurer)
+
+	for _, line := range strings.Split(r.body, "\n") {
+		if v, ok := strings.CutPrefix(line, series+" "); ok {
+			var n int64
+			if _, err := fmt.Sscan(v, &n); err != nil {
+				t.Errorf("parsing metric value %v: %v", v, err)
+			}
+
+			return n
+		}
+	}
+
+	t.Errorf("metric series %v not found", series)
+
+	return 0
+}
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN passphrase_hash TEXT NULL;
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN decrypt_failures NUMBER NOT NULL DEFAULT(0);
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN notify_email TEXT NULL;
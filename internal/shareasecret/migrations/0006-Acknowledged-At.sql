@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN acknowledged_at NUMBER NULL;
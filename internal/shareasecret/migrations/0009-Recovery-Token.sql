@@ -0,0 +1,3 @@
+ALTER TABLE secrets ADD COLUMN recovery_token_hash TEXT NULL;
+
+CREATE INDEX idx_secrets_recovery_token_hash ON secrets (recovery_token_hash);
@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX idx_secrets_access_id ON secrets (access_id);
+CREATE UNIQUE INDEX idx_secrets_management_id ON secrets (management_id);
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN reveal_delay_seconds NUMBER NOT NULL DEFAULT(0);
@@ -0,0 +1,13 @@
+ALTER TABLE secrets ADD COLUMN track_access NUMBER NOT NULL DEFAULT(0);
+
+CREATE TABLE secret_accesses (
+    id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    secret_id   INTEGER NOT NULL,
+    ip_hash     TEXT NULL,
+    user_agent  TEXT NULL,
+    accessed_at NUMBER NOT NULL,
+
+    FOREIGN KEY (secret_id) REFERENCES secrets (id)
+);
+
+CREATE INDEX idx_secret_accesses_secret_id_accessed_at ON secret_accesses (secret_id, accessed_at);
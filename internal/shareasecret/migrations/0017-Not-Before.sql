@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN not_before NUMBER NOT NULL DEFAULT(0);
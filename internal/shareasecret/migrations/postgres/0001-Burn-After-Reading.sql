@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT(FALSE);
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN decrypt_failures INTEGER NOT NULL DEFAULT(0);
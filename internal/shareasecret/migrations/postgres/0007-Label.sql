@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN label TEXT NULL;
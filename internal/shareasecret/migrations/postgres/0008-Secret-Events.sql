@@ -0,0 +1,12 @@
+CREATE TABLE secret_events (
+    id          BIGSERIAL NOT NULL PRIMARY KEY,
+    secret_id   BIGINT NOT NULL,
+    event_type  TEXT NOT NULL,
+    detail      TEXT NULL,
+    ip_hash     TEXT NULL,
+    occurred_at BIGINT NOT NULL,
+
+    FOREIGN KEY (secret_id) REFERENCES secrets (id)
+);
+
+CREATE INDEX idx_secret_events_secret_id_occurred_at ON secret_events (secret_id, occurred_at);
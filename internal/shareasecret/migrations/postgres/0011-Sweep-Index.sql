@@ -0,0 +1,5 @@
+DROP INDEX idx_secrets_access_id_deleted_at;
+DROP INDEX idx_secrets_management_id_deleted_at;
+DROP INDEX idx_secrets_alive_created_at_ttl_deleted_at;
+
+CREATE INDEX idx_secrets_deleted_at_created_at_ttl ON secrets (deleted_at, created_at, ttl);
@@ -0,0 +1,22 @@
+CREATE TABLE bundles (
+    id            BIGSERIAL NOT NULL PRIMARY KEY,
+    access_id     TEXT NOT NULL,
+    management_id TEXT NOT NULL,
+    created_at    BIGINT NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_bundles_access_id ON bundles (access_id);
+CREATE UNIQUE INDEX idx_bundles_management_id ON bundles (management_id);
+
+CREATE TABLE bundle_secrets (
+    bundle_id BIGINT NOT NULL,
+    secret_id BIGINT NOT NULL,
+    position  BIGINT NOT NULL,
+    title     TEXT NOT NULL,
+
+    PRIMARY KEY (bundle_id, position),
+    FOREIGN KEY (bundle_id) REFERENCES bundles (id),
+    FOREIGN KEY (secret_id) REFERENCES secrets (id)
+);
+
+CREATE UNIQUE INDEX idx_bundle_secrets_secret_id ON bundle_secrets (secret_id);
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN reveal_delay_seconds INTEGER NOT NULL DEFAULT(0);
@@ -0,0 +1,3 @@
+ALTER TABLE secrets ADD COLUMN creator_session_hash TEXT NULL;
+
+CREATE INDEX idx_secrets_creator_session_hash ON secrets (creator_session_hash);
@@ -0,0 +1,13 @@
+ALTER TABLE secrets ADD COLUMN track_access BOOLEAN NOT NULL DEFAULT(FALSE);
+
+CREATE TABLE secret_accesses (
+    id          BIGSERIAL NOT NULL PRIMARY KEY,
+    secret_id   BIGINT NOT NULL,
+    ip_hash     TEXT NULL,
+    user_agent  TEXT NULL,
+    accessed_at BIGINT NOT NULL,
+
+    FOREIGN KEY (secret_id) REFERENCES secrets (id)
+);
+
+CREATE INDEX idx_secret_accesses_secret_id_accessed_at ON secret_accesses (secret_id, accessed_at);
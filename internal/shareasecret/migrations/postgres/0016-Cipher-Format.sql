@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN cipher_format TEXT NOT NULL DEFAULT('v1');
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN not_before BIGINT NOT NULL DEFAULT(0);
@@ -0,0 +1,101 @@
+package shareasecret
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// creatorSessionCookieName is the name of the cookie containing the anonymous session of a browser that creators have
+// asked to remember their secrets in
+const creatorSessionCookieName = "creator_session"
+
+// creatorSessionMaxAge is how long a browser remembers the secrets created in it for, in seconds, equal to one year
+const creatorSessionMaxAge = 365 * 24 * 60 * 60
+
+// maximumMySecrets is the most secrets listed on the /my-secrets page
+const maximumMySecrets = 100
+
+// hashCreatorSession hashes the anonymous session secrets are associated with. As with recovery tokens the hash is
+// unsalted, as the secrets of a session have to be found by it.
+func hashCreatorSession(session string) string {
+	h := sha256.Sum256([]byte(session))
+
+	return hex.EncodeToString(h[:])
+}
+
+// creatorSession returns the anonymous session of the requesting browser, or an empty string if it doesn't have one
+func creatorSession(r *http.Request) string {
+	c, err := r.Cookie(creatorSessionCookieName)
+	if err != nil {
+		return ""
+	}
+
+	// sessions are always 192 bit hex encoded identifiers, anything else cannot have been issued by us
+	if b, err := hex.DecodeString(c.Value); err != nil || len(b) != 24 {
+		return ""
+	}
+
+	return c.Value
+}
+
+// startCreatorSession returns the anonymous session of the requesting browser, starting a new one if it doesn't
+// already have one. Session cookies are hidden from JavaScript and only sent over HTTPS when the request was served
+// over it.
+func startCreatorSession(w http.ResponseWriter, r *http.Request) (string, error) {
+	if session := creatorSession(r); session != "" {
+		return session, nil
+	}
+
+	session, err := secureID(24)
+	if err != nil {
+		return "", fmt.Errorf("generating creator session: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     creatorSessionCookieName,
+		Value:    session,
+		Path:     pathFor(r.Context(), "/"),
+		MaxAge:   creatorSessionMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   requestIsSecure(r),
+	})
+
+	return session, nil
+}
+
+// handleMySecrets lists the secrets created in the requesting browser along with links to manage them. Browsers
+// without a session list nothing, as no secrets can be associated with them.
+func (a *Application) handleMySecrets(w http.ResponseWriter, r *http.Request) {
+	noStore(w)
+	noIndex(w)
+
+	var secrets []mySecretDetails
+	if session := creatorSession(r); session != "" {
+		stored, err := a.store.ListByCreatorSession(r.Context(), hashCreatorSession(session), maximumMySecrets)
+		if err != nil {
+			zerolog.Ctx(r.Context()).Err(err).Msg("listing secrets of creator session")
+			redirectToOopsPage(w, r)
+			return
+		}
+
+		loc := timezoneFromRequest(r)
+
+		for _, s := range stored {
+			secrets = append(secrets, mySecretDetails{
+				label:             s.label,
+				manageURL:         fmt.Sprintf("%s/manage-secret/%s", a.baseURL, s.managementID),
+				createdAt:         time.UnixMilli(s.createdAt).In(loc),
+				views:             s.views,
+				unavailableReason: s.unavailableReason(),
+			})
+		}
+	}
+
+	pageMySecrets(secrets, notificationsFromRequest(r, w)).Render(r.Context(), w)
+}
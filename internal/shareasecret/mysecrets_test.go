@@ -0,0 +1,76 @@
+package shareasecret
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMySecrets(t *testing.T) {
+	create := func(t *testing.T, body string, rc func(r *http.Request)) consumedResponse {
+		t.Helper()
+
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&"+body, rc)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v: %v", r.statusCode, r.body)
+		}
+
+		return r
+	}
+
+	t.Run("lists the secrets created in the browser when asked to", func(t *testing.T) {
+		r := create(t, "rememberSecret=true&label=first", emptyRequestConfigurer)
+
+		var session *http.Cookie
+		for _, c := range r.cookies {
+			if c.Name == creatorSessionCookieName {
+				session = c
+			}
+		}
+		if session == nil || !session.HttpOnly {
+			t.Fatalf("wanted http only session cookie, got %v", r.cookies)
+		}
+		first := strings.TrimPrefix(r.headers.Get("Location"), "/manage-secret/")
+
+		// the existing session is reused rather than replaced
+		r = create(t, "rememberSecret=true&label=second", func(r *http.Request) { r.AddCookie(session) })
+		for _, c := range r.cookies {
+			if c.Name == creatorSessionCookieName {
+				t.Errorf("wanted existing session to be reused, got %v", c)
+			}
+		}
+		second := strings.TrimPrefix(r.headers.Get("Location"), "/manage-secret/")
+
+		forgotten := strings.TrimPrefix(create(t, "label=third", func(r *http.Request) { r.AddCookie(session) }).headers.Get("Location"), "/manage-secret/")
+
+		r = get(t, app.handleMySecrets, func(r *http.Request) { r.AddCookie(session) })
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		}
+		for _, managementID := range []string{first, second} {
+			if !strings.Contains(r.body, "/manage-secret/"+managementID) {
+				t.Errorf("wanted management link of %v in body", managementID)
+			}
+		}
+		if strings.Contains(r.body, "/manage-secret/"+forgotten) {
+			t.Errorf("wanted secret created without asking to be remembered to not be listed")
+		}
+		if r.headers.Get("Cache-Control") != "no-store, max-age=0" {
+			t.Errorf("wanted page to not be stored, got %v", r.headers.Get("Cache-Control"))
+		}
+	})
+
+	t.Run("lists nothing without a session", func(t *testing.T) {
+		for _, rc := range []func(r *http.Request){
+			emptyRequestConfigurer,
+			func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: creatorSessionCookieName, Value: "not-a-session"})
+			},
+		} {
+			r := get(t, app.handleMySecrets, rc)
+			if r.statusCode != 200 || strings.Contains(r.body, "/manage-secret/") {
+				t.Errorf("wanted no secrets listed, got %v: %v", r.statusCode, r.body)
+			}
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+)
+
+// javascriptUnavailableCookieName is the name of the cookie set when a visitor is sent to the /nojs page by the
+// noscript fallback of the layout. It is cleared by global.mjs as soon as JavaScript runs.
+const javascriptUnavailableCookieName = "nojs"
+
+// javascriptUnavailableContextKey is the context key set when a page is rendered for a visitor known to be without
+// JavaScript, which stops the layout redirecting them to the /nojs page
+type javascriptUnavailableContextKey struct{}
+
+// handleNoJavascript renders the page explaining that JavaScript is required, remembering that the visitor is without
+// it so that the home page can explain the same rather than show a form that cannot work
+func handleNoJavascript(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     javascriptUnavailableCookieName,
+		Value:    "1",
+		Path:     pathFor(r.Context(), "/"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	pageNoJavascript().Render(withJavascriptUnavailable(r.Context()), w)
+}
+
+// javascriptUnavailable identifies whether the visitor is known to be without JavaScript, either via the cookie set by
+// the /nojs page or a nojs query parameter
+func javascriptUnavailable(r *http.Request) bool {
+	if r.URL.Query().Has("nojs") {
+		return true
+	}
+
+	_, err := r.Cookie(javascriptUnavailableCookieName)
+	return err == nil
+}
+
+// withJavascriptUnavailable marks the given context as belonging to a visitor without JavaScript
+func withJavascriptUnavailable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, javascriptUnavailableContextKey{}, true)
+}
+
+// noscriptRedirectDisabled identifies whether the layout should no longer redirect visitors without JavaScript to the
+// /nojs page, as the page being rendered already accounts for them
+func noscriptRedirectDisabled(ctx context.Context) bool {
+	v, _ := ctx.Value(javascriptUnavailableContextKey{}).(bool)
+	return v
+}
@@ -0,0 +1,67 @@
+package shareasecret
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJavascriptUnavailable(t *testing.T) {
+	serve := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+
+		app.router.ServeHTTP(recorder, r)
+
+		return recorder
+	}
+
+	t.Run("remembers visitors sent to the nojs page", func(t *testing.T) {
+		r := serve("/nojs")
+
+		var cookie *http.Cookie
+		for _, c := range r.Result().Cookies() {
+			if c.Name == javascriptUnavailableCookieName {
+				cookie = c
+			}
+		}
+
+		if cookie == nil {
+			t.Fatalf("wanted %v cookie to be set", javascriptUnavailableCookieName)
+		} else if strings.Contains(r.Body.String(), `http-equiv="refresh"`) {
+			t.Errorf("did not expect the nojs page to redirect to itself")
+		}
+	})
+
+	t.Run("shows the create secret form by default", func(t *testing.T) {
+		r := serve("/")
+
+		if !strings.Contains(r.Body.String(), `id="createSecretForm"`) {
+			t.Errorf("wanted create secret form in body")
+		} else if !strings.Contains(r.Body.String(), `http-equiv="refresh"`) {
+			t.Errorf("wanted noscript redirect in body")
+		}
+	})
+
+	for name, r := range map[string]*httptest.ResponseRecorder{
+		"cookie":          serve("/", &http.Cookie{Name: javascriptUnavailableCookieName, Value: "1"}),
+		"query parameter": serve("/?nojs"),
+	} {
+		t.Run("explains javascript is required when identified by "+name, func(t *testing.T) {
+			if r.Code != 200 {
+				t.Errorf("wanted 200 status code, got %v", r.Code)
+			} else if strings.Contains(r.Body.String(), `id="createSecretForm"`) {
+				t.Errorf("did not expect create secret form in body")
+			} else if !strings.Contains(r.Body.String(), `href="/nojs"`) {
+				t.Errorf("wanted link to nojs page in body")
+			} else if strings.Contains(r.Body.String(), `http-equiv="refresh"`) {
+				t.Errorf("did not expect noscript redirect in body")
+			}
+		})
+	}
+}
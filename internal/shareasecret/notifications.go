@@ -0,0 +1,40 @@
+package shareasecret
+
+import (
+	"fmt"
+	"time"
+)
+
+// notifySecretViewed notifies the creator of a secret that it has been viewed via whichever of the webhook and email
+// address (if any) the secret was created with. Notifications are sent in the background.
+func (a *Application) notifySecretViewed(s storedSecret, viewedAt time.Time) {
+	if s.notifyWebhook != "" {
+		a.webhooks.notifyViewed(s.notifyWebhook, s.accessID, viewedAt)
+	}
+
+	if s.notifyEmail != "" {
+		a.emails.notify(
+			s.notifyEmail,
+			s.accessID,
+			"Your secret has been viewed",
+			fmt.Sprintf(
+				"The secret you shared (viewing ID %s) was viewed at %s.",
+				s.accessID,
+				viewedAt.UTC().Format("2 Jan 2006 15:04 MST"),
+			),
+		)
+	}
+}
+
+// notifySecretExpired notifies the creator of a secret that it has expired via the email address (if any) the secret was
+// created with. Notifications are sent in the background.
+func (a *Application) notifySecretExpired(s storedSecret) {
+	if s.notifyEmail != "" {
+		a.emails.notify(
+			s.notifyEmail,
+			s.accessID,
+			"Your secret has expired",
+			fmt.Sprintf("The secret you shared (viewing ID %s) has expired and can no longer be viewed by anyone.", s.accessID),
+		)
+	}
+}
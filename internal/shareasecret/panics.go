@@ -0,0 +1,35 @@
+package shareasecret
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/rs/zerolog"
+)
+
+// recoverPanics recovers any panic raised whilst serving a request, so that a single bad request cannot take down the
+// process. Panics are logged along with their stack trace (the logger of the request carries its ID), and the oops page
+// is rendered with a 500 status code in place of the response unless it had already begun to be written.
+// [http.ErrAbortHandler] is raised again, as the server handles it by silently aborting the response.
+func (a *Application) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &statusRecordingResponseWriter{ResponseWriter: w}
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			} else if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			zerolog.Ctx(r.Context()).Error().Any("panic", p).Str("stack", string(debug.Stack())).Msg("recovered from panic")
+
+			if rw.statusCode == 0 && rw.bytesWritten == 0 {
+				internalServerError(w, r)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,59 @@
+package shareasecret
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	serve := func(h http.HandlerFunc) (*httptest.ResponseRecorder, string) {
+		var logs bytes.Buffer
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(zerolog.New(&logs).With().Str("request_id", "abc123").Logger().WithContext(r.Context()))
+
+		w := httptest.NewRecorder()
+		app.recoverPanics(h).ServeHTTP(w, r)
+
+		return w, logs.String()
+	}
+
+	t.Run("renders the oops page and logs panics along with their stack", func(t *testing.T) {
+		w, logs := serve(func(w http.ResponseWriter, r *http.Request) { panic("handling request") })
+
+		if w.Code != 500 || !strings.Contains(w.Body.String(), translate("oops.title", defaultLanguage)) {
+			t.Errorf("wanted 500 status code and oops page, got %v %v", w.Code, w.Body.String())
+		}
+		for _, want := range []string{`"panic":"handling request"`, `"request_id":"abc123"`, `"stack":"goroutine`} {
+			if !strings.Contains(logs, want) {
+				t.Errorf("wanted %v in logs, got %v", want, logs)
+			}
+		}
+	})
+
+	t.Run("leaves responses that had already begun to be written", func(t *testing.T) {
+		w, _ := serve(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			panic("writing response")
+		})
+
+		if w.Code != 200 || w.Body.String() != "partial" {
+			t.Errorf("wanted partial response to be left alone, got %v %v", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("raises aborted handlers again", func(t *testing.T) {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("wanted http.ErrAbortHandler to be raised again, got %v", p)
+			}
+		}()
+
+		serve(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+	})
+}
@@ -0,0 +1,76 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// rateLimiter is a token bucket rate limiter keyed on an arbitrary string (typically the requesting IP address), whose
+// buckets are held in [sharedState] so that limits apply across all instances of the application
+type rateLimiter struct {
+	state     sharedState
+	name      string
+	perSecond float64
+	burst     float64
+}
+
+// newRateLimiter creates a [rateLimiter] that permits the given number of requests per minute, with short bursts of up
+// to the given size. The name distinguishes its buckets from those of other rate limiters sharing the same state. A
+// requestsPerMinute of 0 disables rate limiting.
+func newRateLimiter(state sharedState, name string, requestsPerMinute int, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		state:     state,
+		name:      name,
+		perSecond: float64(requestsPerMinute) / 60,
+		burst:     float64(burst),
+	}
+}
+
+// allow identifies whether a request for the given key is permitted, consuming a token from its bucket if it is.
+// Requests are permitted if the shared state cannot be reached, as an outage of it should not take the application
+// down with it.
+func (rl *rateLimiter) allow(ctx context.Context, key string) bool {
+	if rl.perSecond <= 0 {
+		return true
+	}
+
+	allowed, err := rl.state.Take(ctx, rl.name+":"+key, rl.perSecond, rl.burst)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("checking rate limit")
+		return true
+	}
+
+	return allowed
+}
+
+// rateLimit wraps the given handler, calling the limited function instead of it if the requesting IP address has
+// exceeded the configured rate limit
+func (a *Application) rateLimit(next http.HandlerFunc, limited func(w http.ResponseWriter)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := rateLimitKey(r)
+
+		if !a.rateLimiter.allow(r.Context(), ip) {
+			zerolog.Ctx(r.Context()).Warn().Str("ip", ip).Msg("rate limit exceeded")
+			limited(w)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// rateLimitKey returns the key to rate limit the request by: the IP address of the client (see [clientIP]), falling
+// back to the address of the immediate peer when it cannot be parsed
+func rateLimitKey(r *http.Request) string {
+	if ip := clientIP(r); ip != nil {
+		return ip.String()
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,73 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimiting(t *testing.T) {
+	t.Run("permits requests up to the burst size", func(t *testing.T) {
+		rl := newRateLimiter(newMemorySharedState(), "test", 1, 3)
+
+		for i := 0; i < 3; i++ {
+			if !rl.allow(context.Background(), "10.0.0.1") {
+				t.Errorf("expected request %v to be allowed", i+1)
+			}
+		}
+
+		if rl.allow(context.Background(), "10.0.0.1") {
+			t.Errorf("expected request exceeding burst to be denied")
+		} else if !rl.allow(context.Background(), "10.0.0.2") {
+			t.Errorf("expected request from a different key to be allowed")
+		}
+	})
+
+	t.Run("permits all requests when disabled", func(t *testing.T) {
+		rl := newRateLimiter(newMemorySharedState(), "test", 0, 1)
+
+		for i := 0; i < 10; i++ {
+			if !rl.allow(context.Background(), "10.0.0.1") {
+				t.Errorf("expected request %v to be allowed", i+1)
+			}
+		}
+	})
+
+	t.Run("returns too many requests once limit exceeded", func(t *testing.T) {
+		limitedApp := &Application{config: app.config, store: app.store, metrics: newMetrics(), rateLimiter: newRateLimiter(newMemorySharedState(), "test", 1, 1), ipHasher: app.ipHasher}
+		handler := limitedApp.rateLimit(limitedApp.handleCreateSecret, tooManyRequests)
+
+		if r := post(t, handler, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 303 {
+			t.Errorf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		if r := post(t, handler, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 429 {
+			t.Errorf("wanted 429 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("keys on the client ip address", func(t *testing.T) {
+		key := func(remoteAddr string, forwardedFor string) (k string) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = remoteAddr
+			if forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", forwardedFor)
+			}
+
+			app.clientIPs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { k = rateLimitKey(r) })).ServeHTTP(httptest.NewRecorder(), r)
+
+			return k
+		}
+
+		if k := key("192.168.1.10:5000", ""); k != "192.168.1.10" {
+			t.Errorf("wanted remote address key, got %v", k)
+		}
+		if k := key("127.0.0.1:5000", "198.51.100.1, 203.0.113.7"); k != "203.0.113.7" {
+			t.Errorf("wanted forwarded ip key from a trusted proxy, got %v", k)
+		}
+		if k := key("192.168.1.10:5000", "203.0.113.7"); k != "192.168.1.10" {
+			t.Errorf("wanted remote address key from an untrusted peer, got %v", k)
+		}
+	})
+}
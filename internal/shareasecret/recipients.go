@@ -0,0 +1,138 @@
+package shareasecret
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// recipientEmailsPerMinute and recipientEmailsBurst limit how many viewing URLs can be emailed to recipients, both by
+// each IP address and of each secret, so that instances cannot be used to relay large volumes of email
+const (
+	recipientEmailsPerMinute = 1
+	recipientEmailsBurst     = 5
+)
+
+// recipientEmailSubject is the subject of the emails viewing URLs are sent to recipients in
+const recipientEmailSubject = "A secret has been shared with you"
+
+// recipientEmailsEnabled identifies whether creators can email the viewing URLs of their secrets to recipients
+func (a *Application) recipientEmailsEnabled() bool {
+	return a.config.Email.RecipientEmails && a.emails.enabled()
+}
+
+// recipientDomainPermitted identifies whether viewing URLs can be emailed to the given address, based on the
+// configured allowed and denied domains. Domains also match their subdomains, and denied domains take precedence.
+func (a *Application) recipientDomainPermitted(email string) bool {
+	_, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return false
+	}
+
+	matches := func(domains []string) bool {
+		for _, d := range domains {
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if matches(a.config.Email.RecipientDeniedDomains) {
+		return false
+	}
+
+	return len(a.config.Email.RecipientAllowedDomains) == 0 || matches(a.config.Email.RecipientAllowedDomains)
+}
+
+// handleEmailRecipient emails the viewing URL of a secret that can still be viewed to the recipient given by its
+// creator, so that the creator doesn't have to copy it into another channel themselves. Only the viewing URL is sent:
+// never the management URL, and never anything written by the creator (such as the label), so the emails cannot be
+// used to deliver arbitrary content. The creator is returned to the management page of the secret.
+func (a *Application) handleEmailRecipient(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+	lang := language(r.Context())
+
+	if msg, err := a.emailRecipient(r, managementID, strings.TrimSpace(r.PostFormValue("recipientEmail")), lang); err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("emailing recipient")
+		redirectToOopsPage(w, r)
+		return
+	} else if msg != "" {
+		setFlashErr(msg, w, r)
+	} else {
+		setFlashSuccess(translate("flash.recipientEmailed", lang), w, r)
+	}
+
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/manage-secret/%s", managementID)))
+}
+
+// emailRecipient sends the viewing URL of the secret with the given management ID to the recipient. A message (in the
+// given language) describing why it could not be sent is returned if recipient emails are disabled, the recipient is
+// invalid or not permitted, too many emails have been sent, or the secret can no longer be viewed.
+func (a *Application) emailRecipient(r *http.Request, managementID string, recipient string, lang string) (string, error) {
+	if !a.recipientEmailsEnabled() {
+		return translate("flash.recipientsDisabled", lang), nil
+	} else if !validNotifyEmail(recipient) {
+		return translate("flash.recipientInvalid", lang), nil
+	} else if !a.recipientDomainPermitted(recipient) {
+		return translate("flash.recipientNotPermitted", lang), nil
+	}
+
+	if !a.recipientRateLimiter.allow(r.Context(), rateLimitKey(r)) || !a.recipientRateLimiter.allow(r.Context(), managementID) {
+		zerolog.Ctx(r.Context()).Warn().Str("ip", rateLimitKey(r)).Str("management_id", managementID).Msg("recipient email rate limit exceeded")
+		return translate("flash.recipientRateLimited", lang), nil
+	}
+
+	secret, err := a.store.GetByManagementID(r.Context(), managementID)
+	if errors.Is(err, errSecretNotFound) || (err == nil && (secret.deleted() || secret.expired())) {
+		return translate("flash.secretUnavailable", lang), nil
+	} else if err != nil {
+		return "", fmt.Errorf("retrieving secret: %w", err)
+	}
+
+	a.emails.send(recipient, secret.accessID, recipientEmailSubject, a.recipientEmailBody(secret))
+
+	// only the domain is recorded, so that the events of a secret don't reveal who it was shared with
+	_, domain, _ := strings.Cut(recipient, "@")
+	a.recordEvent(r, secretEvent{managementID: managementID, eventType: eventRecipientEmailed, detail: strings.ToLower(domain)})
+
+	return "", nil
+}
+
+// recipientEmailBody describes the email the viewing URL of the secret is sent to recipients in, making clear how many
+// times the link can be opened, when it can first be opened and when it expires
+func (a *Application) recipientEmailBody(s storedSecret) string {
+	lines := []string{
+		"Someone has shared a secret with you. Open it here:",
+		"",
+		fmt.Sprintf("%s/secret/%s", a.baseURL, s.accessID),
+		"",
+	}
+
+	if s.maximumViews == 1 || s.burnAfterReading {
+		lines = append(lines, "This link can only be opened once, after which the secret is gone for good.")
+	} else if s.maximumViews > 1 {
+		lines = append(lines, fmt.Sprintf("This link can only be opened %v more time(s), after which the secret is gone for good.", s.maximumViews-s.views))
+	}
+
+	if s.notBefore > 0 {
+		notBefore := time.UnixMilli(s.notBefore).UTC()
+		lines = append(lines, fmt.Sprintf("It cannot be opened until %s.", notBefore.Format("2 Jan 2006 15:04 MST")))
+	}
+
+	if s.ttl > 0 {
+		expiresAt := time.UnixMilli(s.createdAt + (s.ttl * 60 * 1000)).UTC()
+		lines = append(lines, fmt.Sprintf("It expires at %s and cannot be opened after then.", expiresAt.Format("2 Jan 2006 15:04 MST")))
+	}
+
+	return strings.Join(append(lines,
+		"",
+		"If the secret is protected with a passphrase, the person who shared it will give it to you separately.",
+		"If you weren't expecting this email, you can safely ignore it.",
+	), "\n")
+}
@@ -0,0 +1,151 @@
+package shareasecret
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecipientEmails(t *testing.T) {
+	send := func(t *testing.T, managementID string, recipient string) consumedResponse {
+		t.Helper()
+
+		r := post(t, app.handleEmailRecipient, "recipientEmail="+recipient, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !responseIsRedirectTo(r, "/manage-secret/"+managementID) {
+			t.Fatalf("expected redirect to management page, got %v %v", r.statusCode, r.headers.Get("Location"))
+		}
+
+		return r
+	}
+
+	enable := func(t *testing.T) *fakeMailer {
+		m := withFakeMailer(t)
+
+		app.config.Email.RecipientEmails = true
+		limiter := app.recipientRateLimiter
+		app.recipientRateLimiter = newRateLimiter(newMemorySharedState(), "recipient_emails", recipientEmailsPerMinute, recipientEmailsBurst)
+		t.Cleanup(func() {
+			app.config.Email.RecipientEmails = false
+			app.recipientRateLimiter = limiter
+		})
+
+		return m
+	}
+
+	t.Run("refuses to email recipients unless enabled", func(t *testing.T) {
+		m := withFakeMailer(t)
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		if r := send(t, managementID, "recipient@example.com"); r.cookies[0].Name != "flash_err" {
+			t.Errorf("expected flash_err cookie to be present, got %v", r.cookies)
+		}
+
+		app.emails.wait()
+		if len(m.sent) != 0 {
+			t.Errorf("expected no emails to be sent, got %v", m.sent)
+		}
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if strings.Contains(r.body, `name="recipientEmail"`) {
+			t.Errorf("did not expect recipient email form in body")
+		}
+	})
+
+	t.Run("emails only the viewing url to the recipient", func(t *testing.T) {
+		m := enable(t)
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !strings.Contains(r.body, `name="recipientEmail"`) {
+			t.Errorf("expected recipient email form in body")
+		}
+
+		if r := send(t, managementID, "recipient@example.com"); r.cookies[0].Name != "flash_success" {
+			t.Errorf("expected flash_success cookie to be present, got %v", r.cookies)
+		}
+
+		app.emails.wait()
+		if !m.sentTo("recipient@example.com", recipientEmailSubject) {
+			t.Fatalf("expected email to be sent to recipient, got %v", m.sent)
+		}
+
+		body := m.bodies[0]
+		for _, want := range []string{"/secret/" + accessID, "only be opened once", "expires at"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected %q in email body, got %v", want, body)
+			}
+		}
+		if strings.Contains(body, managementID) {
+			t.Errorf("did not expect management id in email body")
+		}
+	})
+
+	t.Run("refuses invalid or unpermitted recipients", func(t *testing.T) {
+		m := enable(t)
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		allowed, denied := app.config.Email.RecipientAllowedDomains, app.config.Email.RecipientDeniedDomains
+		app.config.Email.RecipientAllowedDomains = []string{"example.com"}
+		app.config.Email.RecipientDeniedDomains = []string{"spam.example.com"}
+		defer func() {
+			app.config.Email.RecipientAllowedDomains, app.config.Email.RecipientDeniedDomains = allowed, denied
+		}()
+
+		for _, recipient := range []string{"", "not-an-email", "recipient@example.org", "recipient@spam.example.com", "recipient@a.spam.example.com"} {
+			if r := send(t, managementID, recipient); r.cookies[0].Name != "flash_err" {
+				t.Errorf("expected flash_err cookie for %q, got %v", recipient, r.cookies)
+			}
+		}
+
+		if r := send(t, managementID, "recipient@mail.example.com"); r.cookies[0].Name != "flash_success" {
+			t.Errorf("expected subdomain of allowed domain to be permitted, got %v", r.cookies)
+		}
+
+		app.emails.wait()
+		if len(m.sent) != 1 {
+			t.Errorf("expected only the permitted recipient to be emailed, got %v", m.sent)
+		}
+	})
+
+	t.Run("refuses to email the recipients of unavailable secrets", func(t *testing.T) {
+		enable(t)
+		_, managementID := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		if r := send(t, managementID, "recipient@example.com"); r.cookies[0].Name != "flash_err" {
+			t.Errorf("expected flash_err cookie to be present, got %v", r.cookies)
+		}
+	})
+
+	t.Run("limits how many recipients each secret is emailed to", func(t *testing.T) {
+		m := enable(t)
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		for i := 0; i < recipientEmailsBurst; i++ {
+			send(t, managementID, "recipient@example.com")
+		}
+
+		if r := send(t, managementID, "recipient@example.com"); r.cookies[0].Name != "flash_err" {
+			t.Errorf("expected flash_err cookie once rate limited, got %v", r.cookies)
+		}
+
+		app.emails.wait()
+		if len(m.sent) != recipientEmailsBurst {
+			t.Errorf("expected %v emails to be sent, got %v", recipientEmailsBurst, len(m.sent))
+		}
+	})
+}
+
+func TestRecipientEmailBody(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC).UnixMilli()
+
+	body := app.recipientEmailBody(storedSecret{accessID: "abc", maximumViews: 3, views: 1, ttl: 0, createdAt: createdAt})
+	if !strings.Contains(body, "opened 2 more time(s)") || strings.Contains(body, "expires") {
+		t.Errorf("expected remaining views and no expiry, got %v", body)
+	}
+
+	body = app.recipientEmailBody(storedSecret{accessID: "abc", maximumViews: 3, burnAfterReading: true, ttl: 90, createdAt: createdAt})
+	if !strings.Contains(body, "only be opened once") || !strings.Contains(body, "expires at 2 Jan 2024 11:30 UTC") {
+		t.Errorf("expected single view and expiry, got %v", body)
+	}
+}
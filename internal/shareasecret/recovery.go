@@ -0,0 +1,51 @@
+package shareasecret
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// hashRecoveryToken hashes the recovery token secrets are associated with. Unlike passphrases the hash is unsalted, as
+// the secrets of a recovery token have to be found by it.
+func hashRecoveryToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(h[:])
+}
+
+// handleGetRevoke renders the page that creators can revoke all of the secrets associated with a recovery token from
+func (a *Application) handleGetRevoke(w http.ResponseWriter, r *http.Request) {
+	pageRevoke(notificationsFromRequest(r, w)).Render(r.Context(), w)
+}
+
+// handleRevoke deletes all of the secrets associated with the submitted recovery token, for when a creator suspects
+// their secrets have been compromised
+func (a *Application) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	token := r.PostFormValue("recoveryToken")
+	if token == "" {
+		setFlashErr(translate("flash.recoveryTokenRequired", language(r.Context())), w, r)
+		safeRedirect(w, r, pathFor(r.Context(), "/revoke"))
+		return
+	}
+
+	accessIDs, err := a.store.Revoke(r.Context(), hashRecoveryToken(token))
+	if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Msg("revoking secrets")
+		redirectToOopsPage(w, r)
+		return
+	}
+
+	if len(accessIDs) > 0 {
+		a.metrics.secretsDeleted(deletionReasonUserDeleted, int64(len(accessIDs)))
+	}
+	for _, accessID := range accessIDs {
+		a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventDeleted, detail: deletionReasonUserDeleted})
+	}
+
+	setFlashSuccess(fmt.Sprintf(translate("flash.secretsRevoked", language(r.Context())), len(accessIDs)), w, r)
+	safeRedirect(w, r, pathFor(r.Context(), "/revoke"))
+}
@@ -0,0 +1,66 @@
+package shareasecret
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestRevoke(t *testing.T) {
+	create := func(t *testing.T, recoveryToken string) string {
+		t.Helper()
+
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&recoveryToken="+recoveryToken, emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v: %v", r.statusCode, r.body)
+		}
+
+		return strings.TrimPrefix(r.headers.Get("Location"), "/manage-secret/")
+	}
+
+	t.Run("rejects recovery tokens that are too short", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&recoveryToken=short", emptyRequestConfigurer)
+		if r.statusCode != 400 || !strings.Contains(r.body, "Recovery token is too short") {
+			t.Errorf("wanted 400 status code for short recovery token, got %v: %v", r.statusCode, r.body)
+		}
+	})
+
+	t.Run("deletes every secret created with the recovery token", func(t *testing.T) {
+		token := "correct-horse-battery-staple"
+		revoked := []string{create(t, token), create(t, token)}
+		kept := create(t, "")
+
+		r := post(t, app.handleRevoke, "recoveryToken="+token, emptyRequestConfigurer)
+		if !responseIsRedirectTo(r, "/revoke") {
+			t.Fatalf("wanted redirect to revoke page, got %v", r.statusCode)
+		} else if c := r.cookies[0]; c.Name != "flash_success" || c.Value != base64.StdEncoding.EncodeToString([]byte("2 secret(s) revoked.")) {
+			t.Errorf("wanted success flash of 2 revoked secrets, got %v", c)
+		}
+
+		for _, managementID := range revoked {
+			if s, err := app.store.GetByManagementID(context.Background(), managementID); err != nil || s.deletionReason != deletionReasonUserDeleted {
+				t.Errorf("wanted secret deleted by user, got %+v (%v)", s, err)
+			}
+		}
+		if s, err := app.store.GetByManagementID(context.Background(), kept); err != nil || s.deleted() {
+			t.Errorf("wanted secret without recovery token to remain, got %+v (%v)", s, err)
+		}
+	})
+
+	t.Run("requires a recovery token", func(t *testing.T) {
+		r := post(t, app.handleRevoke, "", emptyRequestConfigurer)
+		if !responseIsRedirectTo(r, "/revoke") {
+			t.Errorf("wanted redirect to revoke page, got %v", r.statusCode)
+		} else if c := r.cookies[0]; c.Name != "flash_err" {
+			t.Errorf("wanted error flash, got %v", c)
+		}
+	})
+
+	t.Run("renders the revoke page", func(t *testing.T) {
+		r := get(t, app.handleGetRevoke, emptyRequestConfigurer)
+		if r.statusCode != 200 || !strings.Contains(r.body, `name="recoveryToken"`) {
+			t.Errorf("wanted revoke form, got %v", r.statusCode)
+		}
+	})
+}
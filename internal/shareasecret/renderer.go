@@ -0,0 +1,166 @@
+package shareasecret
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// pageRendererTempl and pageRendererPlain are the renderers the error pages can be rendered with. The plain renderer
+// only depends on html/template and renders minimal, unstyled pages.
+const (
+	pageRendererTempl = "templ"
+	pageRendererPlain = "plain"
+)
+
+// pageRenderer renders the pages shown when a request cannot be served, which must render even when everything else
+// has gone wrong
+type pageRenderer interface {
+	// renderOops renders the catch all error page, including the reference of the failed request if there is one
+	renderOops(ctx context.Context, w io.Writer, reference string, retryURL string) error
+	// renderNotFound renders the page shown when nothing exists at the requested URL
+	renderNotFound(ctx context.Context, w io.Writer) error
+}
+
+// newPageRenderer creates the configured [pageRenderer]
+func newPageRenderer(config *Configuration) pageRenderer {
+	if config.Server.PageRenderer == pageRendererPlain {
+		return plainPageRenderer{}
+	}
+
+	return templPageRenderer{}
+}
+
+// templPageRenderer renders the error pages with templ, as every other page is
+type templPageRenderer struct{}
+
+func (templPageRenderer) renderOops(ctx context.Context, w io.Writer, reference string, retryURL string) error {
+	return pageOops(reference, retryURL).Render(ctx, w)
+}
+
+func (templPageRenderer) renderNotFound(ctx context.Context, w io.Writer) error {
+	return pageNotFound().Render(ctx, w)
+}
+
+// plainPageTemplate is the html/template the [plainPageRenderer] renders every page with
+var plainPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="{{ .Language }}">
+	<head>
+		<title>{{ .Title }}</title>
+		<meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+	</head>
+	<body>
+		<main>
+			<h1>{{ .Title }}</h1>
+			<p>{{ .Body }}</p>
+			{{- if .Reference }}
+			<p>{{ .ReferenceText }} <code>{{ .Reference }}</code></p>
+			{{- end }}
+			{{- if .RetryURL }}
+			<p><a href="{{ .RetryURL }}">{{ .RetryText }}</a></p>
+			{{- end }}
+		</main>
+	</body>
+</html>
+`))
+
+// plainPage contains the values the [plainPageTemplate] is executed with
+type plainPage struct {
+	Language      string
+	Title         string
+	Body          string
+	ReferenceText string
+	Reference     string
+	RetryText     string
+	RetryURL      string
+}
+
+// plainPageRenderer renders the error pages with html/template, independently of templ and the assets of the
+// application
+type plainPageRenderer struct{}
+
+func (plainPageRenderer) renderOops(ctx context.Context, w io.Writer, reference string, retryURL string) error {
+	lang := language(ctx)
+
+	return plainPageTemplate.Execute(w, plainPage{
+		Language:      lang,
+		Title:         translate("oops.title", lang),
+		Body:          translate("oops.body", lang),
+		ReferenceText: translate("oops.reference", lang),
+		Reference:     reference,
+		RetryText:     translate("oops.retry", lang),
+		RetryURL:      retryURL,
+	})
+}
+
+func (plainPageRenderer) renderNotFound(ctx context.Context, w io.Writer) error {
+	lang := language(ctx)
+
+	return plainPageTemplate.Execute(w, plainPage{
+		Language: lang,
+		Title:    translate("notFound.title", lang),
+		Body:     translate("notFound.body", lang),
+	})
+}
+
+type pageRendererContextKey struct{}
+
+// renderers adds the configured [pageRenderer] to the context of the request, for the error pages rendered whilst
+// serving it
+func (a *Application) renderers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), pageRendererContextKey{}, a.pages)))
+	})
+}
+
+// pageRendererFrom retrieves the configured [pageRenderer] from the context, falling back to the templ renderer
+func pageRendererFrom(ctx context.Context) pageRenderer {
+	if p, ok := ctx.Value(pageRendererContextKey{}).(pageRenderer); ok {
+		return p
+	}
+
+	return templPageRenderer{}
+}
+
+// renderErrorPage renders an error page with the configured [pageRenderer], responding with the given status code.
+// Pages are rendered in full before anything is written so that, should rendering fail or panic, the page can be
+// rendered again by the [plainPageRenderer] instead.
+func renderErrorPage(w http.ResponseWriter, r *http.Request, statusCode int, render func(p pageRenderer, w io.Writer) error) {
+	var buf bytes.Buffer
+
+	if err := renderSafely(func() error { return render(pageRendererFrom(r.Context()), &buf) }); err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Msg("rendering error page, falling back to plain html")
+
+		buf.Reset()
+		if err := render(plainPageRenderer{}, &buf); err != nil {
+			zerolog.Ctx(r.Context()).Err(err).Msg("rendering plain html error page")
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	w.Write(buf.Bytes())
+}
+
+// renderSafely calls the given render function, returning any panic it raises as an error
+func renderSafely(render func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic rendering page: %v", p)
+		}
+	}()
+
+	return render()
+}
+
+// notFound renders the not found page with a 404 status code
+func notFound(w http.ResponseWriter, r *http.Request) {
+	renderErrorPage(w, r, http.StatusNotFound, func(p pageRenderer, w io.Writer) error {
+		return p.renderNotFound(r.Context(), w)
+	})
+}
@@ -0,0 +1,64 @@
+package shareasecret
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// panickingPageRenderer is a [pageRenderer] that panics whenever it renders a page
+type panickingPageRenderer struct{}
+
+func (panickingPageRenderer) renderOops(ctx context.Context, w io.Writer, reference string, retryURL string) error {
+	panic("rendering oops page")
+}
+
+func (panickingPageRenderer) renderNotFound(ctx context.Context, w io.Writer) error {
+	panic("rendering not found page")
+}
+
+func TestPageRenderers(t *testing.T) {
+	// requests are served with the given page renderer in their context, as the renderers middleware would
+	serve := func(p pageRenderer, h http.HandlerFunc) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(context.WithValue(context.WithValue(r.Context(), pageRendererContextKey{}, p), requestIDContextKey{}, "abc123"))
+
+		w := httptest.NewRecorder()
+		h(w, r)
+
+		return w
+	}
+
+	t.Run("renders error pages with templ by default", func(t *testing.T) {
+		w := serve(templPageRenderer{}, notFound)
+		if w.Code != 404 || !strings.Contains(w.Body.String(), "style.css") {
+			t.Errorf("wanted styled not found page, got %v %v", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("renders plain error pages when configured to", func(t *testing.T) {
+		if _, ok := newPageRenderer(&Configuration{}).(templPageRenderer); !ok {
+			t.Errorf("wanted templ renderer by default")
+		}
+
+		config := &Configuration{}
+		config.Server.PageRenderer = pageRendererPlain
+
+		w := serve(newPageRenderer(config), notFound)
+		if w.Code != 404 || !strings.Contains(w.Body.String(), "<h1>not found</h1>") || strings.Contains(w.Body.String(), "style.css") {
+			t.Errorf("wanted plain not found page, got %v %v", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("falls back to plain error pages should rendering panic", func(t *testing.T) {
+		w := serve(panickingPageRenderer{}, internalServerError)
+		if w.Code != 500 || !strings.Contains(w.Body.String(), "<code>abc123</code>") {
+			t.Errorf("wanted plain oops page with a reference, got %v %v", w.Code, w.Body.String())
+		} else if w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("wanted html content type, got %v", w.Header().Get("Content-Type"))
+		}
+	})
+}
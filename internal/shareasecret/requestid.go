@@ -0,0 +1,46 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/rs/zerolog"
+)
+
+// requestIDHeader is the response header containing the ID of the request
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern matches the IDs generated for requests, so that references supplied by visitors can be validated
+var requestIDPattern = regexp.MustCompile("^[0-9a-f]{16}$")
+
+// requestIDContextKey is the context key the ID of the current request is stored under
+type requestIDContextKey struct{}
+
+// requestIDs wraps the given handler, assigning every request a random ID that is added to its logger and returned in
+// the response. Visitors shown an error can quote the ID as a reference, mapping their report to the exact log entries.
+func (a *Application) requestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := secureID(8)
+		if err != nil {
+			zerolog.Ctx(r.Context()).Err(err).Msg("generating request id")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		l := zerolog.Ctx(r.Context()).With().Str("request_id", id).Logger()
+		ctx := context.WithValue(l.WithContext(r.Context()), requestIDContextKey{}, id)
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestID retrieves the ID of the current request from the context
+func requestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDContextKey{}).(string); ok {
+		return id
+	}
+
+	return ""
+}
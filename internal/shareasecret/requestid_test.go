@@ -0,0 +1,78 @@
+package shareasecret
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDs(t *testing.T) {
+	t.Run("assigns every request an id returned in the response", func(t *testing.T) {
+		var id string
+
+		recorder := httptest.NewRecorder()
+		app.requestIDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = requestID(r.Context())
+		})).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+		if !requestIDPattern.MatchString(id) {
+			t.Errorf("wanted a request id in the context, got %q", id)
+		} else if h := recorder.Header().Get(requestIDHeader); h != id {
+			t.Errorf("wanted %v header of %v, got %v", requestIDHeader, id, h)
+		}
+	})
+
+	t.Run("shows the request id as a reference when an error occurs", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		app.requestIDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			internalServerError(w, r)
+		})).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+		if recorder.Code != 500 {
+			t.Errorf("wanted 500 status code, got %v", recorder.Code)
+		} else if !strings.Contains(recorder.Body.String(), recorder.Header().Get(requestIDHeader)) {
+			t.Errorf("wanted request id in body")
+		}
+	})
+
+	t.Run("passes the request id to the oops page", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		app.requestIDs(http.HandlerFunc(redirectToOopsPage)).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+		if l := recorder.Header().Get("Location"); l != "/oops?ref="+recorder.Header().Get(requestIDHeader) {
+			t.Errorf("wanted redirect to oops page with reference, got %v", l)
+		}
+	})
+
+	t.Run("offers to try again from the referring page of this instance", func(t *testing.T) {
+		cases := map[string]string{
+			"": `href="/"`,
+			"http://example.com/manage-secret/abc?x=1": `href="/manage-secret/abc?x=1"`,
+			"http://evil.example/phish":                `href="/"`,
+			"http://example.com/oops?ref=abc":          `href="/"`,
+		}
+
+		for referer, wanted := range cases {
+			r := get(t, app.handleOops, func(r *http.Request) {
+				r.Host = "example.com"
+				r.Header.Set("Referer", referer)
+			})
+			if !strings.Contains(r.body, wanted+`>try again</a>`) {
+				t.Errorf("wanted try again link of %v for referer %q, got %v", wanted, referer, r.body)
+			}
+		}
+	})
+
+	t.Run("only shows valid references on the oops page", func(t *testing.T) {
+		r := get(t, app.handleOops, func(r *http.Request) { r.URL.RawQuery = "ref=0123456789abcdef" })
+		if !strings.Contains(r.body, "<code>0123456789abcdef</code>") {
+			t.Errorf("wanted reference in body")
+		}
+
+		r = get(t, app.handleOops, func(r *http.Request) { r.URL.RawQuery = "ref=call+us+on+0123" })
+		if strings.Contains(r.body, "call us") {
+			t.Errorf("did not expect invalid reference in body")
+		}
+	})
+}
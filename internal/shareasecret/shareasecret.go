@@ -1,35 +1,223 @@
 package shareasecret
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// shutdownTimeout is the maximum amount of time in-flight requests are given to complete once the application has been
+// asked to stop
+const shutdownTimeout = 10 * time.Second
+
+const (
+	// defaultReadTimeout is the maximum amount of time a client is given to send a request, headers and body included,
+	// so that slow clients cannot hold connections open indefinitely
+	defaultReadTimeout = 10 * time.Second
+	// defaultWriteTimeout is the maximum amount of time a request is given to be served once its headers have been read
+	defaultWriteTimeout = 30 * time.Second
+	// defaultIdleTimeout is how long an idle keep-alive connection is kept open waiting for another request
+	defaultIdleTimeout = 120 * time.Second
+)
+
+// defaultContentSecurityPolicy is the Content-Security-Policy header sent when one isn't configured. All pages only
+// load scripts, styles and images served by the application itself.
+const defaultContentSecurityPolicy = "default-src 'self'; frame-ancestors 'none'"
+
+// defaultTTL is the TTL (time to live) of secrets created without one when it isn't configured, matching the shortest
+// TTL preset of the home page
+const defaultTTL = 30 * time.Minute
+
+// defaultMaximumSecretSize is the maximum size (in bytes) of a secret's cipher text when one isn't configured
+const defaultMaximumSecretSize = 64 * 1024
+
+// defaultSecretSizeWarningThreshold is the size (in bytes) of a secret's cipher text above which its creator is warned
+// that it is unusually large, when it isn't configured
+const defaultSecretSizeWarningThreshold = 16 * 1024
+
+// maximumRequestOverhead is the number of bytes a secret creation request body can exceed the maximum secret size by,
+// leaving room for the other fields submitted alongside the cipher text
+const maximumRequestOverhead = 4 * 1024
+
+// defaultViewingIDSize is the number of random bytes in the viewing ID of a secret when it isn't configured
+const defaultViewingIDSize = 24
+
+// minimumViewingIDSize is the fewest random bytes the viewing ID of a secret can be configured to contain. 128 bits
+// of entropy keep viewing IDs infeasible to guess even when shortened.
+const minimumViewingIDSize = 16
+
+// viewingIDEncodingHex and viewingIDEncodingBase64URL are the encodings viewing IDs can be generated with. Base64URL
+// produces shorter URLs than hex from the same number of random bytes.
+const (
+	viewingIDEncodingHex       = "hex"
+	viewingIDEncodingBase64URL = "base64url"
+)
+
+// maximumRevealDelay is the maximum number of seconds a creator can delay the reveal of their secret by once decrypted
+const maximumRevealDelay = 60
+
+// maximumLabelLength is the maximum number of characters in the label a creator can attach to a secret
+const maximumLabelLength = 200
+
+// defaultDeletedSecretRetention is how long deleted secrets are kept, without their cipher text, before they are purged
+// entirely unless configured otherwise
+const defaultDeletedSecretRetention = 30 * 24 * time.Hour
+
+// minimumRecoveryTokenLength is the minimum number of characters in the recovery token a creator can associate secrets
+// with. Recovery tokens are looked up via an unsalted hash, so they must be long enough not to be guessable.
+const minimumRecoveryTokenLength = 16
+
+// passphraseAttemptsPerMinute is the number of attempts that can be made to open an individual passphrase protected
+// secret each minute. Attempts are limited per secret rather than per IP address so that distributed brute force
+// attempts are limited too.
+const passphraseAttemptsPerMinute = 5
+
 // deletionReasonExpired is a deletion reason used when secrets have exceeded their TTL (time to live)
 const deletionReasonExpired = "expired"
 
 // deletionReasonUserDeleted is a deletion reason used when a user actions the deletion themselves
 const deletionReasonUserDeleted = "user_deleted"
 
+// deletionReasonViewed is a deletion reason used when a secret created with the burn after reading option has been
+// viewed
+const deletionReasonViewed = "viewed"
+
 // deletionReasonMaximumViewCountHit is a deletion reason used when the maximum number of views for a secret has been
 // hit or exceeded
 const deletionReasonMaximumViewCountHit = "maximum_view_count_hit"
 
+// deletionReasonDecryptionFailures is a deletion reason used when the number of failed decryption attempts reported
+// for a secret has hit or exceeded the configured maximum
+const deletionReasonDecryptionFailures = "decryption_failures"
+
+// deletionReasonDescriptions maps each deletion reason to a human readable description shown to the creator of a secret
+var deletionReasonDescriptions = map[string]string{
+	deletionReasonExpired:             "This secret expired.",
+	deletionReasonUserDeleted:         "You deleted this secret.",
+	deletionReasonMaximumViewCountHit: "This secret reached its maximum number of views.",
+	deletionReasonViewed:              "This secret was viewed and burned.",
+	deletionReasonDecryptionFailures:  "This secret had too many failed decryption attempts.",
+}
+
+// describeDeletionReason returns a human readable description of the given deletion reason
+func describeDeletionReason(reason string) string {
+	if d, ok := deletionReasonDescriptions[reason]; ok {
+		return d
+	}
+
+	return "This secret was deleted."
+}
+
 // Configuration contains all of the possible configuration options for the application.
 type Configuration struct {
 	Database struct {
-		Path string
+		Driver       string
+		Path         string
+		DSN          string
+		BusyTimeout  time.Duration
+		QueryTimeout time.Duration
 	}
 	Server struct {
-		BaseUrl       string
-		ListeningAddr string
+		BaseUrl               string
+		BasePath              string
+		ListeningAddr         string
+		ReadTimeout           time.Duration
+		WriteTimeout          time.Duration
+		IdleTimeout           time.Duration
+		ContentSecurityPolicy string
+		DisableCompression    bool
+		TLSCertFile           string
+		TLSKeyFile            string
+		PageRenderer          string
+		TrustedProxies        []net.IPNet
+	}
+	Secrets struct {
+		MaximumSize               int
+		SizeWarningThreshold      int
+		MaximumDecryptionFailures int
+		DefaultTTL                time.Duration
+		MinimumTTL                time.Duration
+		MaximumTTL                time.Duration
+		ClampTTL                  bool
+		AllowNeverExpire          bool
+		ViewingIDSize             int
+		ViewingIDEncoding         string
+		// MaximumConcurrentCreations is the number of secrets that can be inserted at once, with 0 leaving creations
+		// unlimited
+		MaximumConcurrentCreations int
+	}
+	RateLimiting struct {
+		RequestsPerMinute int
+		Burst             int
+	}
+	SharedState struct {
+		RedisURL string
+	}
+	Jobs struct {
+		DeleteExpiredSecretsInterval time.Duration
+		DeletedSecretRetention       time.Duration
+	}
+	Admin struct {
+		Token string
+	}
+	API struct {
+		DistinguishGone bool
+	}
+	Branding struct {
+		Name       string
+		Logo       string
+		FooterText string
+		SupportURL string
+	}
+	Email struct {
+		SMTPHost      string
+		SMTPPort      int
+		SMTPUsername  string
+		SMTPPassword  string
+		From          string
+		EncryptionKey []byte
+		// RecipientEmails permits creators to email the viewing URL of their secrets to recipients, optionally only
+		// those at (or never those at) the given domains
+		RecipientEmails         bool
+		RecipientAllowedDomains []string
+		RecipientDeniedDomains  []string
+	}
+	Tracing struct {
+		OTLPEndpoint string
+	}
+	Logging struct {
+		Format         string
+		Level          zerolog.Level
+		File           string
+		FileMaxSize    int
+		FileMaxBackups int
+	}
+	Captcha struct {
+		Provider  string
+		SiteKey   string
+		SecretKey string
 	}
 	SecretCreationRestrictions struct {
 		IPAddresses struct {
@@ -39,29 +227,453 @@ type Configuration struct {
 	}
 }
 
-// PopulateFromEnv populates all of the configuration values from environment variables, returning errors if this
-// cannot be achieved.
+// Populate populates all of the configuration values from environment variables and then from the given command line
+// arguments (which take precedence), returning errors if required values are missing or any values are malformed.
+func (c *Configuration) Populate(args []string) error {
+	if err := c.PopulateFromEnv(); err != nil {
+		return err
+	}
+
+	if err := c.PopulateFromFlags(args); err != nil {
+		return err
+	}
+
+	return c.Validate()
+}
+
+// PopulateFromEnv populates all of the configuration values from environment variables, returning errors if any of
+// them are malformed.
 func (c *Configuration) PopulateFromEnv() error {
 	err := godotenv.Load()
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("loading env file: %w", err)
 	}
 
+	c.Database.Driver = os.Getenv("SHAREASECRET_DB_DRIVER")
+	if c.Database.Driver == "" {
+		c.Database.Driver = driverSQLite
+	}
+
 	c.Database.Path = os.Getenv("SHAREASECRET_DB_PATH")
-	if c.Database.Path == "" {
-		return fmt.Errorf("SHAREASECRET_DB_PATH not set")
+	c.Database.DSN = os.Getenv("SHAREASECRET_DB_DSN")
+
+	c.Database.BusyTimeout = defaultBusyTimeout
+	if v := os.Getenv("SHAREASECRET_DB_BUSY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_DB_BUSY_TIMEOUT", v)
+		}
+
+		c.Database.BusyTimeout = d
 	}
 
-	c.Server.BaseUrl = os.Getenv("SHAREASECRET_BASE_URL")
-	if c.Server.BaseUrl == "" {
-		return fmt.Errorf("SHAREASECRET_BASE_URL not set")
+	c.Database.QueryTimeout = defaultQueryTimeout
+	if v := os.Getenv("SHAREASECRET_DB_QUERY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_DB_QUERY_TIMEOUT", v)
+		}
+
+		c.Database.QueryTimeout = d
 	}
 
+	c.Server.BaseUrl = os.Getenv("SHAREASECRET_BASE_URL")
+	c.Server.BasePath = os.Getenv("SHAREASECRET_BASE_PATH")
+
 	c.Server.ListeningAddr = os.Getenv("SHAREASECRET_LISTENING_ADDR")
 	if c.Server.ListeningAddr == "" {
 		c.Server.ListeningAddr = "127.0.0.1:8994"
 	}
 
+	c.Server.ReadTimeout = defaultReadTimeout
+	if v := os.Getenv("SHAREASECRET_READ_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_READ_TIMEOUT", v)
+		}
+
+		c.Server.ReadTimeout = d
+	}
+
+	c.Server.WriteTimeout = defaultWriteTimeout
+	if v := os.Getenv("SHAREASECRET_WRITE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_WRITE_TIMEOUT", v)
+		}
+
+		c.Server.WriteTimeout = d
+	}
+
+	c.Server.IdleTimeout = defaultIdleTimeout
+	if v := os.Getenv("SHAREASECRET_IDLE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_IDLE_TIMEOUT", v)
+		}
+
+		c.Server.IdleTimeout = d
+	}
+
+	c.Server.ContentSecurityPolicy = os.Getenv("SHAREASECRET_CONTENT_SECURITY_POLICY")
+	if c.Server.ContentSecurityPolicy == "" {
+		c.Server.ContentSecurityPolicy = defaultContentSecurityPolicy
+	}
+
+	if v := os.Getenv("SHAREASECRET_DISABLE_COMPRESSION"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean (%v) in SHAREASECRET_DISABLE_COMPRESSION", v)
+		}
+
+		c.Server.DisableCompression = b
+	}
+
+	c.Server.PageRenderer = pageRendererTempl
+	if v := os.Getenv("SHAREASECRET_PAGE_RENDERER"); v != "" {
+		if v != pageRendererTempl && v != pageRendererPlain {
+			return fmt.Errorf("invalid renderer (%v) in SHAREASECRET_PAGE_RENDERER", v)
+		}
+
+		c.Server.PageRenderer = v
+	}
+
+	trustedProxies := defaultTrustedProxies
+	if v := strings.TrimSpace(os.Getenv("SHAREASECRET_TRUSTED_PROXIES")); v == trustedProxiesNone {
+		trustedProxies = nil
+	} else if v != "" {
+		trustedProxies = strings.Split(v, ",")
+	}
+
+	c.Server.TrustedProxies = nil
+	for _, v := range trustedProxies {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		// single IP addresses are trusted as a network containing only themselves
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return fmt.Errorf("invalid ip in SHAREASECRET_TRUSTED_PROXIES: %v", v)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			c.Server.TrustedProxies = append(c.Server.TrustedProxies, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, nw, err := net.ParseCIDR(v)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR (%v) in SHAREASECRET_TRUSTED_PROXIES: %w", v, err)
+		}
+
+		c.Server.TrustedProxies = append(c.Server.TrustedProxies, *nw)
+	}
+
+	c.Server.TLSCertFile = os.Getenv("SHAREASECRET_TLS_CERT_FILE")
+	c.Server.TLSKeyFile = os.Getenv("SHAREASECRET_TLS_KEY_FILE")
+
+	c.Secrets.MaximumSize = defaultMaximumSecretSize
+	if v := os.Getenv("SHAREASECRET_MAXIMUM_SECRET_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_MAXIMUM_SECRET_SIZE", v)
+		}
+
+		c.Secrets.MaximumSize = n
+	}
+
+	// a threshold of 0 never warns creators of the size of their secrets
+	c.Secrets.SizeWarningThreshold = defaultSecretSizeWarningThreshold
+	if v := os.Getenv("SHAREASECRET_SECRET_SIZE_WARNING_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_SECRET_SIZE_WARNING_THRESHOLD", v)
+		}
+
+		c.Secrets.SizeWarningThreshold = n
+	}
+
+	c.Secrets.MaximumDecryptionFailures = 5
+	if v := os.Getenv("SHAREASECRET_MAXIMUM_DECRYPTION_FAILURES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_MAXIMUM_DECRYPTION_FAILURES", v)
+		}
+
+		c.Secrets.MaximumDecryptionFailures = n
+	}
+
+	if v := os.Getenv("SHAREASECRET_MAXIMUM_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_MAXIMUM_TTL", v)
+		}
+
+		c.Secrets.MaximumTTL = d
+	}
+
+	// TTLs are stored in whole minutes, so the minimum must be too
+	if v := os.Getenv("SHAREASECRET_MINIMUM_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute || d%time.Minute != 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_MINIMUM_TTL", v)
+		} else if c.Secrets.MaximumTTL > 0 && d > c.Secrets.MaximumTTL {
+			return fmt.Errorf("SHAREASECRET_MINIMUM_TTL (%v) must not exceed SHAREASECRET_MAXIMUM_TTL", v)
+		}
+
+		c.Secrets.MinimumTTL = d
+	}
+
+	c.Secrets.DefaultTTL = defaultTTL
+	if v := os.Getenv("SHAREASECRET_DEFAULT_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute || d%time.Minute != 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_DEFAULT_TTL", v)
+		} else if c.Secrets.MaximumTTL > 0 && d > c.Secrets.MaximumTTL {
+			return fmt.Errorf("SHAREASECRET_DEFAULT_TTL (%v) must not exceed SHAREASECRET_MAXIMUM_TTL", v)
+		} else if d < c.Secrets.MinimumTTL {
+			return fmt.Errorf("SHAREASECRET_DEFAULT_TTL (%v) must not be less than SHAREASECRET_MINIMUM_TTL", v)
+		}
+
+		c.Secrets.DefaultTTL = d
+	} else if c.Secrets.MaximumTTL > 0 && c.Secrets.MaximumTTL < defaultTTL {
+		c.Secrets.DefaultTTL = c.Secrets.MaximumTTL.Truncate(time.Minute)
+	} else if c.Secrets.MinimumTTL > defaultTTL {
+		c.Secrets.DefaultTTL = c.Secrets.MinimumTTL
+	}
+
+	if v := os.Getenv("SHAREASECRET_CLAMP_TTL"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean (%v) in SHAREASECRET_CLAMP_TTL", v)
+		}
+
+		c.Secrets.ClampTTL = b
+	}
+
+	c.Secrets.AllowNeverExpire = true
+	if v := os.Getenv("SHAREASECRET_ALLOW_NEVER_EXPIRE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean (%v) in SHAREASECRET_ALLOW_NEVER_EXPIRE", v)
+		}
+
+		c.Secrets.AllowNeverExpire = b
+	}
+
+	c.Secrets.ViewingIDSize = defaultViewingIDSize
+	if v := os.Getenv("SHAREASECRET_VIEWING_ID_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_VIEWING_ID_SIZE", v)
+		} else if n < minimumViewingIDSize {
+			return fmt.Errorf("SHAREASECRET_VIEWING_ID_SIZE (%v) must be at least %v bytes", v, minimumViewingIDSize)
+		}
+
+		c.Secrets.ViewingIDSize = n
+	}
+
+	c.Secrets.ViewingIDEncoding = viewingIDEncodingHex
+	if v := os.Getenv("SHAREASECRET_VIEWING_ID_ENCODING"); v != "" {
+		if v != viewingIDEncodingHex && v != viewingIDEncodingBase64URL {
+			return fmt.Errorf("invalid encoding (%v) in SHAREASECRET_VIEWING_ID_ENCODING", v)
+		}
+
+		c.Secrets.ViewingIDEncoding = v
+	}
+
+	c.Secrets.MaximumConcurrentCreations = defaultMaximumConcurrentCreations
+	if v := os.Getenv("SHAREASECRET_MAXIMUM_CONCURRENT_CREATIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_MAXIMUM_CONCURRENT_CREATIONS", v)
+		}
+
+		c.Secrets.MaximumConcurrentCreations = n
+	}
+
+	c.RateLimiting.RequestsPerMinute = 30
+	if v := os.Getenv("SHAREASECRET_RATE_LIMIT_REQUESTS_PER_MINUTE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_RATE_LIMIT_REQUESTS_PER_MINUTE", v)
+		}
+
+		c.RateLimiting.RequestsPerMinute = n
+	}
+
+	c.RateLimiting.Burst = 10
+	if v := os.Getenv("SHAREASECRET_RATE_LIMIT_BURST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_RATE_LIMIT_BURST", v)
+		}
+
+		c.RateLimiting.Burst = n
+	}
+
+	c.Jobs.DeleteExpiredSecretsInterval = 1 * time.Minute
+	if i := os.Getenv("SHAREASECRET_DELETE_EXPIRED_SECRETS_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_DELETE_EXPIRED_SECRETS_INTERVAL", i)
+		}
+
+		c.Jobs.DeleteExpiredSecretsInterval = d
+	}
+
+	c.Jobs.DeletedSecretRetention = defaultDeletedSecretRetention
+	if v := os.Getenv("SHAREASECRET_DELETED_SECRET_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration (%v) in SHAREASECRET_DELETED_SECRET_RETENTION", v)
+		}
+
+		c.Jobs.DeletedSecretRetention = d
+	}
+
+	// state is only shared between instances via Redis if it is configured
+	c.SharedState.RedisURL = os.Getenv("SHAREASECRET_REDIS_URL")
+
+	// admin endpoints are only enabled if an admin token is configured
+	c.Admin.Token = os.Getenv("SHAREASECRET_ADMIN_TOKEN")
+
+	if v := os.Getenv("SHAREASECRET_API_DISTINGUISH_GONE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean (%v) in SHAREASECRET_API_DISTINGUISH_GONE", v)
+		}
+
+		c.API.DistinguishGone = b
+	}
+
+	c.Branding.Name = os.Getenv("SHAREASECRET_BRAND_NAME")
+	c.Branding.Logo = os.Getenv("SHAREASECRET_BRAND_LOGO")
+	c.Branding.FooterText = os.Getenv("SHAREASECRET_BRAND_FOOTER_TEXT")
+	c.Branding.SupportURL = os.Getenv("SHAREASECRET_BRAND_SUPPORT_URL")
+
+	// spans are only exported if an OTLP endpoint is configured
+	c.Tracing.OTLPEndpoint = os.Getenv("SHAREASECRET_OTLP_ENDPOINT")
+
+	c.Logging.Format = logFormatJSON
+	if v := os.Getenv("SHAREASECRET_LOG_FORMAT"); v != "" {
+		if v != logFormatJSON && v != logFormatConsole {
+			return fmt.Errorf("invalid format (%v) in SHAREASECRET_LOG_FORMAT", v)
+		}
+
+		c.Logging.Format = v
+	}
+
+	c.Logging.Level = zerolog.InfoLevel
+	if v := os.Getenv("SHAREASECRET_LOG_LEVEL"); v != "" {
+		l, err := zerolog.ParseLevel(v)
+		if err != nil || l == zerolog.NoLevel {
+			return fmt.Errorf("invalid level (%v) in SHAREASECRET_LOG_LEVEL", v)
+		}
+
+		c.Logging.Level = l
+	}
+
+	// logs are only written to a (rotated) file if one is configured, and to stdout otherwise
+	c.Logging.File = os.Getenv("SHAREASECRET_LOG_FILE")
+
+	c.Logging.FileMaxSize = defaultLogFileMaxSize
+	if v := os.Getenv("SHAREASECRET_LOG_FILE_MAX_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_LOG_FILE_MAX_SIZE", v)
+		}
+
+		c.Logging.FileMaxSize = n
+	}
+
+	c.Logging.FileMaxBackups = defaultLogFileMaxBackups
+	if v := os.Getenv("SHAREASECRET_LOG_FILE_MAX_BACKUPS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number (%v) in SHAREASECRET_LOG_FILE_MAX_BACKUPS", v)
+		}
+
+		c.Logging.FileMaxBackups = n
+	}
+
+	// email notifications are only enabled if an SMTP server is configured
+	if c.Email.SMTPHost = os.Getenv("SHAREASECRET_SMTP_HOST"); c.Email.SMTPHost != "" {
+		c.Email.SMTPPort = 587
+		if v := os.Getenv("SHAREASECRET_SMTP_PORT"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("invalid number (%v) in SHAREASECRET_SMTP_PORT", v)
+			}
+
+			c.Email.SMTPPort = n
+		}
+
+		c.Email.SMTPUsername = os.Getenv("SHAREASECRET_SMTP_USERNAME")
+		c.Email.SMTPPassword = os.Getenv("SHAREASECRET_SMTP_PASSWORD")
+
+		c.Email.From = os.Getenv("SHAREASECRET_SMTP_FROM")
+		if !validNotifyEmail(c.Email.From) {
+			return fmt.Errorf("invalid email (%v) in SHAREASECRET_SMTP_FROM", c.Email.From)
+		}
+
+		k, err := hex.DecodeString(os.Getenv("SHAREASECRET_EMAIL_ENCRYPTION_KEY"))
+		if err != nil || len(k) != 32 {
+			return fmt.Errorf("SHAREASECRET_EMAIL_ENCRYPTION_KEY must be 32 hex encoded bytes")
+		}
+
+		c.Email.EncryptionKey = k
+	}
+
+	if v := os.Getenv("SHAREASECRET_ALLOW_RECIPIENT_EMAILS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean (%v) in SHAREASECRET_ALLOW_RECIPIENT_EMAILS", v)
+		} else if b && c.Email.SMTPHost == "" {
+			return fmt.Errorf("SHAREASECRET_ALLOW_RECIPIENT_EMAILS requires SHAREASECRET_SMTP_HOST to be set")
+		}
+
+		c.Email.RecipientEmails = b
+	}
+
+	for name, domains := range map[string]*[]string{
+		"SHAREASECRET_RECIPIENT_EMAIL_ALLOWED_DOMAINS": &c.Email.RecipientAllowedDomains,
+		"SHAREASECRET_RECIPIENT_EMAIL_DENIED_DOMAINS":  &c.Email.RecipientDeniedDomains,
+	} {
+		*domains = nil
+		for _, v := range strings.Split(os.Getenv(name), ",") {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if v == "" {
+				continue
+			} else if strings.ContainsAny(v, "@/ ") || strings.HasPrefix(v, ".") {
+				return fmt.Errorf("invalid domain (%v) in %v", v, name)
+			}
+
+			*domains = append(*domains, v)
+		}
+	}
+
+	// a CAPTCHA is only required of viewers if the keys of a provider are configured
+	c.Captcha.Provider = os.Getenv("SHAREASECRET_CAPTCHA_PROVIDER")
+	if c.Captcha.Provider == "" {
+		c.Captcha.Provider = captchaProviderTurnstile
+	} else if _, ok := captchaProviders[c.Captcha.Provider]; !ok {
+		return fmt.Errorf("invalid provider (%v) in SHAREASECRET_CAPTCHA_PROVIDER", c.Captcha.Provider)
+	}
+
+	c.Captcha.SiteKey = os.Getenv("SHAREASECRET_CAPTCHA_SITE_KEY")
+	c.Captcha.SecretKey = os.Getenv("SHAREASECRET_CAPTCHA_SECRET_KEY")
+	if (c.Captcha.SiteKey == "") != (c.Captcha.SecretKey == "") {
+		return fmt.Errorf("SHAREASECRET_CAPTCHA_SITE_KEY and SHAREASECRET_CAPTCHA_SECRET_KEY must be set together")
+	}
+
 	if cr := strings.TrimSpace(os.Getenv("SHAREASECRET_SECRET_CREATION_IP_RESTRICTIONS")); cr != "" {
 		for _, v := range strings.Split(cr, ",") {
 			v = strings.TrimSpace(v)
@@ -90,31 +702,318 @@ func (c *Configuration) PopulateFromEnv() error {
 	return nil
 }
 
+// PopulateFromFlags overrides configuration values with those specified in the given command line arguments. The
+// defaults of each flag are the already populated values, so any flag that isn't specified leaves its value untouched.
+func (c *Configuration) PopulateFromFlags(args []string) error {
+	fs := flag.NewFlagSet("shareasecret", flag.ContinueOnError)
+
+	fs.StringVar(&c.Database.Driver, "db-driver", c.Database.Driver, "the database to persist secrets in (sqlite, postgres or memory)")
+	fs.StringVar(&c.Database.Path, "db-path", c.Database.Path, "the path to the database file when using SQLite")
+	fs.StringVar(&c.Database.DSN, "db-dsn", c.Database.DSN, "the connection string of the database when using PostgreSQL")
+	fs.StringVar(&c.Server.BaseUrl, "base-url", c.Server.BaseUrl, "the base URL that shareasecret will be running under")
+	fs.StringVar(&c.Server.BasePath, "base-path", c.Server.BasePath, "the path prefix shareasecret is served under (i.e. /secrets)")
+	fs.StringVar(&c.Server.ListeningAddr, "listening-addr", c.Server.ListeningAddr, "the address the server will listen on")
+	fs.IntVar(&c.Secrets.MaximumSize, "maximum-secret-size", c.Secrets.MaximumSize, "the maximum size (in bytes) of an encrypted secret")
+	fs.DurationVar(
+		&c.Jobs.DeleteExpiredSecretsInterval,
+		"delete-expired-secrets-interval",
+		c.Jobs.DeleteExpiredSecretsInterval,
+		"how often expired secrets are deleted",
+	)
+	fs.DurationVar(
+		&c.Jobs.DeletedSecretRetention,
+		"deleted-secret-retention",
+		c.Jobs.DeletedSecretRetention,
+		"how long deleted secrets are kept before being purged entirely (0 keeps them forever)",
+	)
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	return nil
+}
+
+// Validate ensures all required configuration values are present and that values which may have been overridden by
+// flags are well formed.
+func (c *Configuration) Validate() error {
+	switch c.Database.Driver {
+	case driverSQLite:
+		if c.Database.Path == "" {
+			return fmt.Errorf("SHAREASECRET_DB_PATH not set")
+		}
+	case driverPostgres:
+		if c.Database.DSN == "" {
+			return fmt.Errorf("SHAREASECRET_DB_DSN not set")
+		}
+	case driverMemory:
+	default:
+		return fmt.Errorf("invalid driver (%v) in SHAREASECRET_DB_DRIVER", c.Database.Driver)
+	}
+
+	if c.Server.BaseUrl == "" {
+		return fmt.Errorf("SHAREASECRET_BASE_URL not set")
+	} else if u, err := url.Parse(c.Server.BaseUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url (%v) in SHAREASECRET_BASE_URL", c.Server.BaseUrl)
+	}
+
+	if c.Server.BasePath != "" {
+		if u, err := url.Parse(c.Server.BasePath); err != nil || !strings.HasPrefix(c.Server.BasePath, "/") || u.Path != c.Server.BasePath {
+			return fmt.Errorf("invalid path (%v) in SHAREASECRET_BASE_PATH", c.Server.BasePath)
+		}
+	}
+
+	if c.Server.ListeningAddr == "" {
+		return fmt.Errorf("SHAREASECRET_LISTENING_ADDR not set")
+	}
+
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return fmt.Errorf("SHAREASECRET_TLS_CERT_FILE and SHAREASECRET_TLS_KEY_FILE must be set together")
+	}
+
+	if c.Secrets.MaximumSize < 1 {
+		return fmt.Errorf("invalid number (%v) in SHAREASECRET_MAXIMUM_SECRET_SIZE", c.Secrets.MaximumSize)
+	}
+
+	if c.Jobs.DeleteExpiredSecretsInterval <= 0 {
+		return fmt.Errorf("invalid duration (%v) in SHAREASECRET_DELETE_EXPIRED_SECRETS_INTERVAL", c.Jobs.DeleteExpiredSecretsInterval)
+	}
+
+	if c.Jobs.DeletedSecretRetention < 0 {
+		return fmt.Errorf("invalid duration (%v) in SHAREASECRET_DELETED_SECRET_RETENTION", c.Jobs.DeletedSecretRetention)
+	}
+
+	if c.SharedState.RedisURL != "" {
+		if _, err := redis.ParseURL(c.SharedState.RedisURL); err != nil {
+			return fmt.Errorf("invalid url (%v) in SHAREASECRET_REDIS_URL", c.SharedState.RedisURL)
+		}
+	}
+
+	if c.Tracing.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url (%v) in SHAREASECRET_OTLP_ENDPOINT", c.Tracing.OTLPEndpoint)
+		}
+	}
+
+	if c.Branding.SupportURL != "" {
+		if u, err := url.Parse(c.Branding.SupportURL); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto") {
+			return fmt.Errorf("invalid url (%v) in SHAREASECRET_BRAND_SUPPORT_URL", c.Branding.SupportURL)
+		}
+	}
+
+	return nil
+}
+
 // Application is a wrapper/container for the "ShareASecret" project. All jobs and entry points hang off of this
 // struct.
 type Application struct {
-	db        *database
-	config    *Configuration
-	router    *http.ServeMux
-	baseURL   string
-	webAssets fs.FS
+	store                 SecretStore
+	config                *Configuration
+	router                *http.ServeMux
+	baseURL               string
+	basePath              string
+	webAssets             fs.FS
+	branding              branding
+	assetVersion          string
+	state                 sharedState
+	rateLimiter           *rateLimiter
+	passphraseRateLimiter *rateLimiter
+	recipientRateLimiter  *rateLimiter
+	metrics               *metrics
+	webhooks              *webhookNotifier
+	emails                *emailNotifier
+	captcha               *captchaVerifier
+	ipHasher              *ipHasher
+	tracerProvider        *sdktrace.TracerProvider
+	// creations is the semaphore limiting concurrent creations of secrets (see [Application.acquireCreation]), which
+	// is nil if they are unlimited
+	creations chan struct{}
+	// pages renders the error pages (see [pageRenderer])
+	pages pageRenderer
+	jobs  sync.WaitGroup
+	// comparePassphrase compares passphrases against bcrypt hashes (see [Application.passphraseMatches])
+	comparePassphrase func(hash []byte, passphrase []byte) error
+
+	deleteExpiredSecretsJobRunning atomic.Bool
 }
 
 // NewApplication initializes the Application struct which provides access to all available components of the project.
 func NewApplication(config *Configuration, webAssets fs.FS) (*Application, error) {
-	db, err := newDatabase("file:" + config.Database.Path)
+	var store SecretStore = newMemorySecretStore()
+	if config.Database.Driver != driverMemory {
+		connectionString := config.Database.DSN
+		if config.Database.Driver == driverSQLite {
+			connectionString = sqliteConnectionString(config.Database.Path, config.Database.BusyTimeout)
+		}
+
+		db, err := newDatabase(config.Database.Driver, connectionString)
+		if err != nil {
+			return nil, fmt.Errorf("new db: %w", err)
+		}
+
+		if config.Database.Driver == driverSQLite {
+			pragmas, err := db.sqlitePragmas()
+			if err != nil {
+				return nil, fmt.Errorf("reading sqlite pragmas: %w", err)
+			}
+
+			log.Info().Fields(pragmas).Msg("configured sqlite")
+		}
+
+		store = newSQLSecretStore(db, config.Database.QueryTimeout)
+	}
+
+	var state sharedState = newMemorySharedState()
+	if config.SharedState.RedisURL != "" {
+		redisState, err := newRedisSharedState(config.SharedState.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("new redis shared state: %w", err)
+		}
+
+		state = redisState
+	}
+
+	hasher, err := newIPHasher()
+	if err != nil {
+		return nil, fmt.Errorf("new ip hasher: %w", err)
+	}
+
+	tracerProvider, err := newTracerProvider(config)
+	if err != nil {
+		return nil, fmt.Errorf("new tracer provider: %w", err)
+	}
+
+	assetVersion, err := fingerprintAssets(webAssets)
 	if err != nil {
-		return nil, fmt.Errorf("new db: %w", err)
+		return nil, fmt.Errorf("fingerprinting web assets: %w", err)
 	}
 
 	application := &Application{
-		db:        db,
-		config:    config,
-		router:    http.NewServeMux(),
-		baseURL:   config.Server.BaseUrl,
-		webAssets: webAssets,
+		store:                 store,
+		config:                config,
+		router:                http.NewServeMux(),
+		baseURL:               strings.TrimSuffix(config.Server.BaseUrl, "/") + strings.TrimSuffix(config.Server.BasePath, "/"),
+		basePath:              strings.TrimSuffix(config.Server.BasePath, "/"),
+		webAssets:             webAssets,
+		branding:              newBranding(config),
+		assetVersion:          assetVersion,
+		state:                 state,
+		rateLimiter:           newRateLimiter(state, "requests", config.RateLimiting.RequestsPerMinute, config.RateLimiting.Burst),
+		passphraseRateLimiter: newRateLimiter(state, "passphrases", passphraseAttemptsPerMinute, passphraseAttemptsPerMinute),
+		recipientRateLimiter:  newRateLimiter(state, "recipient_emails", recipientEmailsPerMinute, recipientEmailsBurst),
+		metrics:               newMetrics(),
+		webhooks:              newWebhookNotifier(),
+		emails:                &emailNotifier{key: config.Email.EncryptionKey},
+		captcha:               newCaptchaVerifier(config),
+		ipHasher:              hasher,
+		tracerProvider:        tracerProvider,
+		creations:             newCreationSemaphore(config.Secrets.MaximumConcurrentCreations),
+		pages:                 newPageRenderer(config),
+		comparePassphrase:     bcrypt.CompareHashAndPassword,
+	}
+	if config.Email.SMTPHost != "" {
+		application.emails.mailer = newSMTPMailer(config)
 	}
 	application.mapRoutes()
 
 	return application, nil
 }
+
+// newServer creates the HTTP server that serves all endpoints of the application, with the configured timeouts. A
+// timeout of 0 disables it, except that an idle timeout of 0 falls back to the read timeout.
+func (a *Application) newServer() *http.Server {
+	return &http.Server{
+		Addr:         a.config.Server.ListeningAddr,
+		Handler:      a,
+		ReadTimeout:  a.config.Server.ReadTimeout,
+		WriteTimeout: a.config.Server.WriteTimeout,
+		IdleTimeout:  a.config.Server.IdleTimeout,
+	}
+}
+
+// Run runs all background jobs and serves all HTTP endpoints until the given context is cancelled or the process
+// receives a SIGINT or SIGTERM signal. Once stopped, in-flight requests are given time to complete before the
+// background jobs are stopped and the database connection is closed. The jobs are stopped and the connection closed in
+// the same way if the server fails to start.
+func (a *Application) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// serve all HTTP endpoints
+	server := a.newServer()
+
+	// serve over HTTPS directly when given a certificate, watching it for renewals
+	if a.config.Server.TLSCertFile != "" {
+		certificates, err := newCertificateReloader(a.config.Server.TLSCertFile, a.config.Server.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certificates.GetCertificate,
+		}
+	}
+
+	// run any jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	a.RunDeleteExpiredSecretsJob(jobsCtx)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			log.Info().Str("addr", server.Addr).Msg("booting HTTPS server")
+			serverErr <- server.ListenAndServeTLS("", "")
+			return
+		}
+
+		log.Info().Str("addr", server.Addr).Msg("booting HTTP server")
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		// the server never started (i.e. the address is in use), so only the jobs and connections need releasing
+		releaseCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		return errors.Join(fmt.Errorf("listen and serve: %w", err), a.release(releaseCtx, stopJobs))
+	case <-ctx.Done():
+	}
+
+	log.Info().Msg("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down HTTP server: %w", err)
+	}
+
+	return a.release(shutdownCtx, stopJobs)
+}
+
+// release stops the background jobs, waits for them and any outstanding notifications to finish, and then closes the
+// database, shared state and tracer, so that the application is left cleanly however [Application.Run] stops
+func (a *Application) release(ctx context.Context, stopJobs context.CancelFunc) error {
+	stopJobs()
+	a.jobs.Wait()
+	a.webhooks.wait()
+	a.emails.wait()
+
+	if err := a.store.Close(); err != nil {
+		return fmt.Errorf("closing database: %w", err)
+	}
+
+	if err := a.state.Close(); err != nil {
+		return fmt.Errorf("closing shared state: %w", err)
+	}
+
+	if err := a.shutdownTracing(ctx); err != nil {
+		return fmt.Errorf("shutting down tracing: %w", err)
+	}
+
+	return nil
+}
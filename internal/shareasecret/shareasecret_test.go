@@ -1,6 +1,7 @@
 package shareasecret
 
 import (
+	"context"
 	"database/sql"
 	"net"
 	"os"
@@ -15,11 +16,21 @@ func TestMain(m *testing.M) {
 	_, nw, _ := net.ParseCIDR("127.0.0.0/8")
 
 	config := &Configuration{}
+	config.Database.Driver = driverSQLite
 	config.Database.Path = "shareasecret_test.db"
 	config.Server.BaseUrl = "http://127.0.0.1:8999"
+	config.Server.ContentSecurityPolicy = defaultContentSecurityPolicy
+	config.Secrets.MaximumSize = 1024
+	config.Secrets.MaximumDecryptionFailures = 2
+	config.Secrets.ViewingIDSize = defaultViewingIDSize
+	config.Secrets.ViewingIDEncoding = viewingIDEncodingHex
+	config.Secrets.AllowNeverExpire = true
+	config.Secrets.DefaultTTL = defaultTTL
+	config.Jobs.DeleteExpiredSecretsInterval = 5 * time.Millisecond
+	config.Server.TrustedProxies = []net.IPNet{*nw}
 	config.SecretCreationRestrictions.IPAddresses.CIDRs = []net.IPNet{*nw}
 
-	a, err := NewApplication(config, os.DirFS("../web/"))
+	a, err := NewApplication(config, os.DirFS("../../web/"))
 	if err != nil {
 		panic(err)
 	}
@@ -38,6 +49,353 @@ func TestMain(m *testing.M) {
 	m.Run()
 }
 
+func TestRun(t *testing.T) {
+	t.Run("configures the timeouts of the server", func(t *testing.T) {
+		config := *app.config
+		config.Server.ReadTimeout = time.Second
+		config.Server.WriteTimeout = 2 * time.Second
+		config.Server.IdleTimeout = 3 * time.Second
+
+		a := &Application{config: &config}
+		s := a.newServer()
+		if s.ReadTimeout != time.Second || s.WriteTimeout != 2*time.Second || s.IdleTimeout != 3*time.Second {
+			t.Errorf("wanted configured timeouts, got %v, %v and %v", s.ReadTimeout, s.WriteTimeout, s.IdleTimeout)
+		}
+	})
+
+	t.Run("shuts down gracefully once the context is cancelled", func(t *testing.T) {
+		config := *app.config
+		config.Database.Path = "shareasecret_test.db.run"
+		config.Server.ListeningAddr = "127.0.0.1:0"
+
+		a, err := NewApplication(&config, os.DirFS("../../web/"))
+		if err != nil {
+			t.Fatalf("creating application: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error, 1)
+
+		go func() {
+			stopped <- a.Run(ctx)
+		}()
+
+		<-time.After(20 * time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-stopped:
+			if err != nil {
+				t.Errorf("expected graceful shutdown, got %v", err)
+			} else if err := a.store.Ping(context.Background()); err == nil {
+				t.Errorf("expected database connection to be closed")
+			}
+		case <-time.After(shutdownTimeout):
+			t.Errorf("application did not stop within the shutdown timeout")
+		}
+	})
+
+	t.Run("closes the database if the server fails to start", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listening: %v", err)
+		}
+		defer l.Close()
+
+		config := *app.config
+		config.Database.Path = "shareasecret_test.db.run"
+		config.Server.ListeningAddr = l.Addr().String()
+
+		a, err := NewApplication(&config, os.DirFS("../../web/"))
+		if err != nil {
+			t.Fatalf("creating application: %v", err)
+		}
+
+		if err := a.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "listen and serve") {
+			t.Errorf("expected listen and serve error, got %v", err)
+		} else if err := a.store.Ping(context.Background()); err == nil {
+			t.Errorf("expected database connection to be closed")
+		}
+	})
+}
+
+func TestConfiguration(t *testing.T) {
+	t.Run("flags override environment variables", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		c := &Configuration{}
+		err := c.Populate([]string{"-base-url", "https://secrets.example", "-delete-expired-secrets-interval", "30s"})
+		if err != nil {
+			t.Fatalf("populating configuration: %v", err)
+		}
+
+		if c.Server.BaseUrl != "https://secrets.example" {
+			t.Errorf("wanted base url from flag, got %v", c.Server.BaseUrl)
+		} else if c.Database.Path != "shareasecret_test.db" {
+			t.Errorf("wanted database path from environment, got %v", c.Database.Path)
+		} else if c.Jobs.DeleteExpiredSecretsInterval != 30*time.Second {
+			t.Errorf("wanted interval from flag, got %v", c.Jobs.DeleteExpiredSecretsInterval)
+		}
+	})
+
+	t.Run("errors if required values are missing", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		c := &Configuration{}
+		if err := c.Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_BASE_URL") {
+			t.Errorf("wanted missing base url error, got %v", err)
+		}
+	})
+
+	t.Run("errors if values are malformed", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		for _, args := range [][]string{{"-base-url", "not a url"}, {"-base-path", "secrets"}, {"-base-path", "/secrets?a=b"}, {"-maximum-secret-size", "0"}, {"-listening-addr", ""}} {
+			c := &Configuration{}
+			if err := c.Populate(args); err == nil {
+				t.Errorf("wanted error for %v", args)
+			}
+		}
+	})
+
+	t.Run("permits secrets that never expire unless configured otherwise", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		c := &Configuration{}
+		if err := c.Populate(nil); err != nil || !c.Secrets.AllowNeverExpire {
+			t.Errorf("wanted secrets that never expire to be permitted by default (%v)", err)
+		}
+
+		t.Setenv("SHAREASECRET_ALLOW_NEVER_EXPIRE", "false")
+
+		c = &Configuration{}
+		if err := c.Populate(nil); err != nil || c.Secrets.AllowNeverExpire {
+			t.Errorf("wanted secrets that never expire not to be permitted (%v)", err)
+		}
+
+		t.Setenv("SHAREASECRET_ALLOW_NEVER_EXPIRE", "sometimes")
+
+		if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_ALLOW_NEVER_EXPIRE") {
+			t.Errorf("wanted error for malformed boolean, got %v", err)
+		}
+	})
+
+	t.Run("applies default server timeouts unless configured otherwise", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+		t.Setenv("SHAREASECRET_WRITE_TIMEOUT", "1m")
+		t.Setenv("SHAREASECRET_IDLE_TIMEOUT", "0")
+
+		c := &Configuration{}
+		if err := c.Populate(nil); err != nil {
+			t.Fatalf("populating configuration: %v", err)
+		}
+
+		if c.Server.ReadTimeout != defaultReadTimeout || c.Server.WriteTimeout != time.Minute || c.Server.IdleTimeout != 0 {
+			t.Errorf("wanted default read timeout and configured write and idle timeouts, got %+v", c.Server)
+		}
+
+		for _, env := range []string{"SHAREASECRET_READ_TIMEOUT", "SHAREASECRET_WRITE_TIMEOUT", "SHAREASECRET_IDLE_TIMEOUT"} {
+			t.Run(env, func(t *testing.T) {
+				t.Setenv(env, "-1s")
+
+				if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), env) {
+					t.Errorf("wanted %v error, got %v", env, err)
+				}
+			})
+		}
+	})
+
+	t.Run("errors if the default ttl is malformed or exceeds the maximum", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		for _, v := range []string{"30s", "90s", "forever"} {
+			t.Setenv("SHAREASECRET_DEFAULT_TTL", v)
+
+			if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_DEFAULT_TTL") {
+				t.Errorf("wanted error for default ttl %v, got %v", v, err)
+			}
+		}
+
+		t.Setenv("SHAREASECRET_DEFAULT_TTL", "2h")
+		t.Setenv("SHAREASECRET_MAXIMUM_TTL", "1h")
+
+		if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_DEFAULT_TTL") {
+			t.Errorf("wanted error for default ttl exceeding the maximum, got %v", err)
+		}
+	})
+
+	t.Run("errors if the minimum ttl is malformed or exceeds the maximum", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		for _, v := range []string{"30s", "90s", "soon"} {
+			t.Setenv("SHAREASECRET_MINIMUM_TTL", v)
+
+			if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_MINIMUM_TTL") {
+				t.Errorf("wanted error for minimum ttl %v, got %v", v, err)
+			}
+		}
+
+		t.Setenv("SHAREASECRET_MINIMUM_TTL", "2h")
+		t.Setenv("SHAREASECRET_MAXIMUM_TTL", "1h")
+
+		if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_MINIMUM_TTL") {
+			t.Errorf("wanted error for minimum ttl exceeding the maximum, got %v", err)
+		}
+
+		// the default is raised to the minimum unless it was configured below it
+		t.Setenv("SHAREASECRET_MAXIMUM_TTL", "")
+
+		c := &Configuration{}
+		if err := c.Populate(nil); err != nil || c.Secrets.MinimumTTL != 2*time.Hour || c.Secrets.DefaultTTL != 2*time.Hour {
+			t.Errorf("wanted minimum and default ttl of 2h, got %v and %v (%v)", c.Secrets.MinimumTTL, c.Secrets.DefaultTTL, err)
+		}
+
+		t.Setenv("SHAREASECRET_DEFAULT_TTL", "1h")
+
+		if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_DEFAULT_TTL") {
+			t.Errorf("wanted error for default ttl below the minimum, got %v", err)
+		}
+	})
+
+	t.Run("errors if the page renderer is unknown", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_PAGE_RENDERER", "html")
+
+		if err := (&Configuration{}).PopulateFromEnv(); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_PAGE_RENDERER") {
+			t.Errorf("wanted error for page renderer, got %v", err)
+		}
+	})
+
+	t.Run("trusts only loopback proxies unless configured", func(t *testing.T) {
+		c := &Configuration{}
+		if err := c.PopulateFromEnv(); err != nil {
+			t.Fatalf("populating configuration: %v", err)
+		} else if len(c.Server.TrustedProxies) != len(defaultTrustedProxies) {
+			t.Errorf("wanted default trusted proxies, got %v", c.Server.TrustedProxies)
+		} else if !inNetworks(net.ParseIP("127.0.0.1"), c.Server.TrustedProxies) || inNetworks(net.ParseIP("10.0.0.2"), c.Server.TrustedProxies) {
+			t.Errorf("wanted only loopback addresses to be trusted, got %v", c.Server.TrustedProxies)
+		}
+
+		t.Setenv("SHAREASECRET_TRUSTED_PROXIES", "203.0.113.7, 2001:db8::/32")
+		if err := c.PopulateFromEnv(); err != nil {
+			t.Fatalf("populating configuration: %v", err)
+		} else if len(c.Server.TrustedProxies) != 2 || c.Server.TrustedProxies[0].String() != "203.0.113.7/32" || c.Server.TrustedProxies[1].String() != "2001:db8::/32" {
+			t.Errorf("wanted configured trusted proxies, got %v", c.Server.TrustedProxies)
+		}
+
+		t.Setenv("SHAREASECRET_TRUSTED_PROXIES", "none")
+		if err := c.PopulateFromEnv(); err != nil {
+			t.Fatalf("populating configuration: %v", err)
+		} else if len(c.Server.TrustedProxies) != 0 {
+			t.Errorf("wanted no trusted proxies, got %v", c.Server.TrustedProxies)
+		}
+
+		for _, v := range []string{"proxy", "10.0.0.0/33"} {
+			t.Setenv("SHAREASECRET_TRUSTED_PROXIES", v)
+			if err := (&Configuration{}).PopulateFromEnv(); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_TRUSTED_PROXIES") {
+				t.Errorf("wanted error for trusted proxies %q, got %v", v, err)
+			}
+		}
+	})
+
+	t.Run("errors if recipient emails are misconfigured", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_ALLOW_RECIPIENT_EMAILS", "true")
+		if err := (&Configuration{}).PopulateFromEnv(); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_SMTP_HOST") {
+			t.Errorf("wanted error for recipient emails without smtp, got %v", err)
+		}
+
+		t.Setenv("SHAREASECRET_ALLOW_RECIPIENT_EMAILS", "")
+		t.Setenv("SHAREASECRET_RECIPIENT_EMAIL_DENIED_DOMAINS", "example.com, user@example.org")
+		if err := (&Configuration{}).PopulateFromEnv(); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_RECIPIENT_EMAIL_DENIED_DOMAINS") {
+			t.Errorf("wanted error for denied domains, got %v", err)
+		}
+
+		t.Setenv("SHAREASECRET_RECIPIENT_EMAIL_DENIED_DOMAINS", "")
+		t.Setenv("SHAREASECRET_RECIPIENT_EMAIL_ALLOWED_DOMAINS", " Example.com ,mycompany.example")
+		c := &Configuration{}
+		if err := c.PopulateFromEnv(); err != nil {
+			t.Fatalf("populating configuration: %v", err)
+		} else if strings.Join(c.Email.RecipientAllowedDomains, ",") != "example.com,mycompany.example" {
+			t.Errorf("wanted normalised allowed domains, got %v", c.Email.RecipientAllowedDomains)
+		}
+	})
+
+	t.Run("errors if the log format or level is unknown", func(t *testing.T) {
+		for name, v := range map[string]string{"SHAREASECRET_LOG_FORMAT": "text", "SHAREASECRET_LOG_LEVEL": "loud"} {
+			t.Run(name, func(t *testing.T) {
+				t.Setenv(name, v)
+
+				if err := (&Configuration{}).PopulateFromEnv(); err == nil || !strings.Contains(err.Error(), name) {
+					t.Errorf("wanted error for %v, got %v", name, err)
+				}
+			})
+		}
+	})
+
+	t.Run("errors if the otlp endpoint is malformed", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		for _, v := range []string{"collector:4318", "ftp://collector:4318", "http://"} {
+			t.Setenv("SHAREASECRET_OTLP_ENDPOINT", v)
+
+			if err := (&Configuration{}).Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_OTLP_ENDPOINT") {
+				t.Errorf("wanted error for otlp endpoint %v, got %v", v, err)
+			}
+		}
+	})
+
+	t.Run("errors if the brand support url is malformed", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+		t.Setenv("SHAREASECRET_BRAND_SUPPORT_URL", "javascript:alert(1)")
+
+		c := &Configuration{}
+		if err := c.Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_BRAND_SUPPORT_URL") {
+			t.Errorf("wanted invalid support url error, got %v", err)
+		}
+	})
+
+	t.Run("errors if only one of the tls certificate and key is set", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+		t.Setenv("SHAREASECRET_TLS_CERT_FILE", "cert.pem")
+
+		c := &Configuration{}
+		if err := c.Populate(nil); err == nil || !strings.Contains(err.Error(), "SHAREASECRET_TLS_KEY_FILE") {
+			t.Errorf("wanted missing tls key error, got %v", err)
+		}
+	})
+
+	t.Run("errors if viewing ids would have too little entropy", func(t *testing.T) {
+		t.Setenv("SHAREASECRET_BASE_URL", "http://127.0.0.1:8999")
+		t.Setenv("SHAREASECRET_DB_PATH", "shareasecret_test.db")
+
+		for env, v := range map[string]string{"SHAREASECRET_VIEWING_ID_SIZE": "15", "SHAREASECRET_VIEWING_ID_ENCODING": "base32"} {
+			t.Run(env, func(t *testing.T) {
+				t.Setenv(env, v)
+
+				c := &Configuration{}
+				if err := c.Populate(nil); err == nil || !strings.Contains(err.Error(), env) {
+					t.Errorf("wanted %v error, got %v", env, err)
+				}
+			})
+		}
+	})
+}
+
+// testDB returns the underlying database of the shared application, for tests that need to arrange or assert on its
+// contents directly
+func testDB() *sql.DB {
+	return app.store.(*sqlSecretStore).db.db
+}
+
 // createSecret creates a secret instance in the database
 func createSecret(t *testing.T, deletedAt time.Time, deletionReason string) (string, string) {
 	accessID, _ := secureID(24)
@@ -54,7 +412,7 @@ func createSecret(t *testing.T, deletedAt time.Time, deletionReason string) (str
 		dbDeletionReason = sql.NullString{Valid: true, String: deletionReason}
 	}
 
-	_, err := app.db.db.Exec(
+	_, err := testDB().Exec(
 		`
 			INSERT INTO secrets (access_id, management_id, maximum_views, ttl, cipher_text, deleted_at, deletion_reason, created_at)
 			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
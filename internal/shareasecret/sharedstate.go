@@ -0,0 +1,148 @@
+package shareasecret
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sharedState holds the short lived state, such as rate limits and locks, that must be consistent across every instance
+// of the application when more than one is deployed behind a load balancer. Secrets themselves are held by the
+// [SecretStore].
+type sharedState interface {
+	// Take consumes a token from the bucket of the given key, identifying whether one was available. Buckets start
+	// full with burst tokens and refill at perSecond tokens per second.
+	Take(ctx context.Context, key string, perSecond float64, burst float64) (bool, error)
+	// Lock acquires the lock of the given key until it is unlocked or the TTL elapses, returning the token required to
+	// unlock it or an empty string if it is already held
+	Lock(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// Unlock releases the lock of the given key, providing it is still held with the given token
+	Unlock(ctx context.Context, key string, token string) error
+	// Close closes any connections held by the state
+	Close() error
+}
+
+// memorySharedState is a [sharedState] held in the memory of a single instance, meaning it is only suitable when one
+// instance of the application is deployed
+type memorySharedState struct {
+	mu         sync.Mutex
+	buckets    map[string]*tokenBucket
+	locks      map[string]memoryLock
+	lastPruned time.Time
+}
+
+// tokenBucket contains the state of an individual key's bucket
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// fullAt is when the bucket will have completely refilled, after which it is indistinguishable from a new bucket
+	fullAt time.Time
+}
+
+// memoryLock is a lock held in a [memorySharedState]
+type memoryLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+// newMemorySharedState creates an empty [memorySharedState]
+func newMemorySharedState() *memorySharedState {
+	return &memorySharedState{
+		buckets:    map[string]*tokenBucket{},
+		locks:      map[string]memoryLock{},
+		lastPruned: time.Now(),
+	}
+}
+
+// Take consumes a token from the bucket of the given key, identifying whether one was available
+func (m *memorySharedState) Take(ctx context.Context, key string, perSecond float64, burst float64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.prune(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, updatedAt: now}
+		m.buckets[key] = b
+	}
+
+	// refill the bucket based on the time elapsed since it was last used, never exceeding the burst size
+	b.tokens = min(burst, b.tokens+now.Sub(b.updatedAt).Seconds()*perSecond)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false, nil
+	}
+
+	b.tokens--
+	b.fullAt = now.Add(time.Duration((burst - b.tokens) / perSecond * float64(time.Second)))
+
+	return true, nil
+}
+
+// Lock acquires the lock of the given key until it is unlocked or the TTL elapses
+func (m *memorySharedState) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if l, ok := m.locks[key]; ok && now.Before(l.expiresAt) {
+		return "", nil
+	}
+
+	token, err := secureID(16)
+	if err != nil {
+		return "", err
+	}
+
+	m.locks[key] = memoryLock{token: token, expiresAt: now.Add(ttl)}
+
+	return token, nil
+}
+
+// Unlock releases the lock of the given key, providing it is still held with the given token
+func (m *memorySharedState) Unlock(ctx context.Context, key string, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.locks[key]; ok && l.token == token {
+		delete(m.locks, key)
+	}
+
+	return nil
+}
+
+// Close discards all state
+func (m *memorySharedState) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.buckets = map[string]*tokenBucket{}
+	m.locks = map[string]memoryLock{}
+
+	return nil
+}
+
+// prune removes any buckets that have completely refilled and any locks that have expired. It runs at most once a
+// minute. The lock must be held by the caller.
+func (m *memorySharedState) prune(now time.Time) {
+	if now.Sub(m.lastPruned) < time.Minute {
+		return
+	}
+
+	for k, b := range m.buckets {
+		if !now.Before(b.fullAt) {
+			delete(m.buckets, k)
+		}
+	}
+
+	for k, l := range m.locks {
+		if !now.Before(l.expiresAt) {
+			delete(m.locks, k)
+		}
+	}
+
+	m.lastPruned = now
+}
@@ -0,0 +1,140 @@
+package shareasecret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testSharedStates returns the shared states to run tests against. Redis is only included when an instance to test
+// against is provided via SHAREASECRET_TEST_REDIS_URL.
+func testSharedStates(t *testing.T) map[string]sharedState {
+	states := map[string]sharedState{"memory": newMemorySharedState()}
+
+	if url := os.Getenv("SHAREASECRET_TEST_REDIS_URL"); url != "" {
+		s, err := newRedisSharedState(url)
+		if err != nil {
+			t.Fatalf("creating redis shared state: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+
+		states["redis"] = s
+	}
+
+	return states
+}
+
+func TestSharedStates(t *testing.T) {
+	ctx := context.Background()
+
+	for name, state := range testSharedStates(t) {
+		t.Run(name, func(t *testing.T) {
+			key, _ := secureID(8)
+
+			t.Run("takes tokens up to the burst size", func(t *testing.T) {
+				for i := 0; i < 3; i++ {
+					if ok, err := state.Take(ctx, "bucket:"+key, 1, 3); err != nil || !ok {
+						t.Fatalf("expected token %v to be taken (%v)", i+1, err)
+					}
+				}
+
+				if ok, _ := state.Take(ctx, "bucket:"+key, 1, 3); ok {
+					t.Errorf("expected taking a token from an empty bucket to fail")
+				}
+			})
+
+			t.Run("refills buckets over time", func(t *testing.T) {
+				if ok, _ := state.Take(ctx, "refill:"+key, 50, 1); !ok {
+					t.Fatalf("expected first token to be taken")
+				}
+
+				<-time.After(40 * time.Millisecond)
+
+				if ok, _ := state.Take(ctx, "refill:"+key, 50, 1); !ok {
+					t.Errorf("expected refilled token to be taken")
+				}
+			})
+
+			t.Run("locks are exclusive until unlocked", func(t *testing.T) {
+				token, err := state.Lock(ctx, "lock:"+key, time.Minute)
+				if err != nil || token == "" {
+					t.Fatalf("expected lock to be acquired (%v)", err)
+				}
+
+				if other, _ := state.Lock(ctx, "lock:"+key, time.Minute); other != "" {
+					t.Errorf("did not expect a held lock to be acquired")
+				}
+
+				state.Unlock(ctx, "lock:"+key, "not the token")
+				if other, _ := state.Lock(ctx, "lock:"+key, time.Minute); other != "" {
+					t.Errorf("did not expect a lock to be released with the wrong token")
+				}
+
+				state.Unlock(ctx, "lock:"+key, token)
+				if other, _ := state.Lock(ctx, "lock:"+key, time.Minute); other == "" {
+					t.Errorf("expected an unlocked lock to be acquired")
+				}
+			})
+
+			t.Run("locks expire after their ttl", func(t *testing.T) {
+				if token, _ := state.Lock(ctx, "expiring:"+key, 10*time.Millisecond); token == "" {
+					t.Fatalf("expected lock to be acquired")
+				}
+
+				<-time.After(20 * time.Millisecond)
+
+				if token, _ := state.Lock(ctx, "expiring:"+key, time.Minute); token == "" {
+					t.Errorf("expected an expired lock to be acquired")
+				}
+			})
+		})
+	}
+}
+
+func TestConsumeView(t *testing.T) {
+	t.Run("serves a single view secret once to concurrent viewers", func(t *testing.T) {
+		s := newTestStoredSecret(t, app.store, func(s *storedSecret) { s.maximumViews = 1 })
+
+		var keys []string
+		for i := 0; i < 5; i++ {
+			key, _ := secureID(4)
+			if err := app.store.CreateView(context.Background(), s.accessID, key); err != nil {
+				t.Fatalf("creating view: %v", err)
+			}
+			keys = append(keys, key)
+		}
+
+		var served atomic.Int32
+		var wg sync.WaitGroup
+		for _, key := range keys {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+
+				if _, _, err := app.consumeView(context.Background(), s.accessID, key); err == nil {
+					served.Add(1)
+				}
+			}(key)
+		}
+		wg.Wait()
+
+		if n := served.Load(); n != 1 {
+			t.Errorf("wanted secret to be served once, got %v", n)
+		}
+	})
+
+	t.Run("gives up if the secret stays locked", func(t *testing.T) {
+		s := newTestStoredSecret(t, app.store, nil)
+
+		token, _ := app.state.Lock(context.Background(), "view:"+s.accessID, time.Minute)
+		defer app.state.Unlock(context.Background(), "view:"+s.accessID, token)
+
+		if _, _, err := app.consumeView(context.Background(), s.accessID, "key"); !errors.Is(err, errSecretLocked) {
+			t.Errorf("wanted locked error, got %v", err)
+		}
+	})
+}
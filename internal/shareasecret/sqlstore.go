@@ -0,0 +1,1342 @@
+package shareasecret
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqlSecretStore is a [SecretStore] backed by a SQLite or PostgreSQL [database]
+type sqlSecretStore struct {
+	db *database
+	// queryTimeout is the maximum amount of time each operation on the store may take, or zero if operations are only
+	// limited by their context
+	queryTimeout time.Duration
+}
+
+// newSQLSecretStore creates a [sqlSecretStore] persisting secrets in the given database, abandoning operations that
+// take longer than the given timeout
+func newSQLSecretStore(db *database, queryTimeout time.Duration) *sqlSecretStore {
+	return &sqlSecretStore{db: db, queryTimeout: queryTimeout}
+}
+
+// withTimeout derives the context an operation on the store is performed with from the given context, limiting it to
+// the query timeout of the store
+func (s *sqlSecretStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// insertSecretQuery inserts a secret with the arguments returned by [insertSecretArgs]
+const insertSecretQuery = `
+	INSERT INTO
+		secrets (
+			access_id,
+			management_id,
+			cipher_text,
+			cipher_format,
+			ttl,
+			maximum_views,
+			burn_after_reading,
+			passphrase_hash,
+			notify_webhook,
+			notify_email,
+			label,
+			recovery_token_hash,
+			reveal_delay_seconds,
+			creator_session_hash,
+			track_access,
+			not_before,
+			created_at
+		)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// insertSecretArgs returns the arguments of [insertSecretQuery] for the given secret
+func insertSecretArgs(secret storedSecret) []any {
+	return []any{
+		secret.accessID,
+		secret.managementID,
+		secret.cipherText,
+		secret.cipherFormat,
+		secret.ttl,
+		secret.maximumViews,
+		secret.burnAfterReading,
+		nullString(secret.passphraseHash),
+		nullString(secret.notifyWebhook),
+		nullString(secret.notifyEmail),
+		nullString(secret.label),
+		nullString(secret.recoveryTokenHash),
+		secret.revealDelaySeconds,
+		nullString(secret.creatorSessionHash),
+		secret.trackAccess,
+		secret.notBefore,
+		secret.createdAt,
+	}
+}
+
+// Create persists a new secret
+func (s *sqlSecretStore) Create(ctx context.Context, secret storedSecret) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.db.retryBusy(func() error {
+		_, err := s.db.exec(ctx, insertSecretQuery, insertSecretArgs(secret)...)
+		if isUniqueViolation(err) {
+			return errSecretIDCollision
+		}
+
+		return err
+	})
+}
+
+// GetByViewingID retrieves a secret that can still be viewed via its access ID. The cipher text is not retrieved.
+func (s *sqlSecretStore) GetByViewingID(ctx context.Context, accessID string) (storedSecret, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	secret := storedSecret{accessID: accessID}
+
+	var passphraseHash sql.NullString
+	var notifyWebhook sql.NullString
+	var notifyEmail sql.NullString
+
+	err := s.db.queryRow(
+		ctx,
+		`
+			SELECT
+				s.management_id,
+				s.ttl,
+				s.maximum_views,
+				(SELECT COUNT(1) FROM secret_views v WHERE v.secret_id = s.id AND v.viewed_at IS NOT NULL),
+				s.burn_after_reading,
+				s.passphrase_hash,
+				s.notify_webhook,
+				s.notify_email,
+				s.reveal_delay_seconds,
+				s.track_access,
+				s.not_before,
+				s.created_at
+			FROM
+				secrets s
+			WHERE
+				s.access_id = ? AND
+				s.deleted_at IS NULL
+		`,
+		accessID,
+	).Scan(
+		&secret.managementID,
+		&secret.ttl,
+		&secret.maximumViews,
+		&secret.views,
+		&secret.burnAfterReading,
+		&passphraseHash,
+		&notifyWebhook,
+		&notifyEmail,
+		&secret.revealDelaySeconds,
+		&secret.trackAccess,
+		&secret.notBefore,
+		&secret.createdAt,
+	)
+
+	if errors.Is(sql.ErrNoRows, err) || (err == nil && secret.expired()) {
+		return storedSecret{}, errSecretNotFound
+	} else if err != nil {
+		return storedSecret{}, err
+	} else if secret.notYetAvailable() {
+		return storedSecret{notBefore: secret.notBefore}, errSecretNotYetAvailable
+	}
+
+	secret.passphraseHash = passphraseHash.String
+	secret.notifyWebhook = notifyWebhook.String
+	secret.notifyEmail = notifyEmail.String
+
+	return secret, nil
+}
+
+// UnavailableReason retrieves why a secret that existed can no longer be viewed via its access ID
+func (s *sqlSecretStore) UnavailableReason(ctx context.Context, accessID string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	secret := storedSecret{accessID: accessID}
+
+	var deletedAt sql.NullInt64
+	var deletionReason sql.NullString
+
+	err := s.db.queryRow(
+		ctx,
+		`
+			SELECT
+				ttl,
+				created_at,
+				deleted_at,
+				deletion_reason
+			FROM
+				secrets
+			WHERE
+				access_id = ?
+		`,
+		accessID,
+	).Scan(
+		&secret.ttl,
+		&secret.createdAt,
+		&deletedAt,
+		&deletionReason,
+	)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", errSecretNotFound
+	case err != nil:
+		return "", err
+	case deletedAt.Valid:
+		return deletionReason.String, nil
+	case secret.expired():
+		return deletionReasonExpired, nil
+	default:
+		return "", errSecretNotFound
+	}
+}
+
+// GetByManagementID retrieves a secret via its management ID, regardless of whether it has been deleted or expired.
+// Only the size of the cipher text is retrieved.
+func (s *sqlSecretStore) GetByManagementID(ctx context.Context, managementID string) (storedSecret, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	secret := storedSecret{managementID: managementID}
+
+	var label sql.NullString
+	var acknowledgedAt sql.NullInt64
+	var deletedAt sql.NullInt64
+	var deletionReason sql.NullString
+
+	err := s.db.queryRow(
+		ctx,
+		`
+			SELECT
+				s.access_id,
+				s.ttl,
+				s.maximum_views,
+				(SELECT COUNT(1) FROM secret_views v WHERE v.secret_id = s.id AND v.viewed_at IS NOT NULL),
+				s.burn_after_reading,
+				s.reveal_delay_seconds,
+				s.track_access,
+				COALESCE(LENGTH(s.cipher_text), 0),
+				s.label,
+				s.not_before,
+				s.created_at,
+				s.acknowledged_at,
+				s.deleted_at,
+				s.deletion_reason
+			FROM
+				secrets s
+			WHERE
+				s.management_id = ?
+		`,
+		managementID,
+	).Scan(
+		&secret.accessID,
+		&secret.ttl,
+		&secret.maximumViews,
+		&secret.views,
+		&secret.burnAfterReading,
+		&secret.revealDelaySeconds,
+		&secret.trackAccess,
+		&secret.cipherTextSize,
+		&label,
+		&secret.notBefore,
+		&secret.createdAt,
+		&acknowledgedAt,
+		&deletedAt,
+		&deletionReason,
+	)
+
+	if errors.Is(sql.ErrNoRows, err) {
+		return storedSecret{}, errSecretNotFound
+	} else if err != nil {
+		return storedSecret{}, err
+	}
+
+	secret.label = label.String
+	secret.acknowledgedAt = acknowledgedAt.Int64
+	secret.deletedAt = deletedAt.Int64
+	secret.deletionReason = deletionReason.String
+
+	return secret, nil
+}
+
+// CreateView records an unused view of a secret that can be viewed
+func (s *sqlSecretStore) CreateView(ctx context.Context, accessID string, viewingKey string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// the parameters are cast explicitly as PostgreSQL is unable to infer their types from the target columns
+	rs, err := s.db.exec(
+		ctx,
+		`
+			INSERT INTO secret_views (secret_id, viewing_key, created_at)
+			SELECT
+				id,
+				CAST(?1 AS TEXT),
+				CAST(?2 AS BIGINT)
+			FROM
+				secrets
+			WHERE
+				access_id = ?3 AND
+				deleted_at IS NULL AND
+				not_before <= ?2 AND
+				(ttl = 0 OR (created_at + (ttl * 60 * 1000)) > ?2)
+		`,
+		viewingKey,
+		time.Now().UnixMilli(),
+		accessID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rc, err := rs.RowsAffected(); err != nil {
+		return err
+	} else if rc == 0 {
+		return errSecretNotFound
+	}
+
+	return nil
+}
+
+// ConsumeView atomically marks the view of a secret as used, deleting the secret within the same transaction if the
+// view exhausts it. The transaction is retried should the database be busy, i.e. because another view of the secret
+// was consumed after this one read it.
+func (s *sqlSecretStore) ConsumeView(ctx context.Context, accessID string, viewingKey string) (storedSecret, string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var secret storedSecret
+	var deletionReason string
+
+	err := s.db.retryBusy(func() error {
+		return s.db.withTx(ctx, func(tx *transaction) error {
+			var err error
+			secret, deletionReason, err = consumeViewWithin(tx, accessID, viewingKey)
+
+			return err
+		})
+	})
+	if err != nil {
+		return storedSecret{}, "", err
+	}
+
+	return secret, deletionReason, nil
+}
+
+// consumeViewWithin marks the view of a secret as used within the given transaction, deleting the secret if the view
+// exhausts it. The secret is returned along with the reason it was deleted, if it was.
+func consumeViewWithin(tx *transaction, accessID string, viewingKey string) (storedSecret, string, error) {
+	// retrieve the secret and the unused view of it, treating an expired secret as if it doesn't exist
+	secret := storedSecret{accessID: accessID}
+
+	var secretViewID int
+	var notifyWebhook sql.NullString
+	var notifyEmail sql.NullString
+
+	err := tx.queryRow(
+		`
+			SELECT
+				s.management_id,
+				s.cipher_text,
+				s.cipher_format,
+				v.id,
+				s.maximum_views,
+				s.created_at,
+				s.ttl,
+				s.burn_after_reading,
+				s.reveal_delay_seconds,
+				s.track_access,
+				s.notify_webhook,
+				s.notify_email
+			FROM
+				secrets s
+				INNER JOIN secret_views v ON v.secret_id = s.id
+			WHERE
+				s.access_id = ? AND
+				s.deleted_at IS NULL AND
+				v.viewing_key = ? AND
+				v.viewed_at IS NULL
+		`,
+		accessID,
+		viewingKey,
+	).Scan(
+		&secret.managementID,
+		&secret.cipherText,
+		&secret.cipherFormat,
+		&secretViewID,
+		&secret.maximumViews,
+		&secret.createdAt,
+		&secret.ttl,
+		&secret.burnAfterReading,
+		&secret.revealDelaySeconds,
+		&secret.trackAccess,
+		&notifyWebhook,
+		&notifyEmail,
+	)
+
+	if errors.Is(sql.ErrNoRows, err) || (err == nil && secret.expired()) {
+		return storedSecret{}, "", errSecretNotFound
+	} else if err != nil {
+		return storedSecret{}, "", fmt.Errorf("retrieving secret: %w", err)
+	}
+
+	secret.notifyWebhook = notifyWebhook.String
+	secret.notifyEmail = notifyEmail.String
+
+	// record the secret view as being used so nobody else can use it to see the secret, unless somebody else already
+	// has
+	rs, err := tx.exec("UPDATE secret_views SET viewed_at = ? WHERE id = ? AND viewed_at IS NULL", time.Now().UnixMilli(), secretViewID)
+	if err != nil {
+		return storedSecret{}, "", fmt.Errorf("updating secret view: %w", err)
+	} else if rc, err := rs.RowsAffected(); err != nil {
+		return storedSecret{}, "", fmt.Errorf("updating secret view: %w", err)
+	} else if rc == 0 {
+		return storedSecret{}, "", errSecretNotFound
+	}
+
+	// the views are counted once this one has been recorded, so that views consumed concurrently are included
+	err = tx.queryRow(
+		"SELECT COUNT(1) FROM secret_views v INNER JOIN secrets s ON s.id = v.secret_id WHERE s.access_id = ? AND v.viewed_at IS NOT NULL",
+		accessID,
+	).Scan(&secret.views)
+	if err != nil {
+		return storedSecret{}, "", fmt.Errorf("counting secret views: %w", err)
+	}
+
+	// delete the secret if this view exhausts it
+	deletionReason, servable, err := consumeSecretView(tx, accessID, secret.maximumViews, secret.views, secret.burnAfterReading)
+	if err != nil {
+		return storedSecret{}, "", fmt.Errorf("consuming secret view: %w", err)
+	} else if !servable {
+		return storedSecret{}, "", errSecretNotFound
+	}
+
+	return secret, deletionReason, nil
+}
+
+// consumeSecretView deletes the secret within the given transaction if the view just recorded exhausts it, either
+// because it was created as a one-time (burn after reading) secret or because the maximum number of views has been
+// reached. The number of views includes the one just recorded, and the reason for the deletion is returned if the
+// secret was deleted.
+//
+// Both deletions are conditional on the secret not having been deleted already, meaning that if two requests race to
+// exhaust it only the request that performs the deletion is told the secret is servable.
+func consumeSecretView(tx *transaction, accessID string, maxViews int, views int, burnAfterReading bool) (string, bool, error) {
+	if burnAfterReading {
+		rs, err := tx.exec(
+			"UPDATE secrets SET deleted_at = ?, deletion_reason = ?, cipher_text = NULL WHERE access_id = ? AND deleted_at IS NULL",
+			time.Now().UnixMilli(),
+			deletionReasonViewed,
+			accessID,
+		)
+		if err != nil {
+			return "", false, fmt.Errorf("burning secret: %w", err)
+		} else if rc, err := rs.RowsAffected(); err != nil {
+			return "", false, fmt.Errorf("burning secret: %w", err)
+		} else if rc == 0 {
+			return "", false, nil
+		}
+
+		return deletionReasonViewed, true, nil
+	}
+
+	// mark the secret as being deleted if this view is equal to or exceeds the maximum permitted views for the secret
+	if maxViews > 0 && views >= maxViews {
+		rs, err := tx.exec(
+			"UPDATE secrets SET deleted_at = ?, deletion_reason = ?, cipher_text = NULL WHERE access_id = ? AND deleted_at IS NULL",
+			time.Now().UnixMilli(),
+			deletionReasonMaximumViewCountHit,
+			accessID,
+		)
+		if err != nil {
+			return "", false, fmt.Errorf("deleting secret: %w", err)
+		} else if rc, err := rs.RowsAffected(); err != nil {
+			return "", false, fmt.Errorf("deleting secret: %w", err)
+		} else if rc == 0 || views > maxViews {
+			return "", false, nil
+		}
+
+		return deletionReasonMaximumViewCountHit, true, nil
+	}
+
+	return "", true, nil
+}
+
+// Acknowledge records that the creator of a secret has stored its links
+func (s *sqlSecretStore) Acknowledge(ctx context.Context, managementID string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rs, err := s.db.exec(
+		ctx,
+		"UPDATE secrets SET acknowledged_at = COALESCE(acknowledged_at, ?) WHERE management_id = ?",
+		time.Now().UnixMilli(),
+		managementID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rc, err := rs.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rc > 0, nil
+}
+
+// Delete deletes a secret on behalf of its creator
+func (s *sqlSecretStore) Delete(ctx context.Context, managementID string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var rs sql.Result
+
+	err := s.db.retryBusy(func() error {
+		var err error
+		rs, err = s.db.exec(
+			ctx,
+			"UPDATE secrets SET deleted_at = ?, deletion_reason = ?, cipher_text = NULL WHERE management_id = ? AND deleted_at IS NULL",
+			time.Now().UnixMilli(),
+			deletionReasonUserDeleted,
+			managementID,
+		)
+
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	rc, err := rs.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rc > 0, nil
+}
+
+// RotateAccessID replaces the access ID of a secret that can still be viewed, discarding any unused views of it
+func (s *sqlSecretStore) RotateAccessID(ctx context.Context, managementID string, accessID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.db.withTx(ctx, func(tx *transaction) error {
+		var secretID int64
+		err := tx.queryRow(
+			`
+				UPDATE
+					secrets
+				SET
+					access_id = ?1
+				WHERE
+					management_id = ?2 AND
+					deleted_at IS NULL AND
+					(ttl = 0 OR (created_at + (ttl * 60 * 1000)) > ?3)
+				RETURNING
+					id
+			`,
+			accessID,
+			managementID,
+			time.Now().UnixMilli(),
+		).Scan(&secretID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errSecretNotFound
+		} else if isUniqueViolation(err) {
+			return errSecretIDCollision
+		} else if err != nil {
+			return fmt.Errorf("updating access id: %w", err)
+		}
+
+		// views created via the previous access ID but not yet used would otherwise still reveal the secret
+		if _, err := tx.exec("DELETE FROM secret_views WHERE secret_id = ? AND viewed_at IS NULL", secretID); err != nil {
+			return fmt.Errorf("deleting unused views: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateTTL replaces the TTL of a secret that can still be viewed
+func (s *sqlSecretStore) UpdateTTL(ctx context.Context, managementID string, ttl int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var secretID int64
+	err := s.db.queryRow(
+		ctx,
+		`
+			UPDATE
+				secrets
+			SET
+				ttl = ?1
+			WHERE
+				management_id = ?2 AND
+				deleted_at IS NULL AND
+				(ttl = 0 OR (created_at + (ttl * 60 * 1000)) > ?3)
+			RETURNING
+				id
+		`,
+		ttl,
+		managementID,
+		time.Now().UnixMilli(),
+	).Scan(&secretID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errSecretNotFound
+	} else if err != nil {
+		return fmt.Errorf("updating ttl: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke deletes all secrets associated with the given recovery token hash on behalf of their creator
+func (s *sqlSecretStore) Revoke(ctx context.Context, recoveryTokenHash string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var accessIDs []string
+
+	err := s.db.retryBusy(func() error {
+		accessIDs = nil
+
+		rows, err := s.db.query(
+			ctx,
+			`
+				UPDATE
+					secrets
+				SET
+					deleted_at = ?1,
+					deletion_reason = ?2,
+					cipher_text = NULL
+				WHERE
+					recovery_token_hash = ?3 AND
+					deleted_at IS NULL
+				RETURNING
+					access_id
+			`,
+			time.Now().UnixMilli(),
+			deletionReasonUserDeleted,
+			recoveryTokenHash,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var accessID string
+			if err := rows.Scan(&accessID); err != nil {
+				return err
+			}
+
+			accessIDs = append(accessIDs, accessID)
+		}
+
+		return rows.Err()
+	})
+
+	return accessIDs, err
+}
+
+// ListByCreatorSession retrieves the most recently created secrets associated with the given creator session hash
+func (s *sqlSecretStore) ListByCreatorSession(ctx context.Context, creatorSessionHash string, limit int) ([]storedSecret, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.query(
+		ctx,
+		`
+			SELECT
+				s.access_id,
+				s.management_id,
+				s.ttl,
+				s.maximum_views,
+				(SELECT COUNT(1) FROM secret_views v WHERE v.secret_id = s.id AND v.viewed_at IS NOT NULL),
+				s.burn_after_reading,
+				s.label,
+				s.created_at,
+				s.deleted_at,
+				s.deletion_reason
+			FROM
+				secrets s
+			WHERE
+				s.creator_session_hash = ?
+			ORDER BY
+				s.created_at DESC,
+				s.id DESC
+			LIMIT ?
+		`,
+		creatorSessionHash,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []storedSecret
+	for rows.Next() {
+		secret := storedSecret{creatorSessionHash: creatorSessionHash}
+
+		var label sql.NullString
+		var deletedAt sql.NullInt64
+		var deletionReason sql.NullString
+
+		err := rows.Scan(
+			&secret.accessID,
+			&secret.managementID,
+			&secret.ttl,
+			&secret.maximumViews,
+			&secret.views,
+			&secret.burnAfterReading,
+			&label,
+			&secret.createdAt,
+			&deletedAt,
+			&deletionReason,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		secret.label = label.String
+		secret.deletedAt = deletedAt.Int64
+		secret.deletionReason = deletionReason.String
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, rows.Err()
+}
+
+// RecordDecryptionFailure increments the number of failed decryption attempts of a secret, deleting it within the same
+// transaction once the maximum has been reached
+func (s *sqlSecretStore) RecordDecryptionFailure(ctx context.Context, accessID string, maximum int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var deleted bool
+
+	err := s.db.retryBusy(func() error {
+		deleted = false
+
+		return s.db.withTx(ctx, func(tx *transaction) error {
+			var failures int
+
+			err := tx.queryRow(
+				`
+					UPDATE
+						secrets
+					SET
+						decrypt_failures = decrypt_failures + 1
+					WHERE
+						access_id = ?1 AND
+						deleted_at IS NULL AND
+						(ttl = 0 OR (created_at + (ttl * 60 * 1000)) > ?2)
+					RETURNING
+						decrypt_failures
+				`,
+				accessID,
+				time.Now().UnixMilli(),
+			).Scan(&failures)
+
+			if errors.Is(sql.ErrNoRows, err) {
+				return errSecretNotFound
+			} else if err != nil {
+				return fmt.Errorf("recording decryption failure: %w", err)
+			}
+
+			if maximum <= 0 || failures < maximum {
+				return nil
+			}
+
+			rs, err := tx.exec(
+				"UPDATE secrets SET deleted_at = ?, deletion_reason = ?, cipher_text = NULL WHERE access_id = ? AND deleted_at IS NULL",
+				time.Now().UnixMilli(),
+				deletionReasonDecryptionFailures,
+				accessID,
+			)
+			if err != nil {
+				return fmt.Errorf("deleting secret: %w", err)
+			}
+
+			rc, err := rs.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("deleting secret: %w", err)
+			}
+
+			deleted = rc > 0
+			return nil
+		})
+	})
+
+	return deleted, err
+}
+
+// SweepExpired deletes all secrets that have expired as of the given time. Only the access ID and notification email
+// of each deleted secret are returned.
+func (s *sqlSecretStore) SweepExpired(ctx context.Context, now time.Time) ([]storedSecret, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.query(
+		ctx,
+		`
+			UPDATE
+				secrets
+			SET
+				deleted_at = ?1,
+				deletion_reason = ?2,
+				cipher_text = NULL
+			WHERE
+				deleted_at IS NULL AND
+				created_at <= ?3 AND
+				ttl > 0 AND
+				(created_at + (ttl * 60 * 1000)) <= ?1
+			RETURNING
+				access_id,
+				notify_email
+		`,
+		now.UnixMilli(),
+		deletionReasonExpired,
+		// secrets live for at least a minute, which bounds the range of creation times scanned via the index
+		now.Add(-time.Minute).UnixMilli(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var swept []storedSecret
+	for rows.Next() {
+		var secret storedSecret
+		var notifyEmail sql.NullString
+
+		if err := rows.Scan(&secret.accessID, &notifyEmail); err != nil {
+			return nil, err
+		}
+
+		secret.notifyEmail = notifyEmail.String
+		swept = append(swept, secret)
+	}
+
+	return swept, rows.Err()
+}
+
+// PurgeDeleted permanently removes all secrets deleted at or before the given time. The views and events of the secrets
+// are removed first within the same transaction as they reference the secrets.
+func (s *sqlSecretStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var purged int64
+
+	err := s.db.retryBusy(func() error {
+		return s.db.withTx(ctx, func(tx *transaction) error {
+			deleted := "SELECT id FROM secrets WHERE deleted_at IS NOT NULL AND deleted_at <= ?"
+
+			if _, err := tx.exec("DELETE FROM secret_views WHERE secret_id IN ("+deleted+")", deletedBefore.UnixMilli()); err != nil {
+				return fmt.Errorf("deleting secret views: %w", err)
+			}
+			if _, err := tx.exec("DELETE FROM secret_events WHERE secret_id IN ("+deleted+")", deletedBefore.UnixMilli()); err != nil {
+				return fmt.Errorf("deleting secret events: %w", err)
+			}
+			if _, err := tx.exec("DELETE FROM secret_accesses WHERE secret_id IN ("+deleted+")", deletedBefore.UnixMilli()); err != nil {
+				return fmt.Errorf("deleting secret accesses: %w", err)
+			}
+
+			if _, err := tx.exec("DELETE FROM bundle_secrets WHERE secret_id IN ("+deleted+")", deletedBefore.UnixMilli()); err != nil {
+				return fmt.Errorf("deleting bundle secrets: %w", err)
+			}
+
+			rs, err := tx.exec("DELETE FROM secrets WHERE deleted_at IS NOT NULL AND deleted_at <= ?", deletedBefore.UnixMilli())
+			if err != nil {
+				return fmt.Errorf("deleting secrets: %w", err)
+			}
+
+			if purged, err = rs.RowsAffected(); err != nil {
+				return err
+			}
+
+			// bundles are removed along with the last of their secrets
+			if _, err := tx.exec("DELETE FROM bundles WHERE NOT EXISTS (SELECT 1 FROM bundle_secrets b WHERE b.bundle_id = bundles.id)"); err != nil {
+				return fmt.Errorf("deleting bundles: %w", err)
+			}
+
+			return nil
+		})
+	})
+
+	return purged, err
+}
+
+// RecordEvent records an event in the lifecycle of a secret, which is identified by whichever of its access ID or
+// management ID is set on the event
+func (s *sqlSecretStore) RecordEvent(ctx context.Context, e secretEvent) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// the parameters are cast explicitly as PostgreSQL is unable to infer their types from the target columns
+	_, err := s.db.exec(
+		ctx,
+		`
+			INSERT INTO secret_events (secret_id, event_type, detail, ip_hash, occurred_at)
+			SELECT
+				id,
+				CAST(?1 AS TEXT),
+				CAST(?2 AS TEXT),
+				CAST(?3 AS TEXT),
+				CAST(?4 AS BIGINT)
+			FROM
+				secrets
+			WHERE
+				access_id = ?5 OR
+				management_id = ?6
+		`,
+		e.eventType,
+		nullString(e.detail),
+		nullString(e.ipHash),
+		e.occurredAt,
+		e.accessID,
+		e.managementID,
+	)
+
+	return err
+}
+
+// Events retrieves the most recent events of a secret via its management ID, newest first
+func (s *sqlSecretStore) Events(ctx context.Context, managementID string, limit int) ([]secretEvent, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.query(
+		ctx,
+		`
+			SELECT
+				e.event_type,
+				e.detail,
+				e.ip_hash,
+				e.occurred_at
+			FROM
+				secret_events e
+				INNER JOIN secrets s ON s.id = e.secret_id
+			WHERE
+				s.management_id = ?
+			ORDER BY
+				e.occurred_at DESC,
+				e.id DESC
+			LIMIT ?
+		`,
+		managementID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []secretEvent
+	for rows.Next() {
+		e := secretEvent{managementID: managementID}
+
+		var detail sql.NullString
+		var ipHash sql.NullString
+
+		if err := rows.Scan(&e.eventType, &detail, &ipHash, &e.occurredAt); err != nil {
+			return nil, err
+		}
+
+		e.detail = detail.String
+		e.ipHash = ipHash.String
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// RecordAccess records an access of the secret with the given access ID in the access log shown to its creator,
+// regardless of whether the access deleted the secret
+func (s *sqlSecretStore) RecordAccess(ctx context.Context, accessID string, access secretAccess) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// the parameters are cast explicitly as PostgreSQL is unable to infer their types from the target columns
+	_, err := s.db.exec(
+		ctx,
+		`
+			INSERT INTO secret_accesses (secret_id, ip_hash, user_agent, accessed_at)
+			SELECT
+				id,
+				CAST(?1 AS TEXT),
+				CAST(?2 AS TEXT),
+				CAST(?3 AS BIGINT)
+			FROM
+				secrets
+			WHERE
+				access_id = ?4
+		`,
+		nullString(access.ipHash),
+		nullString(access.userAgent),
+		access.accessedAt,
+		accessID,
+	)
+
+	return err
+}
+
+// Accesses retrieves the most recent accesses of a secret via its management ID, newest first
+func (s *sqlSecretStore) Accesses(ctx context.Context, managementID string, limit int) ([]secretAccess, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.query(
+		ctx,
+		`
+			SELECT
+				a.ip_hash,
+				a.user_agent,
+				a.accessed_at
+			FROM
+				secret_accesses a
+				INNER JOIN secrets s ON s.id = a.secret_id
+			WHERE
+				s.management_id = ?
+			ORDER BY
+				a.accessed_at DESC,
+				a.id DESC
+			LIMIT ?
+		`,
+		managementID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accesses []secretAccess
+	for rows.Next() {
+		var a secretAccess
+
+		var ipHash sql.NullString
+		var userAgent sql.NullString
+
+		if err := rows.Scan(&ipHash, &userAgent, &a.accessedAt); err != nil {
+			return nil, err
+		}
+
+		a.ipHash = ipHash.String
+		a.userAgent = userAgent.String
+		accesses = append(accesses, a)
+	}
+
+	return accesses, rows.Err()
+}
+
+// CreateBundle persists a new bundle along with its secrets within a single transaction
+func (s *sqlSecretStore) CreateBundle(ctx context.Context, b storedBundle) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.db.retryBusy(func() error {
+		return s.db.withTx(ctx, func(tx *transaction) error {
+			var bundleID int64
+			err := tx.queryRow(
+				"INSERT INTO bundles (access_id, management_id, created_at) VALUES (?, ?, ?) RETURNING id",
+				b.accessID,
+				b.managementID,
+				b.createdAt,
+			).Scan(&bundleID)
+			if isUniqueViolation(err) {
+				return errSecretIDCollision
+			} else if err != nil {
+				return fmt.Errorf("inserting bundle: %w", err)
+			}
+
+			for position, secret := range b.secrets {
+				var secretID int64
+				err := tx.queryRow(insertSecretQuery+" RETURNING id", insertSecretArgs(secret.storedSecret)...).Scan(&secretID)
+				if isUniqueViolation(err) {
+					return errSecretIDCollision
+				} else if err != nil {
+					return fmt.Errorf("inserting secret: %w", err)
+				}
+
+				_, err = tx.exec(
+					"INSERT INTO bundle_secrets (bundle_id, secret_id, position, title) VALUES (?, ?, ?, ?)",
+					bundleID,
+					secretID,
+					position,
+					secret.title,
+				)
+				if err != nil {
+					return fmt.Errorf("inserting bundle secret: %w", err)
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// GetBundleByViewingID retrieves a bundle via its access ID along with all of its secrets
+func (s *sqlSecretStore) GetBundleByViewingID(ctx context.Context, accessID string) (storedBundle, error) {
+	b, err := s.getBundle(ctx, "access_id", accessID)
+	if err != nil {
+		return storedBundle{}, err
+	}
+
+	for i, secret := range b.secrets {
+		b.secrets[i].storedSecret = forViewer(secret.storedSecret)
+	}
+
+	return b, nil
+}
+
+// GetBundleByManagementID retrieves a bundle via its management ID along with all of its secrets
+func (s *sqlSecretStore) GetBundleByManagementID(ctx context.Context, managementID string) (storedBundle, error) {
+	return s.getBundle(ctx, "management_id", managementID)
+}
+
+// getBundle retrieves a bundle along with all of its secrets via the value of the given identifier column, which must
+// never be user input
+func (s *sqlSecretStore) getBundle(ctx context.Context, column string, id string) (storedBundle, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var b storedBundle
+	var bundleID int64
+
+	err := s.db.queryRow(
+		ctx,
+		"SELECT id, access_id, management_id, created_at FROM bundles WHERE "+column+" = ?",
+		id,
+	).Scan(&bundleID, &b.accessID, &b.managementID, &b.createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storedBundle{}, errSecretNotFound
+	} else if err != nil {
+		return storedBundle{}, err
+	}
+
+	rows, err := s.db.query(
+		ctx,
+		`
+			SELECT
+				b.title,
+				s.access_id,
+				s.management_id,
+				s.ttl,
+				s.maximum_views,
+				(SELECT COUNT(1) FROM secret_views v WHERE v.secret_id = s.id AND v.viewed_at IS NOT NULL),
+				s.burn_after_reading,
+				s.passphrase_hash,
+				s.label,
+				s.not_before,
+				s.created_at,
+				s.deleted_at,
+				s.deletion_reason
+			FROM
+				bundle_secrets b
+				INNER JOIN secrets s ON s.id = b.secret_id
+			WHERE
+				b.bundle_id = ?
+			ORDER BY
+				b.position
+		`,
+		bundleID,
+	)
+	if err != nil {
+		return storedBundle{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var secret bundledSecret
+		var passphraseHash sql.NullString
+		var label sql.NullString
+		var deletedAt sql.NullInt64
+		var deletionReason sql.NullString
+
+		err := rows.Scan(
+			&secret.title,
+			&secret.accessID,
+			&secret.managementID,
+			&secret.ttl,
+			&secret.maximumViews,
+			&secret.views,
+			&secret.burnAfterReading,
+			&passphraseHash,
+			&label,
+			&secret.notBefore,
+			&secret.createdAt,
+			&deletedAt,
+			&deletionReason,
+		)
+		if err != nil {
+			return storedBundle{}, err
+		}
+
+		secret.passphraseHash = passphraseHash.String
+		secret.label = label.String
+		secret.deletedAt = deletedAt.Int64
+		secret.deletionReason = deletionReason.String
+
+		b.secrets = append(b.secrets, secret)
+	}
+
+	return b, rows.Err()
+}
+
+// DeleteBundle deletes all of the secrets of a bundle on behalf of its creator
+func (s *sqlSecretStore) DeleteBundle(ctx context.Context, managementID string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var bundleID int64
+
+	err := s.db.queryRow(ctx, "SELECT id FROM bundles WHERE management_id = ?", managementID).Scan(&bundleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errSecretNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var accessIDs []string
+
+	err = s.db.retryBusy(func() error {
+		accessIDs = nil
+
+		rows, err := s.db.query(
+			ctx,
+			`
+				UPDATE
+					secrets
+				SET
+					deleted_at = ?1,
+					deletion_reason = ?2,
+					cipher_text = NULL
+				WHERE
+					id IN (SELECT secret_id FROM bundle_secrets WHERE bundle_id = ?3) AND
+					deleted_at IS NULL
+				RETURNING
+					access_id
+			`,
+			time.Now().UnixMilli(),
+			deletionReasonUserDeleted,
+			bundleID,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var accessID string
+			if err := rows.Scan(&accessID); err != nil {
+				return err
+			}
+
+			accessIDs = append(accessIDs, accessID)
+		}
+
+		return rows.Err()
+	})
+
+	return accessIDs, err
+}
+
+// CountActive counts the secrets that have neither been deleted nor expired. Secrets can expire without the job that
+// deletes expired secrets having ran yet, so expiry is checked explicitly.
+func (s *sqlSecretStore) CountActive(ctx context.Context) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var active int64
+
+	err := s.db.queryRow(
+		ctx,
+		`
+			SELECT
+				COUNT(1)
+			FROM
+				secrets
+			WHERE
+				deleted_at IS NULL AND
+				(ttl = 0 OR (created_at + (ttl * 60 * 1000)) > ?)
+		`,
+		time.Now().UnixMilli(),
+	).Scan(&active)
+
+	return active, err
+}
+
+// Stats aggregates the state of all secrets as of the given time. Expiry is checked explicitly as secrets can expire
+// before the job that deletes expired secrets has run.
+func (s *sqlSecretStore) Stats(ctx context.Context, now time.Time) (secretStats, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var stats secretStats
+	var oldestActiveCreatedAt sql.NullInt64
+
+	err := s.db.queryRow(
+		ctx,
+		`
+			SELECT
+				COALESCE(SUM(CASE WHEN deleted_at IS NULL AND (ttl = 0 OR (created_at + (ttl * 60 * 1000)) > ?1) THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN deletion_reason = ?3 OR (deleted_at IS NULL AND ttl > 0 AND (created_at + (ttl * 60 * 1000)) <= ?1) THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN deleted_at IS NOT NULL AND deletion_reason <> ?3 THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN created_at > ?2 THEN 1 ELSE 0 END), 0),
+				MIN(CASE WHEN deleted_at IS NULL AND (ttl = 0 OR (created_at + (ttl * 60 * 1000)) > ?1) THEN created_at END),
+				(SELECT COUNT(DISTINCT secret_id) FROM secret_views WHERE viewed_at IS NOT NULL)
+			FROM
+				secrets
+		`,
+		now.UnixMilli(),
+		now.Add(-24*time.Hour).UnixMilli(),
+		deletionReasonExpired,
+	).Scan(
+		&stats.active,
+		&stats.expired,
+		&stats.deleted,
+		&stats.createdLastDay,
+		&oldestActiveCreatedAt,
+		&stats.viewed,
+	)
+	if err != nil {
+		return secretStats{}, err
+	}
+
+	stats.oldestActiveCreatedAt = oldestActiveCreatedAt.Int64
+
+	return stats, nil
+}
+
+// Ping verifies the database is reachable
+func (s *sqlSecretStore) Ping(ctx context.Context) error {
+	return s.db.db.PingContext(ctx)
+}
+
+// Close closes the connection to the database
+func (s *sqlSecretStore) Close() error {
+	return s.db.db.Close()
+}
+
+// nullString converts an optional string value into one that is stored as NULL when empty
+func nullString(s string) sql.NullString {
+	return sql.NullString{Valid: s != "", String: s}
+}
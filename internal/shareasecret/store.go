@@ -0,0 +1,208 @@
+package shareasecret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errSecretNotFound is returned by a [SecretStore] when a secret (or the view of one) does not exist, has been deleted,
+// or has expired
+var errSecretNotFound = errors.New("secret not found")
+
+// errSecretIDCollision is returned by [SecretStore.Create] when the access ID or management ID of a new secret is
+// already in use by another secret
+var errSecretIDCollision = errors.New("secret id already in use")
+
+// errSecretNotYetAvailable is returned by [SecretStore.GetByViewingID] when a secret exists but cannot be viewed until
+// its not before time. It wraps [errSecretNotFound] so that, unless handled specifically, such secrets are treated as
+// if they do not exist.
+var errSecretNotYetAvailable = fmt.Errorf("%w: not yet available", errSecretNotFound)
+
+// SecretStore persists secrets and the views of them. Operations are abandoned once their context is done.
+// Implementations are responsible for enforcing the expiry, burn after reading and maximum view semantics of secrets,
+// meaning secrets that have expired are treated as if they do not exist when accessed via their access ID.
+type SecretStore interface {
+	// Create persists a new secret, using the identifiers already set on it. [errSecretIDCollision] is returned if
+	// either identifier is already in use.
+	Create(ctx context.Context, s storedSecret) error
+	// GetByViewingID retrieves a secret that can still be viewed via its access ID. The label of the secret, which is
+	// only ever shown to its creator, is not returned by this or any other method used when viewing a secret.
+	// [errSecretNotYetAvailable] is returned, along with a secret with only its not before time set, if the secret
+	// cannot be viewed yet.
+	GetByViewingID(ctx context.Context, accessID string) (storedSecret, error)
+	// UnavailableReason retrieves why a secret that existed can no longer be viewed via its access ID: the reason it
+	// was deleted, or [deletionReasonExpired] if it has expired but not yet been deleted. [errSecretNotFound] is
+	// returned if the secret never existed, has been permanently removed, or can still be viewed.
+	UnavailableReason(ctx context.Context, accessID string) (string, error)
+	// GetByManagementID retrieves a secret via its management ID, regardless of whether it has been deleted or expired.
+	// The size of the cipher text is retrieved rather than the cipher text itself.
+	GetByManagementID(ctx context.Context, managementID string) (storedSecret, error)
+	// CreateView records an unused view of a secret that can be consumed with [SecretStore.ConsumeView]. Views cannot
+	// be created of secrets before their not before time.
+	CreateView(ctx context.Context, accessID string, viewingKey string) error
+	// ConsumeView atomically uses a view of a secret, deleting the secret if the view exhausts it. The secret (including
+	// its cipher text and format) is returned along with the reason it was deleted, if it was.
+	ConsumeView(ctx context.Context, accessID string, viewingKey string) (storedSecret, string, error)
+	// Acknowledge records that the creator of a secret has stored its links, returning whether the secret was found.
+	// Secrets that have already been acknowledged keep the time they were first acknowledged.
+	Acknowledge(ctx context.Context, managementID string) (bool, error)
+	// RotateAccessID replaces the access ID of a secret that can still be viewed via its management ID, immediately
+	// invalidating the previous access ID along with any unused views created via it. [errSecretNotFound] is returned if
+	// the secret does not exist, has been deleted, or has expired, and [errSecretIDCollision] if the new access ID is
+	// already in use.
+	RotateAccessID(ctx context.Context, managementID string, accessID string) error
+	// UpdateTTL replaces the TTL (in minutes, counted from when the secret was created) of a secret that can still be
+	// viewed via its management ID. [errSecretNotFound] is returned if the secret does not exist, has been deleted, or
+	// has expired.
+	UpdateTTL(ctx context.Context, managementID string, ttl int64) error
+	// Delete deletes a secret on behalf of its creator, returning whether a secret that had not already been deleted
+	// was found
+	Delete(ctx context.Context, managementID string) (bool, error)
+	// Revoke deletes all secrets associated with the given recovery token hash on behalf of their creator, in the same
+	// manner as [SecretStore.Delete]. The access IDs of the secrets deleted are returned.
+	Revoke(ctx context.Context, recoveryTokenHash string) ([]string, error)
+	// ListByCreatorSession retrieves the most recently created secrets (up to the given limit) associated with the given
+	// creator session hash, newest first and regardless of whether they have been deleted or expired. The cipher text of
+	// the secrets is not returned.
+	ListByCreatorSession(ctx context.Context, creatorSessionHash string, limit int) ([]storedSecret, error)
+	// RecordDecryptionFailure increments the number of failed decryption attempts of a secret, deleting the secret if
+	// the given maximum (if greater than 0) has been reached. Whether the secret was deleted is returned.
+	RecordDecryptionFailure(ctx context.Context, accessID string, maximum int) (bool, error)
+	// SweepExpired deletes all secrets that have expired as of the given time, returning the secrets deleted
+	SweepExpired(ctx context.Context, now time.Time) ([]storedSecret, error)
+	// PurgeDeleted permanently removes all secrets (along with their views, events and accesses) deleted at or before
+	// the given time, returning the number of secrets removed
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// RecordEvent records an event in the lifecycle of the secret with the access ID or management ID set on the event
+	RecordEvent(ctx context.Context, e secretEvent) error
+	// Events retrieves the most recent events (up to the given limit) of a secret via its management ID, newest first
+	Events(ctx context.Context, managementID string, limit int) ([]secretEvent, error)
+	// RecordAccess records an access of the secret with the given access ID in the access log shown to its creator
+	RecordAccess(ctx context.Context, accessID string, access secretAccess) error
+	// Accesses retrieves the most recent accesses (up to the given limit) of a secret via its management ID, newest
+	// first
+	Accesses(ctx context.Context, managementID string, limit int) ([]secretAccess, error)
+	// CreateBundle persists a new bundle along with its secrets, using the identifiers already set on them.
+	// [errSecretIDCollision] is returned if any identifier is already in use by a bundle or secret.
+	CreateBundle(ctx context.Context, b storedBundle) error
+	// GetBundleByViewingID retrieves a bundle via its access ID along with all of its secrets, in the order they were
+	// created. Neither the cipher text nor the label of the secrets is returned, and the viewer is left to determine
+	// which of them can still be viewed.
+	GetBundleByViewingID(ctx context.Context, accessID string) (storedBundle, error)
+	// GetBundleByManagementID retrieves a bundle via its management ID along with all of its secrets, in the order they
+	// were created. The cipher text of the secrets is not returned.
+	GetBundleByManagementID(ctx context.Context, managementID string) (storedBundle, error)
+	// DeleteBundle deletes all of the secrets of a bundle that have not already been deleted on behalf of its creator,
+	// in the same manner as [SecretStore.Delete]. The access IDs of the secrets deleted are returned, or
+	// [errSecretNotFound] if the bundle does not exist.
+	DeleteBundle(ctx context.Context, managementID string) ([]string, error)
+	// CountActive counts the secrets that can still be viewed
+	CountActive(ctx context.Context) (int64, error)
+	// Stats aggregates the state of all secrets as of the given time
+	Stats(ctx context.Context, now time.Time) (secretStats, error)
+	// Ping verifies the store is reachable
+	Ping(ctx context.Context) error
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// storedSecret is a secret as persisted by a [SecretStore]. Optional values are empty when not set, and timestamps are
+// unix milliseconds.
+type storedSecret struct {
+	accessID     string
+	managementID string
+	cipherText   string
+	// cipherFormat is the version of the format of the cipher text (see [cipherTextFormat])
+	cipherFormat string
+	// cipherTextSize is the size of the cipher text in bytes, which is retrieved in place of the cipher text itself when
+	// managing secrets and is 0 once they are deleted
+	cipherTextSize   int
+	ttl              int64
+	maximumViews     int
+	views            int
+	burnAfterReading bool
+	// revealDelaySeconds is how long the viewer of the secret waits after decrypting it before it is revealed
+	revealDelaySeconds int
+	passphraseHash     string
+	notifyWebhook      string
+	notifyEmail        string
+	label              string
+	// recoveryTokenHash is the hash of the recovery token of the secret (see [hashRecoveryToken]), and is only ever
+	// written
+	recoveryTokenHash string
+	// trackAccess identifies whether each access of the secret is recorded in an access log shown to its creator
+	trackAccess bool
+	// creatorSessionHash is the hash of the browser session the secret was created in (see [hashCreatorSession]),
+	// which lists the secret on the /my-secrets page of that browser
+	creatorSessionHash string
+	// notBefore is when the secret becomes viewable, or 0 if it can be viewed as soon as it is created
+	notBefore      int64
+	createdAt      int64
+	acknowledgedAt int64
+	deletedAt      int64
+	deletionReason string
+}
+
+// storedBundle is a group of secrets shared via a single link, as persisted by a [SecretStore]. Bundles are removed
+// along with the last of their secrets when deleted secrets are purged.
+type storedBundle struct {
+	accessID     string
+	managementID string
+	createdAt    int64
+	secrets      []bundledSecret
+}
+
+// bundledSecret is a secret belonging to a [storedBundle]
+type bundledSecret struct {
+	// title describes the secret to those viewing the bundle, unlike the label of the secret which is only ever shown to
+	// its creator
+	title string
+	storedSecret
+}
+
+// secretStats are aggregate statistics of all secrets in a [SecretStore]
+type secretStats struct {
+	// active is the number of secrets that can still be viewed
+	active int64
+	// viewed is the number of secrets that have been viewed at least once
+	viewed int64
+	// expired is the number of secrets that have exceeded their TTL (time to live)
+	expired int64
+	// deleted is the number of secrets deleted for any reason other than expiring
+	deleted int64
+	// createdLastDay is the number of secrets created in the 24 hours before the time the statistics were generated
+	createdLastDay int64
+	// oldestActiveCreatedAt is when the oldest secret that can still be viewed was created, or 0 if there isn't one
+	oldestActiveCreatedAt int64
+}
+
+// deleted identifies whether the secret has been deleted
+func (s storedSecret) deleted() bool {
+	return s.deletedAt != 0
+}
+
+// expired identifies whether the secret has exceeded its TTL (time to live), regardless of whether the job that deletes
+// expired secrets has deleted it yet
+func (s storedSecret) expired() bool {
+	return isExpired(s.createdAt, s.ttl)
+}
+
+// notYetAvailable identifies whether the secret cannot be viewed yet as its not before time hasn't been reached
+func (s storedSecret) notYetAvailable() bool {
+	return s.notBefore > time.Now().UnixMilli()
+}
+
+// unavailableReason describes why the secret can no longer be viewed, or returns an empty string if it still can be.
+// Secrets that can't be viewed until their not before time are still available, as their viewing URL can be shared in
+// the meantime.
+func (s storedSecret) unavailableReason() string {
+	if s.deleted() {
+		return describeDeletionReason(s.deletionReason)
+	} else if s.expired() {
+		return describeDeletionReason(deletionReasonExpired)
+	}
+
+	return ""
+}
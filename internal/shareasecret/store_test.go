@@ -0,0 +1,794 @@
+package shareasecret
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testStores returns every [SecretStore] implementation that should behave identically
+func testStores() map[string]SecretStore {
+	return map[string]SecretStore{
+		"sql":    app.store,
+		"memory": newMemorySecretStore(),
+	}
+}
+
+// newTestStoredSecret creates a secret in the store with random identifiers, modified by the given function
+func newTestStoredSecret(t *testing.T, store SecretStore, modify func(s *storedSecret)) storedSecret {
+	t.Helper()
+
+	accessID, err := secureID(24)
+	if err != nil {
+		t.Fatalf("access id: %v", err)
+	}
+	managementID, err := secureID(24)
+	if err != nil {
+		t.Fatalf("management id: %v", err)
+	}
+
+	s := storedSecret{
+		accessID:     accessID,
+		managementID: managementID,
+		cipherText:   "YWJj.ZGVm.Z2hp",
+		cipherFormat: cipherFormatV1,
+		ttl:          30,
+		maximumViews: 1,
+		createdAt:    time.Now().UnixMilli(),
+	}
+	if modify != nil {
+		modify(&s)
+	}
+
+	if err := store.Create(context.Background(), s); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	return s
+}
+
+// newTestStoredBundle creates a bundle of secrets with the given titles in the given store
+func newTestStoredBundle(t *testing.T, store SecretStore, titles ...string) storedBundle {
+	t.Helper()
+
+	accessID, _ := secureID(24)
+	managementID, _ := secureID(24)
+
+	b := storedBundle{accessID: accessID, managementID: managementID, createdAt: time.Now().UnixMilli()}
+	for _, title := range titles {
+		secretAccessID, _ := secureID(24)
+		secretManagementID, _ := secureID(24)
+
+		b.secrets = append(b.secrets, bundledSecret{
+			title: title,
+			storedSecret: storedSecret{
+				accessID:     secretAccessID,
+				managementID: secretManagementID,
+				cipherText:   "YWJj.ZGVm.Z2hp",
+				ttl:          30,
+				label:        "label",
+				createdAt:    time.Now().UnixMilli(),
+			},
+		})
+	}
+
+	if err := store.CreateBundle(context.Background(), b); err != nil {
+		t.Fatalf("create bundle: %v", err)
+	}
+
+	return b
+}
+
+func TestSecretStores(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("retrieves created secrets", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				byViewingID, err := store.GetByViewingID(ctx, s.accessID)
+				if err != nil {
+					t.Fatalf("get by viewing id: %v", err)
+				}
+				if byViewingID.managementID != s.managementID || byViewingID.maximumViews != 1 {
+					t.Errorf("wanted secret %+v, got %+v", s, byViewingID)
+				}
+
+				byManagementID, err := store.GetByManagementID(ctx, s.managementID)
+				if err != nil {
+					t.Fatalf("get by management id: %v", err)
+				}
+				if byManagementID.accessID != s.accessID {
+					t.Errorf("wanted access id %v, got %v", s.accessID, byManagementID.accessID)
+				} else if byManagementID.cipherText != "" || byManagementID.cipherTextSize != len(s.cipherText) {
+					t.Errorf("wanted size of cipher text %v only, got %q of size %v", len(s.cipherText), byManagementID.cipherText, byManagementID.cipherTextSize)
+				}
+			})
+
+			t.Run("refuses to reuse the identifiers of existing secrets", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				other, _ := secureID(24)
+				for _, c := range []storedSecret{
+					{accessID: s.accessID, managementID: other, cipherText: s.cipherText, createdAt: s.createdAt},
+					{accessID: other, managementID: s.managementID, cipherText: s.cipherText, createdAt: s.createdAt},
+				} {
+					if err := store.Create(ctx, c); !errors.Is(err, errSecretIDCollision) {
+						t.Errorf("wanted id collision error, got %v", err)
+					}
+				}
+			})
+
+			t.Run("does not find secrets via the other identifier", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				if _, err := store.GetByViewingID(ctx, s.managementID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("get by viewing id: wanted not found error for management id, got %v", err)
+				}
+				if _, err := store.GetByManagementID(ctx, s.accessID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("get by management id: wanted not found error for access id, got %v", err)
+				}
+			})
+
+			t.Run("only returns the label when managing secrets", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, func(s *storedSecret) { s.label = "label" })
+
+				if managed, err := store.GetByManagementID(ctx, s.managementID); err != nil || managed.label != "label" {
+					t.Errorf("get by management id: wanted label, got %q (%v)", managed.label, err)
+				}
+				if viewable, err := store.GetByViewingID(ctx, s.accessID); err != nil || viewable.label != "" {
+					t.Errorf("get by viewing id: wanted no label, got %q (%v)", viewable.label, err)
+				}
+
+				if err := store.CreateView(ctx, s.accessID, "key"); err != nil {
+					t.Fatalf("create view: %v", err)
+				}
+				if viewed, _, err := store.ConsumeView(ctx, s.accessID, "key"); err != nil || viewed.label != "" {
+					t.Errorf("consume view: wanted no label, got %q (%v)", viewed.label, err)
+				}
+			})
+
+			t.Run("returns not found for unknown secrets", func(t *testing.T) {
+				if _, err := store.GetByViewingID(ctx, "unknown"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("get by viewing id: wanted errSecretNotFound, got %v", err)
+				}
+				if _, err := store.GetByManagementID(ctx, "unknown"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("get by management id: wanted errSecretNotFound, got %v", err)
+				}
+			})
+
+			t.Run("treats expired secrets as not found when viewing", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, func(s *storedSecret) {
+					s.createdAt = time.Now().Add(-time.Hour).UnixMilli()
+				})
+
+				if _, err := store.GetByViewingID(ctx, s.accessID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("get by viewing id: wanted errSecretNotFound, got %v", err)
+				}
+				if err := store.CreateView(ctx, s.accessID, "key"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("create view: wanted errSecretNotFound, got %v", err)
+				}
+				if _, err := store.GetByManagementID(ctx, s.managementID); err != nil {
+					t.Errorf("get by management id: %v", err)
+				}
+			})
+
+			t.Run("does not view secrets before their not before time", func(t *testing.T) {
+				notBefore := time.Now().Add(time.Minute).UnixMilli()
+				s := newTestStoredSecret(t, store, func(s *storedSecret) { s.notBefore = notBefore })
+
+				if secret, err := store.GetByViewingID(ctx, s.accessID); !errors.Is(err, errSecretNotYetAvailable) || secret.notBefore != notBefore || secret.managementID != "" {
+					t.Errorf("get by viewing id: wanted errSecretNotYetAvailable with only the not before time, got %+v (%v)", secret, err)
+				}
+				if err := store.CreateView(ctx, s.accessID, "key"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("create view: wanted errSecretNotFound, got %v", err)
+				}
+				if _, err := store.UnavailableReason(ctx, s.accessID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("unavailable reason: wanted errSecretNotFound, got %v", err)
+				}
+				if managed, err := store.GetByManagementID(ctx, s.managementID); err != nil || managed.notBefore != notBefore {
+					t.Errorf("get by management id: wanted not before time %v, got %v (%v)", notBefore, managed.notBefore, err)
+				}
+
+				available := newTestStoredSecret(t, store, func(s *storedSecret) { s.notBefore = time.Now().Add(-time.Minute).UnixMilli() })
+				if _, err := store.GetByViewingID(ctx, available.accessID); err != nil {
+					t.Errorf("get by viewing id: wanted secret once its not before time has passed, got %v", err)
+				}
+				if err := store.CreateView(ctx, available.accessID, "key"); err != nil {
+					t.Errorf("create view: wanted view once its not before time has passed, got %v", err)
+				}
+			})
+
+			t.Run("describes why secrets that existed are unavailable", func(t *testing.T) {
+				active := newTestStoredSecret(t, store, nil)
+				expired := newTestStoredSecret(t, store, func(s *storedSecret) {
+					s.ttl = 1
+					s.createdAt = time.Now().Add(-2 * time.Minute).UnixMilli()
+				})
+				deleted := newTestStoredSecret(t, store, nil)
+				store.Delete(ctx, deleted.managementID)
+
+				for accessID, want := range map[string]string{expired.accessID: deletionReasonExpired, deleted.accessID: deletionReasonUserDeleted} {
+					if reason, err := store.UnavailableReason(ctx, accessID); err != nil || reason != want {
+						t.Errorf("wanted reason %v, got %v (%v)", want, reason, err)
+					}
+				}
+
+				for _, accessID := range []string{active.accessID, "unknown"} {
+					if _, err := store.UnavailableReason(ctx, accessID); !errors.Is(err, errSecretNotFound) {
+						t.Errorf("wanted not found error, got %v", err)
+					}
+				}
+			})
+
+			t.Run("views can only be consumed once", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, func(s *storedSecret) { s.maximumViews = 0 })
+
+				if err := store.CreateView(ctx, s.accessID, "key"); err != nil {
+					t.Fatalf("create view: %v", err)
+				}
+
+				viewed, reason, err := store.ConsumeView(ctx, s.accessID, "key")
+				if err != nil {
+					t.Fatalf("consume view: %v", err)
+				}
+				if viewed.cipherText != s.cipherText || viewed.cipherFormat != cipherFormatV1 || reason != "" {
+					t.Errorf("wanted cipher text %v and no deletion, got %v and %q", s.cipherText, viewed.cipherText, reason)
+				}
+
+				if _, _, err := store.ConsumeView(ctx, s.accessID, "key"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("second consume: wanted errSecretNotFound, got %v", err)
+				}
+				if _, _, err := store.ConsumeView(ctx, s.accessID, "other"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("unknown key: wanted errSecretNotFound, got %v", err)
+				}
+			})
+
+			t.Run("serves no more views than the maximum when consumed concurrently", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, func(s *storedSecret) { s.maximumViews = 2 })
+
+				keys := []string{"a", "b", "c", "d"}
+				for _, key := range keys {
+					if err := store.CreateView(ctx, s.accessID, key); err != nil {
+						t.Fatalf("create view: %v", err)
+					}
+				}
+
+				var wg sync.WaitGroup
+				var served atomic.Int32
+				for _, key := range keys {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, _, err := store.ConsumeView(ctx, s.accessID, key); err == nil {
+							served.Add(1)
+						} else if !errors.Is(err, errSecretNotFound) {
+							t.Errorf("consume view: %v", err)
+						}
+					}()
+				}
+				wg.Wait()
+
+				if served.Load() != 2 {
+					t.Errorf("wanted 2 views to be served, got %v", served.Load())
+				}
+			})
+
+			t.Run("deletes secrets that are burnt or hit their maximum views", func(t *testing.T) {
+				cases := []struct {
+					modify func(s *storedSecret)
+					reason string
+				}{
+					{func(s *storedSecret) { s.maximumViews = 0; s.burnAfterReading = true }, deletionReasonViewed},
+					{nil, deletionReasonMaximumViewCountHit},
+				}
+
+				for _, c := range cases {
+					s := newTestStoredSecret(t, store, c.modify)
+
+					if err := store.CreateView(ctx, s.accessID, "key"); err != nil {
+						t.Fatalf("create view: %v", err)
+					}
+					if _, reason, err := store.ConsumeView(ctx, s.accessID, "key"); err != nil || reason != c.reason {
+						t.Fatalf("consume view: wanted reason %q, got %q (%v)", c.reason, reason, err)
+					}
+
+					if _, err := store.GetByViewingID(ctx, s.accessID); !errors.Is(err, errSecretNotFound) {
+						t.Errorf("get by viewing id: wanted errSecretNotFound, got %v", err)
+					}
+
+					deleted, err := store.GetByManagementID(ctx, s.managementID)
+					if err != nil {
+						t.Fatalf("get by management id: %v", err)
+					}
+					if !deleted.deleted() || deleted.deletionReason != c.reason || deleted.views != 1 {
+						t.Errorf("wanted secret deleted for %q with 1 view, got %+v", c.reason, deleted)
+					}
+				}
+			})
+
+			t.Run("keeps the time a secret was first acknowledged", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				if found, err := store.Acknowledge(ctx, s.managementID); err != nil || !found {
+					t.Fatalf("first acknowledge: wanted found, got %v (%v)", found, err)
+				}
+				first, err := store.GetByManagementID(ctx, s.managementID)
+				if err != nil || first.acknowledgedAt == 0 {
+					t.Fatalf("wanted secret to be acknowledged, got %+v (%v)", first, err)
+				}
+
+				time.Sleep(2 * time.Millisecond)
+
+				if found, err := store.Acknowledge(ctx, s.managementID); err != nil || !found {
+					t.Fatalf("second acknowledge: wanted found, got %v (%v)", found, err)
+				}
+				if second, err := store.GetByManagementID(ctx, s.managementID); err != nil || second.acknowledgedAt != first.acknowledgedAt {
+					t.Errorf("wanted acknowledged at %v, got %v (%v)", first.acknowledgedAt, second.acknowledgedAt, err)
+				}
+
+				if found, err := store.Acknowledge(ctx, "unknown"); err != nil || found {
+					t.Errorf("unknown acknowledge: wanted not found, got %v (%v)", found, err)
+				}
+			})
+
+			t.Run("returns the most recent events of a secret", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				for i, e := range []secretEvent{
+					{accessID: s.accessID, eventType: eventCreated},
+					{accessID: s.accessID, eventType: eventViewed, ipHash: "hash"},
+					{managementID: s.managementID, eventType: eventDeleted, detail: deletionReasonUserDeleted},
+				} {
+					e.occurredAt = int64(i + 1)
+					if err := store.RecordEvent(ctx, e); err != nil {
+						t.Fatalf("record event: %v", err)
+					}
+				}
+
+				events, err := store.Events(ctx, s.managementID, 2)
+				if err != nil {
+					t.Fatalf("events: %v", err)
+				} else if len(events) != 2 {
+					t.Fatalf("wanted 2 events, got %+v", events)
+				}
+
+				if events[0].eventType != eventDeleted || events[0].detail != deletionReasonUserDeleted {
+					t.Errorf("wanted deleted event first, got %+v", events[0])
+				} else if events[1].eventType != eventViewed || events[1].ipHash != "hash" {
+					t.Errorf("wanted viewed event second, got %+v", events[1])
+				}
+
+				if events, err := store.Events(ctx, "unknown", 10); err != nil || len(events) != 0 {
+					t.Errorf("wanted no events for unknown secret, got %+v (%v)", events, err)
+				}
+			})
+
+			t.Run("returns the most recent accesses of a secret", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, func(s *storedSecret) { s.trackAccess = true })
+
+				if byManagementID, err := store.GetByManagementID(ctx, s.managementID); err != nil || !byManagementID.trackAccess {
+					t.Fatalf("wanted tracked secret, got %+v (%v)", byManagementID, err)
+				}
+
+				for i, ua := range []string{"first", "second", "third"} {
+					if err := store.RecordAccess(ctx, s.accessID, secretAccess{ipHash: "hash", userAgent: ua, accessedAt: int64(i + 1)}); err != nil {
+						t.Fatalf("record access: %v", err)
+					}
+				}
+
+				accesses, err := store.Accesses(ctx, s.managementID, 2)
+				if err != nil {
+					t.Fatalf("accesses: %v", err)
+				} else if len(accesses) != 2 || accesses[0].userAgent != "third" || accesses[1].userAgent != "second" {
+					t.Errorf("wanted two most recent accesses, got %+v", accesses)
+				} else if accesses[0].ipHash != "hash" || accesses[0].accessedAt != 3 {
+					t.Errorf("wanted hashed ip address and time of access, got %+v", accesses[0])
+				}
+
+				if accesses, err := store.Accesses(ctx, "unknown", 10); err != nil || len(accesses) != 0 {
+					t.Errorf("wanted no accesses for unknown secret, got %+v (%v)", accesses, err)
+				}
+			})
+
+			t.Run("deletes secrets once", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				if found, err := store.Delete(ctx, s.managementID); err != nil || !found {
+					t.Fatalf("first delete: wanted found, got %v (%v)", found, err)
+				}
+				if found, err := store.Delete(ctx, s.managementID); err != nil || found {
+					t.Errorf("second delete: wanted not found, got %v (%v)", found, err)
+				}
+				if found, err := store.Delete(ctx, "unknown"); err != nil || found {
+					t.Errorf("unknown delete: wanted not found, got %v (%v)", found, err)
+				}
+			})
+
+			t.Run("revokes the secrets of a recovery token", func(t *testing.T) {
+				hash := hashRecoveryToken(t.Name())
+				first := newTestStoredSecret(t, store, func(s *storedSecret) { s.recoveryTokenHash = hash })
+				second := newTestStoredSecret(t, store, func(s *storedSecret) { s.recoveryTokenHash = hash })
+				other := newTestStoredSecret(t, store, nil)
+
+				revoked, err := store.Revoke(ctx, hash)
+				if err != nil {
+					t.Fatalf("revoke: %v", err)
+				} else if len(revoked) != 2 {
+					t.Fatalf("wanted 2 secrets revoked, got %v", revoked)
+				}
+
+				for _, s := range []storedSecret{first, second} {
+					if deleted, err := store.GetByManagementID(ctx, s.managementID); err != nil || deleted.deletionReason != deletionReasonUserDeleted {
+						t.Errorf("wanted secret deleted by user, got %+v (%v)", deleted, err)
+					}
+				}
+				if _, err := store.GetByViewingID(ctx, other.accessID); err != nil {
+					t.Errorf("wanted secret without recovery token to remain, got %v", err)
+				}
+
+				if revoked, err := store.Revoke(ctx, hash); err != nil || len(revoked) != 0 {
+					t.Errorf("second revoke: wanted nothing revoked, got %v (%v)", revoked, err)
+				}
+			})
+
+			t.Run("updates the ttl of secrets that can still be viewed", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				if err := store.UpdateTTL(ctx, s.managementID, 120); err != nil {
+					t.Fatalf("update ttl: %v", err)
+				}
+				if updated, err := store.GetByManagementID(ctx, s.managementID); err != nil || updated.ttl != 120 {
+					t.Errorf("wanted ttl of 120, got %v (%v)", updated.ttl, err)
+				}
+
+				expired := newTestStoredSecret(t, store, func(s *storedSecret) { s.createdAt = time.Now().Add(-time.Hour).UnixMilli() })
+				deleted := newTestStoredSecret(t, store, nil)
+				if _, err := store.Delete(ctx, deleted.managementID); err != nil {
+					t.Fatalf("delete: %v", err)
+				}
+
+				for _, managementID := range []string{expired.managementID, deleted.managementID, "unknown"} {
+					if err := store.UpdateTTL(ctx, managementID, 120); !errors.Is(err, errSecretNotFound) {
+						t.Errorf("wanted errSecretNotFound, got %v", err)
+					}
+				}
+			})
+
+			t.Run("rotates the access ids of secrets", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+				if err := store.CreateView(ctx, s.accessID, "unused"); err != nil {
+					t.Fatalf("create view: %v", err)
+				}
+
+				rotated, _ := secureID(24)
+				if err := store.RotateAccessID(ctx, s.managementID, rotated); err != nil {
+					t.Fatalf("rotate access id: %v", err)
+				}
+
+				if _, err := store.GetByViewingID(ctx, s.accessID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("wanted previous access id to be not found, got %v", err)
+				}
+				if _, _, err := store.ConsumeView(ctx, rotated, "unused"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("wanted unused view to be discarded, got %v", err)
+				}
+
+				if err := store.CreateView(ctx, rotated, "new"); err != nil {
+					t.Fatalf("create view: %v", err)
+				}
+				if viewed, _, err := store.ConsumeView(ctx, rotated, "new"); err != nil || viewed.cipherText != s.cipherText {
+					t.Errorf("wanted cipher text via rotated access id, got %+v (%v)", viewed, err)
+				}
+			})
+
+			t.Run("refuses to rotate access ids that are in use or of unavailable secrets", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+				other := newTestStoredSecret(t, store, nil)
+
+				if err := store.RotateAccessID(ctx, s.managementID, other.accessID); !errors.Is(err, errSecretIDCollision) {
+					t.Errorf("wanted errSecretIDCollision, got %v", err)
+				}
+
+				if _, err := store.Delete(ctx, s.managementID); err != nil {
+					t.Fatalf("delete: %v", err)
+				}
+
+				rotated, _ := secureID(24)
+				for _, managementID := range []string{s.managementID, "unknown"} {
+					if err := store.RotateAccessID(ctx, managementID, rotated); !errors.Is(err, errSecretNotFound) {
+						t.Errorf("wanted errSecretNotFound for %v, got %v", managementID, err)
+					}
+				}
+			})
+
+			t.Run("lists the secrets of a creator session", func(t *testing.T) {
+				hash := hashCreatorSession(t.Name())
+				first := newTestStoredSecret(t, store, func(s *storedSecret) { s.creatorSessionHash = hash; s.label = "first" })
+				second := newTestStoredSecret(t, store, func(s *storedSecret) { s.creatorSessionHash = hash; s.createdAt++ })
+				newTestStoredSecret(t, store, nil)
+
+				if _, err := store.Delete(ctx, first.managementID); err != nil {
+					t.Fatalf("delete: %v", err)
+				}
+
+				listed, err := store.ListByCreatorSession(ctx, hash, 10)
+				if err != nil {
+					t.Fatalf("list by creator session: %v", err)
+				} else if len(listed) != 2 {
+					t.Fatalf("wanted 2 secrets listed, got %+v", listed)
+				}
+				if listed[0].managementID != second.managementID || listed[1].managementID != first.managementID {
+					t.Errorf("wanted newest secret first, got %+v", listed)
+				}
+				if listed[1].label != "first" || !listed[1].deleted() || listed[1].cipherText != "" {
+					t.Errorf("wanted deleted secret with label and without cipher text, got %+v", listed[1])
+				}
+
+				if limited, err := store.ListByCreatorSession(ctx, hash, 1); err != nil || len(limited) != 1 {
+					t.Errorf("wanted 1 secret listed once limited, got %+v (%v)", limited, err)
+				}
+			})
+
+			t.Run("deletes secrets once the maximum decryption failures are hit", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+
+				if deleted, err := store.RecordDecryptionFailure(ctx, s.accessID, 2); err != nil || deleted {
+					t.Fatalf("first failure: wanted not deleted, got %v (%v)", deleted, err)
+				}
+				if deleted, err := store.RecordDecryptionFailure(ctx, s.accessID, 2); err != nil || !deleted {
+					t.Fatalf("second failure: wanted deleted, got %v (%v)", deleted, err)
+				}
+				if _, err := store.RecordDecryptionFailure(ctx, s.accessID, 2); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("third failure: wanted errSecretNotFound, got %v", err)
+				}
+			})
+
+			t.Run("aggregates the state of all secrets", func(t *testing.T) {
+				before, err := store.Stats(ctx, time.Now())
+				if err != nil {
+					t.Fatalf("stats: %v", err)
+				}
+
+				newTestStoredSecret(t, store, func(s *storedSecret) { s.ttl = 0 })
+				newTestStoredSecret(t, store, func(s *storedSecret) { s.createdAt = time.Now().Add(-time.Hour).UnixMilli() })
+				deleted := newTestStoredSecret(t, store, nil)
+				if _, err := store.Delete(ctx, deleted.managementID); err != nil {
+					t.Fatalf("delete: %v", err)
+				}
+				viewed := newTestStoredSecret(t, store, func(s *storedSecret) { s.maximumViews = 0 })
+				if err := store.CreateView(ctx, viewed.accessID, "key"); err != nil {
+					t.Fatalf("create view: %v", err)
+				}
+				if _, _, err := store.ConsumeView(ctx, viewed.accessID, "key"); err != nil {
+					t.Fatalf("consume view: %v", err)
+				}
+
+				after, err := store.Stats(ctx, time.Now())
+				if err != nil {
+					t.Fatalf("stats: %v", err)
+				}
+
+				// the shared database contains secrets created by other tests, so only the difference is verified
+				wanted := secretStats{active: 2, viewed: 1, expired: 1, deleted: 1, createdLastDay: 4}
+				got := secretStats{
+					active:         after.active - before.active,
+					viewed:         after.viewed - before.viewed,
+					expired:        after.expired - before.expired,
+					deleted:        after.deleted - before.deleted,
+					createdLastDay: after.createdLastDay - before.createdLastDay,
+				}
+				if got != wanted {
+					t.Errorf("wanted stats to change by %+v, got %+v", wanted, got)
+				}
+				if after.oldestActiveCreatedAt == 0 || after.oldestActiveCreatedAt > viewed.createdAt {
+					t.Errorf("wanted oldest active secret created at or before %v, got %v", viewed.createdAt, after.oldestActiveCreatedAt)
+				}
+			})
+
+			t.Run("retrieves bundles along with their secrets in order", func(t *testing.T) {
+				b := newTestStoredBundle(t, store, "first", "second")
+
+				viewed, err := store.GetBundleByViewingID(ctx, b.accessID)
+				if err != nil {
+					t.Fatalf("get bundle by viewing id: %v", err)
+				} else if len(viewed.secrets) != 2 || viewed.secrets[0].title != "first" || viewed.secrets[1].title != "second" {
+					t.Fatalf("wanted both secrets in order, got %+v", viewed.secrets)
+				} else if viewed.secrets[0].accessID != b.secrets[0].accessID || viewed.secrets[0].label != "" || viewed.secrets[0].cipherText != "" {
+					t.Errorf("wanted secret without its label or cipher text, got %+v", viewed.secrets[0])
+				}
+
+				managed, err := store.GetBundleByManagementID(ctx, b.managementID)
+				if err != nil {
+					t.Fatalf("get bundle by management id: %v", err)
+				} else if managed.accessID != b.accessID || managed.secrets[1].label != "label" {
+					t.Errorf("wanted bundle with labelled secrets, got %+v", managed)
+				}
+
+				if _, err := store.GetByViewingID(ctx, b.secrets[1].accessID); err != nil {
+					t.Errorf("wanted secrets of the bundle to be viewable, got %v", err)
+				}
+
+				if _, err := store.GetBundleByViewingID(ctx, b.managementID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("wanted not found via management id, got %v", err)
+				}
+			})
+
+			t.Run("refuses to reuse the identifiers of existing bundles or secrets", func(t *testing.T) {
+				b := newTestStoredBundle(t, store, "first")
+
+				other, _ := secureID(24)
+				secret := bundledSecret{storedSecret: storedSecret{accessID: other, managementID: other + "m", cipherText: "YWJj.ZGVm.Z2hp", createdAt: time.Now().UnixMilli()}}
+				reused := secret
+				reused.accessID = b.secrets[0].accessID
+
+				for _, c := range []storedBundle{
+					{accessID: b.accessID, managementID: other, secrets: []bundledSecret{secret}},
+					{accessID: other, managementID: other, secrets: []bundledSecret{reused}},
+				} {
+					if err := store.CreateBundle(ctx, c); !errors.Is(err, errSecretIDCollision) {
+						t.Errorf("wanted collision error, got %v", err)
+					}
+				}
+
+				if _, err := store.GetByManagementID(ctx, other+"m"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("did not expect secrets of a bundle that failed to be created to exist, got %v", err)
+				}
+			})
+
+			t.Run("deletes all secrets of a bundle once", func(t *testing.T) {
+				b := newTestStoredBundle(t, store, "first", "second")
+				store.Delete(ctx, b.secrets[0].managementID)
+
+				if deleted, err := store.DeleteBundle(ctx, b.managementID); err != nil || len(deleted) != 1 || deleted[0] != b.secrets[1].accessID {
+					t.Errorf("wanted only the remaining secret to be deleted, got %v (%v)", deleted, err)
+				}
+				if deleted, err := store.DeleteBundle(ctx, b.managementID); err != nil || len(deleted) != 0 {
+					t.Errorf("wanted no secrets to be deleted again, got %v (%v)", deleted, err)
+				}
+				if _, err := store.DeleteBundle(ctx, "unknown"); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("wanted not found error, got %v", err)
+				}
+
+				if _, err := store.PurgeDeleted(ctx, time.Now()); err != nil {
+					t.Fatalf("purge: %v", err)
+				} else if _, err := store.GetBundleByManagementID(ctx, b.managementID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("wanted bundle to be purged along with its secrets, got %v", err)
+				}
+			})
+
+			t.Run("purges secrets deleted before the given time", func(t *testing.T) {
+				s := newTestStoredSecret(t, store, nil)
+				if err := store.CreateView(ctx, s.accessID, "key"); err != nil {
+					t.Fatalf("create view: %v", err)
+				} else if err := store.RecordEvent(ctx, secretEvent{accessID: s.accessID, eventType: eventCreated}); err != nil {
+					t.Fatalf("record event: %v", err)
+				} else if err := store.RecordAccess(ctx, s.accessID, secretAccess{accessedAt: 1}); err != nil {
+					t.Fatalf("record access: %v", err)
+				} else if _, err := store.Delete(ctx, s.managementID); err != nil {
+					t.Fatalf("delete: %v", err)
+				}
+				active := newTestStoredSecret(t, store, nil)
+
+				if _, err := store.PurgeDeleted(ctx, time.Now().Add(-time.Hour)); err != nil {
+					t.Fatalf("purge: %v", err)
+				} else if _, err := store.GetByManagementID(ctx, s.managementID); err != nil {
+					t.Fatalf("wanted secret deleted after the given time to be kept, got %v", err)
+				}
+
+				if purged, err := store.PurgeDeleted(ctx, time.Now()); err != nil || purged < 1 {
+					t.Fatalf("purge: wanted at least 1 secret purged, got %v (%v)", purged, err)
+				}
+				if _, err := store.GetByManagementID(ctx, s.managementID); !errors.Is(err, errSecretNotFound) {
+					t.Errorf("wanted purged secret to be not found, got %v", err)
+				}
+				if events, err := store.Events(ctx, s.managementID, 10); err != nil || len(events) != 0 {
+					t.Errorf("wanted no events for purged secret, got %+v (%v)", events, err)
+				}
+				if accesses, err := store.Accesses(ctx, s.managementID, 10); err != nil || len(accesses) != 0 {
+					t.Errorf("wanted no accesses for purged secret, got %+v (%v)", accesses, err)
+				}
+				if _, err := store.GetByViewingID(ctx, active.accessID); err != nil {
+					t.Errorf("wanted active secret to be kept, got %v", err)
+				}
+			})
+
+			t.Run("sweeps expired secrets", func(t *testing.T) {
+				expired := newTestStoredSecret(t, store, func(s *storedSecret) {
+					s.createdAt = time.Now().Add(-time.Hour).UnixMilli()
+				})
+				active := newTestStoredSecret(t, store, nil)
+
+				swept, err := store.SweepExpired(ctx, time.Now())
+				if err != nil {
+					t.Fatalf("sweep: %v", err)
+				}
+
+				// the background job may have already swept the expired secret from the shared database, so only its final
+				// state is verified
+				for _, s := range swept {
+					if s.accessID == active.accessID {
+						t.Errorf("active secret was swept")
+					}
+				}
+
+				s, err := store.GetByManagementID(ctx, expired.managementID)
+				if err != nil {
+					t.Fatalf("get by management id: %v", err)
+				}
+				if s.deletionReason != deletionReasonExpired {
+					t.Errorf("wanted deletion reason %q, got %q", deletionReasonExpired, s.deletionReason)
+				}
+			})
+		})
+	}
+}
+
+func TestMemorySecretStoreCountsActiveSecrets(t *testing.T) {
+	store := newMemorySecretStore()
+
+	newTestStoredSecret(t, store, nil)
+	newTestStoredSecret(t, store, func(s *storedSecret) { s.createdAt = time.Now().Add(-time.Hour).UnixMilli() })
+	deleted := newTestStoredSecret(t, store, nil)
+	if _, err := store.Delete(context.Background(), deleted.managementID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if active, err := store.CountActive(context.Background()); err != nil || active != 1 {
+		t.Errorf("wanted 1 active secret, got %v (%v)", active, err)
+	}
+}
+
+func TestSQLSecretStoreAbandonsOperations(t *testing.T) {
+	db := app.store.(*sqlSecretStore).db
+
+	t.Run("once their context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := newSQLSecretStore(db, time.Minute).GetByViewingID(ctx, "anything"); !errors.Is(err, context.Canceled) {
+			t.Errorf("wanted context cancelled error, got %v", err)
+		}
+	})
+
+	t.Run("that exceed the query timeout", func(t *testing.T) {
+		store := newSQLSecretStore(db, time.Nanosecond)
+
+		if _, err := store.Delete(context.Background(), "anything"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wanted deadline exceeded error, got %v", err)
+		}
+		if _, err := store.Revoke(context.Background(), "anything"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wanted deadline exceeded error, got %v", err)
+		}
+	})
+}
+
+func TestStoredSecretUnavailableReason(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		secret storedSecret
+		want   string
+	}{
+		{"available", storedSecret{createdAt: now.UnixMilli(), ttl: 30}, ""},
+		{"not yet viewable", storedSecret{createdAt: now.UnixMilli(), ttl: 30, notBefore: now.Add(time.Minute).UnixMilli()}, ""},
+		{"expired", storedSecret{createdAt: now.Add(-time.Hour).UnixMilli(), ttl: 30}, describeDeletionReason(deletionReasonExpired)},
+		{
+			"deleted",
+			storedSecret{createdAt: now.Add(-time.Hour).UnixMilli(), ttl: 30, deletedAt: now.UnixMilli(), deletionReason: deletionReasonViewed},
+			describeDeletionReason(deletionReasonViewed),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.secret.unavailableReason(); got != tt.want {
+				t.Errorf("wanted %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
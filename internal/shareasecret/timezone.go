@@ -0,0 +1,58 @@
+package shareasecret
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// timezoneCookieName is the name of the cookie global.mjs stores the IANA timezone of the visitor's browser in, so
+// that times can be rendered in it
+const timezoneCookieName = "tz"
+
+// timezoneFromRequest chooses the location times are rendered in for the visitor, preferring a tz query parameter
+// over the tz cookie. Missing or unknown timezones fall back to UTC.
+func timezoneFromRequest(r *http.Request) *time.Location {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		if c, err := r.Cookie(timezoneCookieName); err == nil {
+			name = c.Value
+		}
+	}
+
+	// an empty name and "Local" are both accepted by time.LoadLocation but would reveal the timezone of the server
+	if name == "" || name == "Local" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// timeAgo describes how long ago the given time was relative to now, in the largest whole unit that fits
+func timeAgo(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
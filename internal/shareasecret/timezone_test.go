@@ -0,0 +1,55 @@
+package shareasecret
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimezoneFromRequest(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		cookie string
+		want   string
+	}{
+		{name: "defaults to utc", want: "UTC"},
+		{name: "uses the cookie", cookie: "Europe/London", want: "Europe/London"},
+		{name: "prefers the query parameter", query: "America/New_York", cookie: "Europe/London", want: "America/New_York"},
+		{name: "ignores unknown timezones", cookie: "Nowhere/Special", want: "UTC"},
+		{name: "ignores the server timezone", query: "Local", want: "UTC"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?tz="+c.query, nil)
+			if c.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: timezoneCookieName, Value: c.cookie})
+			}
+
+			if got := timezoneFromRequest(r).String(); got != c.want {
+				t.Errorf("wanted %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[time.Duration]string{
+		10 * time.Second:            "just now",
+		time.Minute:                 "1 minute ago",
+		59 * time.Minute:            "59 minutes ago",
+		2*time.Hour + time.Minute:   "2 hours ago",
+		24 * time.Hour:              "1 day ago",
+		10*24*time.Hour + time.Hour: "10 days ago",
+	}
+
+	for ago, want := range cases {
+		if got := timeAgo(now.Add(-ago), now); got != want {
+			t.Errorf("timeAgo(%v): wanted %v, got %v", ago, want, got)
+		}
+	}
+}
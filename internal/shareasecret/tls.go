@@ -0,0 +1,90 @@
+package shareasecret
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// certificateReloader serves the certificate and key held in the given files over TLS, reloading them whenever either
+// file is modified so that renewed certificates are used without restarting the server
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// newCertificateReloader creates a [certificateReloader], loading the certificate and key immediately so that invalid
+// files are reported at startup rather than when the first client connects
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	c := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetCertificate returns the current certificate, reloading it first if either file has been modified since it was
+// loaded. The previous certificate continues to be served if the modified files cannot be loaded, as renewals often
+// replace the certificate and key one after the other.
+func (c *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	certModTime, keyModTime, err := c.modTimes()
+	if err != nil {
+		log.Warn().Err(err).Msg("checking tls certificate for changes")
+	} else if !certModTime.Equal(c.certModTime) || !keyModTime.Equal(c.keyModTime) {
+		if err := c.reload(); err != nil {
+			log.Warn().Err(err).Msg("reloading tls certificate")
+		} else {
+			log.Info().Str("cert_file", c.certFile).Msg("reloaded tls certificate")
+		}
+	}
+
+	return c.certificate, nil
+}
+
+// reload loads the certificate and key from their files along with their modification times. The lock must be held by
+// the caller unless the reloader is still being created.
+func (c *certificateReloader) reload() error {
+	certModTime, keyModTime, err := c.modTimes()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading tls certificate: %w", err)
+	}
+
+	c.certificate = &certificate
+	c.certModTime = certModTime
+	c.keyModTime = keyModTime
+
+	return nil
+}
+
+// modTimes retrieves the modification times of the certificate and key files
+func (c *certificateReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("stat tls certificate: %w", err)
+	}
+
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("stat tls key: %w", err)
+	}
+
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
@@ -0,0 +1,112 @@
+package shareasecret
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	// serialNumber identifies which of the generated certificates was served
+	serialNumber := func(c *certificateReloader) int64 {
+		certificate, err := c.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("getting certificate: %v", err)
+		}
+
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			t.Fatalf("parsing certificate: %v", err)
+		}
+
+		return leaf.SerialNumber.Int64()
+	}
+
+	writeTestCertificate(t, certFile, keyFile, 1, time.Now().Add(-time.Hour))
+
+	c, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("creating reloader: %v", err)
+	}
+
+	t.Run("serves the certificate it was created with", func(t *testing.T) {
+		if n := serialNumber(c); n != 1 {
+			t.Errorf("wanted certificate 1, got %v", n)
+		}
+	})
+
+	t.Run("reloads the certificate once the files change", func(t *testing.T) {
+		writeTestCertificate(t, certFile, keyFile, 2, time.Now())
+
+		if n := serialNumber(c); n != 2 {
+			t.Errorf("wanted certificate 2, got %v", n)
+		}
+	})
+
+	t.Run("keeps serving the previous certificate if the files cannot be loaded", func(t *testing.T) {
+		if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+			t.Fatalf("writing key: %v", err)
+		}
+		os.Chtimes(keyFile, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+
+		if n := serialNumber(c); n != 2 {
+			t.Errorf("wanted certificate 2, got %v", n)
+		}
+	})
+
+	t.Run("errors on creation if the files cannot be loaded", func(t *testing.T) {
+		if _, err := newCertificateReloader(certFile, filepath.Join(dir, "missing.pem")); err == nil {
+			t.Errorf("wanted error for missing key")
+		}
+	})
+}
+
+// writeTestCertificate writes a self-signed certificate with the given serial number and its key to the given files,
+// setting their modification times to the given time
+func writeTestCertificate(t *testing.T, certFile string, keyFile string, serialNumber int64, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("writing %v: %v", file, err)
+		} else if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("setting modification time of %v: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package shareasecret
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the instrumentation that spans are created by
+const tracerName = "github.com/lsymds/shareasecret"
+
+// newTracerProvider creates a provider that exports spans in batches to the configured OTLP (HTTP) endpoint, installing
+// it (and W3C trace context propagation) globally. Tracing remains a no-op, as the global provider is by default, when
+// no endpoint is configured, in which case a nil provider is returned.
+func newTracerProvider(config *Configuration) (*sdktrace.TracerProvider, error) {
+	if config.Tracing.OTLPEndpoint == "" {
+		return nil, nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(config.Tracing.OTLPEndpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating otlp exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(
+			resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("shareasecret"), semconv.ServiceVersion(Version)),
+		),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider, nil
+}
+
+// shutdownTracing exports any spans yet to be exported before stopping the tracer provider, if tracing is configured
+func (a *Application) shutdownTracing(ctx context.Context) error {
+	if a.tracerProvider == nil {
+		return nil
+	}
+
+	return a.tracerProvider.Shutdown(ctx)
+}
+
+// tracer returns the tracer spans are created with, which is a no-op unless tracing has been configured
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// tracing wraps the given handler, handling every request within a span continuing any trace propagated by the caller.
+// Spans are named after the route the request matches rather than its path, as paths contain the identifiers of
+// secrets. The trace and span IDs are added to the logger of the request so that its logs can be found from its trace.
+func (a *Application) tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := a.router.Handler(r)
+
+		// the method is already part of the patterns of most routes
+		name := route
+		if !strings.HasPrefix(route, r.Method+" ") {
+			name = strings.TrimSpace(r.Method + " " + route)
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(
+			ctx,
+			name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.HTTPRoute(route)),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			l := zerolog.Ctx(ctx).With().Str("trace_id", sc.TraceID().String()).Str("span_id", sc.SpanID().String()).Logger()
+			ctx = l.WithContext(ctx)
+		}
+
+		rw := &statusRecordingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rw.statusCode))
+		if rw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.statusCode))
+		}
+	})
+}
+
+// startQuerySpan starts a span around a call to the database of the given driver made with the given query, which only
+// ever contains placeholders rather than the values (including cipher texts) it is executed with
+func startQuerySpan(ctx context.Context, driver string, query string) (context.Context, trace.Span) {
+	system := semconv.DBSystemSqlite
+	if driver == driverPostgres {
+		system = semconv.DBSystemPostgreSQL
+	}
+
+	operation := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+
+	return tracer().Start(
+		ctx,
+		operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			system,
+			semconv.DBOperationNameKey.String(operation),
+			semconv.DBQueryTextKey.String(strings.Join(strings.Fields(query), " ")),
+		),
+	)
+}
+
+// endQuerySpan ends a span started by [startQuerySpan], marking it as failed if the call returned an error
+// other than there being no rows
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+	}
+
+	span.End()
+}
@@ -0,0 +1,134 @@
+package shareasecret
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	t.Run("traces requests and their database calls without recording secret identifiers", func(t *testing.T) {
+		accessID, _, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		// continue a trace started by the caller
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+		r := httptest.NewRequest("GET", "/secret/"+accessID, nil)
+		r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+		app.ServeHTTP(httptest.NewRecorder(), r)
+
+		var server sdktrace.ReadOnlySpan
+		var queries int
+		for _, s := range recorder.Ended() {
+			if s.SpanKind() == trace.SpanKindServer && s.SpanContext().TraceID().String() == traceID {
+				server = s
+			}
+		}
+		if server == nil {
+			t.Fatalf("wanted server span continuing the propagated trace")
+		} else if server.Name() != "GET /secret/{accessID}" {
+			t.Errorf("wanted span named after the route, got %v", server.Name())
+		}
+
+		for _, s := range recorder.Ended() {
+			if s.SpanKind() == trace.SpanKindClient && s.Parent().SpanID() == server.SpanContext().SpanID() {
+				queries++
+			}
+
+			for _, a := range s.Attributes() {
+				if strings.Contains(a.Value.Emit(), accessID) {
+					t.Errorf("did not expect access id in attribute %v of span %v", a.Key, s.Name())
+				}
+			}
+		}
+		if queries == 0 {
+			t.Errorf("wanted database calls to be traced within the request")
+		}
+	})
+
+	t.Run("adds the trace to the logger of the request", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		h := app.tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			zerolog.Ctx(r.Context()).Info().Msg("handling")
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		h.ServeHTTP(httptest.NewRecorder(), r.WithContext(zerolog.New(&logs).WithContext(r.Context())))
+
+		if !strings.Contains(logs.String(), `"trace_id"`) || !strings.Contains(logs.String(), `"span_id"`) {
+			t.Errorf("wanted trace and span ids in logs, got %v", logs.String())
+		}
+	})
+}
+
+func TestTracerProvider(t *testing.T) {
+	t.Run("is not created unless an endpoint is configured", func(t *testing.T) {
+		if p, err := newTracerProvider(&Configuration{}); p != nil || err != nil {
+			t.Errorf("wanted no tracer provider, got %v (%v)", p, err)
+		}
+	})
+
+	t.Run("exports to the configured endpoint", func(t *testing.T) {
+		defer func() {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+			otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+		}()
+
+		exported := make(chan string, 1)
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case exported <- r.URL.Path:
+			default:
+			}
+		}))
+		defer collector.Close()
+
+		config := &Configuration{}
+		config.Tracing.OTLPEndpoint = collector.URL + "/v1/traces"
+
+		p, err := newTracerProvider(config)
+		if err != nil {
+			t.Fatalf("new tracer provider: %v", err)
+		}
+
+		_, span := tracer().Start(context.Background(), "test")
+		span.End()
+
+		if err := p.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+
+		select {
+		case path := <-exported:
+			if path != "/v1/traces" {
+				t.Errorf("wanted spans exported to /v1/traces, got %v", path)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("wanted spans to be exported")
+		}
+	})
+}
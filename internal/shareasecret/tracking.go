@@ -0,0 +1,72 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maximumDisplayedAccesses is the most accesses listed in the access log on the management page of a secret
+const maximumDisplayedAccesses = 20
+
+// maximumUserAgentLength is the longest user agent (in bytes) recorded in an access log, longer ones are truncated
+const maximumUserAgentLength = 256
+
+// secretAccess is an access of a secret created with access tracking, as recorded in the access log shown to its
+// creator. As with audit events the IP address of the viewer is only ever stored hashed.
+type secretAccess struct {
+	ipHash     string
+	userAgent  string
+	accessedAt int64
+}
+
+// recordAccess records the access of a secret by the given request in its access log, if it was created with access
+// tracking. Failing to record an access is logged rather than failing the view that caused it.
+func (a *Application) recordAccess(r *http.Request, secret storedSecret) {
+	if !secret.trackAccess {
+		return
+	}
+
+	access := secretAccess{
+		ipHash:     a.ipHasher.hash(rateLimitKey(r)),
+		userAgent:  truncateUserAgent(r.UserAgent()),
+		accessedAt: time.Now().UnixMilli(),
+	}
+
+	// the secret has already been viewed, so the access is recorded even if the request is cancelled
+	if err := a.store.RecordAccess(context.WithoutCancel(r.Context()), secret.accessID, access); err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Msg("recording secret access")
+	}
+}
+
+// truncateUserAgent shortens user agents exceeding [maximumUserAgentLength], without splitting a multi-byte character
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) <= maximumUserAgentLength {
+		return userAgent
+	}
+
+	return strings.ToValidUTF8(userAgent[:maximumUserAgentLength], "")
+}
+
+// secretAccessDetailsFrom describes the accesses of a secret for its management page. Only the first few characters
+// of the hashed IP address are shown, which is enough to tell viewers apart.
+func secretAccessDetailsFrom(accesses []secretAccess, loc *time.Location) []secretAccessDetails {
+	details := make([]secretAccessDetails, 0, len(accesses))
+	for _, access := range accesses {
+		visitor := access.ipHash
+		if len(visitor) > 12 {
+			visitor = visitor[:12]
+		}
+
+		details = append(details, secretAccessDetails{
+			accessedAt: time.UnixMilli(access.accessedAt).In(loc),
+			visitor:    visitor,
+			userAgent:  access.userAgent,
+		})
+	}
+
+	return details
+}
@@ -0,0 +1,109 @@
+package shareasecret
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAccessTracking(t *testing.T) {
+	view := func(t *testing.T, accessID string, userAgent string) {
+		t.Helper()
+
+		r := post(t, app.handleCreateSecretView, "", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		key, ok := strings.CutPrefix(r.headers.Get("Location"), "/secret/"+accessID+"/")
+		if r.statusCode != http.StatusSeeOther || !ok {
+			t.Fatalf("wanted redirect to viewing page, got %v %v", r.statusCode, r.headers.Get("Location"))
+		}
+
+		r = get(t, app.handleAccessSecret, func(r *http.Request) {
+			r.SetPathValue("accessID", accessID)
+			r.SetPathValue("viewingKey", key)
+			r.Header.Set("User-Agent", userAgent)
+		})
+		if r.statusCode != http.StatusOK {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		}
+	}
+
+	t.Run("lists the accesses of tracked secrets on the management page", func(t *testing.T) {
+		accessID, managementID, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 2, trackAccess: true})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		manage := func(r *http.Request) { r.SetPathValue("managementID", managementID) }
+
+		if r := get(t, app.handleManageSecret, manage); !strings.Contains(r.body, "nobody has viewed this secret yet") {
+			t.Errorf("wanted empty access log in management page body")
+		}
+
+		r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if !strings.Contains(r.body, "logs each view of it") {
+			t.Errorf("wanted viewers to be told their access is logged")
+		}
+
+		view(t, accessID, "first-browser/1.0")
+		view(t, accessID, "second-browser/2.0")
+
+		accesses, err := app.store.Accesses(context.Background(), managementID, 10)
+		if err != nil || len(accesses) != 2 {
+			t.Fatalf("wanted 2 accesses, got %+v (%v)", accesses, err)
+		} else if accesses[0].userAgent != "second-browser/2.0" || accesses[0].ipHash == "" {
+			t.Errorf("wanted most recent access first with a hashed ip address, got %+v", accesses[0])
+		}
+
+		r = get(t, app.handleManageSecret, manage)
+		if !strings.Contains(r.body, "first-browser/1.0") || !strings.Contains(r.body, "second-browser/2.0") {
+			t.Errorf("wanted user agents of both accesses in management page body")
+		} else if strings.Contains(r.body, accesses[0].ipHash) || !strings.Contains(r.body, accesses[0].ipHash[:12]) {
+			t.Errorf("wanted only the start of the hashed ip address in management page body")
+		}
+	})
+
+	t.Run("does not track accesses unless asked to", func(t *testing.T) {
+		accessID, managementID, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 2})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if strings.Contains(r.body, "logs each view of it") {
+			t.Errorf("did not expect viewers to be told their access is logged")
+		}
+
+		view(t, accessID, "browser/1.0")
+
+		if accesses, err := app.store.Accesses(context.Background(), managementID, 10); err != nil || len(accesses) != 0 {
+			t.Errorf("wanted no accesses, got %+v (%v)", accesses, err)
+		}
+		if r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) }); strings.Contains(r.body, "recent views") {
+			t.Errorf("did not expect access log in management page body")
+		}
+	})
+
+	t.Run("creates tracked secrets via the form", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&trackAccess=true", emptyRequestConfigurer)
+		if r.statusCode != http.StatusSeeOther {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		managementID := strings.TrimPrefix(r.headers.Get("Location"), "/manage-secret/")
+		if secret, err := app.store.GetByManagementID(context.Background(), managementID); err != nil || !secret.trackAccess {
+			t.Errorf("wanted tracked secret, got %+v (%v)", secret, err)
+		}
+	})
+}
+
+func TestTruncateUserAgent(t *testing.T) {
+	if ua := truncateUserAgent("browser/1.0"); ua != "browser/1.0" {
+		t.Errorf("wanted short user agent to be kept, got %v", ua)
+	}
+
+	ua := truncateUserAgent(strings.Repeat("a", maximumUserAgentLength-1) + "é")
+	if len(ua) != maximumUserAgentLength-1 || !utf8.ValidString(ua) {
+		t.Errorf("wanted long user agent to be truncated without splitting a character, got %v bytes", len(ua))
+	}
+}
@@ -0,0 +1,66 @@
+package shareasecret
+
+import "fmt"
+
+// errorCodeHeader is the response header the code of a validation failure is returned in when the body is plain text,
+// as it is to the script that submits the create secret form
+const errorCodeHeader = "X-Shareasecret-Error-Code"
+
+// errorCode identifies, in a machine readable manner, why a secret could not be created. Codes are part of the API and
+// must not change once released.
+type errorCode string
+
+const (
+	errorCodeInvalidRequest             errorCode = "invalid_request"
+	errorCodeSecretTooLarge             errorCode = "secret_too_large"
+	errorCodeInvalidCipherFormat        errorCode = "invalid_cipher_format"
+	errorCodeInvalidTTL                 errorCode = "invalid_ttl"
+	errorCodeTTLTooLong                 errorCode = "ttl_too_long"
+	errorCodeTTLTooShort                errorCode = "ttl_too_short"
+	errorCodeNeverExpireNotPermitted    errorCode = "never_expire_not_permitted"
+	errorCodeInvalidExpiry              errorCode = "invalid_expiry"
+	errorCodeExpiryInPast               errorCode = "expiry_in_past"
+	errorCodeInvalidMaxViews            errorCode = "invalid_max_views"
+	errorCodeInvalidBurnAfterReading    errorCode = "invalid_burn_after_reading"
+	errorCodeInvalidRevealDelay         errorCode = "invalid_reveal_delay"
+	errorCodeInvalidNotBefore           errorCode = "invalid_not_before"
+	errorCodeNotBeforeAfterExpiry       errorCode = "not_before_after_expiry"
+	errorCodePassphraseTooLong          errorCode = "passphrase_too_long"
+	errorCodeInvalidWebhook             errorCode = "invalid_webhook"
+	errorCodeEmailNotificationsDisabled errorCode = "email_notifications_disabled"
+	errorCodeInvalidNotifyEmail         errorCode = "invalid_notify_email"
+	errorCodeLabelTooLong               errorCode = "label_too_long"
+	errorCodeRecoveryTokenTooShort      errorCode = "recovery_token_too_short"
+	errorCodeBundleTooLarge             errorCode = "bundle_too_large"
+	errorCodeTitleTooLong               errorCode = "title_too_long"
+)
+
+// validationError describes why a secret could not be created: a code for clients to handle specific failures with
+// and a message to display to people, in their language where they are using the web interface
+type validationError struct {
+	code errorCode
+	// key is the key of the message in [messages], formatted with args
+	key  string
+	args []any
+}
+
+// invalid creates a [validationError] with the given code and the message of the given key, formatted with the
+// arguments
+func invalid(code errorCode, key string, args ...any) *validationError {
+	return &validationError{code: code, key: key, args: args}
+}
+
+// message describes the validation failure in the given language. Arguments that are themselves validation errors
+// (those of the individual secrets of a bundle) are described in the same language.
+func (e *validationError) message(lang string) string {
+	args := make([]any, len(e.args))
+	for i, arg := range e.args {
+		if err, ok := arg.(*validationError); ok {
+			args[i] = err.message(lang)
+		} else {
+			args[i] = arg
+		}
+	}
+
+	return fmt.Sprintf(translate(e.key, lang), args...)
+}
@@ -0,0 +1,58 @@
+package shareasecret
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Version is the version of the running build, which is set by the main package from the version injected at build
+// time
+var Version = "dev"
+
+// versionResponse is the JSON body returned from the version endpoint
+type versionResponse struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	ServerTime int64  `json:"serverTime"`
+	// MinimumTTL is the minimum TTL of a secret in minutes, or zero if there is no minimum
+	MinimumTTL int `json:"minimumTTL"`
+	// MaximumTTL is the maximum TTL of a secret in minutes, or zero if secrets can live forever
+	MaximumTTL int `json:"maximumTTL"`
+}
+
+// handleVersion describes the running build, the time according to the server (in unix milliseconds) and the range of
+// TTLs secrets can be created with, so that operators can confirm which build is deployed and whether its clock is
+// aligned with theirs
+func (a *Application) handleVersion(w http.ResponseWriter, r *http.Request) {
+	noStore(w)
+
+	writeJSON(
+		versionResponse{
+			Version:    Version,
+			Commit:     buildCommit(),
+			ServerTime: time.Now().UnixMilli(),
+			MinimumTTL: int(a.config.Secrets.MinimumTTL / time.Minute),
+			MaximumTTL: int(a.config.Secrets.MaximumTTL / time.Minute),
+		},
+		http.StatusOK,
+		w,
+	)
+}
+
+// buildCommit retrieves the VCS revision the binary was built from, which the Go toolchain embeds when building from a
+// repository, returning an empty string if it is not known
+func buildCommit() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return ""
+}
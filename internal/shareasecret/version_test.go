@@ -0,0 +1,37 @@
+package shareasecret
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestVersion(t *testing.T) {
+	t.Run("describes the build, server time, and ttl range", func(t *testing.T) {
+		app.config.Secrets.MinimumTTL = 5 * time.Minute
+		app.config.Secrets.MaximumTTL = 2 * time.Hour
+		defer func() {
+			app.config.Secrets.MinimumTTL = 0
+			app.config.Secrets.MaximumTTL = 0
+		}()
+
+		before := time.Now().UnixMilli()
+		r := get(t, app.handleVersion, emptyRequestConfigurer)
+		if r.statusCode != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.statusCode)
+		}
+
+		var v versionResponse
+		if err := json.Unmarshal([]byte(r.body), &v); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+
+		if v.Version != Version {
+			t.Errorf("wanted version %v, got %v", Version, v.Version)
+		} else if v.ServerTime < before || v.ServerTime > time.Now().UnixMilli() {
+			t.Errorf("wanted current server time, got %v", v.ServerTime)
+		} else if v.MinimumTTL != 5 || v.MaximumTTL != 120 {
+			t.Errorf("wanted ttls between 5 and 120 minutes, got %v and %v", v.MinimumTTL, v.MaximumTTL)
+		}
+	})
+}
@@ -1,23 +1,29 @@
 package shareasecret
 
 import (
+	"context"
 	"crypto/rand"
-	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
-	"net"
+	"math"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
-	"github.com/a-h/templ"
 	"github.com/lsymds/go-utils/pkg/http/middleware"
 	"github.com/lsymds/staticmodtimefs"
 	"github.com/rs/zerolog"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // mapRoutes maps all HTTP routes for the application.
@@ -25,31 +31,71 @@ func (a *Application) mapRoutes() {
 	assetsFS := staticmodtimefs.NewStaticModTimeFS(a.webAssets, time.Now())
 	assetsFSHandler := http.FileServerFS(assetsFS)
 
-	a.router.Handle("GET /static/", http.StripPrefix("/static/", assetsFSHandler))
+	a.router.Handle("GET /static/", a.cacheAssets(http.StripPrefix("/static/", assetsFSHandler)))
 	a.router.Handle("GET /robots.txt", serveFile(assetsFS, "robots.txt"))
-
-	a.router.HandleFunc("GET /", a.handleGetIndex)
-
-	a.router.Handle("GET /nojs", templ.Handler(pageNoJavascript()))
-	a.router.Handle("GET /oops", templ.Handler(pageOops()))
-
-	a.router.HandleFunc("POST /secret", a.handleCreateSecret)
-	a.router.HandleFunc("GET /secret/{accessID}", a.handleAccessSecretInterstitial)
-	a.router.HandleFunc("POST /secret/{accessID}", a.handleCreateSecretView)
-	a.router.HandleFunc("GET /secret/{accessID}/{viewingKey}", a.handleAccessSecret)
-	a.router.HandleFunc("GET /manage-secret/{managementID}", a.handleManageSecret)
-	a.router.HandleFunc("POST /manage-secret/{managementID}/delete", a.handleDeleteSecret)
+	a.router.Handle("GET /favicon.ico", a.cacheAssets(serveFile(assetsFS, "favicon.ico")))
+
+	a.router.HandleFunc("GET /{$}", a.handleGetIndex)
+	a.router.HandleFunc("GET /metrics", a.handleMetrics)
+	a.router.HandleFunc("GET /healthz", a.handleHealthz)
+	a.router.HandleFunc("GET /readyz", a.handleReadyz)
+	a.router.HandleFunc("GET /version", a.handleVersion)
+
+	a.router.HandleFunc("GET /nojs", handleNoJavascript)
+	a.router.HandleFunc("GET /oops", a.handleOops)
+	a.router.HandleFunc("GET /", notFound)
+
+	a.router.HandleFunc("POST /secret", a.rateLimit(a.handleCreateSecret, tooManyRequests))
+	a.router.HandleFunc("GET /secret/{accessID}", a.wellFormedIDs(a.handleAccessSecretInterstitial, redirectSecretNotFound))
+	a.router.HandleFunc("POST /secret/{accessID}", a.rateLimit(a.wellFormedIDs(a.handleCreateSecretView, redirectSecretNotFound), tooManyRequests))
+	a.router.HandleFunc("GET /secret/{accessID}/{viewingKey}", a.wellFormedIDs(a.handleAccessSecret, viewNotFound))
+	a.router.HandleFunc("GET /secret/{accessID}/download", a.rateLimit(a.wellFormedIDs(a.handleDownloadSecret, plainNotFound), tooManyRequests))
+	a.router.HandleFunc("POST /secret/{accessID}/report-failure", a.rateLimit(a.wellFormedIDs(a.handleReportDecryptionFailure, http.NotFound), tooManyRequests))
+	a.router.HandleFunc("GET /manage-secret/{managementID}", a.wellFormedIDs(a.handleManageSecret, redirectManagedSecretNotFound))
+	a.router.HandleFunc("GET /my-secrets", a.handleMySecrets)
+	a.router.HandleFunc("GET /revoke", a.handleGetRevoke)
+	a.router.HandleFunc("POST /revoke", a.rateLimit(a.handleRevoke, tooManyRequests))
+	a.router.HandleFunc("GET /manage-secret/{managementID}/qr", a.wellFormedIDs(a.handleManageSecretQRCode, http.NotFound))
+	a.router.HandleFunc("POST /manage-secret/{managementID}/ack", a.rateLimit(a.wellFormedIDs(a.handleAcknowledgeSecret, redirectManagedSecretNotFound), tooManyRequests))
+	a.router.HandleFunc("POST /manage-secret/{managementID}/delete", a.rateLimit(a.wellFormedIDs(a.handleDeleteSecret, redirectManagedSecretNotFound), tooManyRequests))
+	a.router.HandleFunc("POST /manage-secret/{managementID}/rotate-view", a.rateLimit(a.wellFormedIDs(a.handleRotateViewingLink, redirectManagedSecretNotFound), tooManyRequests))
+	a.router.HandleFunc("POST /manage-secret/{managementID}/ttl", a.rateLimit(a.wellFormedIDs(a.handleUpdateTTL, redirectManagedSecretNotFound), tooManyRequests))
+	a.router.HandleFunc("POST /manage-secret/{managementID}/email", a.rateLimit(a.wellFormedIDs(a.handleEmailRecipient, redirectManagedSecretNotFound), tooManyRequests))
+	a.router.HandleFunc("GET /bundle/{accessID}", a.wellFormedIDs(a.handleViewBundle, redirectSecretNotFound))
+	a.router.HandleFunc("GET /manage-bundle/{managementID}", a.wellFormedIDs(a.handleManageBundle, redirectManagedBundleNotFound))
+	a.router.HandleFunc("POST /manage-bundle/{managementID}/delete", a.rateLimit(a.wellFormedIDs(a.handleDeleteBundle, redirectManagedBundleNotFound), tooManyRequests))
+
+	a.router.HandleFunc("POST /api/secrets", a.rateLimit(a.handleAPICreateSecret, apiTooManyRequests))
+	a.router.HandleFunc("POST /api/validate", a.rateLimit(a.handleAPIValidateSecret, apiTooManyRequests))
+	a.router.HandleFunc("GET /api/secrets/{accessID}", a.rateLimit(a.wellFormedIDs(a.handleAPIAccessSecret, apiNotFound), apiTooManyRequests))
+	a.router.HandleFunc("DELETE /api/secrets/{managementID}", a.rateLimit(a.wellFormedIDs(a.handleAPIDeleteSecret, apiNotFound), apiTooManyRequests))
+	a.router.HandleFunc("GET /api/manage/{managementID}", a.wellFormedIDs(a.handleAPISecretMetadata, apiNotFound))
+	a.router.HandleFunc("POST /api/manage/{managementID}/rotate-view", a.rateLimit(a.wellFormedIDs(a.handleAPIRotateViewingLink, apiNotFound), apiTooManyRequests))
+
+	a.router.HandleFunc("GET /admin", a.adminPageOnly(a.handleAdminDashboard))
+	a.router.HandleFunc("GET /api/admin/secrets/{managementID}/events", a.adminOnly(a.wellFormedIDs(a.handleAPIAdminSecretEvents, apiNotFound)))
 }
 
 // ServeHTTP is the root [http.Handler] method for the application. It serves all application routes, wrapping them with
 // any required middlewares
 func (a *Application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	middleware.Logging(
-		middleware.Recovery(
-			a.router,
-			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				http.Redirect(w, r, "/oops", http.StatusSeeOther)
-			}),
+		a.clientIPs(
+			a.stripBasePath(
+				a.requestIDs(
+					a.tracing(
+						a.accessLog(
+							a.compress(
+								a.renderers(
+									a.recoverPanics(
+										a.securityHeaders(a.languages(a.assetVersions(a.brand(a.csrfProtection(a.router))))),
+									),
+								),
+							),
+						),
+					),
+				),
+			),
 		),
 		nil,
 	).ServeHTTP(w, r)
@@ -65,10 +111,64 @@ func serveFile(fs fs.FS, fileName string) http.Handler {
 // handleGetIndex renders the root page for the application - this is where visitors are able to create secrets
 // (performed in the [handleCreateSecret] handler)
 func (a *Application) handleGetIndex(w http.ResponseWriter, r *http.Request) {
+	// secrets can only be encrypted with JavaScript, so explain as much instead of showing a form that cannot work
+	if javascriptUnavailable(r) {
+		pageIndexWithoutJavascript().Render(withJavascriptUnavailable(r.Context()), w)
+		return
+	}
+
 	ns := notificationsFromRequest(r, w)
 	ipRestricted := !requestingIPCanCreateSecret(a.config, r)
 
-	pageIndex(ns, ipRestricted).Render(r.Context(), w)
+	// pre-select the TTL (in minutes) of a previously created secret when creating another, ignoring any that don't
+	// match a preset
+	form := createSecretFormDetails{maxViews: "1", revealDelay: "0"}
+	if ttl, err := strconv.Atoi(r.URL.Query().Get("ttl")); err == nil {
+		form.ttlPreset = ttlPresetFor(ttl)
+	}
+
+	pageIndex(ns, ipRestricted, a.emails.enabled(), form).Render(r.Context(), w)
+}
+
+// createSecretFailed responds to a request to [handleCreateSecret] that could not be fulfilled. Browsers submitting
+// the form themselves are shown the home page again with the error and the options they had chosen, whereas the
+// script that usually submits the form receives the error as plain text to display alongside the form.
+func (a *Application) createSecretFailed(err *validationError, w http.ResponseWriter, r *http.Request) {
+	if !prefersHTML(r) {
+		w.Header().Set(errorCodeHeader, string(err.code))
+		badRequest(err.message(language(r.Context())), w)
+		return
+	}
+
+	form := createSecretFormDetails{
+		ttlPreset:        r.Form.Get("ttlPreset"),
+		maxViews:         r.Form.Get("maxViews"),
+		revealDelay:      r.Form.Get("revealDelay"),
+		notBefore:        r.Form.Get("notBefore"),
+		notifyWebhook:    r.Form.Get("notifyWebhook"),
+		notifyEmail:      r.Form.Get("notifyEmail"),
+		label:            r.Form.Get("label"),
+		burnAfterReading: r.Form.Get("burnAfterReading") == "true",
+		rememberSecret:   r.Form.Get("rememberSecret") == "true",
+		trackAccess:      r.Form.Get("trackAccess") == "true",
+	}
+	if ttl, err := strconv.Atoi(r.Form.Get("ttl")); err == nil && form.ttlPreset == "" {
+		form.ttlPreset = ttlPresetFor(ttl)
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	pageIndex(notifications{errorMsg: err.message(language(r.Context())), errorCode: string(err.code)}, false, a.emails.enabled(), form).Render(r.Context(), w)
+}
+
+// parseForm parses the url encoded form of a request, refusing to read more of the body than the largest form (that
+// of a bundle of secrets at the maximum size) could need, where [http.Request.PostFormValue] alone would read up to
+// 10MB (or, for multipart bodies, 32MB) of any body. Forms that have already been parsed are left as they are.
+func (a *Application) parseForm(w http.ResponseWriter, r *http.Request) error {
+	if r.PostForm == nil {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(a.config.Secrets.MaximumSize*maximumBundleSize+maximumRequestOverhead))
+	}
+
+	return r.ParseForm()
 }
 
 // handleCreateSecret validates and persists a secret (consisting of encrypted ciphertext)
@@ -77,76 +177,508 @@ func (a *Application) handleCreateSecret(w http.ResponseWriter, r *http.Request)
 
 	// redirect to the home page if requester is not permitted to create secrets
 	if !requestingIPCanCreateSecret(a.config, r) {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		safeRedirect(w, r, pathFor(r.Context(), "/"))
 		return
 	}
 
-	secret := ""
-	ttl := 0
-	maxViews := 0
+	var secret newSecret
 
-	// parse and validate the request
-	if err := r.ParseForm(); err != nil {
-		badRequest("Unable to parse request form. Please try again.", w)
+	// parse and validate the request, refusing to buffer bodies that couldn't possibly contain a valid secret (or bundle
+	// of secrets)
+	if err := a.parseForm(w, r); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			a.createSecretFailed(a.secretTooLarge(), w, r)
+		} else {
+			a.createSecretFailed(invalid(errorCodeInvalidRequest, "invalid.requestForm"), w, r)
+		}
 		return
 	} else {
-		// very little we can do here aside from validating the structure of the "encrypted" text string received matches
-		// how the front-end should have formatted it
-		secret = r.Form.Get("encryptedSecret")
-		if strings.Count(secret, ".") != 2 {
-			badRequest("Secret format is invalid. Please try again.", w)
-			return
+		secret.cipherText = r.Form.Get("encryptedSecret")
+		secret.passphrase = r.Form.Get("passphrase")
+		secret.notifyWebhook = r.Form.Get("notifyWebhook")
+		secret.notifyEmail = r.Form.Get("notifyEmail")
+		secret.label = r.Form.Get("label")
+		secret.recoveryToken = r.Form.Get("recoveryToken")
+		secret.trackAccess = r.Form.Get("trackAccess") == "true"
+
+		// prefer an absolute expiry time, then the human friendly TTL preset, falling back to the raw TTL (in minutes)
+		// for older clients
+		if e := r.Form.Get("expiresAt"); e != "" {
+			secret.ttl, err = ttlUntil(e, time.Now())
+			if errors.Is(err, errExpiryInPast) {
+				a.createSecretFailed(invalid(errorCodeExpiryInPast, "invalid.expiryInPast"), w, r)
+				return
+			} else if err != nil {
+				a.createSecretFailed(invalid(errorCodeInvalidExpiry, "invalid.expiry"), w, r)
+				return
+			}
+		} else if p := r.Form.Get("ttlPreset"); p != "" {
+			secret.ttl, err = parseTTLPreset(p)
+			if err != nil {
+				a.createSecretFailed(invalid(errorCodeInvalidTTL, "invalid.ttlPreset"), w, r)
+				return
+			}
+		} else if !r.Form.Has("ttl") {
+			// minimal clients may not send a TTL at all, in which case the configured default applies. a TTL that is
+			// present but can't be parsed is still rejected below.
+			secret.ttl = int(a.config.Secrets.DefaultTTL / time.Minute)
+			secret.defaultTTL = true
+			l.Info().Int("ttl", secret.ttl).Msg("no ttl provided, applying the default")
+		} else {
+			secret.ttl, err = strconv.Atoi(r.Form.Get("ttl"))
+			if err != nil {
+				a.createSecretFailed(invalid(errorCodeInvalidTTL, "invalid.ttl"), w, r)
+				return
+			}
 		}
 
-		ttl, err = strconv.Atoi(r.Form.Get("ttl"))
+		secret.maxViews, err = strconv.Atoi(r.Form.Get("maxViews"))
 		if err != nil {
-			badRequest("Unable to parse the TTL (time to live) for the secret.", w)
+			a.createSecretFailed(invalid(errorCodeInvalidMaxViews, "invalid.maxViews"), w, r)
 			return
 		}
 
-		maxViews, err = strconv.Atoi(r.Form.Get("maxViews"))
-		if err != nil || maxViews < 0 {
-			badRequest("Unable to parse the maximum views permitted for the secret.", w)
+		if b := r.Form.Get("burnAfterReading"); b != "" {
+			secret.burnAfterReading, err = strconv.ParseBool(b)
+			if err != nil {
+				a.createSecretFailed(invalid(errorCodeInvalidBurnAfterReading, "invalid.burnAfterReading"), w, r)
+				return
+			}
+		}
+
+		if d := r.Form.Get("revealDelay"); d != "" {
+			secret.revealDelay, err = strconv.Atoi(d)
+			if err != nil {
+				a.createSecretFailed(invalid(errorCodeInvalidRevealDelay, "invalid.revealDelay"), w, r)
+				return
+			}
+		}
+
+		// browsers submitting the form themselves send the value of the datetime-local input as is, which is in the
+		// timezone of the visitor
+		if n := r.Form.Get("notBefore"); n != "" {
+			secret.notBefore, err = parseTimestamp(n)
+			if err != nil {
+				secret.notBefore, err = time.ParseInLocation(datetimeLocalLayout, n, timezoneFromRequest(r))
+			}
+			if err != nil {
+				a.createSecretFailed(invalid(errorCodeInvalidNotBefore, "invalid.notBefore"), w, r)
+				return
+			}
+		}
+
+		if err := a.validateSecret(&secret); err != nil {
+			a.createSecretFailed(err, w, r)
 			return
 		}
 	}
 
-	// create the secret, and generate two cryptographically random, 192 bit identifiers to use for viewing and
-	// management of the secret respectively
-	accessID, err := secureID(24)
-	if err != nil {
-		l.Err(err).Msg("generating access id")
-		internalServerError(w)
+	// secrets are listed on the /my-secrets page of the browser they were created in if the creator asks for them to be
+	if r.Form.Get("rememberSecret") == "true" {
+		var err error
+		if secret.creatorSession, err = startCreatorSession(w, r); err != nil {
+			l.Err(err).Msg("starting creator session")
+			internalServerError(w, r)
+			return
+		}
+	}
+
+	// several secrets submitted together are shared as a bundle
+	if len(r.Form["encryptedSecret"]) > 1 {
+		a.createBundleFromForm(w, r, secret)
 		return
 	}
 
-	managementID, err := secureID(24)
-	if err != nil {
-		l.Err(err).Msg("generating management id")
-		internalServerError(w)
-		return
-	}
-
-	if _, err := a.db.db.Exec(
-		`
-			INSERT INTO
-				secrets (access_id, management_id, cipher_text, ttl, maximum_views, created_at)
-			VALUES
-				(?, ?, ?, ?, ?, ?)
-		`,
-		accessID,
-		managementID,
-		secret,
-		ttl,
-		maxViews,
-		time.Now().UnixMilli(),
-	); err != nil {
+	accessID, managementID, err := a.createSecret(r.Context(), secret)
+	if errors.Is(err, errTooManyCreations) {
+		serviceUnavailable(w)
+		return
+	} else if err != nil {
 		l.Err(err).Msg("creating secret")
-		internalServerError(w)
+		internalServerError(w, r)
+		return
+	}
+
+	a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventCreated})
+	noteDefaultTTL(secret, w, r)
+
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/manage-secret/%s", managementID)))
+}
+
+// noteDefaultTTL tells the creator of a secret (or bundle) that was created without a TTL how long it will live for
+func noteDefaultTTL(s newSecret, w http.ResponseWriter, r *http.Request) {
+	if s.defaultTTL {
+		setFlashWarn(fmt.Sprintf(translate("flash.defaultTTLApplied", language(r.Context())), s.ttl), w, r)
+	}
+}
+
+// parseTTLPreset parses a human friendly TTL (time to live) preset such as 30m, 1h or 7d into the number of minutes
+// the secret should live for. Presets are Go durations with additional support for a number of days, and must be a
+// positive, whole number of minutes.
+func parseTTLPreset(preset string) (int, error) {
+	var d time.Duration
+
+	if days, ok := strings.CutSuffix(preset, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 || time.Duration(n) > math.MaxInt64/(24*time.Hour) {
+			return 0, fmt.Errorf("invalid number of days (%v)", days)
+		}
+
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(preset); err != nil {
+			return 0, err
+		}
+	}
+
+	if d < time.Minute || d%time.Minute != 0 {
+		return 0, fmt.Errorf("preset (%v) is not a positive, whole number of minutes", preset)
+	}
+
+	return int(d / time.Minute), nil
+}
+
+// secretTooLarge describes the maximum size of a secret, for when a secret (or the request containing it) is too large
+func (a *Application) secretTooLarge() *validationError {
+	return invalid(errorCodeSecretTooLarge, "invalid.secretTooLarge", a.config.Secrets.MaximumSize)
+}
+
+// formTooLarge responds to a form larger than [Application.parseForm] reads. Only the create secret form can
+// legitimately approach that size, so its submitters are told that the secret is too large, as they would be if the
+// cipher text alone exceeded the maximum size.
+func (a *Application) formTooLarge(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/secret" {
+		plainError("Request is too large.", http.StatusRequestEntityTooLarge, w)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/manage-secret/%s", managementID), http.StatusCreated)
+	a.createSecretFailed(a.secretTooLarge(), w, r)
+}
+
+// formatSize describes a number of bytes in the largest unit (up to megabytes) it is at least one of
+func formatSize(bytes int) string {
+	switch {
+	case bytes >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1024*1024))
+	case bytes >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/1024)
+	default:
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+}
+
+// errExpiryInPast is returned by [ttlUntil] when the expiry time has already passed
+var errExpiryInPast = errors.New("expiry time is in the past")
+
+// ttlUntil converts an absolute expiry time, either in RFC3339 format or as unix milliseconds, into the TTL (time to
+// live) in minutes of a secret created at the given time. TTLs are rounded up to the next whole minute, so a secret
+// expires within a minute after the time given.
+func ttlUntil(expiresAt string, now time.Time) (int, error) {
+	t, err := parseTimestamp(expiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expiry time (%v)", expiresAt)
+	}
+
+	if !t.After(now) {
+		return 0, errExpiryInPast
+	}
+
+	// durations saturate rather than overflow, so expiry times centuries away are capped rather than wrapping around
+	d := t.Sub(now)
+
+	minutes := int(d / time.Minute)
+	if d%time.Minute != 0 {
+		minutes++
+	}
+
+	return minutes, nil
+}
+
+// datetimeLocalLayout is the layout of the values of datetime-local inputs, which have no timezone
+const datetimeLocalLayout = "2006-01-02T15:04"
+
+// parseTimestamp parses a timestamp supplied either in RFC3339 format or as unix milliseconds
+func parseTimestamp(timestamp string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return t, nil
+	}
+
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp (%v)", timestamp)
+	}
+
+	return time.UnixMilli(ms), nil
+}
+
+// newSecret contains the values submitted by a visitor (or API client) creating a secret
+type newSecret struct {
+	cipherText       string
+	ttl              int
+	maxViews         int
+	burnAfterReading bool
+	revealDelay      int
+	// notBefore is when the secret becomes viewable, or the zero time if it can be viewed as soon as it is created
+	notBefore     time.Time
+	passphrase    string
+	notifyWebhook string
+	notifyEmail   string
+	label         string
+	recoveryToken string
+	// trackAccess identifies whether each access of the secret is recorded in an access log shown to its creator
+	trackAccess bool
+	// defaultTTL identifies whether the TTL is the configured default, as the creator didn't provide one
+	defaultTTL bool
+	// creatorSession is the anonymous session of the browser the secret was created in, if it is to be listed on the
+	// /my-secrets page of that browser
+	creatorSession string
+}
+
+// validateSecret validates the values a secret is being created with, returning an error describing the first invalid
+// value found or nil if the secret is valid. TTLs outside of the configured minimum and maximum are clamped
+// to them if configured to do so.
+func (a *Application) validateSecret(s *newSecret) *validationError {
+	if len(s.cipherText) > a.config.Secrets.MaximumSize {
+		return a.secretTooLarge()
+	}
+
+	// very little we can do here aside from validating the structure of the "encrypted" text string received matches
+	// how the front-end should have formatted it
+	if _, ok := cipherTextFormat(s.cipherText); !ok {
+		return invalid(errorCodeInvalidCipherFormat, "invalid.cipherFormat")
+	}
+
+	if s.maxViews < 0 {
+		return invalid(errorCodeInvalidMaxViews, "invalid.maxViews")
+	}
+
+	if s.revealDelay < 0 || s.revealDelay > maximumRevealDelay {
+		return invalid(errorCodeInvalidRevealDelay, "invalid.revealDelayRange", maximumRevealDelay)
+	}
+
+	if s.ttl < 0 {
+		return invalid(errorCodeInvalidTTL, "invalid.ttlNegative")
+	}
+
+	// secrets that never expire (a TTL of 0) exceed any maximum
+	if maximum := int(a.config.Secrets.MaximumTTL / time.Minute); maximum > 0 && (s.ttl == 0 || s.ttl > maximum) {
+		if !a.config.Secrets.ClampTTL {
+			return invalid(errorCodeTTLTooLong, "invalid.ttlTooLong", maximum)
+		}
+
+		s.ttl = maximum
+	}
+
+	// likewise secrets that never expire are never too short
+	if minimum := int(a.config.Secrets.MinimumTTL / time.Minute); minimum > 0 && s.ttl > 0 && s.ttl < minimum {
+		if !a.config.Secrets.ClampTTL {
+			return invalid(errorCodeTTLTooShort, "invalid.ttlTooShort", minimum)
+		}
+
+		s.ttl = minimum
+	}
+
+	// any secret that would still never expire (i.e. one that wasn't clamped to the maximum) must be permitted to
+	if s.ttl == 0 && !a.config.Secrets.AllowNeverExpire {
+		return invalid(errorCodeNeverExpireNotPermitted, "invalid.neverExpire")
+	}
+
+	// a secret that only became viewable once it had expired could never be viewed at all. the TTL is counted from now,
+	// which is at most a moment before the secret is created.
+	if s.ttl > 0 && !s.notBefore.IsZero() && !s.notBefore.Before(time.Now().Add(time.Duration(s.ttl)*time.Minute)) {
+		return invalid(errorCodeNotBeforeAfterExpiry, "invalid.viewableAfterExpiry")
+	}
+
+	// bcrypt is unable to hash anything longer than 72 bytes
+	if len(s.passphrase) > 72 {
+		return invalid(errorCodePassphraseTooLong, "invalid.passphraseTooLong")
+	}
+
+	if s.notifyWebhook != "" && !validWebhookURL(s.notifyWebhook) {
+		return invalid(errorCodeInvalidWebhook, "invalid.webhook")
+	}
+
+	if s.notifyEmail != "" && !a.emails.enabled() {
+		return invalid(errorCodeEmailNotificationsDisabled, "invalid.emailsDisabled")
+	} else if s.notifyEmail != "" && !validNotifyEmail(s.notifyEmail) {
+		return invalid(errorCodeInvalidNotifyEmail, "invalid.notifyEmail")
+	}
+
+	if utf8.RuneCountInString(s.label) > maximumLabelLength {
+		return invalid(errorCodeLabelTooLong, "invalid.labelTooLong", maximumLabelLength)
+	}
+
+	if s.recoveryToken != "" && utf8.RuneCountInString(s.recoveryToken) < minimumRecoveryTokenLength {
+		return invalid(errorCodeRecoveryTokenTooShort, "invalid.recoveryTokenShort", minimumRecoveryTokenLength)
+	}
+
+	return nil
+}
+
+const (
+	// cipherFormatV1 is made up of the base64 encoded encrypted content, salt and initialization vector. Cipher texts
+	// formatted before versions were introduced omit the leading version segment.
+	cipherFormatV1 = "v1"
+	// cipherFormatV2 is [cipherFormatV1] preceded by an identifier of the algorithm the content was encrypted with
+	cipherFormatV2 = "v2"
+)
+
+// cipherFormats describe the segments following the leading version segment of each format of cipher text, where
+// true denotes a base64 encoded segment and false an identifier
+var cipherFormats = map[string][]bool{
+	cipherFormatV1: {true, true, true},
+	cipherFormatV2: {false, true, true, true},
+}
+
+// cipherAlgorithmIdentifier matches the algorithm identifiers of [cipherFormatV2] cipher texts (i.e. AES-GCM)
+var cipherAlgorithmIdentifier = regexp.MustCompile(`^[A-Za-z0-9-]{1,32}$`)
+
+// cipherTextFormat returns the version of the format the given cipher text was formatted in by the front-end,
+// identifying whether it is made up of exactly the non-empty segments that version requires. Cipher texts without a
+// version segment are treated as [cipherFormatV1].
+func cipherTextFormat(cipherText string) (string, bool) {
+	segments := strings.Split(cipherText, ".")
+
+	version := cipherFormatV1
+	if _, ok := cipherFormats[segments[0]]; ok {
+		version, segments = segments[0], segments[1:]
+	}
+
+	format := cipherFormats[version]
+	if len(segments) != len(format) {
+		return "", false
+	}
+
+	for i, encoded := range format {
+		if encoded {
+			if _, err := base64.StdEncoding.DecodeString(segments[i]); segments[i] == "" || err != nil {
+				return "", false
+			}
+		} else if !cipherAlgorithmIdentifier.MatchString(segments[i]) {
+			return "", false
+		}
+	}
+
+	return version, true
+}
+
+// maximumSecretIDAttempts is the number of times the identifiers of a new secret are generated before giving up, should
+// they keep colliding with those of existing secrets
+const maximumSecretIDAttempts = 3
+
+// createSecret persists an already validated secret, returning the access and management identifiers generated for it
+func (a *Application) createSecret(ctx context.Context, s newSecret) (string, string, error) {
+	stored, err := a.storedSecretFrom(s)
+	if err != nil {
+		return "", "", err
+	}
+
+	release, err := a.acquireCreation(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer release()
+
+	// generate two cryptographically random identifiers to use for viewing and management of the secret respectively.
+	// should either collide with those of an existing secret (which is astronomically unlikely) both are regenerated.
+	for attempt := 1; ; attempt++ {
+		stored.accessID, stored.managementID, err = a.secretIDs()
+		if err != nil {
+			return "", "", err
+		}
+
+		err = a.store.Create(ctx, stored)
+		if errors.Is(err, errSecretIDCollision) && attempt < maximumSecretIDAttempts {
+			continue
+		} else if err != nil {
+			return "", "", fmt.Errorf("inserting secret: %w", err)
+		}
+
+		break
+	}
+
+	a.metrics.secretCreated()
+
+	return stored.accessID, stored.managementID, nil
+}
+
+// storedSecretFrom converts an already validated secret into the form it is persisted in, without its identifiers
+func (a *Application) storedSecretFrom(s newSecret) (storedSecret, error) {
+	// the passphrase gates access to the secret on the server, so only a hash of it is ever stored
+	var passphraseHash string
+	if s.passphrase != "" {
+		h, err := bcrypt.GenerateFromPassword([]byte(s.passphrase), bcrypt.DefaultCost)
+		if err != nil {
+			return storedSecret{}, fmt.Errorf("hashing passphrase: %w", err)
+		}
+
+		passphraseHash = string(h)
+	}
+
+	// the email address has to be recoverable in order to notify it, so it is encrypted rather than hashed
+	var notifyEmail string
+	if s.notifyEmail != "" {
+		var err error
+		if notifyEmail, err = encryptNotifyEmail(a.emails.key, s.notifyEmail); err != nil {
+			return storedSecret{}, fmt.Errorf("encrypting notification email: %w", err)
+		}
+	}
+
+	// the recovery token is only ever used to find the secrets associated with it, so it is hashed
+	var recoveryTokenHash string
+	if s.recoveryToken != "" {
+		recoveryTokenHash = hashRecoveryToken(s.recoveryToken)
+	}
+
+	// likewise the session is only ever used to find the secrets created in it
+	var creatorSessionHash string
+	if s.creatorSession != "" {
+		creatorSessionHash = hashCreatorSession(s.creatorSession)
+	}
+
+	// the cipher text has already been validated, so its format is known
+	cipherFormat, _ := cipherTextFormat(s.cipherText)
+
+	var notBefore int64
+	if !s.notBefore.IsZero() {
+		notBefore = s.notBefore.UnixMilli()
+	}
+
+	return storedSecret{
+		cipherText:         s.cipherText,
+		cipherFormat:       cipherFormat,
+		ttl:                int64(s.ttl),
+		maximumViews:       s.maxViews,
+		burnAfterReading:   s.burnAfterReading,
+		revealDelaySeconds: s.revealDelay,
+		passphraseHash:     passphraseHash,
+		notifyWebhook:      s.notifyWebhook,
+		notifyEmail:        notifyEmail,
+		label:              s.label,
+		recoveryTokenHash:  recoveryTokenHash,
+		creatorSessionHash: creatorSessionHash,
+		trackAccess:        s.trackAccess,
+		notBefore:          notBefore,
+		createdAt:          time.Now().UnixMilli(),
+	}, nil
+}
+
+// secretIDs generates the cryptographically random access and management identifiers of a secret (or bundle). The
+// access ID is shared so its size and encoding are configurable, but the management ID is always 192 bits.
+func (a *Application) secretIDs() (string, string, error) {
+	accessID, err := a.viewingID()
+	if err != nil {
+		return "", "", fmt.Errorf("generating access id: %w", err)
+	}
+
+	managementID, err := secureID(managementIDSize)
+	if err != nil {
+		return "", "", fmt.Errorf("generating management id: %w", err)
+	}
+
+	return accessID, managementID, nil
 }
 
 // handleAccessSecretInterstitial presents a disclaimer to the visitor informing them that proceeding will use
@@ -156,29 +688,17 @@ func (a *Application) handleCreateSecret(w http.ResponseWriter, r *http.Request)
 func (a *Application) handleAccessSecretInterstitial(w http.ResponseWriter, r *http.Request) {
 	accessID := r.PathValue("accessID")
 
+	noIndex(w)
+
 	l := zerolog.Ctx(r.Context()).
 		With().
 		Str("access_id", accessID).
 		Logger()
 
-	// retrieve the row identifier of the secret if it exists and has not been deleted
-	var secretID int
-	err := a.db.db.QueryRow(
-		`
-			SELECT
-				id
-			FROM
-				secrets
-			WHERE
-				access_id = ? AND
-				deleted_at IS NULL
-		`,
-		accessID,
-	).Scan(&secretID)
-
-	if errors.Is(sql.ErrNoRows, err) {
-		setFlashErr("Secret does not exist or has been deleted.", w)
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	// retrieve the secret if it exists and can still be viewed
+	secret, err := a.store.GetByViewingID(r.Context(), accessID)
+	if errors.Is(err, errSecretNotFound) {
+		a.secretUnavailable(w, r, accessID)
 		return
 	} else if err != nil {
 		l.Err(err).Msg("retrieving secret")
@@ -186,7 +706,11 @@ func (a *Application) handleAccessSecretInterstitial(w http.ResponseWriter, r *h
 		return
 	}
 
-	pageViewSecretInterstitial().Render(r.Context(), w)
+	if a.captcha.enabled() {
+		w.Header().Set("Content-Security-Policy", a.captcha.contentSecurityPolicy(a.config.Server.ContentSecurityPolicy))
+	}
+
+	pageViewSecretInterstitial(secret.passphraseHash != "", secret.trackAccess, a.captcha.widget(), notificationsFromRequest(r, w)).Render(r.Context(), w)
 }
 
 // handleCreateSecretView creates a 'view' of a secret and is the POST accompaniment to the
@@ -199,6 +723,45 @@ func (a *Application) handleCreateSecretView(w http.ResponseWriter, r *http.Requ
 		Str("access_id", accessID).
 		Logger()
 
+	// require the viewer to have solved a challenge (if configured to) before anything else, slowing down automated
+	// viewing of leaked links
+	if !a.captchaSolved(r, r.PostFormValue(a.captcha.provider.responseField)) {
+		setFlashErr(translate("flash.captchaFailed", language(r.Context())), w, r)
+		safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/secret/%s", accessID)))
+		return
+	}
+
+	// verify the passphrase if the secret is protected by one, limiting how often attempts can be made against the
+	// secret so that the passphrase cannot be brute forced. a passphrase is compared even when the secret doesn't exist
+	// or isn't protected so that the time taken doesn't reveal either.
+	secret, err := a.store.GetByViewingID(r.Context(), accessID)
+	if errors.Is(err, errSecretNotFound) {
+		a.passphraseMatches("", r.PostFormValue("passphrase"))
+		redirectSecretNotFound(w, r)
+		return
+	} else if err != nil {
+		l.Err(err).Msg("retrieving secret")
+		redirectToOopsPage(w, r)
+		return
+	}
+
+	if secret.passphraseHash == "" {
+		a.passphraseMatches("", r.PostFormValue("passphrase"))
+	} else {
+		if !a.passphraseRateLimiter.allow(r.Context(), accessID) {
+			l.Warn().Msg("passphrase attempts rate limited")
+			setFlashErr(translate("flash.passphraseRateLimited", language(r.Context())), w, r)
+			safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/secret/%s", accessID)))
+			return
+		}
+
+		if !a.passphraseMatches(secret.passphraseHash, r.PostFormValue("passphrase")) {
+			setFlashErr(translate("flash.passphraseIncorrect", language(r.Context())), w, r)
+			safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/secret/%s", accessID)))
+			return
+		}
+	}
+
 	// create a 64 bit viewing key for the secret view record
 	key, err := secureID(8)
 	if err != nil {
@@ -209,48 +772,194 @@ func (a *Application) handleCreateSecretView(w http.ResponseWriter, r *http.Requ
 
 	// create the secret view without a viewing date, as this will be set when the viewing page route is actually
 	// called
-	rs, err := a.db.db.Exec(
-		`
-			INSERT INTO secret_views (secret_id, viewing_key, created_at)
-			SELECT
-				id,
-				?,
-				?
-			FROM
-				secrets
-			WHERE
-				access_id = ? AND
-				deleted_at IS NULL
-		`,
-		key,
-		time.Now().UnixMilli(),
-		accessID,
-	)
-
-	if errors.Is(sql.ErrNoRows, err) {
-		setFlashErr("Secret does not exist or has been deleted.", w)
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	err = a.store.CreateView(r.Context(), accessID, key)
+	if errors.Is(err, errSecretNotFound) {
+		redirectSecretNotFound(w, r)
 		return
 	} else if err != nil {
 		l.Err(err).Msg("creating secret view")
 		redirectToOopsPage(w, r)
 		return
-	} else if rc, err := rs.RowsAffected(); err != nil || rc == 0 {
-		l.Error().Err(err).Msg("creating secret view")
-		redirectToOopsPage(w, r)
-		return
 	}
 
 	// redirect them to the actual viewing page of the secret (which will then mark the secret view as viewed)
-	http.Redirect(w, r, fmt.Sprintf("/secret/%s/%s", accessID, key), http.StatusSeeOther)
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/secret/%s/%s", accessID, key)))
+}
+
+// viewLockTTL is the longest a view of a secret can hold the lock of the secret before it is released regardless, should
+// the instance consuming the view stop part way through
+const viewLockTTL = 10 * time.Second
+
+// viewLockAttempts and viewLockRetryDelay are how many times and how often acquiring the lock of a secret being viewed
+// by someone else is attempted before giving up
+const (
+	viewLockAttempts   = 20
+	viewLockRetryDelay = 50 * time.Millisecond
+)
+
+// errSecretLocked is returned when the lock of a secret cannot be acquired in order to view it
+var errSecretLocked = errors.New("secret locked")
+
+// consumeView uses the view of a secret whilst holding its lock in the shared state, so that concurrent views on
+// different instances can never both be served a secret that can only be viewed once
+func (a *Application) consumeView(ctx context.Context, accessID string, viewingKey string) (storedSecret, string, error) {
+	key := "view:" + accessID
+
+	var token string
+	for attempt := 0; token == ""; attempt++ {
+		if attempt == viewLockAttempts {
+			return storedSecret{}, "", errSecretLocked
+		} else if attempt > 0 {
+			time.Sleep(viewLockRetryDelay)
+		}
+
+		var err error
+		if token, err = a.state.Lock(ctx, key, viewLockTTL); err != nil {
+			return storedSecret{}, "", fmt.Errorf("locking secret: %w", err)
+		}
+	}
+
+	defer func() {
+		if err := a.state.Unlock(context.WithoutCancel(ctx), key, token); err != nil {
+			zerolog.Ctx(ctx).Err(err).Msg("unlocking secret")
+		}
+	}()
+
+	return a.store.ConsumeView(ctx, accessID, viewingKey)
+}
+
+// viewSecretDirectly creates and immediately uses a view of the secret with the access ID in the request path, for
+// clients without an interstitial step. The passphrase of the secret (if it is protected by one) and the response to
+// a CAPTCHA challenge (if one is required) are supplied in headers so they don't end up in access logs. Failures are
+// written via the given function, in which case false is returned.
+//
+// A secret that never existed is indistinguishable from one that has been deleted or has expired. Unlike browsers,
+// which are shown the same message as for any other secret they can't view, clients are told when a secret that
+// cannot be viewed yet becomes viewable.
+func (a *Application) viewSecretDirectly(
+	w http.ResponseWriter,
+	r *http.Request,
+	fail func(msg string, statusCode int, w http.ResponseWriter),
+) (storedSecret, bool) {
+	accessID := r.PathValue("accessID")
+
+	l := zerolog.Ctx(r.Context()).
+		With().
+		Str("access_id", accessID).
+		Logger()
+
+	if !a.captchaSolved(r, r.Header.Get(captchaHeader)) {
+		fail("CAPTCHA verification failed.", http.StatusForbidden, w)
+		return storedSecret{}, false
+	}
+
+	// a passphrase is compared even when the secret doesn't exist or isn't protected so that the time taken doesn't
+	// reveal either
+	secret, err := a.store.GetByViewingID(r.Context(), accessID)
+	if errors.Is(err, errSecretNotYetAvailable) {
+		a.passphraseMatches("", r.Header.Get(passphraseHeader))
+		fail(notYetAvailableMessage(secret), http.StatusForbidden, w)
+		return storedSecret{}, false
+	} else if errors.Is(err, errSecretNotFound) {
+		a.passphraseMatches("", r.Header.Get(passphraseHeader))
+		fail("not found", http.StatusNotFound, w)
+		return storedSecret{}, false
+	} else if err != nil {
+		l.Err(err).Msg("retrieving secret")
+		fail("Unable to retrieve secret.", http.StatusInternalServerError, w)
+		return storedSecret{}, false
+	}
+
+	if secret.passphraseHash == "" {
+		a.passphraseMatches("", r.Header.Get(passphraseHeader))
+	} else {
+		if !a.passphraseRateLimiter.allow(r.Context(), accessID) {
+			l.Warn().Msg("passphrase attempts rate limited")
+			fail("Too many requests. Please wait a moment and try again.", http.StatusTooManyRequests, w)
+			return storedSecret{}, false
+		}
+
+		if !a.passphraseMatches(secret.passphraseHash, r.Header.Get(passphraseHeader)) {
+			fail("Incorrect passphrase.", http.StatusForbidden, w)
+			return storedSecret{}, false
+		}
+	}
+
+	key, err := secureID(8)
+	if err != nil {
+		l.Err(err).Msg("creating secret viewing key")
+		fail("Unable to retrieve secret.", http.StatusInternalServerError, w)
+		return storedSecret{}, false
+	}
+
+	viewedAt := time.Now()
+
+	if err := a.store.CreateView(r.Context(), accessID, key); errors.Is(err, errSecretNotFound) {
+		fail("not found", http.StatusNotFound, w)
+		return storedSecret{}, false
+	} else if err != nil {
+		l.Err(err).Msg("creating secret view")
+		fail("Unable to retrieve secret.", http.StatusInternalServerError, w)
+		return storedSecret{}, false
+	}
+
+	secret, deletionReason, err := a.consumeView(r.Context(), accessID, key)
+	if errors.Is(err, errSecretNotFound) {
+		fail("not found", http.StatusNotFound, w)
+		return storedSecret{}, false
+	} else if err != nil {
+		l.Err(err).Msg("consuming secret view")
+		fail("Unable to retrieve secret.", http.StatusInternalServerError, w)
+		return storedSecret{}, false
+	}
+
+	a.metrics.secretViewed()
+	if deletionReason != "" {
+		a.metrics.secretsDeleted(deletionReason, 1)
+	}
+
+	a.notifySecretViewed(secret, viewedAt)
+	a.recordViewEvents(r, accessID, deletionReason)
+	a.recordAccess(r, secret)
+
+	return secret, true
+}
+
+// notYetAvailableMessage describes when a secret that cannot be viewed yet becomes viewable
+func notYetAvailableMessage(s storedSecret) string {
+	return fmt.Sprintf("This secret is not available until %s.", time.UnixMilli(s.notBefore).UTC().Format(time.RFC3339))
+}
+
+// handleDownloadSecret serves the cipher text of a secret as a .enc file to be decrypted elsewhere (i.e. by a CLI),
+// recording a view of the secret in the process. It honours the same expiry, burn after reading and maximum view
+// semantics as the [handleAccessSecret] handler, and as there is no interstitial step it is intended for clients
+// rather than browsers.
+func (a *Application) handleDownloadSecret(w http.ResponseWriter, r *http.Request) {
+	noStore(w)
+	noIndex(w)
+
+	secret, ok := a.viewSecretDirectly(w, r, plainError)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.enc"`, r.PathValue("accessID")))
+	w.Write([]byte(secret.cipherText))
 }
 
 // handleAccessSecret serves the 'decryption' page for a secret providing that a valid access identifier (192 bit) and
-// access key (32 bit) are provided in the request
+// access key (32 bit) are provided in the request. Clients that prefer JSON (via the Accept header) are instead served
+// the cipher text in the same representation as the [handleAPIAccessSecret] handler.
 func (a *Application) handleAccessSecret(w http.ResponseWriter, r *http.Request) {
 	accessID := r.PathValue("accessID")
 	viewingKey := r.PathValue("viewingKey")
 
+	noStore(w)
+	noIndex(w)
+	w.Header().Add("Vary", "Accept")
+	wantsJSON := prefersJSON(r)
+
 	notifications := notifications{}
 
 	l := zerolog.
@@ -260,92 +969,175 @@ func (a *Application) handleAccessSecret(w http.ResponseWriter, r *http.Request)
 		Str("viewing_key", viewingKey).
 		Logger()
 
-	// begin a transaction so the retrieval of the secret's details and the recording of the view being used are atomic
-	tx, err := a.db.db.Begin()
-	if err != nil {
-		l.Err(err).Msg("begin tx")
-		redirectToOopsPage(w, r)
+	// use the view of the secret so nobody else can use it to see the secret, deleting the secret if this view exhausts
+	// it, or return an error if that secret (or the view of it) cannot be found
+	viewedAt := time.Now()
+
+	secret, deletionReason, err := a.consumeView(r.Context(), accessID, viewingKey)
+	if errors.Is(err, errSecretNotFound) && wantsJSON {
+		apiError("not found", http.StatusNotFound, w)
+		return
+	} else if errors.Is(err, errSecretNotFound) {
+		setFlashErr(translate("flash.viewNotFound", language(r.Context())), w, r)
+		safeRedirect(w, r, pathFor(r.Context(), "/"))
+		return
+	} else if err != nil {
+		l.Err(err).Msg("consuming secret view")
+		if wantsJSON {
+			apiError("Unable to retrieve secret.", http.StatusInternalServerError, w)
+		} else {
+			redirectToOopsPage(w, r)
+		}
 		return
 	}
 
-	defer tx.Rollback()
-
-	// retrieve the cipher text and secret view id for the relevant secret, or return an error if that secret cannot be
-	// found
-	var cipherText string
-	var secretViewID int
-	var maxViews int
-	var currentViews int
-
-	err = tx.QueryRow(
-		`
-			SELECT
-				s.cipher_text,
-				v.id,
-				s.maximum_views,
-				(SELECT COUNT(1) FROM secret_views v2 WHERE v2.secret_id = v.secret_id AND viewed_at IS NOT NULL)
-			FROM
-				secrets s
-				INNER JOIN secret_views v ON v.secret_id = s.id
-			WHERE
-				s.access_id = ? AND
-				s.deleted_at IS NULL AND
-				v.viewing_key = ? AND
-				v.viewed_at IS NULL
-		`,
-		accessID,
-		viewingKey,
-	).Scan(&cipherText, &secretViewID, &maxViews, &currentViews)
-
-	if errors.Is(sql.ErrNoRows, err) {
-		setFlashErr("Secret does not exist, has been deleted, or the unique viewing key you attempted to use has been used before.", w)
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	if k, ok := viewerDeletionWarnings[deletionReason]; ok {
+		notifications.warningMsg = translate(k, language(r.Context()))
+	}
+
+	a.metrics.secretViewed()
+	if deletionReason != "" {
+		a.metrics.secretsDeleted(deletionReason, 1)
+	}
+
+	a.notifySecretViewed(secret, viewedAt)
+	a.recordViewEvents(r, accessID, deletionReason)
+	a.recordAccess(r, secret)
+
+	if wantsJSON {
+		writeJSON(apiAccessSecretResponse{CipherText: secret.cipherText, RevealDelaySeconds: secret.revealDelaySeconds}, http.StatusOK, w)
+		return
+	}
+
+	// failed decryption attempts can only be reported for secrets that remain available after this view
+	reportFailureURL := ""
+	if deletionReason == "" {
+		reportFailureURL = pathFor(r.Context(), fmt.Sprintf("/secret/%s/report-failure", accessID))
+	}
+
+	// viewers of secrets destroyed by this view are given a receipt confirming it, so they know reloading the page won't
+	// show the secret again
+	pageViewSecret(secret.cipherText, secret.revealDelaySeconds, reportFailureURL, deletionReason != "", notifications).Render(r.Context(), w)
+}
+
+// handleReportDecryptionFailure records a failed attempt to decrypt a secret, as reported by the front-end, deleting
+// the secret once the configured maximum number of failures has been reached on the assumption that somebody without
+// the encryption key is attempting to guess it
+func (a *Application) handleReportDecryptionFailure(w http.ResponseWriter, r *http.Request) {
+	accessID := r.PathValue("accessID")
+
+	l := zerolog.
+		Ctx(r.Context()).
+		With().
+		Str("access_id", accessID).
+		Logger()
+
+	deleted, err := a.store.RecordDecryptionFailure(r.Context(), accessID, a.config.Secrets.MaximumDecryptionFailures)
+	if errors.Is(err, errSecretNotFound) {
+		http.NotFound(w, r)
 		return
 	} else if err != nil {
-		l.Err(err).Msg("retrieving secret")
-		redirectToOopsPage(w, r)
+		l.Err(err).Msg("recording decryption failure")
+		internalServerError(w, r)
 		return
 	}
 
-	// record the secret view as being used so nobody else can use it to see the secret
-	_, err = tx.Exec("UPDATE secret_views SET viewed_at = ? WHERE id = ?", time.Now().UnixMilli(), secretViewID)
-	if err != nil {
-		l.Err(err).Msg("updating secret view")
+	l.Warn().Bool("deleted", deleted).Msg("decryption failure reported")
+
+	if deleted {
+		a.metrics.secretsDeleted(deletionReasonDecryptionFailures, 1)
+		a.recordEvent(r, secretEvent{accessID: accessID, eventType: eventDeleted, detail: deletionReasonDecryptionFailures})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// viewerDeletionWarnings maps the deletion reasons that can occur whilst viewing a secret to the message key of a warning
+// shown to the viewer
+var viewerDeletionWarnings = map[string]string{
+	deletionReasonViewed:              "warning.burnedAfterReading",
+	deletionReasonMaximumViewCountHit: "warning.maximumViewsReached",
+}
+
+// handleManageSecret renders the management page of a secret and is intended for the original creator of the secret
+// to view
+func (a *Application) handleManageSecret(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	noStore(w)
+	noIndex(w)
+
+	l := zerolog.
+		Ctx(r.Context()).
+		With().
+		Str("management_id", managementID).
+		Logger()
+
+	// retrieve the ID in order to view and decrypt the secret along with its current state, or return an error if that
+	// secret cannot be found. unlike viewers, the creator is told exactly why their secret is unavailable as deleted and
+	// expired secrets are still described on the page, meaning a secret that cannot be found never existed.
+	secret, err := a.store.GetByManagementID(r.Context(), managementID)
+	if errors.Is(err, errSecretNotFound) {
+		setFlashErr(translate("flash.secretNotFound", language(r.Context())), w, r)
+		safeRedirect(w, r, pathFor(r.Context(), "/"))
+		return
+	} else if err != nil {
+		l.Err(err).Msg("retrieving secret")
 		redirectToOopsPage(w, r)
 		return
 	}
 
-	// mark the secret as being deleted if this view is equal to or exceeds the maximum permitted views for the secret
-	if maxViews > 0 && currentViews+1 >= maxViews {
-		_, err := tx.Exec(
-			"UPDATE secrets SET deleted_at = ?, deletion_reason = ?, cipher_text = NULL WHERE access_id = ?",
-			time.Now().UnixMilli(),
-			deletionReasonMaximumViewCountHit,
-			accessID,
-		)
+	// times are rendered in the timezone of the creator where their browser has told us it
+	loc := timezoneFromRequest(r)
+
+	details := secretManagementDetails{
+		viewSecretURL:     fmt.Sprintf("%s/secret/%s", a.baseURL, secret.accessID),
+		deleteSecretURL:   fmt.Sprintf("%s/manage-secret/%s/delete", a.baseURL, managementID),
+		rotateViewURL:     fmt.Sprintf("%s/manage-secret/%s/rotate-view", a.baseURL, managementID),
+		updateTTLURL:      fmt.Sprintf("%s/manage-secret/%s/ttl", a.baseURL, managementID),
+		qrCodeURL:         fmt.Sprintf("%s/manage-secret/%s/qr", a.baseURL, managementID),
+		acknowledgeURL:    fmt.Sprintf("%s/manage-secret/%s/ack", a.baseURL, managementID),
+		createAnotherURL:  fmt.Sprintf("%s/?ttl=%d", a.baseURL, secret.ttl),
+		label:             secret.label,
+		views:             secret.views,
+		unavailableReason: secret.unavailableReason(),
+		createdAt:         time.UnixMilli(secret.createdAt).In(loc),
+		createdAgo:        timeAgo(time.UnixMilli(secret.createdAt), time.Now()),
+		size:              secret.cipherTextSize,
+		large:             a.config.Secrets.SizeWarningThreshold > 0 && secret.cipherTextSize > a.config.Secrets.SizeWarningThreshold,
+	}
+	if secret.ttl > 0 {
+		details.expiresAt = time.UnixMilli(secret.createdAt + (secret.ttl * 60 * 1000)).In(loc)
+	}
+	if secret.notBefore > 0 {
+		details.notBefore = time.UnixMilli(secret.notBefore).In(loc)
+	}
+	if a.recipientEmailsEnabled() {
+		details.emailRecipientURL = fmt.Sprintf("%s/manage-secret/%s/email", a.baseURL, managementID)
+	}
+	if secret.acknowledgedAt > 0 {
+		details.acknowledgedAt = time.UnixMilli(secret.acknowledgedAt).In(loc)
+	}
 
+	// secrets created with access tracking list their most recent accesses for the creator to spot unexpected views
+	if secret.trackAccess {
+		accesses, err := a.store.Accesses(r.Context(), managementID, maximumDisplayedAccesses)
 		if err != nil {
-			l.Err(err).Msg("deleting secret")
+			l.Err(err).Msg("retrieving secret accesses")
 			redirectToOopsPage(w, r)
 			return
 		}
 
-		notifications.warningMsg = "Maximum views reached. This secret will not be accessible again."
+		details.trackAccess = true
+		details.accesses = secretAccessDetailsFrom(accesses, loc)
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		l.Err(err).Msg("committing tx")
-		redirectToOopsPage(w, r)
-		return
-	}
-
-	pageViewSecret(cipherText, notifications).Render(r.Context(), w)
+	pageManageSecret(details, notificationsFromRequest(r, w)).Render(r.Context(), w)
 }
 
-// handleManageSecret renders the management page of a secret and is intended for the original creator of the secret
-// to view
-func (a *Application) handleManageSecret(w http.ResponseWriter, r *http.Request) {
+// handleManageSecretQRCode renders the viewing URL of a secret as a PNG QR code so that it can be shared in person. A
+// QR code is only generated for secrets that exist and can still be viewed.
+func (a *Application) handleManageSecretQRCode(w http.ResponseWriter, r *http.Request) {
 	managementID := r.PathValue("managementID")
 
 	l := zerolog.
@@ -354,37 +1146,45 @@ func (a *Application) handleManageSecret(w http.ResponseWriter, r *http.Request)
 		Str("management_id", managementID).
 		Logger()
 
-	// retrieve the ID in order to view and decrypt the secret, or return an error if that secret cannot be found
-	var accessID string
-
-	err := a.db.db.QueryRow(
-		`
-			SELECT
-				access_id
-			FROM
-				secrets
-			WHERE
-				management_id = ? AND
-				deleted_at IS NULL
-		`,
-		managementID,
-	).Scan(&accessID)
-
-	if errors.Is(sql.ErrNoRows, err) {
-		setFlashErr("Secret does not exist or has been deleted.", w)
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	secret, err := a.store.GetByManagementID(r.Context(), managementID)
+	if errors.Is(err, errSecretNotFound) || (err == nil && (secret.deleted() || secret.expired())) {
+		http.NotFound(w, r)
 		return
 	} else if err != nil {
 		l.Err(err).Msg("retrieving secret")
+		internalServerError(w, r)
+		return
+	}
+
+	png, err := qrcode.Encode(fmt.Sprintf("%s/secret/%s", a.baseURL, secret.accessID), qrcode.Medium, 256)
+	if err != nil {
+		l.Err(err).Msg("generating qr code")
+		internalServerError(w, r)
+		return
+	}
+
+	noStore(w)
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+// handleAcknowledgeSecret records that the creator of a secret has safely stored its links, which is reflected on the
+// management page. Acknowledging a secret does not modify it in any other way.
+func (a *Application) handleAcknowledgeSecret(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	found, err := a.store.Acknowledge(r.Context(), managementID)
+	if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("acknowledging secret")
 		redirectToOopsPage(w, r)
 		return
+	} else if !found {
+		setFlashErr(translate("flash.secretNotFound", language(r.Context())), w, r)
+		safeRedirect(w, r, pathFor(r.Context(), "/"))
+		return
 	}
 
-	pageManageSecret(
-		fmt.Sprintf("%s/secret/%s", a.baseURL, accessID),
-		fmt.Sprintf("%s/manage-secret/%s/delete", a.baseURL, managementID),
-		notificationsFromRequest(r, w),
-	).Render(r.Context(), w)
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/manage-secret/%s", managementID)))
 }
 
 // handleDeleteSecret deletes a secret
@@ -392,22 +1192,166 @@ func (a *Application) handleDeleteSecret(w http.ResponseWriter, r *http.Request)
 	l := zerolog.Ctx(r.Context())
 	managementID := r.PathValue("managementID")
 
-	// delete the secret (if it hasn't already been deleted), returning the user to the manage secret page with an error
-	// message if that fails
-	_, err := a.db.db.Exec(
-		"UPDATE secrets SET deleted_at = ?, deletion_reason = ?, cipher_text = NULL WHERE management_id = ? AND deleted_at IS NULL",
-		time.Now().UnixMilli(),
-		deletionReasonUserDeleted,
-		managementID,
-	)
+	// delete the secret (if it hasn't already been deleted), redirecting the user to the oops page if that fails
+	found, err := a.deleteSecret(r, managementID)
 	if err != nil {
 		l.Err(err).Str("management_id", managementID).Msg("deleting secret")
 		redirectToOopsPage(w, r)
 		return
 	}
 
-	setFlashSuccess("Secret successfully deleted.", w)
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	// deleting a secret again changes nothing, so only claim success when this request was the one that deleted it
+	if found {
+		setFlashSuccess(translate("flash.secretDeleted", language(r.Context())), w, r)
+	} else {
+		setFlashWarn(translate("flash.secretAlreadyDeleted", language(r.Context())), w, r)
+	}
+	safeRedirect(w, r, pathFor(r.Context(), "/"))
+}
+
+// handleRotateViewingLink replaces the viewing link of a secret that can still be viewed, for when a creator suspects
+// the link has leaked before being opened. The previous link stops working immediately, but the cipher text (and so the
+// encryption key) of the secret stays the same. The creator is returned to the management page of the secret, which
+// shows the new viewing link.
+func (a *Application) handleRotateViewingLink(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+
+	if _, err := a.rotateAccessID(r.Context(), managementID); errors.Is(err, errSecretNotFound) {
+		setFlashErr(translate("flash.secretUnavailable", language(r.Context())), w, r)
+	} else if err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("rotating viewing link")
+		redirectToOopsPage(w, r)
+		return
+	} else {
+		a.recordEvent(r, secretEvent{managementID: managementID, eventType: eventRotated})
+		setFlashSuccess(translate("flash.viewingLinkRotated", language(r.Context())), w, r)
+	}
+
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/manage-secret/%s", managementID)))
+}
+
+// handleUpdateTTL changes when a secret that can still be viewed expires, for when a creator wants to give its recipient
+// more (or less) time. The submitted TTL is the number of minutes from now the secret expires in, and is subject to
+// the same limits as when creating a secret. The creator is returned to the management page of the secret, which
+// shows the new expiry.
+func (a *Application) handleUpdateTTL(w http.ResponseWriter, r *http.Request) {
+	managementID := r.PathValue("managementID")
+	lang := language(r.Context())
+
+	if ttl, msg, err := a.updateTTL(r.Context(), managementID, r.PostFormValue("ttl"), lang); err != nil {
+		zerolog.Ctx(r.Context()).Err(err).Str("management_id", managementID).Msg("updating ttl")
+		redirectToOopsPage(w, r)
+		return
+	} else if msg != "" {
+		setFlashErr(msg, w, r)
+	} else {
+		a.recordEvent(r, secretEvent{managementID: managementID, eventType: eventTTLUpdated, detail: strconv.FormatInt(ttl, 10)})
+		setFlashSuccess(translate("flash.ttlUpdated", lang), w, r)
+	}
+
+	safeRedirect(w, r, pathFor(r.Context(), fmt.Sprintf("/manage-secret/%s", managementID)))
+}
+
+// updateTTL replaces the TTL of the secret with the given management ID with one that expires the given number of
+// minutes from now, returning the new TTL. A message (in the given language) describing why the TTL could not be
+// updated is returned if the minutes are invalid, the secret would expire before it becomes viewable, or the secret can
+// no longer be viewed.
+func (a *Application) updateTTL(ctx context.Context, managementID string, minutes string, lang string) (int64, string, error) {
+	n, err := strconv.Atoi(minutes)
+	if err != nil || n < 1 {
+		return 0, translate("flash.ttlInvalid", lang), nil
+	}
+
+	secret, err := a.store.GetByManagementID(ctx, managementID)
+	if errors.Is(err, errSecretNotFound) || (err == nil && (secret.deleted() || secret.expired())) {
+		return 0, translate("flash.secretUnavailable", lang), nil
+	} else if err != nil {
+		return 0, "", fmt.Errorf("retrieving secret: %w", err)
+	}
+
+	ttl, msg := a.ttlFromNow(secret, n, time.Now(), lang)
+	if msg != "" {
+		return 0, msg, nil
+	}
+
+	// as when the secret was created, it must not expire before it ever becomes viewable
+	if secret.notBefore > 0 && secret.createdAt+(ttl*60*1000) <= secret.notBefore {
+		return 0, translate("flash.ttlBeforeViewable", lang), nil
+	}
+
+	// the secret may have been deleted or expired since it was retrieved
+	if err := a.store.UpdateTTL(ctx, managementID, ttl); errors.Is(err, errSecretNotFound) {
+		return 0, translate("flash.secretUnavailable", lang), nil
+	} else if err != nil {
+		return 0, "", err
+	}
+
+	return ttl, "", nil
+}
+
+// ttlFromNow converts the number of minutes from now a secret should expire in into the TTL it is stored with, which
+// counts from when the secret was created. The minutes from now must be at least the configured minimum TTL, and the
+// whole lifetime of the secret within the configured maximum, unless they are clamped to them. A message (in the given
+// language) describing why the TTL cannot be used is returned if it can't be.
+func (a *Application) ttlFromNow(secret storedSecret, minutes int, now time.Time, lang string) (int64, string) {
+	if minimum := int(a.config.Secrets.MinimumTTL / time.Minute); minutes < minimum {
+		if !a.config.Secrets.ClampTTL {
+			return 0, fmt.Sprintf(translate("flash.ttlTooShort", lang), minimum)
+		}
+
+		minutes = minimum
+	}
+
+	// the lifetime of the secret so far is rounded up so that it never expires before the minutes given have passed
+	elapsed := (now.UnixMilli() - secret.createdAt + time.Minute.Milliseconds() - 1) / time.Minute.Milliseconds()
+	ttl := elapsed + int64(minutes)
+
+	if maximum := int64(a.config.Secrets.MaximumTTL / time.Minute); maximum > 0 && ttl > maximum {
+		if !a.config.Secrets.ClampTTL {
+			return 0, fmt.Sprintf(translate("flash.ttlTooLong", lang), maximum)
+		}
+
+		ttl = maximum
+	}
+
+	return ttl, ""
+}
+
+// rotateAccessID replaces the access ID of the secret with the given management ID, returning the new access ID.
+// Should the new access ID collide with that of an existing secret (which is astronomically unlikely) it is
+// regenerated.
+func (a *Application) rotateAccessID(ctx context.Context, managementID string) (string, error) {
+	for attempt := 1; ; attempt++ {
+		accessID, err := a.viewingID()
+		if err != nil {
+			return "", fmt.Errorf("generating access id: %w", err)
+		}
+
+		err = a.store.RotateAccessID(ctx, managementID, accessID)
+		if errors.Is(err, errSecretIDCollision) && attempt < maximumSecretIDAttempts {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		return accessID, nil
+	}
+}
+
+// deleteSecret deletes the secret with the given management ID on behalf of the creator making the request, returning
+// whether a secret that had not already been deleted was found
+func (a *Application) deleteSecret(r *http.Request, managementID string) (bool, error) {
+	found, err := a.store.Delete(r.Context(), managementID)
+	if err != nil {
+		return false, err
+	}
+
+	if found {
+		a.metrics.secretsDeleted(deletionReasonUserDeleted, 1)
+		a.recordEvent(r, secretEvent{managementID: managementID, eventType: eventDeleted, detail: deletionReasonUserDeleted})
+	}
+
+	return found, nil
 }
 
 // badRequest sets the status code of the response to 400 and writes the error to the body
@@ -416,32 +1360,160 @@ func badRequest(err string, w http.ResponseWriter) {
 	w.Write([]byte(err))
 }
 
-// internalServerError sets the status code of the response to 500
-func internalServerError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusInternalServerError)
+// forbidden sets the status code of the response to 403 and writes the error to the body
+func forbidden(err string, w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte(err))
+}
+
+// plainError sets the status code of the response to the one given and writes the error to the body
+func plainError(err string, statusCode int, w http.ResponseWriter) {
+	w.WriteHeader(statusCode)
+	w.Write([]byte(err))
+}
+
+// tooManyRequests sets the status code of the response to 429 and writes a message asking the requester to slow down
+func tooManyRequests(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte("Too many requests. Please wait a moment and try again."))
+}
+
+// serviceUnavailable sets the status code of the response to 503 and writes a message asking the requester to try
+// again shortly, for when the instance is too busy to serve the request
+func serviceUnavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte("Too many secrets are being created. Please wait a moment and try again."))
+}
+
+// internalServerError sets the status code of the response to 500, rendering the oops page with the ID of the request
+// as a reference
+func internalServerError(w http.ResponseWriter, r *http.Request) {
+	renderErrorPage(w, r, http.StatusInternalServerError, func(p pageRenderer, w io.Writer) error {
+		return p.renderOops(r.Context(), w, requestID(r.Context()), retryPath(r))
+	})
+}
+
+// secretUnavailable explains to a viewer that the secret with the given access ID cannot be viewed. Secrets that could
+// only be viewed once and have been are described as such, as the viewer already knows the secret existed by having
+// its URL and would otherwise think they had mistyped it. All other secrets are described by [redirectSecretNotFound].
+func (a *Application) secretUnavailable(w http.ResponseWriter, r *http.Request, accessID string) {
+	reason, err := a.store.UnavailableReason(r.Context(), accessID)
+	if err != nil && !errors.Is(err, errSecretNotFound) {
+		zerolog.Ctx(r.Context()).Err(err).Str("access_id", accessID).Msg("retrieving why secret is unavailable")
+	}
+
+	if reason != deletionReasonViewed {
+		redirectSecretNotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusGone)
+	pageSecretAlreadyViewed().Render(r.Context(), w)
+}
+
+// redirectSecretNotFound redirects a viewer to the home page with the message shown whenever a secret cannot be
+// viewed. The message is the same regardless of whether the secret never existed, has been deleted or has expired so
+// that viewers cannot learn anything about secrets they can't view.
+func redirectSecretNotFound(w http.ResponseWriter, r *http.Request) {
+	setFlashErr(translate("flash.secretUnavailable", language(r.Context())), w, r)
+	safeRedirect(w, r, pathFor(r.Context(), "/"))
 }
 
 // redirectToOopsPage configures the response to redirect to the /oops route which is a catch all error page for
-// any errors that weren't expected
+// any errors that weren't expected. The ID of the failed request is passed along so it can be shown as a reference.
 func redirectToOopsPage(w http.ResponseWriter, r *http.Request) {
-	http.Redirect(w, r, "/oops", http.StatusSeeOther)
+	u := pathFor(r.Context(), "/oops")
+	if id := requestID(r.Context()); id != "" {
+		u += "?ref=" + id
+	}
+
+	safeRedirect(w, r, u)
+}
+
+// handleOops renders the catch all error page, including the reference of the failed request if it is present and
+// valid
+func (a *Application) handleOops(w http.ResponseWriter, r *http.Request) {
+	ref := r.URL.Query().Get("ref")
+	if !requestIDPattern.MatchString(ref) {
+		ref = ""
+	}
+
+	renderErrorPage(w, r, http.StatusOK, func(p pageRenderer, w io.Writer) error {
+		return p.renderOops(r.Context(), w, ref, retryPath(r))
+	})
+}
+
+// safeRedirect redirects the visitor to the given target after a form submission, as long as it is a relative path on
+// this instance. Anything else (absolute and protocol relative URLs, or paths that browsers would treat as such) could
+// send visitors to another site, so the home page is redirected to instead.
+func safeRedirect(w http.ResponseWriter, r *http.Request, target string) {
+	if !isRelativePath(target) {
+		zerolog.Ctx(r.Context()).Warn().Str("target", target).Msg("refusing to redirect outside of the instance")
+		target = pathFor(r.Context(), "/")
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// isRelativePath identifies whether the given redirect target is a path on the same origin as the request
+func isRelativePath(target string) bool {
+	// browsers treat backslashes as forward slashes and ignore tabs and newlines, so "/\evil.example" and
+	// "/\t/evil.example" are as protocol relative as "//evil.example"
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.ContainsAny(target, "\\\t\r\n") {
+		return false
+	}
+
+	u, err := url.Parse(target)
+
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// retryPath returns the path the visitor is offered to try again from after an error: the page that referred them
+// (which browsers keep when following the redirect to the oops page), as long as it is on this instance and isn't the
+// oops page itself, or the home page otherwise
+func retryPath(r *http.Request) string {
+	home := pathFor(r.Context(), "/")
+
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Path == "" || (u.Host != "" && u.Host != r.Host) || u.Path == pathFor(r.Context(), "/oops") {
+		return home
+	}
+
+	return u.RequestURI()
 }
 
 // setFlashErr sets a flash cookie for errors with the content provided
-func setFlashErr(msg string, w http.ResponseWriter) {
-	setFlash("err", msg, w)
+func setFlashErr(msg string, w http.ResponseWriter, r *http.Request) {
+	setFlash("err", msg, w, r)
+}
+
+// setFlashWarn sets a flash cookie for warnings with the content provided
+func setFlashWarn(msg string, w http.ResponseWriter, r *http.Request) {
+	setFlash("warn", msg, w, r)
 }
 
 // setFlashSuccess sets a flash cookie for successes with the content provided
-func setFlashSuccess(msg string, w http.ResponseWriter) {
-	setFlash("success", msg, w)
+func setFlashSuccess(msg string, w http.ResponseWriter, r *http.Request) {
+	setFlash("success", msg, w, r)
 }
 
-// setFlash sets a flash cookie of the given name and message
-func setFlash(name string, msg string, w http.ResponseWriter) {
-	n := fmt.Sprintf("flash_%s", name)
-	m := base64.StdEncoding.EncodeToString([]byte(msg))
-	http.SetCookie(w, &http.Cookie{Name: n, Value: m, Path: "/", HttpOnly: true})
+// setFlash sets a flash cookie of the given name and message. Flash cookies are hidden from JavaScript, only sent
+// with top level navigations from other sites, and only sent over HTTPS when the request was served over it.
+func setFlash(name string, msg string, w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, flashCookie(name, base64.StdEncoding.EncodeToString([]byte(msg)), r))
+}
+
+// flashCookie creates the flash cookie of the given name and (encoded) message
+func flashCookie(name string, value string, r *http.Request) *http.Cookie {
+	return &http.Cookie{
+		Name:     fmt.Sprintf("flash_%s", name),
+		Value:    value,
+		Path:     pathFor(r.Context(), "/"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   requestIsSecure(r),
+	}
 }
 
 // notificationsFromRequest extracts a [notifications] instance from any flash cookies in the request
@@ -455,26 +1527,17 @@ func notificationsFromRequest(r *http.Request, w http.ResponseWriter) notificati
 
 // flash extracts a given flash message cookie from the request
 func flash(name string, r *http.Request, w http.ResponseWriter) string {
-	n := fmt.Sprintf("flash_%s", name)
-
 	// read the cookie, returning an empty string if it doesn't exist
-	c, err := r.Cookie(n)
+	c, err := r.Cookie(fmt.Sprintf("flash_%s", name))
 	if err != nil {
 		return ""
 	}
 
 	// set a cookie with the same name so it is "expired" within the client's browser
-	http.SetCookie(
-		w,
-		&http.Cookie{
-			Name:     n,
-			Value:    "",
-			Expires:  time.Unix(1, 0),
-			MaxAge:   -1,
-			Path:     "/",
-			HttpOnly: true,
-		},
-	)
+	expired := flashCookie(name, "", r)
+	expired.Expires = time.Unix(1, 0)
+	expired.MaxAge = -1
+	http.SetCookie(w, expired)
 
 	// extract the base64 encoded cookie value and decode it before returning it
 	v, err := base64.StdEncoding.DecodeString(c.Value)
@@ -496,6 +1559,63 @@ func secureID(size int) (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// secureSlug generates a cryptographically random, URL safe base64 encoded identifier of the given size (in bytes),
+// which is a third shorter than a [secureID] of the same size
+func secureSlug(size int) (string, error) {
+	b := make([]byte, size)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// viewingID generates the viewing ID of a new secret using the configured size and encoding
+func (a *Application) viewingID() (string, error) {
+	if a.config.Secrets.ViewingIDEncoding == viewingIDEncodingBase64URL {
+		return secureSlug(a.config.Secrets.ViewingIDSize)
+	}
+
+	return secureID(a.config.Secrets.ViewingIDSize)
+}
+
+// passphraseMatches identifies whether the given passphrase matches the bcrypt hash stored against a secret. An empty
+// passphrase never matches and is never compared, as no secret can be protected by one. An empty hash (for secrets
+// that don't exist or aren't protected) never matches either, but a passphrase supplied for one is still compared
+// against a dummy hash so that every comparison takes as long as a real one.
+func (a *Application) passphraseMatches(hash string, passphrase string) bool {
+	if passphrase == "" {
+		return false
+	} else if hash == "" {
+		a.comparePassphrase(dummyPassphraseHash(), []byte(passphrase))
+		return false
+	}
+
+	return a.comparePassphrase([]byte(hash), []byte(passphrase)) == nil
+}
+
+// dummyPassphraseHash is a bcrypt hash, generated at the same cost as real passphrase hashes, that passphrases are
+// compared against when there is no real hash to compare them to
+var dummyPassphraseHash = sync.OnceValue(func() []byte {
+	h, err := bcrypt.GenerateFromPassword([]byte("shareasecret"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("generating dummy passphrase hash: %v", err))
+	}
+
+	return h
+})
+
+// isExpired identifies whether a secret created at the given time (in unix milliseconds) has outlived its TTL (time to
+// live, in minutes). A TTL of 0 is interpreted as the secret never expiring.
+func isExpired(createdAt int64, ttl int64) bool {
+	if ttl <= 0 {
+		return false
+	}
+
+	return createdAt+(ttl*60*1000) <= time.Now().UnixMilli()
+}
+
 // requestingIPCanCreateSecret identifies whether the request was made from an IP address that has been specifically
 // allowed to create secrets.
 func requestingIPCanCreateSecret(config *Configuration, r *http.Request) bool {
@@ -503,7 +1623,7 @@ func requestingIPCanCreateSecret(config *Configuration, r *http.Request) bool {
 		return true
 	}
 
-	sourceIP := net.ParseIP(strings.Split(r.Header.Get("X-Forwarded-For"), ",")[0])
+	sourceIP := clientIP(r)
 	if sourceIP == nil {
 		return false
 	}
@@ -1,19 +1,26 @@
 package shareasecret
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestSecretCreation(t *testing.T) {
 	t.Run("unable to view create secret page if not valid requesting ip", func(t *testing.T) {
-		if r := get(t, app.handleGetIndex, func(r *http.Request) { r.Header.Del("X-Forwarded-For") }); r.statusCode != 200 {
+		if r := get(t, app.handleGetIndex, func(r *http.Request) { r.Header.Set("X-Forwarded-For", "192.0.2.1") }); r.statusCode != 200 {
 			t.Errorf("wanted 200 status code, got %v", r.statusCode)
 		} else if !strings.Contains(r.body, "instance is private") {
 			t.Errorf("wanted 'instance is private' in body")
@@ -22,12 +29,29 @@ func TestSecretCreation(t *testing.T) {
 		}
 	})
 
+	t.Run("pre-selects the ttl preset of a previously created secret", func(t *testing.T) {
+		r := get(t, app.handleGetIndex, func(r *http.Request) { r.URL.RawQuery = "ttl=180" })
+
+		if r.statusCode != 200 {
+			t.Errorf("wanted 200 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, `<option value="3h" selected>`) {
+			t.Errorf("wanted 3h preset to be selected")
+		}
+
+		for _, ttl := range []string{"", "45", "soon"} {
+			r := get(t, app.handleGetIndex, func(r *http.Request) { r.URL.RawQuery = "ttl=" + ttl })
+			if strings.Contains(r.body, "selected") {
+				t.Errorf("did not expect a preset to be selected for ttl %q", ttl)
+			}
+		}
+	})
+
 	t.Run("redirects back to home page if not valid requesting ip to create secret", func(t *testing.T) {
 		r := post(
 			t,
 			app.handleCreateSecret,
-			"ttl=30&encryptedSecret=a.b.c&maxViews=1",
-			func(r *http.Request) { r.Header.Del("X-Forwarded-For") },
+			"ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1",
+			func(r *http.Request) { r.Header.Set("X-Forwarded-For", "192.0.2.1") },
 		)
 
 		if r.statusCode != 303 {
@@ -45,8 +69,364 @@ func TestSecretCreation(t *testing.T) {
 		}
 	})
 
+	t.Run("bad request for ciphertext with empty or non base64 segments", func(t *testing.T) {
+		for _, c := range []string{"..", "YWJj..Z2hp", "YWJj.ZGVm.Z2hp.", "YWJj.ZGVm.not*base64"} {
+			body := "ttl=30&maxViews=1&encryptedSecret=" + url.QueryEscape(c)
+
+			if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+				t.Errorf("wanted 400 status code for %v, got %v", c, r.statusCode)
+			}
+		}
+	})
+
+	t.Run("prefers the ttl preset over the raw ttl", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&ttlPreset=1d&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		var ttl int
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+		if err := testDB().QueryRow("SELECT ttl FROM secrets WHERE management_id = ?", managementID).Scan(&ttl); err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if ttl != 1440 {
+			t.Errorf("wanted ttl of 1440 minutes, got %v", ttl)
+		}
+	})
+
+	t.Run("prefers an absolute expiry time over the ttl", func(t *testing.T) {
+		expiresAt := url.QueryEscape(time.Now().Add(2 * time.Hour).Format(time.RFC3339))
+
+		r := post(t, app.handleCreateSecret, "ttl=30&ttlPreset=1d&expiresAt="+expiresAt+"&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		var ttl int
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+		if err := testDB().QueryRow("SELECT ttl FROM secrets WHERE management_id = ?", managementID).Scan(&ttl); err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if ttl != 120 {
+			t.Errorf("wanted ttl of 120 minutes, got %v", ttl)
+		}
+	})
+
+	t.Run("creates secrets that become viewable at the time given", func(t *testing.T) {
+		notBefore := time.Now().Add(time.Hour).Truncate(time.Minute)
+		loc, _ := time.LoadLocation("Europe/Madrid")
+
+		for _, v := range []string{notBefore.Format(time.RFC3339), notBefore.In(loc).Format(datetimeLocalLayout)} {
+			r := post(t, app.handleCreateSecret, "ttl=120&notBefore="+url.QueryEscape(v)+"&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: timezoneCookieName, Value: "Europe/Madrid"})
+			})
+			if r.statusCode != 303 {
+				t.Fatalf("wanted 303 status code for %v, got %v: %v", v, r.statusCode, r.body)
+			}
+
+			managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+			if secret, err := app.store.GetByManagementID(context.Background(), managementID); err != nil || secret.notBefore != notBefore.UnixMilli() {
+				t.Errorf("wanted not before time %v for %v, got %v (%v)", notBefore.UnixMilli(), v, secret.notBefore, err)
+			}
+		}
+	})
+
+	t.Run("bad request for not before times at or after expiry", func(t *testing.T) {
+		notBefore := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+
+		if r := post(t, app.handleCreateSecret, "ttl=30&notBefore="+notBefore+"&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if r.headers.Get(errorCodeHeader) != string(errorCodeNotBeforeAfterExpiry) {
+			t.Errorf("wanted %v error code, got %v", errorCodeNotBeforeAfterExpiry, r.headers.Get(errorCodeHeader))
+		}
+	})
+
+	t.Run("bad request for expiry times in the past", func(t *testing.T) {
+		if r := post(t, app.handleCreateSecret, "expiresAt=1000&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "must be in the future") {
+			t.Errorf("wanted 'must be in the future' in body, got %v", r.body)
+		}
+	})
+
+	t.Run("bad request for invalid ttl preset", func(t *testing.T) {
+		if r := post(t, app.handleCreateSecret, "ttlPreset=soon&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("bad request for negative ttl", func(t *testing.T) {
+		if r := post(t, app.handleCreateSecret, "ttl=-1&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "must not be negative") {
+			t.Errorf("wanted 'must not be negative' in body, got %v", r.body)
+		}
+	})
+
+	t.Run("creates secrets that never expire unless they are not permitted", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=0&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+		if secret, err := app.store.GetByManagementID(context.Background(), managementID); err != nil || secret.ttl != 0 || secret.expired() {
+			t.Errorf("wanted secret that never expires, got %+v (%v)", secret, err)
+		}
+
+		app.config.Secrets.AllowNeverExpire = false
+		defer func() {
+			app.config.Secrets.AllowNeverExpire = true
+			app.config.Secrets.MaximumTTL = 0
+			app.config.Secrets.ClampTTL = false
+		}()
+
+		if r := post(t, app.handleCreateSecret, "ttl=0&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "never expire are not permitted") {
+			t.Errorf("wanted 'never expire are not permitted' in body, got %v", r.body)
+		}
+
+		// clamping a secret that never expires to the maximum gives it an expiry
+		app.config.Secrets.MaximumTTL = time.Hour
+		app.config.Secrets.ClampTTL = true
+
+		if r := post(t, app.handleCreateSecret, "ttl=0&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 303 {
+			t.Errorf("wanted 303 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("rejects or clamps ttls exceeding the maximum ttl", func(t *testing.T) {
+		app.config.Secrets.MaximumTTL = time.Hour
+		defer func() {
+			app.config.Secrets.MaximumTTL = 0
+			app.config.Secrets.ClampTTL = false
+		}()
+
+		for _, ttl := range []string{"120", "0"} {
+			body := "ttl=" + ttl + "&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1"
+
+			if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+				t.Errorf("wanted 400 status code for ttl %v, got %v", ttl, r.statusCode)
+			} else if !strings.Contains(r.body, "too long") {
+				t.Errorf("wanted 'too long' in body, got %v", r.body)
+			}
+		}
+
+		app.config.Secrets.ClampTTL = true
+
+		r := post(t, app.handleCreateSecret, "ttl=120&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		var ttl int
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+		if err := testDB().QueryRow("SELECT ttl FROM secrets WHERE management_id = ?", managementID).Scan(&ttl); err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if ttl != 60 {
+			t.Errorf("wanted ttl to be clamped to 60 minutes, got %v", ttl)
+		}
+	})
+
+	t.Run("rejects or clamps ttls below the minimum ttl", func(t *testing.T) {
+		app.config.Secrets.MinimumTTL = time.Hour
+		defer func() {
+			app.config.Secrets.MinimumTTL = 0
+			app.config.Secrets.ClampTTL = false
+		}()
+
+		if r := post(t, app.handleCreateSecret, "ttl=5&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "too short") {
+			t.Errorf("wanted 'too short' in body, got %v", r.body)
+		}
+
+		// secrets that never expire are never too short
+		if r := post(t, app.handleCreateSecret, "ttl=0&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 303 {
+			t.Errorf("wanted 303 status code for secret that never expires, got %v", r.statusCode)
+		}
+
+		app.config.Secrets.ClampTTL = true
+
+		r := post(t, app.handleCreateSecret, "ttl=5&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+		if secret, err := app.store.GetByManagementID(context.Background(), managementID); err != nil || secret.ttl != 60 {
+			t.Errorf("wanted ttl to be clamped to 60 minutes, got %+v (%v)", secret, err)
+		}
+	})
+
+	t.Run("applies the default ttl when none is provided", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v: %v", r.statusCode, r.body)
+		}
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+		if secret, err := app.store.GetByManagementID(context.Background(), managementID); err != nil || secret.ttl != 30 {
+			t.Errorf("wanted secret with the default ttl of 30 minutes, got %+v (%v)", secret, err)
+		}
+
+		msg := base64.StdEncoding.EncodeToString([]byte("No expiry was given, so the secret expires after the default of 30 minutes."))
+		if len(r.cookies) == 0 || r.cookies[0].Name != "flash_warn" || r.cookies[0].Value != msg {
+			t.Errorf("wanted warning flash describing the default ttl, got %v", r.cookies)
+		}
+
+		for _, ttl := range []string{"", "forever"} {
+			if r := post(t, app.handleCreateSecret, "ttl="+ttl+"&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+				t.Errorf("wanted 400 status code for ttl %q, got %v", ttl, r.statusCode)
+			}
+		}
+	})
+
+	t.Run("shows the label only on the management page", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&label=database+password", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+
+		r = get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !strings.Contains(r.body, "database password") {
+			t.Errorf("wanted label in management page body")
+		}
+
+		secret, err := app.store.GetByManagementID(context.Background(), managementID)
+		if err != nil {
+			t.Fatalf("retrieving secret: %v", err)
+		}
+
+		r = get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", secret.accessID) })
+		if strings.Contains(r.body, "database password") {
+			t.Errorf("did not expect label in interstitial page body")
+		}
+	})
+
+	t.Run("bad request for labels exceeding the maximum length", func(t *testing.T) {
+		body := "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&label=" + strings.Repeat("a", maximumLabelLength+1)
+
+		if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "Label is too long") {
+			t.Errorf("wanted 'Label is too long' in body, got %v", r.body)
+		}
+	})
+
+	t.Run("generates shorter viewing ids when configured to", func(t *testing.T) {
+		app.config.Secrets.ViewingIDSize = minimumViewingIDSize
+		app.config.Secrets.ViewingIDEncoding = viewingIDEncodingBase64URL
+		defer func() {
+			app.config.Secrets.ViewingIDSize = defaultViewingIDSize
+			app.config.Secrets.ViewingIDEncoding = viewingIDEncodingHex
+		}()
+
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		}
+
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+
+		secret, err := app.store.GetByManagementID(context.Background(), managementID)
+		if err != nil {
+			t.Fatalf("retrieving secret: %v", err)
+		} else if len(secret.accessID) != 22 || len(managementID) != 48 {
+			t.Errorf("wanted 22 character viewing id and 48 character management id, got %v and %v", secret.accessID, managementID)
+		}
+	})
+
+	t.Run("redirects the browser to the management page of the secret", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Fatalf("wanted 303 status code, got %v", r.statusCode)
+		} else if !strings.HasPrefix(r.headers.Get("Location"), "/manage-secret/") {
+			t.Fatalf("wanted redirect to management page, got %v", r.headers.Get("Location"))
+		}
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", r.headers.Get("Location"), nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+
+		app.router.ServeHTTP(recorder, req)
+
+		if recorder.Code != 200 {
+			t.Errorf("wanted 200 status code, got %v", recorder.Code)
+		} else if !strings.Contains(recorder.Body.String(), "manage secret") {
+			t.Errorf("wanted management page, got %v", recorder.Body.String())
+		}
+	})
+
+	t.Run("regenerates identifiers that collide with those of existing secrets", func(t *testing.T) {
+		store := &collidingSecretStore{SecretStore: newMemorySecretStore(), collisions: maximumSecretIDAttempts - 1}
+		collidingApp := &Application{config: app.config, store: store, metrics: newMetrics(), emails: app.emails}
+
+		accessID, managementID, err := collidingApp.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1})
+		if err != nil {
+			t.Fatalf("wanted secret to be created, got %v", err)
+		} else if _, err := store.GetByManagementID(context.Background(), managementID); err != nil {
+			t.Errorf("wanted secret to be stored, got %v", err)
+		} else if store.attempts != maximumSecretIDAttempts || accessID == "" {
+			t.Errorf("wanted %v attempts, got %v", maximumSecretIDAttempts, store.attempts)
+		}
+
+		store.collisions, store.attempts = maximumSecretIDAttempts, 0
+		if _, _, err := collidingApp.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1}); !errors.Is(err, errSecretIDCollision) {
+			t.Errorf("wanted id collision error once attempts are exhausted, got %v", err)
+		}
+	})
+
+	t.Run("bad request for ciphertext exceeding the maximum size", func(t *testing.T) {
+		body := "ttl=30&maxViews=1&encryptedSecret=a.b." + strings.Repeat("c", app.config.Secrets.MaximumSize)
+
+		if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "too large") {
+			t.Errorf("wanted 'too large' in body, got %v", r.body)
+		}
+	})
+
+	t.Run("bad request for request body exceeding the maximum size", func(t *testing.T) {
+		body := "ttl=30&maxViews=1&encryptedSecret=a.b." + strings.Repeat("c", app.config.Secrets.MaximumSize+maximumRequestOverhead)
+
+		if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "Secret is too large") {
+			t.Errorf("wanted 'Secret is too large' in body, got %v", r.body)
+		}
+	})
+
+	t.Run("rejects oversized forms before buffering them", func(t *testing.T) {
+		body := &countingReader{Reader: io.MultiReader(
+			strings.NewReader("csrfToken=token&ttl=30&maxViews=1&encryptedSecret=a.b."),
+			io.LimitReader(infiniteReader('c'), 5*1024*1024),
+		)}
+
+		r := httptest.NewRequest("POST", "/secret", body)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, r)
+
+		limit := app.config.Secrets.MaximumSize*maximumBundleSize + maximumRequestOverhead
+		if recorder.Code != 400 || !strings.Contains(recorder.Body.String(), "Secret is too large") {
+			t.Errorf("wanted 400 status code and 'Secret is too large', got %v: %v", recorder.Code, recorder.Body.String())
+		} else if c := recorder.Header().Get(errorCodeHeader); c != string(errorCodeSecretTooLarge) {
+			t.Errorf("wanted %v error code, got %v", errorCodeSecretTooLarge, c)
+		} else if body.read > limit+64*1024 {
+			t.Errorf("wanted at most around %v bytes to be read, got %v", limit, body.read)
+		}
+	})
+
 	t.Run("bad request for invalid ttl", func(t *testing.T) {
-		if r := post(t, app.handleCreateSecret, "ttl=30x&encryptedSecret=a.b.c&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
+		if r := post(t, app.handleCreateSecret, "ttl=30x&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer); r.statusCode != 400 {
 			t.Errorf("wanted 400 status code, got %v", r.statusCode)
 		} else if !strings.Contains(r.body, "parse the TTL") {
 			t.Errorf("wanted 'parse the TTL' in body, got %v", r.body)
@@ -54,24 +434,97 @@ func TestSecretCreation(t *testing.T) {
 	})
 
 	t.Run("bad request for invalid maximum views", func(t *testing.T) {
-		if r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=a.b.c&maxViews=-30", emptyRequestConfigurer); r.statusCode != 400 {
+		if r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=-30", emptyRequestConfigurer); r.statusCode != 400 {
 			t.Errorf("wanted 400 status code, got %v", r.statusCode)
 		} else if !strings.Contains(r.body, "parse the maximum views") {
 			t.Errorf("wanted 'parse the maximum views' in body, got %v", r.body)
+		} else if c := r.headers.Get(errorCodeHeader); c != string(errorCodeInvalidMaxViews) {
+			t.Errorf("wanted %v error code, got %v", errorCodeInvalidMaxViews, c)
+		}
+	})
+
+	t.Run("re-renders the form with the error and chosen options for browsers", func(t *testing.T) {
+		body := "ttlPreset=3h&encryptedSecret=&maxViews=3&label=database+password&burnAfterReading=true"
+		r := post(t, app.handleCreateSecret, body, func(r *http.Request) {
+			r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		})
+
+		if r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		}
+		for _, want := range []string{
+			`id="createSecretForm"`,
+			"Secret format is invalid",
+			`data-error-code="invalid_cipher_format"`,
+			`<option value="3h" selected>`,
+			`name="maxViews" value="3"`,
+			`value="database password"`,
+			`value="true" checked`,
+		} {
+			if !strings.Contains(r.body, want) {
+				t.Errorf("wanted %v in body, got %v", want, r.body)
+			}
+		}
+	})
+
+	t.Run("describes the error in the language of the visitor", func(t *testing.T) {
+		endpoint := app.languages(http.HandlerFunc(app.handleCreateSecret)).ServeHTTP
+		want := fmt.Sprintf(translate("invalid.revealDelayRange", "es"), maximumRevealDelay)
+
+		html := post(t, endpoint, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&revealDelay=61", func(r *http.Request) {
+			r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+			r.Header.Set("Accept-Language", "es")
+		})
+		if !strings.Contains(html.body, want) {
+			t.Errorf("wanted %v in re-rendered form, got %v", want, html.body)
+		}
+
+		plain := post(t, endpoint, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&revealDelay=61", func(r *http.Request) {
+			r.Header.Set("Accept-Language", "es")
+		})
+		if plain.body != want {
+			t.Errorf("wanted %v, got %v", want, plain.body)
+		}
+	})
+
+	t.Run("describes the errors of secrets in a bundle in the language of the visitor", func(t *testing.T) {
+		endpoint := app.languages(http.HandlerFunc(app.handleCreateSecret)).ServeHTTP
+		r := post(t, endpoint, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&encryptedSecret=abc&maxViews=1", func(r *http.Request) {
+			r.Header.Set("Accept-Language", "es")
+		})
+
+		if want := "Secreto 2: " + translate("invalid.cipherFormat", "es"); r.body != want {
+			t.Errorf("wanted %v, got %v", want, r.body)
+		}
+	})
+
+	t.Run("bad request for invalid reveal delay", func(t *testing.T) {
+		for _, d := range []string{"soon", "-1", "61"} {
+			if r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&revealDelay="+d, emptyRequestConfigurer); r.statusCode != 400 {
+				t.Errorf("wanted 400 status code for %v, got %v", d, r.statusCode)
+			}
+		}
+	})
+
+	t.Run("bad request for invalid burn after reading option", func(t *testing.T) {
+		if r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&burnAfterReading=maybe", emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "burn after reading") {
+			t.Errorf("wanted 'burn after reading' in body, got %v", r.body)
 		}
 	})
 
 	t.Run("creates the secret and redirects correctly", func(t *testing.T) {
-		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=a.b.c&maxViews=1", emptyRequestConfigurer)
-		if r.statusCode != 201 {
-			t.Errorf("wanted 201 status code, got %v", r.statusCode)
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1", emptyRequestConfigurer)
+		if r.statusCode != 303 {
+			t.Errorf("wanted 303 status code, got %v", r.statusCode)
 		} else if _, ok := r.headers["Location"]; !ok {
 			t.Errorf("expected Location response header to be present")
 		}
 
 		var rc int
 
-		err := app.db.db.QueryRow(
+		err := testDB().QueryRow(
 			"SELECT COUNT(1) FROM secrets WHERE management_id = ?",
 			strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", ""),
 		).Scan(&rc)
@@ -85,20 +538,258 @@ func TestSecretCreation(t *testing.T) {
 }
 
 func TestSecretManagement(t *testing.T) {
-	t.Run("redirects home if secret has been deleted", func(t *testing.T) {
+	t.Run("redirects home if secret does not exist", func(t *testing.T) {
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", "unknown") })
+
+		if !responseIsRedirectTo(r, "/") {
+			t.Errorf("expected redirect to home page")
+		} else if c := r.cookies[0]; c.Name != "flash_err" {
+			t.Errorf("expected flash_err cookie to be present")
+		} else if c.Value != base64.StdEncoding.EncodeToString([]byte("Secret does not exist.")) {
+			t.Errorf("expected flash_err cookie to state the secret does not exist")
+		}
+	})
+
+	t.Run("does not manage a secret via its viewing id", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", accessID) })
+
+		if !responseIsRedirectTo(r, "/") {
+			t.Errorf("expected redirect to home page")
+		}
+	})
+
+	t.Run("shows the viewing url and expiry of an active secret", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+
+		if r.statusCode != 200 {
+			t.Errorf("expected 200 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "/secret/"+accessID) {
+			t.Errorf("expected viewing url to be in body")
+		} else if !strings.Contains(r.body, "Expires") || strings.Contains(r.body, "never") {
+			t.Errorf("expected expiry time to be in body")
+		} else if !strings.Contains(r.body, "/?ttl=30") {
+			t.Errorf("expected create another url preserving the ttl to be in body")
+		}
+	})
+
+	t.Run("shows when the secret was created in the creator's timezone", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		createdAt := time.Now().Add(-3 * time.Hour)
+		if _, err := testDB().Exec("UPDATE secrets SET created_at = ? WHERE access_id = ?", createdAt.UnixMilli(), accessID); err != nil {
+			t.Fatalf("updating secret created at: %v", err)
+		}
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) {
+			r.SetPathValue("managementID", managementID)
+			r.AddCookie(&http.Cookie{Name: timezoneCookieName, Value: "Asia/Tokyo"})
+		})
+
+		tokyo, _ := time.LoadLocation("Asia/Tokyo")
+		if !strings.Contains(r.body, "3 hours ago") {
+			t.Errorf("expected relative creation time to be in body")
+		} else if !strings.Contains(r.body, createdAt.In(tokyo).Format("2 Jan 2006 15:04 MST")) {
+			t.Errorf("expected creation time in the creator's timezone to be in body")
+		}
+	})
+
+	t.Run("regenerates the viewing url of a secret", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		r := post(t, app.handleRotateViewingLink, "", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !responseIsRedirectTo(r, "/manage-secret/"+managementID) {
+			t.Fatalf("expected redirect to management page, got %v %v", r.statusCode, r.headers.Get("Location"))
+		} else if c := r.cookies[0]; c.Name != "flash_success" {
+			t.Errorf("expected flash_success cookie to be present, got %v", c)
+		}
+
+		if _, err := app.store.GetByViewingID(context.Background(), accessID); !errors.Is(err, errSecretNotFound) {
+			t.Errorf("expected previous viewing id to no longer work, got %v", err)
+		}
+
+		secret, err := app.store.GetByManagementID(context.Background(), managementID)
+		if err != nil || secret.accessID == accessID {
+			t.Fatalf("expected secret with a new viewing id, got %+v (%v)", secret, err)
+		}
+
+		r = get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !strings.Contains(r.body, "/secret/"+secret.accessID) {
+			t.Errorf("expected new viewing url to be in body")
+		}
+	})
+
+	t.Run("does not regenerate the viewing url of a deleted secret", func(t *testing.T) {
 		_, managementID := createSecret(t, time.Now(), deletionReasonUserDeleted)
 
+		r := post(t, app.handleRotateViewingLink, "", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !responseIsRedirectTo(r, "/manage-secret/"+managementID) {
+			t.Errorf("expected redirect to management page, got %v", r.statusCode)
+		} else if c := r.cookies[0]; c.Name != "flash_err" {
+			t.Errorf("expected flash_err cookie to be present, got %v", c)
+		}
+	})
+
+	t.Run("states the secret has been deleted without a viewing url", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
 		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
 
-		if r.statusCode != 303 {
-			t.Errorf("expected 303 status code, got %v", r.statusCode)
-		} else if r.headers.Get("Location") != "/" {
-			t.Errorf("expected redirect to /, got %v", r.headers.Get("Location"))
+		if r.statusCode != 200 {
+			t.Errorf("expected 200 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "You deleted this secret.") {
+			t.Errorf("expected 'You deleted this secret.' to be in body")
+		} else if strings.Contains(r.body, "/secret/"+accessID) {
+			t.Errorf("did not expect viewing url to be in body")
+		}
+	})
+
+	t.Run("states the secret has expired without a viewing url", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec(
+			"UPDATE secrets SET ttl = 1, created_at = ? WHERE access_id = ?",
+			time.Now().Add(-2*time.Minute).UnixMilli(),
+			accessID,
+		)
+		if err != nil {
+			t.Errorf("updating secret TTL: %v", err)
+		}
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+
+		if r.statusCode != 200 {
+			t.Errorf("expected 200 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "This secret expired.") {
+			t.Errorf("expected 'This secret expired.' to be in body")
+		} else if strings.Contains(r.body, "/secret/"+accessID) {
+			t.Errorf("did not expect viewing url to be in body")
+		}
+	})
+
+	t.Run("acknowledging a secret is reflected on the management page", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !strings.Contains(r.body, "/manage-secret/"+managementID+"/ack") {
+			t.Errorf("expected acknowledge form to be in body")
+		}
+
+		r = post(t, app.handleAcknowledgeSecret, "", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !responseIsRedirectTo(r, "/manage-secret/"+managementID) {
+			t.Errorf("expected redirect to management page")
+		}
+
+		r = get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !strings.Contains(r.body, "you saved the links to this secret on") {
+			t.Errorf("expected acknowledgement to be in body")
+		} else if strings.Contains(r.body, "/manage-secret/"+managementID+"/ack") {
+			t.Errorf("did not expect acknowledge form to be in body")
+		}
+	})
+
+	t.Run("changes when secrets expire", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+		rc := func(r *http.Request) { r.SetPathValue("managementID", managementID) }
+
+		r := post(t, app.handleUpdateTTL, "ttl=120", rc)
+		if !responseIsRedirectTo(r, "/manage-secret/"+managementID) {
+			t.Errorf("expected redirect to management page, got %v %v", r.statusCode, r.headers.Get("Location"))
+		} else if c := r.cookies[0]; c.Name != "flash_success" {
+			t.Errorf("expected flash_success cookie to be present, got %v", c)
+		}
+
+		// the secret was created less than a minute ago
+		if secret, err := app.store.GetByManagementID(context.Background(), managementID); err != nil || secret.ttl < 120 || secret.ttl > 121 {
+			t.Errorf("expected ttl counting from creation of up to 121 minutes, got %v (%v)", secret.ttl, err)
+		}
+	})
+
+	t.Run("refuses to change when secrets expire to before they become viewable", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec("UPDATE secrets SET not_before = ? WHERE management_id = ?", time.Now().Add(2*time.Hour).UnixMilli(), managementID)
+		if err != nil {
+			t.Errorf("updating secret: %v", err)
+		}
+
+		r := post(t, app.handleUpdateTTL, "ttl=60", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if c := r.cookies[0]; c.Name != "flash_err" {
+			t.Errorf("expected flash_err cookie to be present, got %v", c)
+		}
+	})
+
+	t.Run("refuses to change when secrets expire to invalid ttls", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+		rc := func(r *http.Request) { r.SetPathValue("managementID", managementID) }
+
+		maximum := app.config.Secrets.MaximumTTL
+		app.config.Secrets.MaximumTTL = time.Hour
+		defer func() { app.config.Secrets.MaximumTTL = maximum }()
+
+		for _, body := range []string{"", "ttl=0", "ttl=abc", "ttl=61"} {
+			r := post(t, app.handleUpdateTTL, body, rc)
+			if !responseIsRedirectTo(r, "/manage-secret/"+managementID) || r.cookies[0].Name != "flash_err" {
+				t.Errorf("expected redirect with an error for body %q, got %v %v", body, r.statusCode, r.cookies)
+			}
+		}
+
+		if secret, _ := app.store.GetByManagementID(context.Background(), managementID); secret.ttl != 30 {
+			t.Errorf("expected ttl to be unchanged, got %v", secret.ttl)
+		}
+	})
+
+	t.Run("refuses to change when deleted secrets expire", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		r := post(t, app.handleUpdateTTL, "ttl=60", func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !responseIsRedirectTo(r, "/manage-secret/"+managementID) || r.cookies[0].Name != "flash_err" {
+			t.Errorf("expected redirect with an error, got %v %v", r.statusCode, r.cookies)
+		}
+	})
+
+	t.Run("warns of unusually large secrets on the management page", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		threshold := app.config.Secrets.SizeWarningThreshold
+		defer func() { app.config.Secrets.SizeWarningThreshold = threshold }()
+
+		for size, warned := range map[int]bool{0: false, 5: false, 4: true} {
+			app.config.Secrets.SizeWarningThreshold = size
+
+			r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+			if !strings.Contains(r.body, "5 bytes") {
+				t.Errorf("expected size of secret to be in body")
+			} else if strings.Contains(r.body, "unusually large") != warned {
+				t.Errorf("expected warning to be %v with a threshold of %v bytes", warned, size)
+			}
+		}
+	})
+
+	t.Run("redirects home when acknowledging a secret that does not exist", func(t *testing.T) {
+		r := post(t, app.handleAcknowledgeSecret, "", func(r *http.Request) { r.SetPathValue("managementID", "unknown") })
+
+		if !responseIsRedirectTo(r, "/") {
+			t.Errorf("expected redirect to home page")
 		} else if c := r.cookies[0]; c.Name != "flash_err" {
 			t.Errorf("expected flash_err cookie to be present")
 		}
 	})
 
+	t.Run("describes why a secret was deleted", func(t *testing.T) {
+		for reason, description := range deletionReasonDescriptions {
+			_, managementID := createSecret(t, time.Now(), reason)
+
+			r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+			if !strings.Contains(r.body, description) {
+				t.Errorf("expected %q to be in body for deletion reason %v", description, reason)
+			}
+		}
+	})
+
 	t.Run("deletes a secret", func(t *testing.T) {
 		accessID, managementID := createSecret(t, time.Time{}, "")
 
@@ -112,30 +803,287 @@ func TestSecretManagement(t *testing.T) {
 
 		var deletedAt sql.NullInt64
 
-		err := app.db.db.QueryRow("SELECT deleted_at FROM secrets WHERE access_id = ?", accessID).Scan(&deletedAt)
+		err := testDB().QueryRow("SELECT deleted_at FROM secrets WHERE access_id = ?", accessID).Scan(&deletedAt)
 		if err != nil {
 			t.Errorf("querying secret: %v", err)
 		} else if !deletedAt.Valid {
 			t.Errorf("expected secret's deleted_at to have been set")
 		}
 	})
+
+	t.Run("warns rather than claiming success when deleting a deleted or unknown secret", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		for _, id := range []string{managementID, "unknown"} {
+			r := post(t, app.handleDeleteSecret, "", func(r *http.Request) { r.SetPathValue("managementID", id) })
+
+			if !responseIsRedirectTo(r, "/") {
+				t.Errorf("expected redirect to home page")
+			} else if len(r.cookies) != 1 || r.cookies[0].Name != "flash_warn" {
+				t.Errorf("expected only a flash_warn cookie to be present, got %v", r.cookies)
+			}
+		}
+	})
+
+	t.Run("renders a qr code for an active secret", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		r := get(t, app.handleManageSecretQRCode, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+
+		if r.statusCode != 200 {
+			t.Errorf("expected 200 status code, got %v", r.statusCode)
+		} else if r.headers.Get("Content-Type") != "image/png" {
+			t.Errorf("expected png content type, got %v", r.headers.Get("Content-Type"))
+		}
+	})
+
+	t.Run("does not render a qr code for an unknown or deleted secret", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		for _, id := range []string{"unknown", managementID} {
+			r := get(t, app.handleManageSecretQRCode, func(r *http.Request) { r.SetPathValue("managementID", id) })
+
+			if r.statusCode != 404 {
+				t.Errorf("expected 404 status code for %v, got %v", id, r.statusCode)
+			}
+		}
+	})
 }
 
-func TestSecretAccess(t *testing.T) {
-	t.Run("redirects home if secret has been deleted", func(t *testing.T) {
-		accessID, _ := createSecret(t, time.Now(), deletionReasonUserDeleted)
+func TestSecretAccess(t *testing.T) {
+	t.Run("does not access a secret via its management id", func(t *testing.T) {
+		_, managementID := createSecret(t, time.Time{}, "")
+
+		for _, h := range []http.HandlerFunc{app.handleAccessSecretInterstitial, app.handleCreateSecretView} {
+			if r := get(t, h, func(r *http.Request) { r.SetPathValue("accessID", managementID) }); !responseIsRedirectTo(r, "/") {
+				t.Errorf("expected redirect to home page")
+			}
+		}
+	})
+
+	t.Run("redirects home if secret has been deleted", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Now(), deletionReasonUserDeleted)
+
+		r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+
+		if r.statusCode != 303 {
+			t.Errorf("expected 303 status code, got %v", r.statusCode)
+		} else if r.headers.Get("Location") != "/" {
+			t.Errorf("expected redirect to /, got %v", r.headers.Get("Location"))
+		} else if c := r.cookies[0]; c.Name != "flash_err" {
+			t.Errorf("expected flash_err cookie to be present")
+		}
+	})
+
+	t.Run("gives viewers the same message regardless of why the secret is unavailable", func(t *testing.T) {
+		viewedID, _ := createSecret(t, time.Now(), deletionReasonMaximumViewCountHit)
+		expiredID, _ := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec(
+			"UPDATE secrets SET ttl = 1, created_at = ? WHERE access_id = ?",
+			time.Now().Add(-2*time.Minute).UnixMilli(),
+			expiredID,
+		)
+		if err != nil {
+			t.Errorf("updating secret TTL: %v", err)
+		}
+
+		want := base64.StdEncoding.EncodeToString([]byte("Secret does not exist or has been deleted."))
+		for _, id := range []string{"unknown", viewedID, expiredID} {
+			r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", id) })
+
+			if !responseIsRedirectTo(r, "/") {
+				t.Errorf("expected redirect to home page for %v", id)
+			} else if c := r.cookies[0]; c.Name != "flash_err" || c.Value != want {
+				t.Errorf("expected uniform flash_err cookie for %v, got %v", id, c.Value)
+			}
+		}
+	})
+
+	t.Run("gives viewers the same message until the secret becomes viewable", func(t *testing.T) {
+		accessID, managementID := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec("UPDATE secrets SET not_before = ? WHERE access_id = ?", time.Now().Add(time.Minute).UnixMilli(), accessID)
+		if err != nil {
+			t.Errorf("updating secret: %v", err)
+		}
+
+		want := base64.StdEncoding.EncodeToString([]byte("Secret does not exist or has been deleted."))
+		for _, h := range []http.HandlerFunc{app.handleAccessSecretInterstitial, app.handleCreateSecretView} {
+			r := get(t, h, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+
+			if !responseIsRedirectTo(r, "/") {
+				t.Errorf("expected redirect to home page")
+			} else if c := r.cookies[0]; c.Name != "flash_err" || c.Value != want {
+				t.Errorf("expected uniform flash_err cookie, got %v", c.Value)
+			}
+		}
+
+		r := get(t, app.handleManageSecret, func(r *http.Request) { r.SetPathValue("managementID", managementID) })
+		if !strings.Contains(r.body, "Viewable from") {
+			t.Errorf("expected time the secret becomes viewable on the management page")
+		}
+	})
+
+	t.Run("tells viewers a one-time secret has already been viewed", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Now(), deletionReasonViewed)
+
+		r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+
+		if r.statusCode != 410 {
+			t.Errorf("expected 410 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "This secret was one-time and has already been viewed.") {
+			t.Errorf("expected already viewed message to be in body")
+		}
+	})
+
+	t.Run("compares supplied passphrases whether or not the secret exists", func(t *testing.T) {
+		comparisons := 0
+		app.comparePassphrase = func(hash []byte, passphrase []byte) error {
+			comparisons++
+			return bcrypt.CompareHashAndPassword(hash, passphrase)
+		}
+		defer func() { app.comparePassphrase = bcrypt.CompareHashAndPassword }()
+
+		existing, _ := createSecret(t, time.Time{}, "")
+		if _, err := testDB().Exec("UPDATE secrets SET maximum_views = 0 WHERE access_id = ?", existing); err != nil {
+			t.Fatalf("updating secret: %v", err)
+		}
+		unknown, _ := secureID(24)
+
+		for _, tt := range []struct {
+			accessID string
+			body     string
+			want     int
+		}{
+			{existing, "passphrase=guess", 1},
+			{unknown, "passphrase=guess", 1},
+			{existing, "", 0},
+			{unknown, "", 0},
+		} {
+			comparisons = 0
+			post(t, app.handleCreateSecretView, tt.body, func(r *http.Request) { r.SetPathValue("accessID", tt.accessID) })
+			if comparisons != tt.want {
+				t.Errorf("%v (%q): wanted %v comparisons, got %v", tt.accessID, tt.body, tt.want, comparisons)
+			}
+
+			comparisons = 0
+			get(t, app.handleAPIAccessSecret, func(r *http.Request) {
+				r.SetPathValue("accessID", tt.accessID)
+				if tt.body != "" {
+					r.Header.Set(passphraseHeader, "guess")
+				}
+			})
+			if comparisons != tt.want {
+				t.Errorf("%v (%q) via the api: wanted %v comparisons, got %v", tt.accessID, tt.body, tt.want, comparisons)
+			}
+		}
+	})
+
+	t.Run("viewing the interstitial does not consume a one-time secret", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec("UPDATE secrets SET burn_after_reading = 1 WHERE access_id = ?", accessID)
+		if err != nil {
+			t.Errorf("updating secret: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+			if r.statusCode != 200 {
+				t.Errorf("expected 200 status code, got %v", r.statusCode)
+			} else if !strings.Contains(r.body, "Open Secret") {
+				t.Errorf("expected 'Open Secret' in body")
+			}
+		}
+
+		var deletedAt sql.NullInt64
+		var views int
+
+		err = testDB().QueryRow(
+			"SELECT deleted_at, (SELECT COUNT(1) FROM secret_views v WHERE v.secret_id = s.id) FROM secrets s WHERE access_id = ?",
+			accessID,
+		).Scan(&deletedAt, &views)
+		if err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if deletedAt.Valid || views != 0 {
+			t.Errorf("expected secret to be untouched, got deleted_at %v and %v views", deletedAt, views)
+		}
+	})
+
+	t.Run("requires the passphrase of a passphrase protected secret", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&encryptedSecret=YWJj.ZGVm.Z2hp&maxViews=1&passphrase=open+sesame", emptyRequestConfigurer)
+		managementID := strings.ReplaceAll(r.headers.Get("Location"), "/manage-secret/", "")
+
+		var accessID string
+		var passphraseHash sql.NullString
+
+		err := testDB().
+			QueryRow("SELECT access_id, passphrase_hash FROM secrets WHERE management_id = ?", managementID).
+			Scan(&accessID, &passphraseHash)
+		if err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if !passphraseHash.Valid || passphraseHash.String == "open sesame" {
+			t.Errorf("expected passphrase to be stored as a hash")
+		}
+
+		r = get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if !strings.Contains(r.body, `name="passphrase"`) {
+			t.Errorf("expected passphrase input to be in body")
+		}
+
+		r = post(t, app.handleCreateSecretView, "passphrase=wrong", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if !responseIsRedirectTo(r, "/secret/"+accessID) {
+			t.Errorf("expected redirect back to interstitial, got %v", r.headers.Get("Location"))
+		} else if c := r.cookies[0]; c.Name != "flash_err" {
+			t.Errorf("expected flash_err cookie to be present")
+		}
+
+		r = post(t, app.handleCreateSecretView, "passphrase=open+sesame", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if !strings.HasPrefix(r.headers.Get("Location"), fmt.Sprintf("/secret/%v/", accessID)) {
+			t.Errorf("expected redirect to start with /secret/%v/, got %v", accessID, r.headers.Get("Location"))
+		}
+	})
+
+	t.Run("redirects home if secret has expired", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec(
+			"UPDATE secrets SET ttl = 1, created_at = ? WHERE access_id = ?",
+			time.Now().Add(-2*time.Minute).UnixMilli(),
+			accessID,
+		)
+		if err != nil {
+			t.Errorf("updating secret TTL: %v", err)
+		}
 
 		r := get(t, app.handleAccessSecretInterstitial, func(r *http.Request) { r.SetPathValue("accessID", accessID) })
 
-		if r.statusCode != 303 {
-			t.Errorf("expected 303 status code, got %v", r.statusCode)
-		} else if r.headers.Get("Location") != "/" {
-			t.Errorf("expected redirect to /, got %v", r.headers.Get("Location"))
+		if !responseIsRedirectTo(r, "/") {
+			t.Errorf("expected redirect to home page")
 		} else if c := r.cookies[0]; c.Name != "flash_err" {
 			t.Errorf("expected flash_err cookie to be present")
 		}
 	})
 
+	t.Run("passes the reveal delay of the secret to the viewer", func(t *testing.T) {
+		accessID, _, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1, revealDelay: 5})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		r := post(t, app.handleCreateSecretView, "", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		r = get(t, app.handleAccessSecret, func(hr *http.Request) {
+			hr.SetPathValue("accessID", accessID)
+			hr.SetPathValue("viewingKey", (strings.Split(r.headers.Get("Location"), "/")[3]))
+		})
+		if r.statusCode != 200 {
+			t.Errorf("expected 200 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, `data-reveal-delay="5"`) {
+			t.Errorf("expected reveal delay to be rendered, got %v", r.body)
+		}
+	})
+
 	t.Run("marks secret as deleted if maximum views is reached", func(t *testing.T) {
 		accessID, _ := createSecret(t, time.Time{}, "")
 
@@ -157,7 +1105,7 @@ func TestSecretAccess(t *testing.T) {
 		var deletedAt sql.NullInt64
 		var deletionReason sql.NullString
 
-		err := app.db.db.
+		err := testDB().
 			QueryRow("SELECT deleted_at, deletion_reason FROM secrets WHERE access_id = ?", accessID).
 			Scan(&deletedAt, &deletionReason)
 
@@ -170,11 +1118,55 @@ func TestSecretAccess(t *testing.T) {
 		}
 	})
 
+	t.Run("burns secret after reading and only serves it once", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		_, err := testDB().Exec("UPDATE secrets SET maximum_views = 0, burn_after_reading = 1 WHERE access_id = ?", accessID)
+		if err != nil {
+			t.Errorf("updating secret: %v", err)
+		}
+
+		viewingKeys := []string{}
+		for i := 0; i < 2; i++ {
+			r := post(t, app.handleCreateSecretView, "", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+			viewingKeys = append(viewingKeys, strings.Split(r.headers.Get("Location"), "/")[3])
+		}
+
+		r := get(t, app.handleAccessSecret, func(hr *http.Request) {
+			hr.SetPathValue("accessID", accessID)
+			hr.SetPathValue("viewingKey", viewingKeys[0])
+		})
+		if r.statusCode != 200 {
+			t.Errorf("expected 200 status code, got %v", r.statusCode)
+		} else if !strings.Contains(r.body, "burned after reading") {
+			t.Errorf("expected 'burned after reading' in body")
+		} else if !strings.Contains(r.body, "it is now destroyed") {
+			t.Errorf("expected view receipt in body")
+		}
+
+		r = get(t, app.handleAccessSecret, func(hr *http.Request) {
+			hr.SetPathValue("accessID", accessID)
+			hr.SetPathValue("viewingKey", viewingKeys[1])
+		})
+		if !responseIsRedirectTo(r, "/") {
+			t.Errorf("expected second view to redirect to home page")
+		}
+
+		var deletionReason sql.NullString
+
+		err = testDB().QueryRow("SELECT deletion_reason FROM secrets WHERE access_id = ?", accessID).Scan(&deletionReason)
+		if err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if deletionReason.String != deletionReasonViewed {
+			t.Errorf("expected deletion reason to be viewed, got %v", deletionReason.String)
+		}
+	})
+
 	t.Run("returns error if secret viewing key has been used already", func(t *testing.T) {
 		accessID, _ := createSecret(t, time.Time{}, "")
 		viewingKey, _ := secureID(8)
 
-		_, err := app.db.db.Exec(
+		_, err := testDB().Exec(
 			`
 				INSERT INTO secret_views (secret_id, viewing_key, viewed_at, created_at)
 				SELECT id, ?, NULL, ?
@@ -200,6 +1192,476 @@ func TestSecretAccess(t *testing.T) {
 			t.Errorf("expected flash_err cookie to be present")
 		}
 	})
+
+	t.Run("serves the cipher text as json to clients that prefer it", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		r := post(t, app.handleCreateSecretView, "", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		viewingKey := strings.TrimPrefix(r.headers.Get("Location"), "/secret/"+accessID+"/")
+
+		view := func() consumedResponse {
+			return get(t, app.handleAccessSecret, func(hr *http.Request) {
+				hr.SetPathValue("accessID", accessID)
+				hr.SetPathValue("viewingKey", viewingKey)
+				hr.Header.Set("Accept", "application/json")
+			})
+		}
+
+		r = view()
+		if r.statusCode != 200 || r.headers.Get("Content-Type") != "application/json" {
+			t.Fatalf("wanted 200 json response, got %v %v", r.statusCode, r.headers.Get("Content-Type"))
+		}
+
+		var res apiAccessSecretResponse
+		if err := json.Unmarshal([]byte(r.body), &res); err != nil {
+			t.Fatalf("unmarshalling response: %v", err)
+		} else if res.CipherText != "a.b.c" {
+			t.Errorf("wanted cipher text a.b.c, got %v", res.CipherText)
+		}
+
+		// the secret had a maximum of one view, so the same view cannot be used again in either representation
+		if r = view(); r.statusCode != 404 || !strings.Contains(r.body, `"error"`) {
+			t.Errorf("wanted 404 json response for used view, got %v %v", r.statusCode, r.body)
+		}
+	})
+
+	t.Run("serves the decryption page to browsers", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+		if _, err := testDB().Exec("UPDATE secrets SET maximum_views = 2 WHERE access_id = ?", accessID); err != nil {
+			t.Fatalf("updating secret: %v", err)
+		}
+
+		r := post(t, app.handleCreateSecretView, "", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		viewingKey := strings.TrimPrefix(r.headers.Get("Location"), "/secret/"+accessID+"/")
+
+		r = get(t, app.handleAccessSecret, func(hr *http.Request) {
+			hr.SetPathValue("accessID", accessID)
+			hr.SetPathValue("viewingKey", viewingKey)
+			hr.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		})
+		if r.statusCode != 200 || !strings.Contains(r.body, "<html") {
+			t.Errorf("wanted 200 html response, got %v", r.statusCode)
+		} else if r.headers.Get("Vary") != "Accept" {
+			t.Errorf("wanted Vary header of Accept, got %q", r.headers.Get("Vary"))
+		} else if strings.Contains(r.body, "it is now destroyed") {
+			t.Errorf("wanted no view receipt for a secret that can still be viewed")
+		}
+	})
+
+	t.Run("deletes a secret once the maximum decryption failures have been reported", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		for i := 0; i < app.config.Secrets.MaximumDecryptionFailures; i++ {
+			r := post(t, app.handleReportDecryptionFailure, "", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+			if r.statusCode != 204 {
+				t.Errorf("wanted 204 status code, got %v", r.statusCode)
+			}
+		}
+
+		var deletionReason sql.NullString
+
+		err := testDB().QueryRow("SELECT deletion_reason FROM secrets WHERE access_id = ?", accessID).Scan(&deletionReason)
+		if err != nil {
+			t.Errorf("querying secret: %v", err)
+		} else if deletionReason.String != deletionReasonDecryptionFailures {
+			t.Errorf("wanted secret to be deleted due to decryption failures, got %v", deletionReason.String)
+		}
+
+		r := post(t, app.handleReportDecryptionFailure, "", func(r *http.Request) { r.SetPathValue("accessID", accessID) })
+		if r.statusCode != 404 {
+			t.Errorf("wanted 404 status code once deleted, got %v", r.statusCode)
+		}
+	})
+}
+
+func TestSecretDownload(t *testing.T) {
+	t.Run("downloads the cipher text as a file and honours maximum views", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Time{}, "")
+
+		download := func() *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/secret/"+accessID+"/download", nil)
+			r.RemoteAddr = "127.0.0.1:1234"
+
+			app.router.ServeHTTP(recorder, r)
+
+			return recorder
+		}
+
+		r := download()
+		if r.Code != 200 {
+			t.Fatalf("wanted 200 status code, got %v", r.Code)
+		} else if r.Body.String() != "a.b.c" {
+			t.Errorf("wanted cipher text as the body, got %v", r.Body.String())
+		} else if d := r.Header().Get("Content-Disposition"); d != `attachment; filename="`+accessID+`.enc"` {
+			t.Errorf("wanted attachment content disposition, got %v", d)
+		} else if c := r.Header().Get("Cache-Control"); !strings.Contains(c, "no-store") {
+			t.Errorf("wanted download not to be stored, got %v", c)
+		}
+
+		if r := download(); r.Code != 404 {
+			t.Errorf("wanted 404 status code once maximum views reached, got %v", r.Code)
+		}
+	})
+
+	t.Run("requires the passphrase of a passphrase protected secret", func(t *testing.T) {
+		accessID, _, err := app.createSecret(context.Background(), newSecret{cipherText: "YWJj.ZGVm.Z2hp", ttl: 30, maxViews: 1, passphrase: "open sesame"})
+		if err != nil {
+			t.Fatalf("creating secret: %v", err)
+		}
+
+		r := get(t, app.handleDownloadSecret, func(r *http.Request) {
+			r.SetPathValue("accessID", accessID)
+			r.Header.Set(passphraseHeader, "wrong")
+		})
+		if r.statusCode != 403 {
+			t.Errorf("wanted 403 status code, got %v", r.statusCode)
+		}
+
+		r = get(t, app.handleDownloadSecret, func(r *http.Request) {
+			r.SetPathValue("accessID", accessID)
+			r.Header.Set(passphraseHeader, "open sesame")
+		})
+		if r.statusCode != 200 || r.body != "YWJj.ZGVm.Z2hp" {
+			t.Errorf("wanted cipher text, got %v: %v", r.statusCode, r.body)
+		}
+	})
+
+	t.Run("not found for deleted secret", func(t *testing.T) {
+		accessID, _ := createSecret(t, time.Now(), deletionReasonViewed)
+
+		if r := get(t, app.handleDownloadSecret, func(r *http.Request) { r.SetPathValue("accessID", accessID) }); r.statusCode != 404 {
+			t.Errorf("wanted 404 status code, got %v", r.statusCode)
+		}
+	})
+}
+
+func TestSecretBundles(t *testing.T) {
+	bundle := strings.Repeat("&encryptedSecret=YWJj.ZGVm.Z2hp", 2) + "&secretTitle=database&secretTitle=api+key"
+
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+
+		app.router.ServeHTTP(recorder, r)
+
+		return recorder
+	}
+
+	t.Run("creates, lists and deletes the secrets of a bundle", func(t *testing.T) {
+		r := post(t, app.handleCreateSecret, "ttl=30&maxViews=1"+bundle, emptyRequestConfigurer)
+		if r.statusCode != 303 || !strings.HasPrefix(r.headers.Get("Location"), "/manage-bundle/") {
+			t.Fatalf("wanted redirect to bundle management page, got %v: %v", r.statusCode, r.headers.Get("Location"))
+		}
+		managementID := strings.TrimPrefix(r.headers.Get("Location"), "/manage-bundle/")
+
+		b, err := app.store.GetBundleByManagementID(context.Background(), managementID)
+		if err != nil {
+			t.Fatalf("retrieving bundle: %v", err)
+		} else if len(b.secrets) != 2 || b.secrets[0].maximumViews != 1 || b.secrets[1].maximumViews != 1 {
+			t.Fatalf("wanted two secrets with the given options, got %+v", b.secrets)
+		}
+
+		view := serve("GET", "/bundle/"+b.accessID)
+		if view.Code != 200 {
+			t.Fatalf("wanted 200 status code, got %v", view.Code)
+		}
+		for _, want := range []string{"database", "api key", "/secret/" + b.secrets[0].accessID, "/secret/" + b.secrets[1].accessID} {
+			if !strings.Contains(view.Body.String(), want) {
+				t.Errorf("wanted bundle page to contain %v", want)
+			}
+		}
+
+		if _, err := app.store.GetByViewingID(context.Background(), b.secrets[0].accessID); err != nil {
+			t.Errorf("did not expect viewing the bundle to use a view of its secrets, got %v", err)
+		}
+
+		if r := serve("POST", "/manage-bundle/"+managementID+"/delete"); r.Code != 303 || r.Header().Get("Location") != "/manage-bundle/"+managementID {
+			t.Fatalf("wanted redirect to bundle management page, got %v: %v", r.Code, r.Header().Get("Location"))
+		}
+		for _, s := range b.secrets {
+			if _, err := app.store.GetByViewingID(context.Background(), s.accessID); !errors.Is(err, errSecretNotFound) {
+				t.Errorf("wanted secret of deleted bundle to be deleted, got %v", err)
+			}
+		}
+
+		manage := serve("GET", "/manage-bundle/"+managementID)
+		if manage.Code != 200 || strings.Count(manage.Body.String(), describeDeletionReason(deletionReasonUserDeleted)) != 2 {
+			t.Errorf("wanted both secrets to be described as deleted, got %v: %v", manage.Code, manage.Body.String())
+		}
+	})
+
+	t.Run("bad request for too many secrets", func(t *testing.T) {
+		body := "ttl=30&maxViews=1" + strings.Repeat("&encryptedSecret=YWJj.ZGVm.Z2hp", maximumBundleSize+1)
+		if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 {
+			t.Errorf("wanted 400 status code, got %v", r.statusCode)
+		}
+	})
+
+	t.Run("bad request for an invalid secret", func(t *testing.T) {
+		body := "ttl=30&maxViews=1&encryptedSecret=YWJj.ZGVm.Z2hp&encryptedSecret=abc"
+		if r := post(t, app.handleCreateSecret, body, emptyRequestConfigurer); r.statusCode != 400 || !strings.HasPrefix(r.body, "Secret 2: ") {
+			t.Errorf("wanted 400 status code naming the invalid secret, got %v: %v", r.statusCode, r.body)
+		}
+	})
+
+	t.Run("not found for unknown bundle", func(t *testing.T) {
+		if r := serve("GET", "/bundle/unknown"); r.Code != 303 {
+			t.Errorf("wanted redirect for unknown bundle, got %v", r.Code)
+		}
+		if r := serve("GET", "/manage-bundle/unknown"); r.Code != 303 || r.Header().Get("Location") != "/" {
+			t.Errorf("wanted redirect home for unknown bundle, got %v: %v", r.Code, r.Header().Get("Location"))
+		}
+	})
+}
+
+func TestRouting(t *testing.T) {
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", 200, "create a secret"},
+		{"/static/css/style.css", 200, ""},
+		{"/unknown", 404, "not found"},
+		{"/secret/a/b/c", 404, "not found"},
+	}
+
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", c.path, nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+
+		app.router.ServeHTTP(recorder, r)
+
+		if recorder.Code != c.status {
+			t.Errorf("%v: wanted %v status code, got %v", c.path, c.status, recorder.Code)
+		} else if !strings.Contains(recorder.Body.String(), c.body) {
+			t.Errorf("%v: wanted %q in body", c.path, c.body)
+		}
+	}
+}
+
+func TestFlashCookies(t *testing.T) {
+	t.Run("hardens flash cookies", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		setFlashErr("message", recorder, httptest.NewRequest("GET", "/", nil))
+
+		c := recorder.Result().Cookies()[0]
+		if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+			t.Errorf("wanted http only, same site lax cookie, got %v", c)
+		} else if c.Secure {
+			t.Errorf("did not expect secure cookie when not served over https")
+		}
+	})
+
+	t.Run("only sends flash cookies over https when served over https", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.AddCookie(&http.Cookie{Name: "flash_err", Value: base64.StdEncoding.EncodeToString([]byte("message"))})
+		r = r.WithContext(context.WithValue(r.Context(), clientContextKey{}, resolveClient(r, app.config.Server.TrustedProxies)))
+
+		recorder := httptest.NewRecorder()
+		setFlashSuccess("message", recorder, r)
+		if msg := flash("err", r, recorder); msg != "message" {
+			t.Errorf("wanted flash message, got %v", msg)
+		}
+
+		for _, c := range recorder.Result().Cookies() {
+			if !c.Secure {
+				t.Errorf("wanted secure %v cookie", c.Name)
+			}
+		}
+	})
+}
+
+func TestSafeRedirect(t *testing.T) {
+	t.Run("redirects to relative paths", func(t *testing.T) {
+		for _, target := range []string{"/", "/manage-secret/abc", "/secret/abc?ref=1#key", "/revoke"} {
+			recorder := httptest.NewRecorder()
+			safeRedirect(recorder, httptest.NewRequest("POST", "/", nil), target)
+
+			if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != target {
+				t.Errorf("wanted redirect to %v, got %v %v", target, recorder.Code, recorder.Header().Get("Location"))
+			}
+		}
+	})
+
+	t.Run("redirects to the home page instead of other origins", func(t *testing.T) {
+		for _, target := range []string{
+			"",
+			"https://evil.example",
+			"//evil.example",
+			"///evil.example",
+			"/\\evil.example",
+			"/\t/evil.example",
+			"javascript:alert(1)",
+			"manage-secret/abc",
+		} {
+			recorder := httptest.NewRecorder()
+			safeRedirect(recorder, httptest.NewRequest("POST", "/", nil), target)
+
+			if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/" {
+				t.Errorf("wanted redirect to home page for %q, got %v %v", target, recorder.Code, recorder.Header().Get("Location"))
+			}
+		}
+	})
+
+	t.Run("falls back to the home page under the base path", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), basePathContextKey{}, "/secrets"))
+
+		recorder := httptest.NewRecorder()
+		safeRedirect(recorder, r, "//evil.example")
+
+		if recorder.Header().Get("Location") != "/secrets/" {
+			t.Errorf("wanted redirect to home page under base path, got %v", recorder.Header().Get("Location"))
+		}
+	})
+}
+
+func TestIsExpired(t *testing.T) {
+	t.Run("zero ttl never expires", func(t *testing.T) {
+		if isExpired(time.Now().Add(-365*24*time.Hour).UnixMilli(), 0) {
+			t.Errorf("expected secret with 0 ttl not to be expired")
+		}
+	})
+
+	t.Run("expires once ttl has elapsed", func(t *testing.T) {
+		if !isExpired(time.Now().Add(-31*time.Minute).UnixMilli(), 30) {
+			t.Errorf("expected secret to be expired")
+		}
+	})
+
+	t.Run("does not expire before ttl has elapsed", func(t *testing.T) {
+		if isExpired(time.Now().Add(-29*time.Minute).UnixMilli(), 30) {
+			t.Errorf("expected secret not to be expired")
+		}
+	})
+}
+
+func TestParseTTLPreset(t *testing.T) {
+	t.Run("parses durations and days into minutes", func(t *testing.T) {
+		for preset, want := range map[string]int{"5m": 5, "1h": 60, "1h30m": 90, "1d": 1440, "7d": 10080} {
+			if got, err := parseTTLPreset(preset); err != nil || got != want {
+				t.Errorf("wanted %v minutes for %v, got %v (%v)", want, preset, got, err)
+			}
+		}
+	})
+
+	t.Run("rejects invalid presets", func(t *testing.T) {
+		for _, preset := range []string{"", "soon", "0m", "-1h", "30s", "90s", "xd", "0d", "-1d"} {
+			if _, err := parseTTLPreset(preset); err == nil {
+				t.Errorf("wanted error for %v", preset)
+			}
+		}
+	})
+}
+
+func TestTTLUntil(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("converts expiry times into ttls rounded up to the next minute", func(t *testing.T) {
+		cases := map[string]int{
+			"2024-05-01T17:00:00Z":      300,
+			"2024-05-01T12:00:01Z":      1,
+			"2024-05-01T13:30:00+01:00": 30,
+			"1714568400000":             60,
+		}
+
+		for expiresAt, want := range cases {
+			if got, err := ttlUntil(expiresAt, now); err != nil || got != want {
+				t.Errorf("ttlUntil(%v): wanted %v, got %v (%v)", expiresAt, want, got, err)
+			}
+		}
+	})
+
+	t.Run("rejects expiry times that have passed", func(t *testing.T) {
+		for _, expiresAt := range []string{"2024-05-01T12:00:00Z", "2024-04-30T12:00:00Z", "0"} {
+			if _, err := ttlUntil(expiresAt, now); !errors.Is(err, errExpiryInPast) {
+				t.Errorf("ttlUntil(%v): wanted errExpiryInPast, got %v", expiresAt, err)
+			}
+		}
+	})
+
+	t.Run("rejects malformed expiry times", func(t *testing.T) {
+		for _, expiresAt := range []string{"tomorrow", "2024-05-01", "1.5"} {
+			if _, err := ttlUntil(expiresAt, now); err == nil || errors.Is(err, errExpiryInPast) {
+				t.Errorf("ttlUntil(%v): wanted parse error, got %v", expiresAt, err)
+			}
+		}
+	})
+}
+
+func TestTTLFromNow(t *testing.T) {
+	now := time.Now()
+	secret := storedSecret{createdAt: now.Add(-90 * time.Second).UnixMilli(), ttl: 30}
+
+	t.Run("counts the ttl from when the secret was created", func(t *testing.T) {
+		if ttl, msg := app.ttlFromNow(secret, 30, now, defaultLanguage); ttl != 32 || msg != "" {
+			t.Errorf("expected ttl of 32 minutes, got %v %q", ttl, msg)
+		}
+	})
+
+	t.Run("limits the lifetime of the secret", func(t *testing.T) {
+		minimum, maximum, clamp := app.config.Secrets.MinimumTTL, app.config.Secrets.MaximumTTL, app.config.Secrets.ClampTTL
+		app.config.Secrets.MinimumTTL, app.config.Secrets.MaximumTTL = 10*time.Minute, time.Hour
+		defer func() {
+			app.config.Secrets.MinimumTTL, app.config.Secrets.MaximumTTL, app.config.Secrets.ClampTTL = minimum, maximum, clamp
+		}()
+
+		if _, msg := app.ttlFromNow(secret, 5, now, defaultLanguage); !strings.Contains(msg, "too short") {
+			t.Errorf("expected ttl to be too short, got %q", msg)
+		}
+		if _, msg := app.ttlFromNow(secret, 59, now, defaultLanguage); !strings.Contains(msg, "too long") {
+			t.Errorf("expected ttl to be too long, got %q", msg)
+		}
+
+		app.config.Secrets.ClampTTL = true
+		if ttl, _ := app.ttlFromNow(secret, 5, now, defaultLanguage); ttl != 12 {
+			t.Errorf("expected ttl to be clamped to the minimum from now, got %v", ttl)
+		}
+		if ttl, _ := app.ttlFromNow(secret, 59, now, defaultLanguage); ttl != 60 {
+			t.Errorf("expected ttl to be clamped to the maximum, got %v", ttl)
+		}
+	})
+}
+
+func TestCipherTextFormat(t *testing.T) {
+	t.Run("accepts padded base64 segments as produced by the front-end", func(t *testing.T) {
+		if format, ok := cipherTextFormat("YQ==.Yg+/.Yw=="); !ok || format != cipherFormatV1 {
+			t.Errorf("expected cipher text to be valid, got %v %v", format, ok)
+		}
+	})
+
+	t.Run("detects the version of versioned cipher texts", func(t *testing.T) {
+		for cipherText, version := range map[string]string{
+			"v1.YWJj.ZGVm.Z2hp":         cipherFormatV1,
+			"v2.AES-GCM.YWJj.ZGVm.Z2hp": cipherFormatV2,
+		} {
+			if format, ok := cipherTextFormat(cipherText); !ok || format != version {
+				t.Errorf("expected %v to be valid and of version %v, got %v %v", cipherText, version, format, ok)
+			}
+		}
+	})
+
+	t.Run("rejects the wrong number of segments for the version", func(t *testing.T) {
+		for _, cipherText := range []string{"YWJj.ZGVm", "YWJj.ZGVm.Z2hp.amts", "v1.YWJj.ZGVm", "v2.YWJj.ZGVm.Z2hp", "v2.AES-GCM.YWJj.ZGVm.Z2hp.amts"} {
+			if _, ok := cipherTextFormat(cipherText); ok {
+				t.Errorf("expected %v to be invalid", cipherText)
+			}
+		}
+	})
+
+	t.Run("rejects truncated segments and unknown algorithm identifiers", func(t *testing.T) {
+		for _, cipherText := range []string{"YWJj.ZGVm.Z2h", "v1.YWJj..Z2hp", "v2.AES GCM.YWJj.ZGVm.Z2hp", "v2..YWJj.ZGVm.Z2hp"} {
+			if _, ok := cipherTextFormat(cipherText); ok {
+				t.Errorf("expected %v to be invalid", cipherText)
+			}
+		}
+	})
 }
 
 // post calls the handler, constructing an appropriate request and body and returning a simplified, already-read
@@ -211,11 +1673,12 @@ func post(t *testing.T, endpoint http.HandlerFunc, body string, rc func(r *http.
 	if err != nil {
 		t.Error()
 	}
+	r.RemoteAddr = "127.0.0.1:1234"
 	r.Header.Add("X-Forwarded-For", "127.0.0.1")
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	rc(r)
 
-	endpoint.ServeHTTP(recorder, r)
+	app.clientIPs(endpoint).ServeHTTP(recorder, r)
 
 	b, err := io.ReadAll(recorder.Body)
 	if err != nil {
@@ -242,10 +1705,11 @@ func get(t *testing.T, endpoint http.HandlerFunc, rc func(r *http.Request)) cons
 	if err != nil {
 		t.Error()
 	}
+	r.RemoteAddr = "127.0.0.1:1234"
 	r.Header.Add("X-Forwarded-For", "127.0.0.1")
 	rc(r)
 
-	endpoint.ServeHTTP(recorder, r)
+	app.clientIPs(endpoint).ServeHTTP(recorder, r)
 
 	b, err := io.ReadAll(recorder.Body)
 	if err != nil {
@@ -268,7 +1732,51 @@ type consumedResponse struct {
 	cookies    []*http.Cookie
 }
 
+// countingReader counts the number of bytes read from the underlying reader
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+// Read reads from the underlying reader, counting the bytes read
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.read += n
+
+	return n, err
+}
+
+// infiniteReader is a reader that endlessly repeats its byte
+type infiniteReader byte
+
+// Read fills the buffer with the byte
+func (b infiniteReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+
+	return len(p), nil
+}
+
 // responseIsRedirectTo ascertains whether the given response is a HTTP redirect to the specified location
 func responseIsRedirectTo(r consumedResponse, to string) bool {
 	return r.statusCode == 303 && r.headers.Get("Location") == to
 }
+
+// collidingSecretStore is a [SecretStore] whose first calls to Create (up to the number of collisions) fail as if the
+// identifiers of the secret were already in use
+type collidingSecretStore struct {
+	SecretStore
+	collisions int
+	attempts   int
+}
+
+// Create records the attempt, colliding until the number of collisions has been reached
+func (s *collidingSecretStore) Create(ctx context.Context, secret storedSecret) error {
+	s.attempts++
+	if s.attempts <= s.collisions {
+		return errSecretIDCollision
+	}
+
+	return s.SecretStore.Create(ctx, secret)
+}
@@ -0,0 +1,149 @@
+package shareasecret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// webhookTimeout is the maximum amount of time a single webhook delivery can take
+const webhookTimeout = 10 * time.Second
+
+// maximumWebhookURLLength is the longest webhook URL a secret can be created with
+const maximumWebhookURLLength = 2048
+
+// errInternalAddress is returned when a webhook would be delivered to an internal network address
+var errInternalAddress = errors.New("webhooks cannot be delivered to internal addresses")
+
+// webhookViewedPayload is the JSON body delivered to a secret's webhook when it is viewed
+type webhookViewedPayload struct {
+	ViewingID string    `json:"viewingID"`
+	ViewedAt  time.Time `json:"viewedAt"`
+}
+
+// webhookNotifier asynchronously delivers notifications to the webhooks secrets were created with
+type webhookNotifier struct {
+	client     *http.Client
+	deliveries sync.WaitGroup
+}
+
+// newWebhookNotifier creates a [webhookNotifier] whose HTTP client refuses to connect to internal network addresses,
+// preventing webhooks from being used to make requests to services that aren't otherwise reachable (SSRF)
+func newWebhookNotifier() *webhookNotifier {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || isInternalIP(ip) {
+				return errInternalAddress
+			}
+
+			return nil
+		},
+	}
+
+	return &webhookNotifier{
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// notifyViewed delivers a notification that the secret with the given access ID has been viewed to the given webhook
+// in the background, logging (but otherwise ignoring) any failure to do so
+func (n *webhookNotifier) notifyViewed(webhook string, accessID string, viewedAt time.Time) {
+	n.deliveries.Add(1)
+
+	go func() {
+		defer n.deliveries.Done()
+
+		l := log.With().Str("access_id", accessID).Logger()
+
+		if err := n.deliver(webhook, webhookViewedPayload{ViewingID: accessID, ViewedAt: viewedAt.UTC()}); err != nil {
+			l.Err(err).Msg("delivering webhook")
+		}
+	}()
+}
+
+// deliver POSTs the given payload as JSON to the webhook
+func (n *webhookNotifier) deliver(webhook string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshalling payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unsuccessful status code: %v", res.StatusCode)
+	}
+
+	return nil
+}
+
+// wait blocks until all in-flight webhook deliveries have completed
+func (n *webhookNotifier) wait() {
+	n.deliveries.Wait()
+}
+
+// validWebhookURL identifies whether the given URL can be used as a webhook. Only absolute HTTP(S) URLs are permitted,
+// and those with a literal internal IP address are rejected up front rather than when they're delivered to.
+func validWebhookURL(webhook string) bool {
+	if len(webhook) > maximumWebhookURLLength {
+		return false
+	}
+
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && isInternalIP(ip) {
+		return false
+	}
+
+	return true
+}
+
+// isInternalIP identifies whether the IP address is a loopback, private, link local or otherwise non publicly routable
+// address
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast()
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"fmt"
 	"io/fs"
-	"net/http"
 	"os"
 
 	"github.com/lsymds/shareasecret/internal/shareasecret"
@@ -19,21 +20,36 @@ var embeddedWebAssets embed.FS
 var version string = "0.0.1"
 
 func main() {
-	log.Info().Str("version", version).Msg("starting shareasecret")
+	// the prune subcommand deletes expired secrets and purges deleted ones once before exiting, instead of serving
+	// requests, for operators to run from cron
+	args := os.Args[1:]
+	prune := len(args) > 0 && args[0] == "prune"
+	if prune {
+		args = args[1:]
+	}
 
 	config := &shareasecret.Configuration{}
 
-	err := config.PopulateFromEnv()
+	err := config.Populate(args)
 	if err != nil {
 		log.Error().Err(err).Msg("populating configuration")
+		os.Exit(1)
 	}
 
+	// everything from here on is logged as configured
+	closeLogs := shareasecret.ConfigureLogging(config)
+	defer closeLogs()
+
+	log.Info().Str("version", version).Msg("starting shareasecret")
+
 	webAssets, err := fs.Sub(embeddedWebAssets, "web")
 	if err != nil {
 		log.Error().Err(err).Msg("reading embedded web/ subdir")
 		os.Exit(1)
 	}
 
+	shareasecret.Version = version
+
 	// initialize the wrapper application
 	application, err := shareasecret.NewApplication(config, webAssets)
 	if err != nil {
@@ -41,14 +57,22 @@ func main() {
 		os.Exit(1)
 	}
 
-	// run any jobs
-	application.RunDeleteExpiredSecretsJob()
+	if prune {
+		result, err := application.Prune(context.Background())
+		if err != nil {
+			log.Error().Err(err).Msg("pruning database")
+			os.Exit(1)
+		}
 
-	// serve all HTTP endpoints
-	log.Info().Str("addr", config.Server.ListeningAddr).Msg("booting HTTP server")
-	err = http.ListenAndServe(config.Server.ListeningAddr, application)
-	if err != nil {
-		log.Error().Err(err).Msg("listen and serve")
+		fmt.Printf("deleted %d expired secret(s) and purged %d deleted secret(s)\n", result.Expired, result.Purged)
+		return
+	}
+
+	// run the application until it is asked to stop
+	if err := application.Run(context.Background()); err != nil {
+		log.Error().Err(err).Msg("running application")
 		os.Exit(1)
 	}
+
+	log.Info().Msg("stopped shareasecret")
 }
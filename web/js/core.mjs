@@ -32,8 +32,17 @@ export async function decrypt(cipherText, password) {
 		return;
 	}
 
+	// cipher texts are optionally versioned, with v2 identifying the algorithm they were encrypted with
 	const encryptionComponents = cipherText.split(".");
-	if (encryptionComponents.length !== 3) {
+	let algorithm = "AES-GCM";
+	if (encryptionComponents[0] === "v1") {
+		encryptionComponents.shift();
+	} else if (encryptionComponents[0] === "v2") {
+		encryptionComponents.shift();
+		algorithm = encryptionComponents.shift();
+	}
+
+	if (encryptionComponents.length !== 3 || algorithm !== "AES-GCM") {
 		return;
 	}
 
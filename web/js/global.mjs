@@ -1,4 +1,20 @@
 document.addEventListener("DOMContentLoaded", function () {
+	// javascript is evidently available, so forget that it previously wasn't and show the page that requires it
+	if (document.cookie.split("; ").some((c) => c.startsWith("nojs="))) {
+		document.cookie = "nojs=; Path=/; Max-Age=0; SameSite=Lax";
+
+		if (document.getElementById("javascriptUnavailable")) {
+			window.location.reload();
+			return;
+		}
+	}
+
+	// remember the timezone of the browser so that times can be rendered in it by the server
+	const timezone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+	if (timezone) {
+		document.cookie = `tz=${timezone}; Path=/; Max-Age=31536000; SameSite=Lax`;
+	}
+
 	document.querySelectorAll(".j-button--copy").forEach(function (b) {
 		b.addEventListener("click", async function () {
 			const targetAttribute = b.attributes.getNamedItem("data-target");
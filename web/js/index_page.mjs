@@ -31,24 +31,76 @@ document.addEventListener("DOMContentLoaded", function () {
 
 			const requestData = new URLSearchParams();
 			requestData.append(
-				"ttl",
-				createSecretForm.querySelector("select[name=ttl]").value
+				"csrfToken",
+				createSecretForm.querySelector("input[name=csrfToken]").value
+			);
+			requestData.append(
+				"ttlPreset",
+				createSecretForm.querySelector("select[name=ttlPreset]").value
 			);
 			requestData.append("encryptedSecret", encryptedSecret);
 			requestData.append(
 				"maxViews",
 				createSecretForm.querySelector("input[name=maxViews]").value
 			);
+			requestData.append(
+				"revealDelay",
+				createSecretForm.querySelector("input[name=revealDelay]").value
+			);
+			// datetime-local inputs have no timezone, so the time is sent in UTC as understood by the browser
+			const notBefore = createSecretForm.querySelector(
+				"input[name=notBefore]"
+			).value;
+			if (notBefore) {
+				requestData.append("notBefore", new Date(notBefore).toISOString());
+			}
+			requestData.append(
+				"passphrase",
+				createSecretForm.querySelector("input[name=passphrase]").value
+			);
+			requestData.append(
+				"notifyWebhook",
+				createSecretForm.querySelector("input[name=notifyWebhook]").value
+			);
+			const notifyEmailInput = createSecretForm.querySelector(
+				"input[name=notifyEmail]"
+			);
+			if (notifyEmailInput) {
+				requestData.append("notifyEmail", notifyEmailInput.value);
+			}
+			requestData.append(
+				"label",
+				createSecretForm.querySelector("input[name=label]").value
+			);
+			requestData.append(
+				"recoveryToken",
+				createSecretForm.querySelector("input[name=recoveryToken]").value
+			);
+			requestData.append(
+				"burnAfterReading",
+				createSecretForm.querySelector("input[name=burnAfterReading]").checked
+			);
+			requestData.append(
+				"rememberSecret",
+				createSecretForm.querySelector("input[name=rememberSecret]").checked
+			);
+			requestData.append(
+				"trackAccess",
+				createSecretForm.querySelector("input[name=trackAccess]").checked
+			);
 
-			const response = await fetch("/secret", {
+			const response = await fetch(createSecretForm.dataset.createSecretUrl, {
 				method: "POST",
 				body: requestData,
 			});
 
-			if (response.status === 201) {
-				window.location.href = response.headers.get("Location");
+			// successfully created secrets redirect to their management page, which fetch follows
+			if (response.redirected) {
+				window.location.href = response.url;
 			} else if (response.status === 500) {
-				window.location.href = "/oops";
+				const reference = response.headers.get("X-Request-ID");
+				const oopsURL = createSecretForm.dataset.oopsUrl;
+				window.location.href = reference ? oopsURL + "?ref=" + reference : oopsURL;
 			} else {
 				showErrorNotification(createSecretForm, await response.text());
 			}
@@ -33,21 +33,73 @@ document.addEventListener("DOMContentLoaded", function () {
 				cipherTextInput.value,
 				passwordInput.value
 			);
+
+			submitButton.setAttribute("disabled", "true");
+			passwordInput.setAttribute("disabled", "true");
+
+			await countdownToReveal(
+				submitButton,
+				parseInt(decryptSecretForm.dataset.revealDelay, 10) || 0
+			);
+
 			decryptedCipherTextInput.value = decryptedCipherText;
 
 			decryptedCipherTextInput.removeAttribute("disabled");
 			decryptedCipherTextInput.focus();
-
-			submitButton.setAttribute("disabled", "true");
-			passwordInput.setAttribute("disabled", "true");
 		} catch (e) {
 			console.error(e);
 			showErrorNotification(
 				decryptSecretForm,
 				"Unable to decrypt secret. Have you entered the correct password?"
 			);
+			reportDecryptionFailure(decryptSecretForm);
 		} finally {
 			submitButton.removeAttribute("aria-busy");
 		}
 	});
 });
+
+/**
+ * Waits for the reveal delay chosen by the creator of the secret before it is shown, counting down the remaining
+ * seconds in the given button so that the viewer can make sure nobody is looking over their shoulder.
+ * @param {HTMLButtonElement} button The button to show the countdown in.
+ * @param {number} seconds The number of seconds to wait.
+ */
+async function countdownToReveal(button, seconds) {
+	if (seconds <= 0) {
+		return;
+	}
+
+	const originalText = button.textContent;
+	for (let remaining = seconds; remaining > 0; remaining--) {
+		button.textContent = "Revealing in " + remaining + "s";
+		await new Promise((resolve) => setTimeout(resolve, 1000));
+	}
+	button.textContent = originalText;
+}
+
+/**
+ * Reports a failed decryption attempt to the server, which deletes the secret once too many failures have been
+ * reported. Only the first failure of each view is reported so that simple typos don't exhaust the secret.
+ * @param {HTMLFormElement} form The decryption form containing the report URL and CSRF token.
+ */
+async function reportDecryptionFailure(form) {
+	const url = form.dataset.reportFailureUrl;
+	if (!url || form.dataset.reportedFailure) {
+		return;
+	}
+
+	form.dataset.reportedFailure = "true";
+
+	const requestData = new URLSearchParams();
+	requestData.append(
+		"csrfToken",
+		form.querySelector("input[name=csrfToken]").value
+	);
+
+	try {
+		await fetch(url, { method: "POST", body: requestData });
+	} catch (e) {
+		console.error(e);
+	}
+}